@@ -70,8 +70,16 @@ var (
 	r_byte       = reflect.TypeOf(byte(0))
 )
 
-// Unpack output in v according to the abi specification
+// Unpack output in v according to the abi specification. The name is either
+// that of a method, whose outputs are unpacked, or of an event, whose
+// non-indexed inputs are unpacked in to the fields of the struct v points to.
 func (abi ABI) Unpack(v interface{}, name string, output []byte) error {
+	if _, ok := abi.Methods[name]; !ok {
+		if event, ok := abi.Events[name]; ok {
+			return event.unpack(v, output)
+		}
+		return fmt.Errorf("abi: could not locate named method or event")
+	}
 	var method = abi.Methods[name]
 
 	if len(output) == 0 {
@@ -0,0 +1,226 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind_test
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// The contracts below ignore the method selector, so that all the methods of
+// their ABI share the same code.
+const (
+	// echoCode returns its call data without the selector, which is the
+	// encoding of the outputs if they have the same types as the inputs.
+	//
+	//   PUSH1 4 CALLDATASIZE SUB DUP1 PUSH1 4 PUSH1 0 CALLDATACOPY PUSH1 0 RETURN
+	echoCode = "600436038060046000376000f3"
+
+	// callerCode returns the full 200 bit address of its caller.
+	//
+	//   CALLER PUSH1 0 MSTORE PUSH1 32 PUSH1 0 RETURN
+	callerCode = "3360005260206000f3"
+
+	// forwardCode forwards the value it receives to the account in the first
+	// argument.
+	//
+	//   PUSH1 0 DUP1 DUP1 DUP1 CALLVALUE PUSH1 4 CALLDATALOAD GAS CALL POP STOP
+	forwardCode = "6000808080346004355af15000"
+)
+
+const echoABI = `[
+	{"type":"function","name":"echo","constant":true,"inputs":[{"name":"a","type":"address25"}],"outputs":[{"name":"","type":"address25"}]},
+	{"type":"function","name":"echo160","constant":true,"inputs":[{"name":"a","type":"address"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"echoSlice","constant":true,"inputs":[{"name":"a","type":"address25[]"}],"outputs":[{"name":"","type":"address25[]"}]},
+	{"type":"function","name":"echoFixed","constant":true,"inputs":[{"name":"a","type":"address25[2]"}],"outputs":[{"name":"","type":"address25[2]"}]},
+	{"type":"function","name":"echoMixed","constant":true,"inputs":[{"name":"a","type":"uint256"},{"name":"b","type":"address25"},{"name":"c","type":"address"}],"outputs":[{"name":"a","type":"uint256"},{"name":"b","type":"address25"},{"name":"c","type":"address"}]}
+]`
+
+const callerABI = `[
+	{"type":"function","name":"whoami","constant":true,"inputs":[],"outputs":[{"name":"","type":"address25"}]},
+	{"type":"function","name":"whoami160","constant":true,"inputs":[],"outputs":[{"name":"","type":"address"}]}
+]`
+
+const forwardABI = `[
+	{"type":"function","name":"forward","constant":false,"payable":true,"inputs":[{"name":"to","type":"address25"}],"outputs":[]}
+]`
+
+// address25Tester is a simulated chain with an account funded to deploy and
+// call the test contracts.
+type address25Tester struct {
+	sim  *backends.SimulatedBackend
+	auth *bind.TransactOpts
+}
+
+func newAddress25Tester(t *testing.T) *address25Tester {
+	key, _ := crypto.GenerateKey()
+	auth := bind.NewKeyedTransactor(key)
+
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From: {Balance: new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))},
+	})
+	return &address25Tester{sim: sim, auth: auth}
+}
+
+// deploy deploys the given runtime code with the given ABI, behind a
+// constructor returning the code.
+func (at *address25Tester) deploy(t *testing.T, abiJSON string, code string) *bind.BoundContract {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	runtime := common.FromHex(code)
+
+	//   PUSH1 len DUP1 PUSH1 11 PUSH1 0 CODECOPY PUSH1 0 RETURN
+	initcode := append([]byte{0x60, byte(len(runtime)), 0x80, 0x60, 0x0b, 0x60, 0x00, 0x39, 0x60, 0x00, 0xf3}, runtime...)
+
+	addr, _, contract, err := bind.DeployContract(at.auth, parsed, initcode, at.sim)
+	if err != nil {
+		t.Fatalf("failed to deploy contract: %v", err)
+	}
+	at.sim.Commit()
+
+	if deployed, err := at.sim.CodeAt(context.Background(), addr, nil); err != nil || len(deployed) == 0 {
+		t.Fatalf("contract not deployed: %v", err)
+	}
+	return contract
+}
+
+var (
+	// prefixedAddress is an account with a non-zero country/app prefix.
+	prefixedAddress = common.HexToAddress("0x000000009c0102030405060708090a0b0c0d0e0f1011121314")
+	// plainAddress is an account without prefix, as created by 160 bit code.
+	plainAddress = common.HexToAddress("0x00000000000102030405060708090a0b0c0d0e0f1011121314")
+)
+
+// Tests that address25 values round-trip through a contract unchanged, on their
+// own, in slices and arrays and next to other types.
+func TestAddress25RoundTrip(t *testing.T) {
+	at := newAddress25Tester(t)
+	echo := at.deploy(t, echoABI, echoCode)
+
+	var single common.Address
+	if err := echo.Call(nil, &single, "echo", prefixedAddress); err != nil {
+		t.Fatalf("echo failed: %v", err)
+	}
+	if single != prefixedAddress {
+		t.Errorf("echo mismatch: have %x, want %x", single, prefixedAddress)
+	}
+	slice := []common.Address{prefixedAddress, plainAddress, at.auth.From}
+	var slicedOut []common.Address
+	if err := echo.Call(nil, &slicedOut, "echoSlice", slice); err != nil {
+		t.Fatalf("echoSlice failed: %v", err)
+	}
+	if !reflect.DeepEqual(slicedOut, slice) {
+		t.Errorf("echoSlice mismatch: have %x, want %x", slicedOut, slice)
+	}
+	fixed := [2]common.Address{at.auth.From, prefixedAddress}
+	var fixedOut [2]common.Address
+	if err := echo.Call(nil, &fixedOut, "echoFixed", fixed); err != nil {
+		t.Fatalf("echoFixed failed: %v", err)
+	}
+	if fixedOut != fixed {
+		t.Errorf("echoFixed mismatch: have %x, want %x", fixedOut, fixed)
+	}
+	var mixed struct {
+		A *big.Int
+		B common.Address
+		C common.Address
+	}
+	if err := echo.Call(nil, &mixed, "echoMixed", big.NewInt(42), prefixedAddress, plainAddress); err != nil {
+		t.Fatalf("echoMixed failed: %v", err)
+	}
+	if mixed.A.Cmp(big.NewInt(42)) != 0 || mixed.B != prefixedAddress || mixed.C != plainAddress {
+		t.Errorf("echoMixed mismatch: have %v/%x/%x, want 42/%x/%x", mixed.A, mixed.B, mixed.C, prefixedAddress, plainAddress)
+	}
+}
+
+// Tests that the 160 bit address type round-trips addresses without prefix, and
+// refuses to pack addresses it would truncate.
+func TestAddress160RoundTrip(t *testing.T) {
+	at := newAddress25Tester(t)
+	echo := at.deploy(t, echoABI, echoCode)
+
+	var out common.Address
+	if err := echo.Call(nil, &out, "echo160", plainAddress); err != nil {
+		t.Fatalf("echo160 failed: %v", err)
+	}
+	if out != plainAddress {
+		t.Errorf("echo160 mismatch: have %x, want %x", out, plainAddress)
+	}
+	if err := echo.Call(nil, &out, "echo160", prefixedAddress); err == nil {
+		t.Errorf("prefixed address packed as 160 bit address")
+	}
+}
+
+// Tests that the addresses the EVM hands to contracts are 200 bits wide, and
+// are truncated only when read as 160 bit addresses.
+func TestAddress25Caller(t *testing.T) {
+	at := newAddress25Tester(t)
+	caller := at.deploy(t, callerABI, callerCode)
+
+	opts := &bind.CallOpts{From: at.auth.From}
+
+	var full common.Address
+	if err := caller.Call(opts, &full, "whoami"); err != nil {
+		t.Fatalf("whoami failed: %v", err)
+	}
+	if full != at.auth.From {
+		t.Errorf("caller mismatch: have %x, want %x", full, at.auth.From)
+	}
+	var truncated common.Address
+	if err := caller.Call(opts, &truncated, "whoami160"); err != nil {
+		t.Fatalf("whoami160 failed: %v", err)
+	}
+	want := common.BytesToAddress(at.auth.From[common.AddressLength-20:])
+	if truncated != want {
+		t.Errorf("160 bit caller mismatch: have %x, want %x", truncated, want)
+	}
+}
+
+// Tests that value sent by a contract to an address25 argument reaches the
+// account with the prefix, not the one with the prefix dropped.
+func TestAddress25Forward(t *testing.T) {
+	at := newAddress25Tester(t)
+	forward := at.deploy(t, forwardABI, forwardCode)
+
+	opts := *at.auth
+	opts.Value = big.NewInt(params.Ether)
+	if _, err := forward.Transact(&opts, "forward", prefixedAddress); err != nil {
+		t.Fatalf("forward failed: %v", err)
+	}
+	at.sim.Commit()
+
+	ctx := context.Background()
+	if balance, _ := at.sim.BalanceAt(ctx, prefixedAddress, nil); balance.Cmp(big.NewInt(params.Ether)) != 0 {
+		t.Errorf("recipient balance mismatch: have %v, want %v", balance, params.Ether)
+	}
+	if balance, _ := at.sim.BalanceAt(ctx, plainAddress, nil); balance.Sign() != 0 {
+		t.Errorf("value sent to the address without prefix: %v", balance)
+	}
+}
@@ -39,6 +39,10 @@ var (
 	// This error is returned by WaitDeployed if contract creation leaves an
 	// empty contract behind.
 	ErrNoCodeAfterDeploy = errors.New("no contract code after deployment")
+
+	// This error is returned when filtering or watching the events of a contract
+	// bound without a ContractFilterer.
+	ErrNoFilterer = errors.New("contract is bound without event filtering")
 )
 
 // ContractCaller defines the methods needed to allow operating with contract on a read
@@ -90,8 +94,21 @@ type ContractTransactor interface {
 	SendTransaction(ctx context.Context, tx *types.Transaction) error
 }
 
+// ContractFilterer defines the methods needed to access log events using one-off
+// queries or continuous event subscriptions.
+type ContractFilterer interface {
+	// FilterLogs executes a log filter operation, blocking during execution and
+	// returning all the results in one batch.
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+
+	// SubscribeFilterLogs creates a background log filtering operation, returning
+	// a subscription immediately, which can be used to stream the found events.
+	SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error)
+}
+
 // ContractBackend defines the methods needed to work with contracts on a read-write basis.
 type ContractBackend interface {
 	ContractCaller
 	ContractTransactor
+	ContractFilterer
 }
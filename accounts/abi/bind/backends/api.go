@@ -0,0 +1,204 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// This nil assignment ensures compile time that apiBackend implements ethapi.Backend.
+var _ ethapi.Backend = (*apiBackend)(nil)
+
+var errUnknownBlock = errors.New("unknown block")
+
+// RPCClient returns an in-process RPC client to the eth and ofbank APIs of the
+// simulated chain, so that code built on ethclient or plain RPC calls can be
+// tested against it. The pending block and state are served as the pending
+// ones of a node, and transactions sent through the client become part of it.
+func (b *SimulatedBackend) RPCClient() *rpc.Client {
+	b.rpcOnce.Do(func() {
+		backend := &apiBackend{sim: b, am: accounts.NewManager()}
+		backend.dl = downloader.New(downloader.FullSync, b.database, b.mux, b.blockchain, nil, func(string) {})
+
+		server := rpc.NewServer()
+		for _, api := range ethapi.GetAPIs(backend) {
+			if api.Namespace == "eth" || api.Namespace == "ofbank" {
+				if err := server.RegisterName(api.Namespace, api.Service); err != nil {
+					panic(err) // The APIs are static, failures are programming errors
+				}
+			}
+		}
+		if err := server.RegisterName("eth", filters.NewPublicFilterAPI(backend, false)); err != nil {
+			panic(err)
+		}
+		b.rpcClient = rpc.DialInProc(server)
+	})
+	return b.rpcClient
+}
+
+// apiBackend implements ethapi.Backend on top of the simulated chain, serving
+// the pending block of the simulator as the contents of the transaction pool.
+type apiBackend struct {
+	sim *SimulatedBackend
+	am  *accounts.Manager
+	dl  *downloader.Downloader
+}
+
+func (b *apiBackend) Downloader() *downloader.Downloader { return b.dl }
+func (b *apiBackend) ProtocolVersion() int               { return 0 } // The simulator speaks no wire protocol
+func (b *apiBackend) ChainDb() ethdb.Database            { return b.sim.database }
+func (b *apiBackend) EventMux() *event.TypeMux           { return b.sim.mux }
+func (b *apiBackend) AccountManager() *accounts.Manager  { return b.am }
+func (b *apiBackend) ChainConfig() *params.ChainConfig   { return b.sim.config }
+func (b *apiBackend) CurrentBlock() *types.Block         { return b.sim.blockchain.CurrentBlock() }
+
+func (b *apiBackend) SuggestPrice(ctx context.Context) (*big.Int, error) {
+	return b.sim.SuggestGasPrice(ctx)
+}
+
+func (b *apiBackend) SetHead(number uint64) {
+	b.sim.mu.Lock()
+	defer b.sim.mu.Unlock()
+
+	b.sim.blockchain.SetHead(number)
+	b.sim.rollback()
+}
+
+func (b *apiBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Header, error) {
+	block, err := b.BlockByNumber(ctx, blockNr)
+	if block == nil || err != nil {
+		return nil, err
+	}
+	return block.Header(), nil
+}
+
+func (b *apiBackend) BlockByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*types.Block, error) {
+	switch blockNr {
+	case rpc.PendingBlockNumber:
+		b.sim.mu.Lock()
+		defer b.sim.mu.Unlock()
+
+		return b.sim.pendingBlock, nil
+	case rpc.LatestBlockNumber:
+		return b.sim.blockchain.CurrentBlock(), nil
+	}
+	return b.sim.blockchain.GetBlockByNumber(uint64(blockNr)), nil
+}
+
+func (b *apiBackend) StateAndHeaderByNumber(ctx context.Context, blockNr rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	if blockNr == rpc.PendingBlockNumber {
+		b.sim.mu.Lock()
+		defer b.sim.mu.Unlock()
+
+		return b.sim.pendingState.Copy(), b.sim.pendingBlock.Header(), nil
+	}
+	header, err := b.HeaderByNumber(ctx, blockNr)
+	if header == nil || err != nil {
+		return nil, nil, err
+	}
+	statedb, err := b.sim.blockchain.StateAt(header.Root)
+	return statedb, header, err
+}
+
+func (b *apiBackend) GetBlock(ctx context.Context, blockHash common.Hash) (*types.Block, error) {
+	if block := b.sim.blockchain.GetBlockByHash(blockHash); block != nil {
+		return block, nil
+	}
+	return nil, errUnknownBlock
+}
+
+func (b *apiBackend) GetReceipts(ctx context.Context, blockHash common.Hash) (types.Receipts, error) {
+	return core.GetBlockReceipts(b.sim.database, blockHash, core.GetBlockNumber(b.sim.database, blockHash)), nil
+}
+
+func (b *apiBackend) GetTd(blockHash common.Hash) *big.Int {
+	return b.sim.blockchain.GetTdByHash(blockHash)
+}
+
+func (b *apiBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmCfg vm.Config) (*vm.EVM, func() error, error) {
+	state.SetBalance(msg.From(), math.MaxBig256.String())
+	vmError := func() error { return nil }
+
+	context := core.NewEVMContext(msg, header, b.sim.blockchain, nil)
+	return vm.NewEVM(context, state, b.sim.config, vmCfg), vmError, nil
+}
+
+func (b *apiBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
+	b.sim.mu.Lock()
+	defer b.sim.mu.Unlock()
+
+	return b.sim.sendTransaction(signedTx)
+}
+
+// RemoveTx is a noop, pending transactions can only be dropped all at once by
+// rolling back the simulator.
+func (b *apiBackend) RemoveTx(txHash common.Hash) {}
+
+func (b *apiBackend) GetPoolTransactions() (types.Transactions, error) {
+	b.sim.mu.Lock()
+	defer b.sim.mu.Unlock()
+
+	return b.sim.pendingBlock.Transactions(), nil
+}
+
+func (b *apiBackend) GetPoolTransaction(hash common.Hash) *types.Transaction {
+	b.sim.mu.Lock()
+	defer b.sim.mu.Unlock()
+
+	return b.sim.pendingBlock.Transaction(hash)
+}
+
+func (b *apiBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	return b.sim.PendingNonceAt(ctx, addr)
+}
+
+func (b *apiBackend) Stats() (pending int, queued int) {
+	b.sim.mu.Lock()
+	defer b.sim.mu.Unlock()
+
+	return len(b.sim.pendingBlock.Transactions()), 0
+}
+
+func (b *apiBackend) TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
+	b.sim.mu.Lock()
+	defer b.sim.mu.Unlock()
+
+	pending := make(map[common.Address]types.Transactions)
+	signer := types.MakeSigner(b.sim.config, b.sim.pendingBlock.Number())
+	for _, tx := range b.sim.pendingBlock.Transactions() {
+		from, _ := types.Sender(signer, tx)
+		pending[from] = append(pending[from], tx)
+	}
+	return pending, make(map[common.Address]types.Transactions)
+}
@@ -21,7 +21,9 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -32,9 +34,11 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/filters"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 // This nil assignment ensures compile time that SimulatedBackend implements bind.ContractBackend.
@@ -49,8 +53,19 @@ type SimulatedBackend struct {
 	blockchain *core.BlockChain // Ethereum blockchain to handle the consensus
 
 	mu           sync.Mutex
-	pendingBlock *types.Block   // Currently pending block that will be imported on request
-	pendingState *state.StateDB // Currently pending state that will be the active on on request
+	pendingBlock *types.Block                   // Currently pending block that will be imported on request
+	pendingState *state.StateDB                 // Currently pending state that will be the active on on request
+	pendingEdits []func(statedb *state.StateDB) // Ledger edits applied to the pending state before its transactions
+	pendingTime  int64                          // Seconds the pending block time is shifted by
+
+	ledger    *ledgerProcessor       // Block processor replaying the committed ledger edits
+	snapshots map[string]common.Hash // Named chain heads that can be rolled back to
+
+	mux    *event.TypeMux       // Event multiplexer the blockchain posts its events to
+	events *filters.EventSystem // Event system for filtering log events live
+
+	rpcOnce   sync.Once
+	rpcClient *rpc.Client // In-process client to the eth and ofbank APIs of the simulator
 
 	config *params.ChainConfig
 }
@@ -61,8 +76,20 @@ func NewSimulatedBackend(alloc core.GenesisAlloc) *SimulatedBackend {
 	database, _ := ethdb.NewMemDatabase()
 	genesis := core.Genesis{Config: params.AllProtocolChanges, Alloc: alloc}
 	genesis.MustCommit(database)
-	blockchain, _ := core.NewBlockChain(database, genesis.Config, ethash.NewFaker(), new(event.TypeMux), vm.Config{})
-	backend := &SimulatedBackend{database: database, blockchain: blockchain, config: genesis.Config}
+	mux := new(event.TypeMux)
+	blockchain, _ := core.NewBlockChain(database, genesis.Config, ethash.NewFaker(), mux, vm.Config{})
+	ledger := &ledgerProcessor{blockchain.Processor(), make(map[common.Hash][]func(statedb *state.StateDB))}
+	blockchain.SetProcessor(ledger)
+
+	backend := &SimulatedBackend{
+		database:   database,
+		blockchain: blockchain,
+		config:     genesis.Config,
+		ledger:     ledger,
+		snapshots:  make(map[string]common.Hash),
+		mux:        mux,
+		events:     filters.NewEventSystem(mux, &filterBackend{database, blockchain, mux}, false),
+	}
 	backend.rollback()
 	return backend
 }
@@ -73,6 +100,9 @@ func (b *SimulatedBackend) Commit() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if len(b.pendingEdits) > 0 {
+		b.ledger.edits[b.pendingBlock.Hash()] = b.pendingEdits
+	}
 	if _, err := b.blockchain.InsertChain([]*types.Block{b.pendingBlock}); err != nil {
 		panic(err) // This cannot happen unless the simulator is wrong, fail in that case
 	}
@@ -88,9 +118,142 @@ func (b *SimulatedBackend) Rollback() {
 }
 
 func (b *SimulatedBackend) rollback() {
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), b.database, 1, func(int, *core.BlockGen) {})
+	b.pendingEdits, b.pendingTime = nil, 0
+	if err := b.generate(nil); err != nil {
+		panic(err) // An empty block can only fail if the simulator is wrong
+	}
+}
+
+// generate rebuilds the pending block on top of the current head, applying the
+// pending ledger edits and time shift before the given transactions. Invalid
+// transactions are reported as errors, leaving the pending block untouched.
+func (b *SimulatedBackend) generate(txs []*types.Transaction) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), b.database, 1, func(number int, block *core.BlockGen) {
+		if b.pendingTime != 0 {
+			block.OffsetTime(b.pendingTime)
+		}
+		block.ModifyState(func(statedb *state.StateDB) {
+			for _, edit := range b.pendingEdits {
+				edit(statedb)
+			}
+		})
+		for _, tx := range txs {
+			block.AddTx(tx)
+		}
+	})
 	b.pendingBlock = blocks[0]
 	b.pendingState, _ = state.New(b.pendingBlock.Root(), state.NewDatabase(b.database))
+	return nil
+}
+
+// edit records a ledger edit of the pending state and rebuilds the pending
+// block with it.
+func (b *SimulatedBackend) edit(fn func(statedb *state.StateDB)) error {
+	b.pendingEdits = append(b.pendingEdits, fn)
+	if err := b.generate(b.pendingBlock.Transactions()); err != nil {
+		b.pendingEdits = b.pendingEdits[:len(b.pendingEdits)-1]
+		return err
+	}
+	return nil
+}
+
+// SetBalance sets the balance of an account in the pending state to a decimal
+// amount of coins, as stored by the ledger. The change becomes part of the
+// chain with the next Commit.
+func (b *SimulatedBackend) SetBalance(account common.Address, balance string) error {
+	if err := checkDecimal(balance); err != nil {
+		return fmt.Errorf("invalid balance: %v", err)
+	}
+	wei := math.ParseDecimal(balance, big.NewInt(params.Ether)).String()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.edit(func(statedb *state.StateDB) {
+		statedb.SetBalance(account, wei)
+	})
+}
+
+// SetCoinage sets the decimal coinage of an account in the pending state,
+// together with the number of the block it was last accrued at. The change
+// becomes part of the chain with the next Commit.
+func (b *SimulatedBackend) SetCoinage(account common.Address, coinage string, last uint64) error {
+	if err := checkDecimal(coinage); err != nil {
+		return fmt.Errorf("invalid coinage: %v", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.edit(func(statedb *state.StateDB) {
+		statedb.SetCoinage(account, coinage)
+		statedb.SetLast(account, strconv.FormatUint(last, 10))
+	})
+}
+
+// checkDecimal verifies that s is a non-negative decimal number, as the ledger
+// stores balances and coinage.
+func checkDecimal(s string) error {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return fmt.Errorf("malformed decimal %q", s)
+	}
+	if r.Sign() < 0 {
+		return fmt.Errorf("negative amount %q", s)
+	}
+	return nil
+}
+
+// AdjustTime shifts the time of the pending block, and thus of all blocks built
+// on top of it. The pending block must stay after its parent.
+func (b *SimulatedBackend) AdjustTime(adjustment time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	seconds := int64(adjustment / time.Second)
+	if new(big.Int).Add(b.pendingBlock.Time(), big.NewInt(seconds)).Cmp(b.blockchain.CurrentBlock().Time()) <= 0 {
+		return errors.New("time adjustment would move the pending block before its parent")
+	}
+	b.pendingTime += seconds
+	if err := b.generate(b.pendingBlock.Transactions()); err != nil {
+		b.pendingTime -= seconds
+		return err
+	}
+	return nil
+}
+
+// Snapshot records the current head of the chain under the given name, so that
+// the chain can later be rolled back to it. Pending changes are not recorded.
+func (b *SimulatedBackend) Snapshot(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.snapshots[name] = b.blockchain.CurrentBlock().Hash()
+}
+
+// RollbackTo rewinds the chain to the head recorded by the named snapshot,
+// dropping all blocks committed since as well as any pending changes.
+func (b *SimulatedBackend) RollbackTo(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hash, ok := b.snapshots[name]
+	if !ok {
+		return fmt.Errorf("unknown snapshot %q", name)
+	}
+	block := b.blockchain.GetBlockByHash(hash)
+	if block == nil || b.blockchain.GetBlockByNumber(block.NumberU64()).Hash() != hash {
+		return fmt.Errorf("snapshot %q is no longer part of the chain", name)
+	}
+	if err := b.blockchain.SetHead(block.NumberU64()); err != nil {
+		return err
+	}
+	b.rollback()
+	return nil
 }
 
 // CodeAt returns the code associated with a certain account in the blockchain.
@@ -114,7 +277,7 @@ func (b *SimulatedBackend) BalanceAt(ctx context.Context, contract common.Addres
 		return nil, errBlockNumberUnsupported
 	}
 	statedb, _ := b.blockchain.State()
-	return statedb.GetBalance(contract), nil
+	return math.ParseDecimal(statedb.GetBalance(contract), big.NewInt(params.Ether)), nil
 }
 
 // NonceAt returns the nonce of a certain account in the blockchain.
@@ -168,7 +331,7 @@ func (b *SimulatedBackend) CallContract(ctx context.Context, call ethereum.CallM
 	if err != nil {
 		return nil, err
 	}
-	rval, _, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), state)
+	rval, _, _, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), state)
 	return rval, err
 }
 
@@ -178,7 +341,7 @@ func (b *SimulatedBackend) PendingCallContract(ctx context.Context, call ethereu
 	defer b.mu.Unlock()
 	defer b.pendingState.RevertToSnapshot(b.pendingState.Snapshot())
 
-	rval, _, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
+	rval, _, _, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
 	return rval, err
 }
 
@@ -216,11 +379,12 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ethereum.CallMs
 		call.Gas = new(big.Int).SetUint64(mid)
 
 		snapshot := b.pendingState.Snapshot()
-		_, gas, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
+		_, _, failed, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
 		b.pendingState.RevertToSnapshot(snapshot)
 
-		// If the transaction became invalid or used all the gas (failed), raise the gas limit
-		if err != nil || gas.Cmp(call.Gas) == 0 {
+		// If the transaction became invalid or failed (ran out of gas or reverted),
+		// raise the gas limit
+		if err != nil || failed {
 			lo = mid
 			continue
 		}
@@ -232,7 +396,7 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ethereum.CallMs
 
 // callContract implemens common code between normal and pending contract calls.
 // state is modified during execution, make sure to copy it if necessary.
-func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallMsg, block *types.Block, statedb *state.StateDB) ([]byte, *big.Int, error) {
+func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallMsg, block *types.Block, statedb *state.StateDB) ([]byte, *big.Int, bool, error) {
 	// Ensure message is initialized properly.
 	if call.GasPrice == nil {
 		call.GasPrice = big.NewInt(1)
@@ -244,8 +408,7 @@ func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallM
 		call.Value = new(big.Int)
 	}
 	// Set infinite balance to the fake caller account.
-	from := statedb.GetOrNewStateObject(call.From)
-	from.SetBalance(math.MaxBig256)
+	statedb.SetBalance(call.From, math.MaxBig256.String())
 	// Execute the call.
 	msg := callmsg{call}
 
@@ -254,8 +417,8 @@ func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallM
 	// about the transaction and calling mechanisms.
 	vmenv := vm.NewEVM(evmContext, statedb, b.config, vm.Config{})
 	gaspool := new(core.GasPool).AddGas(math.MaxBig256)
-	ret, gasUsed, _, err := core.NewStateTransition(vmenv, msg, gaspool).TransitionDb()
-	return ret, gasUsed, err
+	ret, gasUsed, _, failed, err := core.NewStateTransition(vmenv, msg, gaspool).TransitionDb()
+	return ret, gasUsed, failed, err
 }
 
 // SendTransaction updates the pending block to include the given transaction.
@@ -264,24 +427,107 @@ func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transa
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	sender, err := types.Sender(types.HomesteadSigner{}, tx)
+	if err := b.sendTransaction(tx); err != nil {
+		panic(err)
+	}
+	return nil
+}
+
+// sendTransaction adds the transaction to the pending block, returning an error
+// if it is invalid.
+func (b *SimulatedBackend) sendTransaction(tx *types.Transaction) error {
+	sender, err := types.Sender(types.MakeSigner(b.config, b.pendingBlock.Number()), tx)
 	if err != nil {
-		panic(fmt.Errorf("invalid transaction: %v", err))
+		return fmt.Errorf("invalid transaction: %v", err)
 	}
 	nonce := b.pendingState.GetNonce(sender)
 	if tx.Nonce() != nonce {
-		panic(fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce))
+		return fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce)
 	}
+	txs := append(types.Transactions{}, b.pendingBlock.Transactions()...)
+	if err := b.generate(append(txs, tx)); err != nil {
+		return fmt.Errorf("invalid transaction: %v", err)
+	}
+	return nil
+}
 
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), b.database, 1, func(number int, block *core.BlockGen) {
-		for _, tx := range b.pendingBlock.Transactions() {
-			block.AddTx(tx)
+// FilterLogs executes a log filter operation, blocking during execution and
+// returning all the results in one batch.
+func (b *SimulatedBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	// Initialize unset filter boundaries to run from genesis to chain head
+	from := int64(0)
+	if query.FromBlock != nil {
+		from = query.FromBlock.Int64()
+	}
+	to := int64(-1)
+	if query.ToBlock != nil {
+		to = query.ToBlock.Int64()
+	}
+	// Construct and execute the filter
+	filter := filters.New(&filterBackend{b.database, b.blockchain, nil}, false)
+	filter.SetBeginBlock(from)
+	filter.SetEndBlock(to)
+	filter.SetAddresses(query.Addresses)
+	filter.SetTopics(query.Topics)
+
+	logs, err := filter.Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]types.Log, len(logs))
+	for i, log := range logs {
+		res[i] = *log
+	}
+	return res, nil
+}
+
+// SubscribeFilterLogs creates a background log filtering operation, returning a
+// subscription immediately, which can be used to stream the found events.
+func (b *SimulatedBackend) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	// Subscribe to contract events
+	sink := make(chan []*types.Log)
+
+	sub, err := b.events.SubscribeLogs(filters.FilterCriteria(query), sink)
+	if err != nil {
+		return nil, err
+	}
+	// Since we're getting logs in batches, we need to flatten them into a plain stream
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case logs := <-sink:
+				for _, log := range logs {
+					select {
+					case ch <- *log:
+					case err := <-sub.Err():
+						return err
+					case <-quit:
+						return nil
+					}
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
 		}
-		block.AddTx(tx)
-	})
-	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), state.NewDatabase(b.database))
-	return nil
+	}), nil
+}
+
+// ledgerProcessor is the block processor of the simulated chain. It replays the
+// ledger edits made through the simulator on the blocks they were committed
+// with, before processing the transactions of the blocks as usual.
+type ledgerProcessor struct {
+	core.Processor
+	edits map[common.Hash][]func(statedb *state.StateDB)
+}
+
+func (p *ledgerProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, *big.Int, error) {
+	for _, edit := range p.edits[block.Hash()] {
+		edit(statedb)
+	}
+	return p.Processor.Process(block, statedb, cfg)
 }
 
 // callmsg implements core.Message to allow passing it as a transaction simulator.
@@ -297,3 +543,25 @@ func (m callmsg) GasPrice() *big.Int   { return m.CallMsg.GasPrice }
 func (m callmsg) Gas() *big.Int        { return m.CallMsg.Gas }
 func (m callmsg) Value() *big.Int      { return m.CallMsg.Value }
 func (m callmsg) Data() []byte         { return m.CallMsg.Data }
+
+// filterBackend implements filters.Backend to support filtering for logs on the
+// simulated chain.
+type filterBackend struct {
+	db  ethdb.Database
+	bc  *core.BlockChain
+	mux *event.TypeMux
+}
+
+func (fb *filterBackend) ChainDb() ethdb.Database  { return fb.db }
+func (fb *filterBackend) EventMux() *event.TypeMux { return fb.mux }
+
+func (fb *filterBackend) HeaderByNumber(ctx context.Context, block rpc.BlockNumber) (*types.Header, error) {
+	if block == rpc.LatestBlockNumber {
+		return fb.bc.CurrentHeader(), nil
+	}
+	return fb.bc.GetHeaderByNumber(uint64(block.Int64())), nil
+}
+
+func (fb *filterBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return core.GetBlockReceipts(fb.db, hash, core.GetBlockNumber(fb.db, hash)), nil
+}
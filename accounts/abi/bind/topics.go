@@ -0,0 +1,200 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// makeTopics converts the filter query of the indexed inputs of an event into a
+// filter topic set. Each inner list holds the alternatives accepted at its topic
+// position, nil or empty lists match any topic.
+func makeTopics(inputs []abi.Argument, query [][]interface{}) ([][]common.Hash, error) {
+	if len(query) > len(inputs) {
+		return nil, fmt.Errorf("%d filter rules for %d indexed inputs", len(query), len(inputs))
+	}
+	topics := make([][]common.Hash, len(query))
+	for i, filter := range query {
+		for _, rule := range filter {
+			topic, err := makeTopic(inputs[i].Type, rule)
+			if err != nil {
+				return nil, fmt.Errorf("indexed input %d: %v", i, err)
+			}
+			topics[i] = append(topics[i], topic)
+		}
+	}
+	return topics, nil
+}
+
+// makeTopic encodes a single filter rule as a topic of the given ABI type.
+func makeTopic(kind abi.Type, rule interface{}) (common.Hash, error) {
+	var topic common.Hash
+
+	// Try to generate the topic based on simple types
+	switch rule := rule.(type) {
+	case common.Hash:
+		copy(topic[:], rule[:])
+	case common.Address:
+		// Addresses are logged in the width of their type, a prefixed address
+		// can never match a 160 bit one
+		if kind.T != abi.AddressTy {
+			return topic, fmt.Errorf("address rule for %v input", kind)
+		}
+		for _, b := range rule[:common.AddressLength-kind.Size] {
+			if b != 0 {
+				return topic, errAddressTruncated
+			}
+		}
+		copy(topic[common.HashLength-kind.Size:], rule[common.AddressLength-kind.Size:])
+	case *big.Int:
+		math.ReadBits(math.U256(new(big.Int).Set(rule)), topic[:])
+	case bool:
+		if rule {
+			topic[common.HashLength-1] = 1
+		}
+	case int8:
+		math.ReadBits(math.U256(big.NewInt(int64(rule))), topic[:])
+	case int16:
+		math.ReadBits(math.U256(big.NewInt(int64(rule))), topic[:])
+	case int32:
+		math.ReadBits(math.U256(big.NewInt(int64(rule))), topic[:])
+	case int64:
+		math.ReadBits(math.U256(big.NewInt(rule)), topic[:])
+	case uint8:
+		topic = common.BigToHash(new(big.Int).SetUint64(uint64(rule)))
+	case uint16:
+		topic = common.BigToHash(new(big.Int).SetUint64(uint64(rule)))
+	case uint32:
+		topic = common.BigToHash(new(big.Int).SetUint64(uint64(rule)))
+	case uint64:
+		topic = common.BigToHash(new(big.Int).SetUint64(rule))
+	case string:
+		// Indexed dynamic values are only logged as their hash
+		topic = crypto.Keccak256Hash([]byte(rule))
+	case []byte:
+		topic = crypto.Keccak256Hash(rule)
+
+	default:
+		// Attempt to generate the topic from fixed size byte arrays
+		val := reflect.ValueOf(rule)
+		if val.Kind() != reflect.Array || val.Type().Elem().Kind() != reflect.Uint8 || val.Len() > common.HashLength {
+			return topic, fmt.Errorf("unsupported indexed type: %T", rule)
+		}
+		reflect.Copy(reflect.ValueOf(topic[:val.Len()]), val)
+	}
+	return topic, nil
+}
+
+// parseTopics converts the indexed topic fields of a log into the fields of
+// the struct out points to. Indexed dynamic values are only logged as their
+// hash, so their fields must be of type common.Hash.
+func parseTopics(out interface{}, fields []abi.Argument, topics []common.Hash) error {
+	value := reflect.ValueOf(out)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot parse topics in to %T, need a struct pointer", out)
+	}
+	value = value.Elem()
+
+	for i, arg := range fields {
+		if !arg.Indexed {
+			continue
+		}
+		if len(topics) == 0 {
+			return errors.New("insufficient number of topics for the indexed inputs")
+		}
+		topic := topics[0]
+		topics = topics[1:]
+
+		field := value.FieldByName(abi.FieldName(arg.Name, i))
+		if !field.IsValid() {
+			return fmt.Errorf("no field for indexed input %d", i)
+		}
+		if err := setTopic(field, arg.Type, topic); err != nil {
+			return fmt.Errorf("indexed input %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// errAddressTruncated is returned if a prefixed address is used to filter for a
+// 160 bit Solidity address, which would drop its prefix.
+var errAddressTruncated = errors.New("address with prefix used as 160 bit address, use address25")
+
+var (
+	reflectHash    = reflect.TypeOf(common.Hash{})
+	reflectAddress = reflect.TypeOf(common.Address{})
+	reflectBigInt  = reflect.TypeOf(new(big.Int))
+)
+
+// setTopic decodes a topic of the given ABI type into the field.
+func setTopic(field reflect.Value, kind abi.Type, topic common.Hash) error {
+	switch {
+	// Fixed bytes are typed as arrays too, but logged as is instead of hashed
+	case kind.T == abi.HashTy || kind.T == abi.FixedBytesTy:
+		if field.Kind() != reflect.Array || field.Type().Elem().Kind() != reflect.Uint8 || field.Len() > common.HashLength {
+			return fmt.Errorf("fixed bytes need a byte array field, not %v", field.Type())
+		}
+		reflect.Copy(field, reflect.ValueOf(topic[:field.Len()]))
+
+	case kind.IsSlice || kind.IsArray || kind.T == abi.StringTy || kind.T == abi.BytesTy:
+		if field.Type() != reflectHash {
+			return fmt.Errorf("hashed value needs a common.Hash field, not %v", field.Type())
+		}
+		field.Set(reflect.ValueOf(topic))
+
+	case kind.T == abi.AddressTy:
+		if field.Type() != reflectAddress {
+			return fmt.Errorf("address needs a common.Address field, not %v", field.Type())
+		}
+		field.Set(reflect.ValueOf(common.BytesToAddress(topic[common.HashLength-kind.Size:])))
+
+	case kind.T == abi.BoolTy:
+		if field.Kind() != reflect.Bool {
+			return fmt.Errorf("bool needs a bool field, not %v", field.Type())
+		}
+		field.SetBool(topic[common.HashLength-1] == 1)
+
+	case kind.T == abi.IntTy || kind.T == abi.UintTy:
+		num := new(big.Int).SetBytes(topic[:])
+		if kind.T == abi.IntTy {
+			num = math.S256(num)
+		}
+		switch field.Kind() {
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			field.SetInt(num.Int64())
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			field.SetUint(num.Uint64())
+		default:
+			if field.Type() != reflectBigInt {
+				return fmt.Errorf("integer needs an integer field, not %v", field.Type())
+			}
+			field.Set(reflect.ValueOf(num))
+		}
+
+	default:
+		return fmt.Errorf("unsupported indexed type %v", kind)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bind_test
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+const emitterABI = `[
+	{"type":"function","name":"emit","constant":false,"inputs":[{"name":"who","type":"address25"},{"name":"amount","type":"uint256"},{"name":"tag","type":"bytes32"}],"outputs":[]},
+	{"type":"event","name":"Sent","anonymous":false,"inputs":[{"name":"who","type":"address25","indexed":true},{"name":"amount","type":"uint256","indexed":true},{"name":"tag","type":"bytes32","indexed":true}]}
+]`
+
+// emitterCode returns the code of a contract logging its three arguments as the
+// indexed inputs of the Sent event.
+//
+//   PUSH1 68 CALLDATALOAD PUSH1 36 CALLDATALOAD PUSH1 4 CALLDATALOAD PUSH32 id
+//   PUSH1 0 PUSH1 0 LOG4 STOP
+func emitterCode(t *testing.T) string {
+	parsed, err := abi.JSON(strings.NewReader(emitterABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	id := parsed.Events["Sent"].Id()
+	return "604435602435600435" + "7f" + hex.EncodeToString(id[:]) + "60006000a400"
+}
+
+// sentEvent is the decoded Sent event.
+type sentEvent struct {
+	Who    common.Address
+	Amount *big.Int
+	Tag    [32]byte
+}
+
+// collectLogs drains the logs of a finished filter subscription.
+func collectLogs(t *testing.T, logs chan types.Log, sub event.Subscription) []types.Log {
+	if err := <-sub.Err(); err != nil {
+		t.Fatalf("filtering failed: %v", err)
+	}
+	var found []types.Log
+	for {
+		select {
+		case log := <-logs:
+			found = append(found, log)
+		default:
+			return found
+		}
+	}
+}
+
+// Tests that the logs of an event can be filtered and watched by each of its
+// indexed address25, uint256 and bytes32 inputs.
+func TestIndexedEventFilter(t *testing.T) {
+	at := newAddress25Tester(t)
+	emitter := at.deploy(t, emitterABI, emitterCode(t))
+
+	tagA, tagB := [32]byte{0xaa}, [32]byte{0xbb}
+	sent := []sentEvent{
+		{prefixedAddress, big.NewInt(1), tagA},
+		{plainAddress, big.NewInt(2), tagB},
+		{prefixedAddress, big.NewInt(3), tagB},
+	}
+	// Watch for the events sent to the prefixed address before emitting them
+	watched, watch, err := emitter.WatchLogs(nil, "Sent", []interface{}{prefixedAddress})
+	if err != nil {
+		t.Fatalf("failed to watch logs: %v", err)
+	}
+	defer watch.Unsubscribe()
+
+	for _, ev := range sent {
+		if _, err := emitter.Transact(at.auth, "emit", ev.Who, ev.Amount, ev.Tag); err != nil {
+			t.Fatalf("emit failed: %v", err)
+		}
+		at.sim.Commit()
+	}
+	tests := []struct {
+		query [][]interface{}
+		want  []int
+	}{
+		{nil, []int{0, 1, 2}},
+		{[][]interface{}{{prefixedAddress}}, []int{0, 2}},
+		{[][]interface{}{{plainAddress}}, []int{1}},
+		{[][]interface{}{nil, {big.NewInt(2), big.NewInt(3)}}, []int{1, 2}},
+		{[][]interface{}{nil, nil, {tagB}}, []int{1, 2}},
+		{[][]interface{}{{prefixedAddress}, nil, {tagB}}, []int{2}},
+		{[][]interface{}{{plainAddress}, {big.NewInt(1)}}, nil},
+	}
+	for i, tt := range tests {
+		logs, sub, err := emitter.FilterLogs(nil, "Sent", tt.query...)
+		if err != nil {
+			t.Fatalf("test %d: failed to filter logs: %v", i, err)
+		}
+		found := collectLogs(t, logs, sub)
+		if len(found) != len(tt.want) {
+			t.Errorf("test %d: log count mismatch: have %d, want %d", i, len(found), len(tt.want))
+			continue
+		}
+		for j, log := range found {
+			var ev sentEvent
+			if err := emitter.UnpackLog(&ev, "Sent", log); err != nil {
+				t.Fatalf("test %d: failed to unpack log %d: %v", i, j, err)
+			}
+			want := sent[tt.want[j]]
+			if ev.Who != want.Who || ev.Amount.Cmp(want.Amount) != 0 || ev.Tag != want.Tag {
+				t.Errorf("test %d: log %d mismatch: have %+v, want %+v", i, j, ev, want)
+			}
+		}
+	}
+	// The watcher only received the events sent to the prefixed address
+	for _, want := range []sentEvent{sent[0], sent[2]} {
+		select {
+		case log := <-watched:
+			var ev sentEvent
+			if err := emitter.UnpackLog(&ev, "Sent", log); err != nil {
+				t.Fatalf("failed to unpack watched log: %v", err)
+			}
+			if ev.Who != want.Who || ev.Amount.Cmp(want.Amount) != 0 || ev.Tag != want.Tag {
+				t.Errorf("watched log mismatch: have %+v, want %+v", ev, want)
+			}
+		case err := <-watch.Err():
+			t.Fatalf("watch failed: %v", err)
+		case <-time.After(time.Second):
+			t.Fatalf("watched log not received")
+		}
+	}
+	select {
+	case log := <-watched:
+		t.Errorf("unexpected watched log: %+v", log)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// Tests that a prefixed address can't filter for a 160 bit indexed address,
+// which would silently match the address with the prefix dropped.
+func TestIndexedEventFilterTruncated(t *testing.T) {
+	const legacyABI = `[{"type":"event","name":"Sent","anonymous":false,"inputs":[{"name":"who","type":"address","indexed":true}]}]`
+
+	parsed, err := abi.JSON(strings.NewReader(legacyABI))
+	if err != nil {
+		t.Fatalf("failed to parse ABI: %v", err)
+	}
+	at := newAddress25Tester(t)
+	contract := bind.NewBoundContract(common.Address{}, parsed, at.sim, at.sim, at.sim)
+
+	if _, _, err := contract.FilterLogs(nil, "Sent", []interface{}{prefixedAddress}); err == nil {
+		t.Errorf("prefixed address accepted for 160 bit indexed input")
+	}
+	if _, _, err := contract.FilterLogs(nil, "Sent", []interface{}{plainAddress}); err != nil {
+		t.Errorf("unprefixed address rejected for 160 bit indexed input: %v", err)
+	}
+}
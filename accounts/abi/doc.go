@@ -23,4 +23,9 @@
 // as unsigned slice to signed slice. Bit size type casting is also
 // handled. ints with a bit size of 32 will be properly cast to int256,
 // etc.
+//
+// Next to the 160 bit Solidity address type, the ABI supports the address25
+// type holding a full ofbank address including its 5 byte country/app prefix.
+// Both map to common.Address; passing an address with a non-zero prefix as a
+// 160 bit address is rejected instead of being truncated by the contract.
 package abi
@@ -23,7 +23,8 @@ import (
 )
 
 var (
-	errBadBool = errors.New("abi: improperly encoded boolean value")
+	errBadBool          = errors.New("abi: improperly encoded boolean value")
+	errAddressTruncated = errors.New("abi: address with prefix used as 160 bit address, use address25")
 )
 
 // formatSliceString formats the reflection kind with the given slice size
@@ -18,6 +18,7 @@ package abi
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -33,6 +34,23 @@ type Event struct {
 	Inputs    []Argument
 }
 
+// String returns a readable representation of the event, in the style of its
+// Solidity declaration.
+func (e Event) String() string {
+	inputs := make([]string, len(e.Inputs))
+	for i, input := range e.Inputs {
+		inputs[i] = fmt.Sprintf("%v %v", input.Type, input.Name)
+		if input.Indexed {
+			inputs[i] = fmt.Sprintf("%v indexed %v", input.Type, input.Name)
+		}
+	}
+	anonymous := ""
+	if e.Anonymous {
+		anonymous = " anonymous"
+	}
+	return fmt.Sprintf("event %v(%v)%s", e.Name, strings.Join(inputs, ", "), anonymous)
+}
+
 // Id returns the canonical representation of the event's signature used by the
 // abi definition to identify event names and types.
 func (e Event) Id() common.Hash {
@@ -44,3 +62,44 @@ func (e Event) Id() common.Hash {
 	}
 	return common.BytesToHash(crypto.Keccak256([]byte(fmt.Sprintf("%v(%v)", e.Name, strings.Join(types, ",")))))
 }
+
+// unpack unpacks the non-indexed inputs of the event from the data of a log in
+// to the fields of the struct v points to. Fields are matched by the capitalised
+// name of the inputs, or ArgN for unnamed inputs at position N.
+func (e Event) unpack(v interface{}, output []byte) error {
+	valueOf := reflect.ValueOf(v)
+	if valueOf.Kind() != reflect.Ptr || valueOf.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("abi: cannot unpack event in to %T, need a struct pointer", v)
+	}
+	value := valueOf.Elem()
+
+	i := 0
+	for j, input := range e.Inputs {
+		if input.Indexed {
+			continue
+		}
+		marshalledValue, err := toGoType(i, input, output)
+		if err != nil {
+			return err
+		}
+		i++
+
+		field := value.FieldByName(FieldName(input.Name, j))
+		if !field.IsValid() {
+			return fmt.Errorf("abi: event %s has no field for input %d", e.Name, j)
+		}
+		if err := set(field, reflect.ValueOf(marshalledValue), input); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FieldName returns the name of the Go struct field holding the input of an
+// event at the given position: its name capitalised, or ArgN for unnamed ones.
+func FieldName(name string, position int) string {
+	if name == "" {
+		return fmt.Sprintf("Arg%d", position)
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
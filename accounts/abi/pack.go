@@ -16,6 +16,26 @@ func packBytesSlice(bytes []byte, l int) []byte {
 	return append(len, common.RightPadBytes(bytes, (l+31)/32*32)...)
 }
 
+// checkAddress checks that the given address fits the width of the address type
+// in t. Addresses with a non-zero country/app prefix are rejected as 160 bit
+// Solidity addresses, since the contract would silently drop their prefix and
+// thus reference a different account.
+func checkAddress(t Type, reflectValue reflect.Value) error {
+	if reflectValue.Kind() == reflect.Array {
+		reflectValue = mustArrayToByteSlice(reflectValue)
+	}
+	addr := reflectValue.Bytes()
+	if len(addr) <= t.Size {
+		return nil
+	}
+	for _, b := range addr[:len(addr)-t.Size] {
+		if b != 0 {
+			return errAddressTruncated
+		}
+	}
+	return nil
+}
+
 // packElement packs the given reflect value according to the abi specification in
 // t.
 func packElement(t Type, reflectValue reflect.Value) []byte {
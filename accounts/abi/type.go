@@ -6,6 +6,8 @@ import (
 	"reflect"
 	"regexp"
 	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
 )
 
 const (
@@ -50,6 +52,7 @@ var (
 	//      string     int       uint       fixed
 	//      string32   int8      uint8      uint[]
 	//      address    int256    uint256    fixed128x128[2]
+	//      address25
 	fullTypeRegex = regexp.MustCompile(`([a-zA-Z0-9]+)(\[([0-9]*)\])?`)
 	// typeRegex parses the abi sub types
 	typeRegex = regexp.MustCompile("([a-zA-Z]+)(([0-9]+)(x([0-9]+))?)?")
@@ -122,9 +125,18 @@ func NewType(t string) (typ Type, err error) {
 		typ.Kind = reflect.Bool
 		typ.T = BoolTy
 	case "address":
+		// address is the 160 bit Solidity address, address25 the full width
+		// ofbank address including the country/app prefix.
+		switch varSize {
+		case 0:
+			typ.Size = 20
+		case common.AddressLength:
+			typ.Size = common.AddressLength
+		default:
+			return Type{}, fmt.Errorf("unsupported arg type: %s", t)
+		}
 		typ.Kind = reflect.Array
 		typ.Type = address_t
-		typ.Size = 20
 		typ.T = AddressTy
 	case "string":
 		typ.Kind = reflect.String
@@ -184,7 +196,11 @@ func (t Type) pack(v reflect.Value) ([]byte, error) {
 			return packed, nil
 		}
 	}
-
+	if t.T == AddressTy {
+		if err := checkAddress(t, v); err != nil {
+			return nil, err
+		}
+	}
 	return packElement(t, v), nil
 }
 
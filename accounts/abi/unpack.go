@@ -123,7 +123,7 @@ func toGoSlice(i int, t Argument, output []byte) (interface{}, error) {
 				return nil, err
 			}
 		case AddressTy:
-			inter = common.BytesToAddress(returnOutput)
+			inter = readAddress(*elem, returnOutput)
 		case HashTy:
 			inter = common.BytesToHash(returnOutput)
 		case FixedBytesTy:
@@ -160,6 +160,12 @@ func readInteger(kind reflect.Kind, b []byte) interface{} {
 	}
 }
 
+// readAddress reads an address of the width of the address type in t from the
+// word, ignoring any bytes above it.
+func readAddress(t Type, word []byte) common.Address {
+	return common.BytesToAddress(word[len(word)-t.Size:])
+}
+
 func readBool(word []byte) (bool, error) {
 	if len(word) != 32 {
 		return false, fmt.Errorf("abi: fatal error: incorrect word length")
@@ -223,7 +229,7 @@ func toGoType(i int, t Argument, output []byte) (interface{}, error) {
 	case BoolTy:
 		return readBool(returnOutput)
 	case AddressTy:
-		return common.BytesToAddress(returnOutput), nil
+		return readAddress(t.Type, returnOutput), nil
 	case HashTy:
 		return common.BytesToHash(returnOutput), nil
 	case BytesTy, FixedBytesTy, FunctionTy:
@@ -72,6 +72,7 @@ var (
 		"COPYING",
 		executablePath("abigen"),
 		executablePath("bootnode"),
+		executablePath("diffsim"),
 		executablePath("evm"),
 		executablePath("geth"),
 		executablePath("puppeth"),
@@ -90,6 +91,10 @@ var (
 			Name:        "bootnode",
 			Description: "Ethereum bootnode.",
 		},
+		{
+			Name:        "diffsim",
+			Description: "Developer utility that replays block timestamps through a difficulty adjustment algorithm.",
+		},
 		{
 			Name:        "evm",
 			Description: "Developer utility version of the EVM (Ethereum Virtual Machine) that is capable of running bytecode snippets within a configurable environment and execution mode.",
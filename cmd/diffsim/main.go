@@ -0,0 +1,252 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// diffsim replays block timestamps through a difficulty adjustment algorithm.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	chaindata  = flag.String("chaindata", "", "replay the canonical headers of the given chain database")
+	from       = flag.Uint64("from", 0, "first block to replay from the chain database")
+	to         = flag.Uint64("to", 0, "last block to replay from the chain database (0 = head)")
+	algorithm  = flag.String("algorithm", params.DifficultyLWMA, "difficulty algorithm to simulate (fixed, lwma or stock)")
+	fork       = flag.Uint64("fork", 0, "number of replayed blocks to keep the stock rules for")
+	target     = flag.Uint64("target", 15, "target block time in seconds")
+	window     = flag.Uint64("window", 60, "number of solve times to average")
+	minimum    = flag.String("mindiff", params.MinimumDifficulty.String(), "difficulty never to drop under")
+	difficulty = flag.String("difficulty", params.MinimumDifficulty.String(), "difficulty of the first replayed block, if replaying timestamps")
+	width      = flag.Int("width", 50, "width of the difficulty curve")
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[-chaindata <path>] [-algorithm <name>] [-target <secs>] [-window <n>] [filename]")
+		flag.PrintDefaults()
+		fmt.Fprintln(os.Stderr, `
+Replays historical block timestamps through a difficulty adjustment algorithm
+and prints the resulting difficulty curve next to the original one.
+Timestamps are taken from the canonical headers of a chain database, or read
+from the given file, one unix timestamp per line. If the filename is omitted,
+they are read from stdin.`)
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	// Assemble the headers whose timestamps to replay
+	var (
+		headers []*types.Header
+		err     error
+	)
+	switch {
+	case *chaindata != "":
+		headers, err = readChain(*chaindata, *from, *to)
+
+	case flag.NArg() == 0:
+		headers, err = readTimes(os.Stdin)
+
+	case flag.NArg() == 1:
+		var fd *os.File
+		if fd, err = os.Open(flag.Arg(0)); err != nil {
+			die(err)
+		}
+		defer fd.Close()
+		headers, err = readTimes(fd)
+
+	default:
+		fmt.Fprintln(os.Stderr, "Error: too many arguments")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		die(err)
+	}
+	if len(headers) == 0 {
+		die("no timestamps to replay")
+	}
+	// Replay the timestamps on a simulated chain running the requested algorithm
+	config, err := makeConfig(headers[0].Number)
+	if err != nil {
+		die(err)
+	}
+	simulated := simulate(config, headers)
+
+	// Print the resulting difficulty curve, scaled to the largest difficulty
+	peak := new(big.Int)
+	for _, header := range simulated {
+		if header.Difficulty.Cmp(peak) > 0 {
+			peak.Set(header.Difficulty)
+		}
+	}
+	fmt.Printf("%-10s %-12s %-6s %-20s %-20s %s\n", "number", "timestamp", "solve", "original", "simulated", "curve")
+	for i, header := range simulated {
+		solve := "-"
+		if i > 0 {
+			solve = new(big.Int).Sub(header.Time, simulated[i-1].Time).String()
+		}
+		original := "-"
+		if *chaindata != "" {
+			original = headers[i].Difficulty.String()
+		}
+		bar := new(big.Int).Mul(header.Difficulty, big.NewInt(int64(*width)))
+		bar.Div(bar, peak)
+
+		fmt.Printf("%-10v %-12v %-6s %-20s %-20v %s\n", header.Number, header.Time, solve, original, header.Difficulty, strings.Repeat("#", int(bar.Int64())))
+	}
+}
+
+// makeConfig assembles the chain configuration of the simulation, switching to
+// the requested difficulty algorithm at the fork block.
+func makeConfig(first *big.Int) (*params.ChainConfig, error) {
+	config := *params.TestChainConfig
+	if *algorithm == "stock" {
+		return &config, nil
+	}
+	mindiff, ok := new(big.Int).SetString(*minimum, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid minimum difficulty: %s", *minimum)
+	}
+	config.Ethash = &params.EthashConfig{
+		Difficulty: &params.DifficultyConfig{
+			Block:             new(big.Int).Add(first, new(big.Int).SetUint64(*fork+1)),
+			Algorithm:         *algorithm,
+			TargetBlockTime:   *target,
+			Window:            *window,
+			MinimumDifficulty: mindiff,
+		},
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// simulate recalculates the difficulties of the headers, keeping only their
+// numbers and timestamps, and the difficulty of the first one.
+func simulate(config *params.ChainConfig, headers []*types.Header) []*types.Header {
+	chain := &chain{config: config, first: headers[0].Number.Uint64()}
+	for i, header := range headers {
+		sim := &types.Header{
+			Number: new(big.Int).Set(header.Number),
+			Time:   new(big.Int).Set(header.Time),
+		}
+		if i == 0 {
+			sim.Difficulty = new(big.Int).Set(header.Difficulty)
+		} else {
+			sim.Difficulty = ethash.CalcDifficultyChain(chain, sim.Time.Uint64(), chain.CurrentHeader())
+		}
+		chain.headers = append(chain.headers, sim)
+	}
+	return chain.headers
+}
+
+// readChain retrieves the canonical headers of a chain database in the given
+// range.
+func readChain(path string, from, to uint64) ([]*types.Header, error) {
+	db, err := ethdb.NewLDBDatabase(path, 16, 16)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var headers []*types.Header
+	for number := from; to == 0 || number <= to; number++ {
+		hash := core.GetCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			break
+		}
+		header := core.GetHeader(db, hash, number)
+		if header == nil {
+			return nil, fmt.Errorf("missing header #%d [%x…]", number, hash[:4])
+		}
+		headers = append(headers, header)
+	}
+	return headers, nil
+}
+
+// readTimes reads one unix timestamp per line, numbering the headers from zero.
+func readTimes(r io.Reader) ([]*types.Header, error) {
+	start, ok := new(big.Int).SetString(*difficulty, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid difficulty: %s", *difficulty)
+	}
+	var headers []*types.Header
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		time, err := strconv.ParseUint(line, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q: %v", line, err)
+		}
+		headers = append(headers, &types.Header{
+			Number: big.NewInt(int64(len(headers))),
+			Time:   new(big.Int).SetUint64(time),
+			Dahong: types.Dahong{Difficulty: start},
+		})
+	}
+	return headers, scanner.Err()
+}
+
+// chain is the simulated chain the difficulty adjustment looks up the ancestors
+// of a block in.
+type chain struct {
+	config  *params.ChainConfig
+	first   uint64          // Number of the first simulated header
+	headers []*types.Header // Simulated headers, consecutive from the first one
+}
+
+func (c *chain) Config() *params.ChainConfig               { return c.config }
+func (c *chain) CurrentHeader() *types.Header              { return c.headers[len(c.headers)-1] }
+func (c *chain) GetHeaderByHash(common.Hash) *types.Header { return nil }
+func (c *chain) GetBlock(common.Hash, uint64) *types.Block { return nil }
+
+func (c *chain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	return c.GetHeaderByNumber(number)
+}
+
+func (c *chain) GetHeaderByNumber(number uint64) *types.Header {
+	if number < c.first || number-c.first >= uint64(len(c.headers)) {
+		return nil
+	}
+	return c.headers[number-c.first]
+}
+
+func die(args ...interface{}) {
+	fmt.Fprintln(os.Stderr, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,110 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/tests"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var blockTestCommand = cli.Command{
+	Action:    blockTestCmd,
+	Name:      "blocktest",
+	Usage:     "executes the given blockchain tests",
+	ArgsUsage: "<file>",
+	Description: `The blocktest command imports the blocks of every blockchain test in the
+given JSON file into a fresh chain, and prints a JSON report of the results.`,
+}
+
+// BlocktestResult contains the status of a blockchain test once its blocks were
+// imported, and any error that might have occurred.
+type BlocktestResult struct {
+	Name  string `json:"name"`
+	Pass  bool   `json:"pass"`
+	Fork  string `json:"fork"`
+	Error string `json:"error,omitempty"`
+}
+
+func blockTestCmd(ctx *cli.Context) error {
+	if len(ctx.Args().First()) == 0 {
+		return errors.New("path-to-test argument required")
+	}
+	// Configure the go-ethereum logger
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.GlobalInt(VerbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	// Block imports are traced in machine readable format only, the struct
+	// logger would accumulate the steps of every transaction of the chain
+	var tracer vm.Tracer
+	if ctx.GlobalBool(MachineFlag.Name) {
+		tracer = NewJSONLogger(&vm.LogConfig{
+			DisableMemory: ctx.GlobalBool(DisableMemoryFlag.Name),
+			DisableStack:  ctx.GlobalBool(DisableStackFlag.Name),
+		}, os.Stderr)
+	}
+	// Load the test content from the input file
+	src, err := ioutil.ReadFile(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	var suite map[string]tests.BlockTest
+	if err = json.Unmarshal(src, &suite); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(suite))
+	for name := range suite {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Run all the tests and aggregate the results
+	cfg := vm.Config{
+		Tracer: tracer,
+		Debug:  tracer != nil,
+	}
+	var (
+		results []BlocktestResult
+		failed  int
+	)
+	for _, name := range names {
+		test := suite[name]
+
+		result := BlocktestResult{Name: name, Fork: test.Network(), Pass: true}
+		if err := test.Run(cfg); err != nil {
+			result.Pass, result.Error = false, err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+	out, _ := json.MarshalIndent(results, "", "  ")
+	fmt.Println(string(out))
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d blockchain tests failed", failed, len(results))
+	}
+	return nil
+}
@@ -16,6 +16,8 @@ var compileCommand = cli.Command{
 	Name:      "compile",
 	Usage:     "compiles easm source to evm binary",
 	ArgsUsage: "<file>",
+	Description: `The compile command assembles the given source file and prints the code in hex.
+See the documentation of the core/asm package for the syntax.`,
 }
 
 func compileCmd(ctx *cli.Context) error {
@@ -17,6 +17,7 @@
 package main
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -31,6 +32,8 @@ var disasmCommand = cli.Command{
 	Name:      "disasm",
 	Usage:     "disassembles evm binary",
 	ArgsUsage: "<file>",
+	Description: `The disasm command prints the assembler source of the hex encoded code in
+the given file. Compiling the source yields the very same code.`,
 }
 
 func disasmCmd(ctx *cli.Context) error {
@@ -44,7 +47,10 @@ func disasmCmd(ctx *cli.Context) error {
 		return err
 	}
 
-	code := strings.TrimSpace(string(in[:]))
-	fmt.Printf("%v\n", code)
-	return asm.PrintDisassembled(code)
+	code, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(in)), "0x"))
+	if err != nil {
+		return err
+	}
+	fmt.Print(asm.DisassembleSource(code))
+	return nil
 }
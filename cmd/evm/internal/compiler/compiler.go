@@ -16,7 +16,7 @@ func Compile(fn string, src []byte, debug bool) (string, error) {
 	if len(compileErrors) > 0 {
 		// report errors
 		for _, err := range compileErrors {
-			fmt.Printf("%s:%v\n", fn, err)
+			fmt.Println(err)
 		}
 		return "", errors.New("compiling failed")
 	}
@@ -50,10 +50,10 @@ var (
 		Usage: "price set for the evm",
 		Value: new(big.Int),
 	}
-	ValueFlag = utils.BigFlag{
+	ValueFlag = cli.StringFlag{
 		Name:  "value",
-		Usage: "value set for the evm",
-		Value: new(big.Int),
+		Usage: "value in coins transferred by the evm call",
+		Value: "0",
 	}
 	DumpFlag = cli.BoolFlag{
 		Name:  "dump",
@@ -123,6 +123,8 @@ func init() {
 		compileCommand,
 		disasmCommand,
 		runCommand,
+		stateTestCommand,
+		blockTestCommand,
 	}
 }
 
@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"runtime/pprof"
 	"time"
@@ -30,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/cmd/evm/internal/compiler"
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -107,6 +109,17 @@ func runCmd(ctx *cli.Context) error {
 
 	statedb.CreateAccount(sender)
 
+	// The value is given in coins, transfers move it in wei
+	valueFlag := ctx.GlobalString(ValueFlag.Name)
+	if r, ok := new(big.Rat).SetString(valueFlag); !ok || r.Sign() < 0 {
+		return fmt.Errorf("invalid value %q: want a non-negative decimal coin amount", valueFlag)
+	}
+	value := math.ParseDecimal(valueFlag, big.NewInt(params.Ether))
+	if ctx.GlobalString(GenesisFlag.Name) == "" && value.Sign() > 0 {
+		// Without a prestate, fund the sender so it can afford the transfer
+		statedb.AddBalance(sender, value.String())
+	}
+
 	var (
 		code []byte
 		ret  []byte
@@ -150,7 +163,7 @@ func runCmd(ctx *cli.Context) error {
 		State:    statedb,
 		GasLimit: initialGas,
 		GasPrice: utils.GlobalBig(ctx, PriceFlag.Name),
-		Value:    utils.GlobalBig(ctx, ValueFlag.Name),
+		Value:    value,
 		EVMConfig: vm.Config{
 			Tracer:             tracer,
 			Debug:              ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name),
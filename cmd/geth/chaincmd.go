@@ -19,6 +19,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"os"
 	"runtime"
 	"strconv"
@@ -65,6 +66,8 @@ It expects the genesis file as argument.`,
 			utils.DataDirFlag,
 			utils.CacheFlag,
 			utils.LightModeFlag,
+			utils.ArchiveFlag,
+			utils.VerifyOnlyFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -72,7 +75,15 @@ The import command imports blocks from an RLP-encoded form. The form can be one
 with several RLP-encoded blocks, or several files can be used.
 
 If only one file is used, import error will result in failure. If several files are used, 
-processing will proceed even if an individual RLP-file import failure occurs.`,
+processing will proceed even if an individual RLP-file import failure occurs.
+
+With --archive the arguments are chain archive directories created by export --archive.
+Every segment is checksummed and its headers, seals and body roots are verified in
+parallel before the blocks are imported in order. Segments already in the database
+are skipped, so an interrupted import can be resumed by running it again. The state
+is built by executing the imported blocks; a state snapshot in the archive is only
+verified by rebuilding it and comparing its root, it is never written to the database.
+With --verify-only the archive is checked without importing anything.`,
 	}
 	exportCommand = cli.Command{
 		Action:    utils.MigrateFlags(exportChain),
@@ -83,13 +94,22 @@ processing will proceed even if an individual RLP-file import failure occurs.`,
 			utils.DataDirFlag,
 			utils.CacheFlag,
 			utils.LightModeFlag,
+			utils.ArchiveFlag,
+			utils.ArchiveSegmentFlag,
+			utils.ArchiveStateFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
 Requires a first argument of the file to write to.
 Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
-if already existing.`,
+if already existing.
+
+With --archive the first argument is a directory, into which the blocks are
+written as gzipped segments of --archive.segment blocks each, described by a
+manifest holding the range, boundary hashes, state root and checksum of every
+segment. With --archive.state a snapshot of all accounts (balance, coinage and
+last coinage block included) at the last exported block is added as well.`,
 	}
 	removedbCommand = cli.Command{
 		Action:    utils.MigrateFlags(removeDB),
@@ -104,6 +124,87 @@ if already existing.`,
 		Description: `
 Remove blockchain and state databases`,
 	}
+	freezerCommand = cli.Command{
+		Name:     "freezer",
+		Usage:    "Manage the ancient chain data freezer",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Blocks older than the freezer threshold are moved out of the key-value store
+into flat, append-only files. A running node does this in the background, the
+commands below allow doing it offline and checking the result.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "migrate",
+				Usage:     "Move all ancient chain data into the freezer",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(migrateAncients),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.FreezerThresholdFlag,
+					utils.CacheFlag,
+					utils.NoCompactionFlag,
+				},
+				Description: `
+Moves every canonical block older than --freezer.threshold blocks from the
+key-value database into the ancient store and compacts the database afterwards.`,
+			},
+			{
+				Name:      "inspect",
+				Usage:     "Check the consistency of the ancient store",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(inspectAncients),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.CacheFlag,
+				},
+				Description: `
+Verifies that every frozen header matches its stored hash and is part of the
+canonical chain, and that the key-value store continues where the freezer ends.`,
+			},
+		},
+	}
+	stateCommand = cli.Command{
+		Name:     "state",
+		Usage:    "Export and import state snapshots",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+State snapshots allow bootstrapping a node from a trusted dump of the accounts at
+a given block instead of synchronising and executing the chain from genesis.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "export",
+				Usage:     "Export the state at a block into a snapshot file",
+				ArgsUsage: "<root|number|hash> <filename>",
+				Action:    utils.MigrateFlags(exportState),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+				},
+				Description: `
+Writes every account of the state (address, nonce, balance, coinage, last coinage
+block, code and storage) into a flat file, ordered by the hash of the address.
+The state is selected by block number, block hash or state root; the block it
+belongs to is stored in the snapshot too. Files ending in .gz are compressed.`,
+			},
+			{
+				Name:      "import",
+				Usage:     "Import a state snapshot and mark its block as chain head",
+				ArgsUsage: "<filename>",
+				Action:    utils.MigrateFlags(importState),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+				},
+				Description: `
+Rebuilds the state trie from a snapshot file and verifies that its root matches
+the snapshot. The block of the snapshot is then written as the head of the chain
+so the node continues syncing from that point. Blocks below the snapshot are not
+available locally.`,
+			},
+		},
+	}
 	dumpCommand = cli.Command{
 		Action:    utils.MigrateFlags(dump),
 		Name:      "dump",
@@ -181,7 +282,18 @@ func importChain(ctx *cli.Context) error {
 	// Import the chain
 	start := time.Now()
 
-	if len(ctx.Args()) == 1 {
+	if ctx.GlobalBool(utils.ArchiveFlag.Name) {
+		verify := ctx.GlobalBool(utils.VerifyOnlyFlag.Name)
+		for _, arg := range ctx.Args() {
+			if err := utils.ImportArchive(chain, arg, verify); err != nil {
+				utils.Fatalf("Archive import error: %v", err)
+			}
+		}
+		if verify {
+			fmt.Printf("Verification done in %v.\n", time.Since(start))
+			return nil
+		}
+	} else if len(ctx.Args()) == 1 {
 		if err := utils.ImportChain(chain, ctx.Args().First()); err != nil {
 			utils.Fatalf("Import error: %v", err)
 		}
@@ -196,7 +308,7 @@ func importChain(ctx *cli.Context) error {
 	fmt.Printf("Import done in %v.\n\n", time.Since(start))
 
 	// Output pre-compaction stats mostly to see the import trashing
-	db := chainDb.(*ethdb.LDBDatabase)
+	db := ethdb.KeyValueStore(chainDb).(*ethdb.LDBDatabase)
 
 	stats, err := db.LDB().GetProperty("leveldb.stats")
 	if err != nil {
@@ -246,7 +358,18 @@ func exportChain(ctx *cli.Context) error {
 
 	var err error
 	fp := ctx.Args().First()
-	if len(ctx.Args()) < 3 {
+	if ctx.GlobalBool(utils.ArchiveFlag.Name) {
+		first, last := uint64(0), chain.CurrentBlock().NumberU64()
+		if len(ctx.Args()) >= 3 {
+			var ferr, lerr error
+			first, ferr = strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+			last, lerr = strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+			if ferr != nil || lerr != nil {
+				utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+			}
+		}
+		err = utils.ExportArchive(chain, fp, first, last, ctx.GlobalUint64(utils.ArchiveSegmentFlag.Name), ctx.GlobalBool(utils.ArchiveStateFlag.Name))
+	} else if len(ctx.Args()) < 3 {
 		err = utils.ExportChain(chain, fp)
 	} else {
 		// This can be improved to allow for numbers larger than 9223372036854775807
@@ -297,6 +420,170 @@ func removeDB(ctx *cli.Context) error {
 	return nil
 }
 
+func migrateAncients(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	if _, ok := chainDb.(ethdb.AncientStore); !ok {
+		utils.Fatalf("Chain database has no ancient store attached")
+	}
+	start, total := time.Now(), 0
+	for {
+		frozen, err := chain.FreezeAncients()
+		if err != nil {
+			utils.Fatalf("Migration failed: %v", err)
+		}
+		if frozen == 0 {
+			break
+		}
+		total += frozen
+	}
+	chain.Stop()
+	fmt.Printf("Moved %d blocks into the ancient store in %v, %d frozen in total.\n", total, time.Since(start), chain.Ancients())
+
+	if total == 0 || ctx.GlobalIsSet(utils.NoCompactionFlag.Name) {
+		return nil
+	}
+	// Compact the key-value store to actually reclaim the space of the moved data
+	db, ok := ethdb.KeyValueStore(chainDb).(*ethdb.LDBDatabase)
+	if !ok {
+		return nil
+	}
+	start = time.Now()
+	fmt.Println("Compacting entire database...")
+	if err := db.LDB().CompactRange(util.Range{}); err != nil {
+		utils.Fatalf("Compaction failed: %v", err)
+	}
+	fmt.Printf("Compaction done in %v.\n", time.Since(start))
+	return nil
+}
+
+func inspectAncients(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+	defer chain.Stop()
+
+	ancients, ok := chainDb.(ethdb.AncientStore)
+	if !ok {
+		utils.Fatalf("Chain database has no ancient store attached")
+	}
+	frozen, err := ancients.Ancients()
+	if err != nil {
+		utils.Fatalf("Failed to retrieve ancient count: %v", err)
+	}
+	head := chain.CurrentHeader().Number.Uint64()
+	fmt.Printf("Ancient blocks: %d\n", frozen)
+	fmt.Printf("Chain head:     %d\n", head)
+
+	for number := uint64(0); number < frozen; number++ {
+		blob, err := ancients.Ancient(ethdb.FreezerHashTable, number)
+		if err != nil {
+			utils.Fatalf("Failed to read frozen hash #%d: %v", number, err)
+		}
+		hash := common.BytesToHash(blob)
+		if canon := core.GetCanonicalHash(chainDb, number); canon != hash {
+			utils.Fatalf("Frozen block #%d not canonical: have %x, want %x", number, hash, canon)
+		}
+		header := core.GetHeader(chainDb, hash, number)
+		if header == nil || header.Hash() != hash {
+			utils.Fatalf("Frozen header #%d corrupted", number)
+		}
+		if core.GetBody(chainDb, hash, number) == nil {
+			utils.Fatalf("Frozen body #%d missing", number)
+		}
+		if core.GetTd(chainDb, hash, number) == nil {
+			utils.Fatalf("Frozen total difficulty #%d missing", number)
+		}
+		if number > 0 && number%10000 == 0 {
+			log.Info("Verifying ancient store", "number", number, "frozen", frozen)
+		}
+	}
+	if frozen <= head {
+		hash := core.GetCanonicalHash(chainDb, frozen)
+		if core.GetHeader(chainDb, hash, frozen) == nil {
+			utils.Fatalf("Gap between the ancient store and the key-value store at #%d", frozen)
+		}
+	}
+	fmt.Println("Ancient store is consistent.")
+	return nil
+}
+
+func exportState(ctx *cli.Context) error {
+	if len(ctx.Args()) < 2 {
+		utils.Fatalf("This command requires two arguments.")
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+	defer chain.Stop()
+
+	// Resolve the requested state, which may be a block number, hash or state root
+	var (
+		arg   = ctx.Args().First()
+		root  common.Hash
+		block *types.Block
+	)
+	if !hashish(arg) {
+		num, _ := strconv.ParseUint(arg, 10, 64)
+		block = chain.GetBlockByNumber(num)
+	} else if block = chain.GetBlockByHash(common.HexToHash(arg)); block == nil {
+		root = common.HexToHash(arg)
+		for head := chain.CurrentBlock(); head != nil; head = chain.GetBlock(head.ParentHash(), head.NumberU64()-1) {
+			if head.Root() == root {
+				block = head
+				break
+			}
+			if head.NumberU64() == 0 {
+				break
+			}
+		}
+		if block == nil {
+			log.Warn("No canonical block found for state root", "root", root)
+		}
+	}
+	if block != nil {
+		root = block.Root()
+	} else if root == (common.Hash{}) {
+		utils.Fatalf("Block %s not found", arg)
+	}
+	statedb, err := chain.StateAt(root)
+	if err != nil {
+		utils.Fatalf("Could not open state %x: %v", root, err)
+	}
+	var td *big.Int
+	if block != nil {
+		td = chain.GetTd(block.Hash(), block.NumberU64())
+	}
+	start := time.Now()
+	if err := utils.ExportState(statedb, root, block, td, ctx.Args().Get(1)); err != nil {
+		utils.Fatalf("State export error: %v", err)
+	}
+	fmt.Printf("State export done in %v\n", time.Since(start))
+	return nil
+}
+
+func importState(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	// Make sure the genesis is in place before marking a new chain head
+	if _, _, err := core.SetupGenesisBlock(chainDb, utils.MakeGenesis(ctx)); err != nil {
+		utils.Fatalf("Failed to set up genesis block: %v", err)
+	}
+	start := time.Now()
+	if err := utils.ImportState(chainDb, ctx.Args().First()); err != nil {
+		utils.Fatalf("State import error: %v", err)
+	}
+	fmt.Printf("State import done in %v\n", time.Since(start))
+	return nil
+}
+
 func dump(ctx *cli.Context) error {
 	stack := makeFullNode(ctx)
 	chain, chainDb := utils.MakeChain(ctx, stack)
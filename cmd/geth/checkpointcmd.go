@@ -0,0 +1,267 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/les"
+	"github.com/ethereum/go-ethereum/light"
+	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	checkpointKeyFlag = cli.StringFlag{
+		Name:  "key",
+		Usage: "File containing the hex encoded private key of the checkpoint signer",
+	}
+	checkpointSectionFlag = cli.Uint64Flag{
+		Name:  "section",
+		Usage: "Section index of the checkpoint (0 = latest available)",
+	}
+
+	checkpointCommand = cli.Command{
+		Name:     "checkpoint",
+		Usage:    "Manage signed light client checkpoints",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Checkpoints commit to the canonical hash trie and the bloom trie of the chain up
+to the end of a section. Once signed by enough of the signers listed in the chain
+config, LES servers announce them during the handshake and light clients start
+syncing from the end of the checkpoint's section instead of from genesis.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "sign",
+				Usage:     "Sign the checkpoint of a section of the local chain",
+				ArgsUsage: "<filename>",
+				Action:    utils.MigrateFlags(signCheckpoint),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+					checkpointKeyFlag,
+					checkpointSectionFlag,
+				},
+				Description: `
+Generates the tries of the local chain if needed and signs the checkpoint of the
+requested section. If the file already holds a checkpoint, it must match the local
+one and the signature is added to those already collected; otherwise the file is
+created.`,
+			},
+			{
+				Name:      "combine",
+				Usage:     "Merge the signatures of several checkpoint files",
+				ArgsUsage: "<output> <filename>...",
+				Action:    utils.MigrateFlags(combineCheckpoints),
+				Description: `
+Collects the signatures of checkpoint files signed independently into a single
+file. All files must contain the same checkpoint.`,
+			},
+			{
+				Name:      "verify",
+				Usage:     "Verify the signatures of a checkpoint file",
+				ArgsUsage: "<filename>",
+				Action:    utils.MigrateFlags(verifyCheckpoint),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+				},
+				Description: `
+Checks the signatures against the checkpoint signers of the local chain config
+and, if the local chain covers the section, compares the checkpoint with the
+local tries.`,
+			},
+			{
+				Name:      "publish",
+				Usage:     "Store a signed checkpoint for announcing to light clients",
+				ArgsUsage: "<filename>",
+				Action:    utils.MigrateFlags(publishCheckpoint),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.CacheFlag,
+				},
+				Description: `
+Verifies the checkpoint and stores it in the chain database. A node running a LES
+server announces the stored checkpoint to every light client connecting to it.`,
+			},
+		},
+	}
+)
+
+// signCheckpoint signs the checkpoint of a section of the local chain.
+func signCheckpoint(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	if !ctx.IsSet(checkpointKeyFlag.Name) {
+		utils.Fatalf("The signing key must be given with --%s.", checkpointKeyFlag.Name)
+	}
+	key, err := crypto.LoadECDSA(ctx.String(checkpointKeyFlag.Name))
+	if err != nil {
+		utils.Fatalf("Failed to load signing key: %v", err)
+	}
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	if err := les.GenerateTries(chainDb); err != nil {
+		utils.Fatalf("Failed to generate tries: %v", err)
+	}
+
+	file := ctx.Args().First()
+	signed := new(light.SignedCheckpoint)
+	if _, err := os.Stat(file); err == nil {
+		signed = loadCheckpoint(file)
+	} else {
+		checkpoint, err := les.LocalCheckpoint(chainDb, ctx.Uint64(checkpointSectionFlag.Name))
+		if err != nil {
+			utils.Fatalf("Failed to assemble checkpoint: %v", err)
+		}
+		signed.Checkpoint = *checkpoint
+	}
+	local, err := les.LocalCheckpoint(chainDb, signed.Checkpoint.Section)
+	if err != nil {
+		utils.Fatalf("Failed to assemble checkpoint: %v", err)
+	}
+	if *local != signed.Checkpoint {
+		utils.Fatalf("Checkpoint in %s mismatches the local chain", file)
+	}
+	if err := signed.Sign(key); err != nil {
+		utils.Fatalf("Failed to sign checkpoint: %v", err)
+	}
+	saveCheckpoint(file, signed)
+
+	fmt.Printf("Signed checkpoint of section %d (%x), %d signatures collected\n", signed.Checkpoint.Section, signed.Checkpoint.Hash(), len(signed.Signatures))
+	return nil
+}
+
+// combineCheckpoints merges the signatures of several files of the same checkpoint.
+func combineCheckpoints(ctx *cli.Context) error {
+	if len(ctx.Args()) < 2 {
+		utils.Fatalf("This command requires at least two arguments.")
+	}
+	var signed *light.SignedCheckpoint
+	for _, file := range ctx.Args()[1:] {
+		checkpoint := loadCheckpoint(file)
+		if signed == nil {
+			signed = checkpoint
+			continue
+		}
+		if checkpoint.Checkpoint != signed.Checkpoint {
+			utils.Fatalf("Checkpoint in %s mismatches the previous files", file)
+		}
+		for _, sig := range checkpoint.Signatures {
+			if err := signed.AddSignature(sig); err != nil {
+				utils.Fatalf("Invalid signature in %s: %v", file, err)
+			}
+		}
+	}
+	saveCheckpoint(ctx.Args().First(), signed)
+
+	fmt.Printf("Combined checkpoint of section %d, %d signatures collected\n", signed.Checkpoint.Section, len(signed.Signatures))
+	return nil
+}
+
+// verifyCheckpoint checks a checkpoint file against the local chain config.
+func verifyCheckpoint(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	signed := loadCheckpoint(ctx.Args().First())
+
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	config := checkpointConfig(chainDb)
+	for _, signer := range signed.Signers(config) {
+		fmt.Printf("Signed by %x\n", signer)
+	}
+	if err := signed.Verify(config); err != nil {
+		utils.Fatalf("Checkpoint verification failed: %v", err)
+	}
+	if local, err := les.LocalCheckpoint(chainDb, signed.Checkpoint.Section); err == nil && *local != signed.Checkpoint {
+		utils.Fatalf("Checkpoint mismatches the local chain")
+	}
+	fmt.Printf("Checkpoint of section %d is valid\n", signed.Checkpoint.Section)
+	return nil
+}
+
+// publishCheckpoint stores a verified checkpoint for the LES server to announce.
+func publishCheckpoint(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	signed := loadCheckpoint(ctx.Args().First())
+
+	stack := makeFullNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	if local, err := les.LocalCheckpoint(chainDb, signed.Checkpoint.Section); err == nil && *local != signed.Checkpoint {
+		utils.Fatalf("Checkpoint mismatches the local chain")
+	}
+	stored, err := light.WriteTrustedCheckpoint(chainDb, checkpointConfig(chainDb), signed)
+	if err != nil {
+		utils.Fatalf("Checkpoint verification failed: %v", err)
+	}
+	if !stored {
+		utils.Fatalf("A checkpoint of section %d or newer is already stored", signed.Checkpoint.Section)
+	}
+	fmt.Printf("Published checkpoint of section %d\n", signed.Checkpoint.Section)
+	return nil
+}
+
+// checkpointConfig retrieves the checkpoint signers from the chain config stored
+// with the local genesis block.
+func checkpointConfig(db ethdb.Database) *params.CheckpointConfig {
+	genesis := core.GetCanonicalHash(db, 0)
+	config, err := core.GetChainConfig(db, genesis)
+	if err != nil {
+		utils.Fatalf("Failed to load chain config: %v", err)
+	}
+	return config.Checkpoint
+}
+
+func loadCheckpoint(file string) *light.SignedCheckpoint {
+	blob, err := ioutil.ReadFile(file)
+	if err != nil {
+		utils.Fatalf("Failed to read checkpoint: %v", err)
+	}
+	signed := new(light.SignedCheckpoint)
+	if err := json.Unmarshal(blob, signed); err != nil {
+		utils.Fatalf("Invalid checkpoint file %s: %v", file, err)
+	}
+	return signed
+}
+
+func saveCheckpoint(file string, signed *light.SignedCheckpoint) {
+	blob, err := json.MarshalIndent(signed, "", "  ")
+	if err != nil {
+		utils.Fatalf("Failed to encode checkpoint: %v", err)
+	}
+	if err := ioutil.WriteFile(file, blob, 0644); err != nil {
+		utils.Fatalf("Failed to write checkpoint: %v", err)
+	}
+}
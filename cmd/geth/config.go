@@ -16,9 +16,11 @@ import (
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/contracts/release"
 	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/params"
-//	whisper "github.com/ethereum/go-ethereum/whisper/whisperv5"
+	"github.com/ethereum/go-ethereum/whisper/notify"
+	whisper "github.com/ethereum/go-ethereum/whisper/whisperv5"
 	"github.com/naoina/toml"
 )
 
@@ -28,7 +30,7 @@ var (
 		Name:        "dumpconfig",
 		Usage:       "Show configuration values",
 		ArgsUsage:   "",
-		Flags:       /*append(*/append(nodeFlags, rpcFlags...)/*, whisperFlags...)*/,
+		Flags:       append(append(nodeFlags, rpcFlags...), whisperFlags...),
 		Category:    "MISCELLANEOUS COMMANDS",
 		Description: `The dumpconfig command shows configuration values.`,
 	}
@@ -62,7 +64,7 @@ type ethstatsConfig struct {
 
 type gethConfig struct {
 	Eth      eth.Config
-//	Shh      whisper.Config
+	Shh      whisper.Config
 	Node     node.Config
 	Ethstats ethstatsConfig
 }
@@ -96,7 +98,7 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	// Load defaults.
 	cfg := gethConfig{
 		Eth:  eth.DefaultConfig,
-//		Shh:  whisper.DefaultConfig,
+		Shh:  whisper.DefaultConfig,
 		Node: defaultNodeConfig(),
 	}
 
@@ -118,12 +120,11 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 		cfg.Ethstats.URL = ctx.GlobalString(utils.EthStatsURLFlag.Name)
 	}
 
-//	utils.SetShhConfig(ctx, stack, &cfg.Shh)
+	utils.SetShhConfig(ctx, stack, &cfg.Shh)
 
 	return stack, cfg
 }
 
-/*
 // enableWhisper returns true in case one of the whisper flags is set.
 func enableWhisper(ctx *cli.Context) bool {
 	for _, flag := range whisperFlags {
@@ -133,14 +134,12 @@ func enableWhisper(ctx *cli.Context) bool {
 	}
 	return false
 }
-*/
 
 func makeFullNode(ctx *cli.Context) *node.Node {
 	stack, cfg := makeConfigNode(ctx)
 
 	utils.RegisterEthService(stack, &cfg.Eth)
 
-/*
 	// Whisper must be explicitly enabled by specifying at least 1 whisper flag or in dev mode
 	shhEnabled := enableWhisper(ctx)
 	shhAutoEnabled := !ctx.GlobalIsSet(utils.WhisperEnabledFlag.Name) && ctx.GlobalIsSet(utils.DevModeFlag.Name)
@@ -152,8 +151,27 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 			cfg.Shh.MinimumAcceptedPOW = ctx.Float64(utils.WhisperMinPOWFlag.Name)
 		}
 		utils.RegisterShhService(stack, &cfg.Shh)
+
+		if ctx.GlobalBool(utils.WhisperMailServerFlag.Name) {
+			password := ctx.GlobalString(utils.WhisperMailServerPasswordFlag.Name)
+			if password == "" {
+				utils.Fatalf("Whisper mail server requires --%s", utils.WhisperMailServerPasswordFlag.Name)
+			}
+			utils.RegisterShhMailServer(stack, password, cfg.Shh.MinimumAcceptedPOW)
+		}
+		if ctx.GlobalBool(utils.WhisperNotifyFlag.Name) {
+			if cfg.Eth.SyncMode == downloader.LightSync {
+				utils.Fatalf("Payment notifications are not supported in light mode")
+			}
+			notifyCfg := notify.DefaultConfig
+			notifyCfg.PoW = cfg.Shh.MinimumAcceptedPOW
+			utils.RegisterPaymentNotifyService(stack, &notifyCfg)
+		}
+	}
+	// Add the node registry tracker of a permissioned network if requested.
+	if registry := ctx.GlobalString(utils.PermissionRegistryFlag.Name); registry != "" {
+		utils.RegisterPermissionService(stack, registry)
 	}
-*/
 	// Add the Ethereum Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
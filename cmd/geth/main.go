@@ -45,6 +45,8 @@ var (
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
 		utils.DataDirFlag,
+		utils.AncientFlag,
+		utils.FreezerThresholdFlag,
 		utils.KeyStoreDirFlag,
 		utils.NoUSBFlag,
 		utils.EthashCacheDirFlag,
@@ -68,6 +70,7 @@ var (
 		utils.SyncModeFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
+		utils.LightAuthTokenFlag,
 		utils.LightKDFFlag,
 		utils.CacheFlag,
 		utils.TrieCacheGenFlag,
@@ -79,10 +82,20 @@ var (
 		utils.MinerThreadsFlag,
 		utils.MiningEnabledFlag,
 		utils.TargetGasLimitFlag,
+		utils.MinerOrderingFlag,
+		utils.MinerPrioritySendersFlag,
+		utils.MinerPriorityRecipientsFlag,
+		utils.MinerPriorityGasFlag,
+		utils.StratumAddrFlag,
+		utils.StratumDifficultyFlag,
+		utils.StratumWorkersFlag,
+		utils.StratumShareWindowFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
 		utils.NetrestrictFlag,
+		utils.PermissionedFlag,
+		utils.PermissionRegistryFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
 		utils.DevModeFlag,
@@ -117,13 +130,14 @@ var (
 		utils.IPCPathFlag,
 	}
 
-/*
 	whisperFlags = []cli.Flag{
 		utils.WhisperEnabledFlag,
 		utils.WhisperMaxMessageSizeFlag,
 		utils.WhisperMinPOWFlag,
+		utils.WhisperMailServerFlag,
+		utils.WhisperMailServerPasswordFlag,
+		utils.WhisperNotifyFlag,
 	}
-*/
 )
 
 func init() {
@@ -138,8 +152,14 @@ func init() {
 		exportCommand,
 		removedbCommand,
 		dumpCommand,
+		freezerCommand,
+		stateCommand,
+		// See checkpointcmd.go:
+		checkpointCommand,
 		// See monitorcmd.go:
 		monitorCommand,
+		// See namescmd.go:
+		namesCommand,
 		// See accountcmd.go:
 		accountCommand,
 		walletCommand,
@@ -162,7 +182,7 @@ func init() {
 	app.Flags = append(app.Flags, rpcFlags...)
 	app.Flags = append(app.Flags, consoleFlags...)
 	app.Flags = append(app.Flags, debug.Flags...)
-//	app.Flags = append(app.Flags, whisperFlags...)
+	app.Flags = append(app.Flags, whisperFlags...)
 
 	app.Before = func(ctx *cli.Context) error {
 		runtime.GOMAXPROCS(runtime.NumCPU())
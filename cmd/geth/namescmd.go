@@ -0,0 +1,89 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/contracts/names"
+	"github.com/ethereum/go-ethereum/core"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	namesOwnerFlag = cli.StringFlag{
+		Name:  "owner",
+		Usage: "Account owning the root of the name service",
+	}
+
+	namesCommand = cli.Command{
+		Name:     "names",
+		Usage:    "Manage the bank account name service",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The name service resolves human readable names to bank accounts. Its registry
+is deployed in the genesis state of a network.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "genesis",
+				Usage:     "Add the name service registry to a genesis file",
+				ArgsUsage: "<genesisPath>",
+				Action:    utils.MigrateFlags(namesGenesis),
+				Flags: []cli.Flag{
+					namesOwnerFlag,
+				},
+				Description: `
+Prints the given genesis file with the registry of the name service allocated,
+its root owned by the account given with --owner. The output is meant to be
+distributed to all the nodes of the network and passed to geth init.`,
+			},
+		},
+	}
+)
+
+// namesGenesis prints a genesis file with the name service registry allocated.
+func namesGenesis(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	owner := ctx.String(namesOwnerFlag.Name)
+	if !common.IsHexAddress(owner) {
+		utils.Fatalf("The root owner must be given as an account with --%s.", namesOwnerFlag.Name)
+	}
+	file, err := os.Open(ctx.Args().First())
+	if err != nil {
+		utils.Fatalf("Failed to read genesis file: %v", err)
+	}
+	defer file.Close()
+
+	genesis := new(core.Genesis)
+	if err := json.NewDecoder(file).Decode(genesis); err != nil {
+		utils.Fatalf("invalid genesis file: %v", err)
+	}
+	if err := names.AddToGenesis(genesis, common.HexToAddress(owner)); err != nil {
+		utils.Fatalf("Failed to add the name service: %v", err)
+	}
+	out, err := json.MarshalIndent(genesis, "", "  ")
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(append(out, '\n'))
+	return nil
+}
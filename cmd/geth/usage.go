@@ -50,6 +50,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			configFileFlag,
 			utils.DataDirFlag,
+			utils.AncientFlag,
 			utils.KeyStoreDirFlag,
 			utils.NoUSBFlag,
 			utils.NetworkIdFlag,
@@ -61,6 +62,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.IdentityFlag,
 			utils.LightServFlag,
 			utils.LightPeersFlag,
+			utils.LightAuthTokenFlag,
 			utils.LightKDFFlag,
 		},
 	},
@@ -95,6 +97,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.CacheFlag,
 			utils.TrieCacheGenFlag,
+			utils.FreezerThresholdFlag,
 		},
 	},
 	{
@@ -139,6 +142,8 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
 			utils.NetrestrictFlag,
+			utils.PermissionedFlag,
+			utils.PermissionRegistryFlag,
 			utils.NodeKeyFileFlag,
 			utils.NodeKeyHexFlag,
 		},
@@ -152,6 +157,19 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.TargetGasLimitFlag,
 			utils.GasPriceFlag,
 			utils.ExtraDataFlag,
+			utils.MinerOrderingFlag,
+			utils.MinerPrioritySendersFlag,
+			utils.MinerPriorityRecipientsFlag,
+			utils.MinerPriorityGasFlag,
+		},
+	},
+	{
+		Name: "STRATUM SERVER",
+		Flags: []cli.Flag{
+			utils.StratumAddrFlag,
+			utils.StratumDifficultyFlag,
+			utils.StratumWorkersFlag,
+			utils.StratumShareWindowFlag,
 		},
 	},
 	{
@@ -230,7 +230,7 @@ func (w *wizard) readAddress() *common.Address {
 			return nil
 		}
 		// Make sure it looks ok and return it if so
-		if len(text) != 40 {
+		if len(text) != common.AddressLength*2 {
 			log.Error("Invalid address length, please retry")
 			continue
 		}
@@ -255,7 +255,7 @@ func (w *wizard) readDefaultAddress(def common.Address) common.Address {
 			return def
 		}
 		// Make sure it looks ok and return it if so
-		if len(text) != 40 {
+		if len(text) != common.AddressLength*2 {
 			log.Error("Invalid address length, please retry")
 			continue
 		}
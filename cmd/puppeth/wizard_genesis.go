@@ -61,13 +61,20 @@ func (w *wizard) makeGenesis() {
 		// In the case of clique, configure the consensus parameters
 		genesis.Difficulty = big.NewInt(1)
 		genesis.Config.Clique = &params.CliqueConfig{
-			Period: 15,
-			Epoch:  30000,
+			Period:      15,
+			Epoch:       30000,
+			AgentsBlock: big.NewInt(0),
 		}
 		fmt.Println()
 		fmt.Println("How many seconds should blocks take? (default = 15)")
 		genesis.Config.Clique.Period = uint64(w.readDefaultInt(15))
 
+		fmt.Println()
+		fmt.Println("How many coins should each block reward its miner agents? (default = 0)")
+		if reward := w.readDefaultInt(0); reward > 0 {
+			genesis.Config.Clique.BlockReward = new(big.Int).Mul(big.NewInt(int64(reward)), big.NewInt(params.Ether))
+		}
+
 		// We also need the initial list of signers
 		fmt.Println()
 		fmt.Println("Which accounts are allowed to seal? (mandatory at least one)")
@@ -0,0 +1,676 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// archiveVersion is the version of the segmented chain archive format.
+	archiveVersion = 1
+
+	// archiveManifest is the name of the manifest file within an archive.
+	archiveManifest = "manifest.json"
+
+	// DefaultArchiveSegment is the default number of blocks per archive segment.
+	DefaultArchiveSegment = 10000
+
+	// archiveLookahead is the number of segments per worker that may be decoded
+	// ahead of the one being imported, bounding the memory used by an import.
+	archiveLookahead = 2
+)
+
+// ArchiveManifest describes the content of a segmented chain archive. Every
+// segment is a gzipped stream of RLP encoded blocks, linked to the previous
+// one through its parent hash and protected by a checksum of the file.
+type ArchiveManifest struct {
+	Version  uint             `json:"version"`
+	Genesis  common.Hash      `json:"genesis"`
+	Segments []ArchiveSegment `json:"segments"`
+	State    *ArchiveState    `json:"state,omitempty"`
+}
+
+// ArchiveSegment is a contiguous range of blocks stored in a single file.
+type ArchiveSegment struct {
+	File       string      `json:"file"`
+	First      uint64      `json:"first"`
+	Last       uint64      `json:"last"`
+	ParentHash common.Hash `json:"parentHash"`
+	FirstHash  common.Hash `json:"firstHash"`
+	LastHash   common.Hash `json:"lastHash"`
+	StateRoot  common.Hash `json:"stateRoot"` // State root after the last block of the segment
+	Checksum   string      `json:"checksum"`  // Hex encoded SHA256 of the segment file
+}
+
+// ArchiveState is a snapshot of the accounts at a given block, carrying the
+// full ledger fields (balance, coinage and last coinage block) of every account.
+type ArchiveState struct {
+	File     string      `json:"file"`
+	Number   uint64      `json:"number"`
+	Hash     common.Hash `json:"hash"`
+	Root     common.Hash `json:"root"`
+	Accounts uint64      `json:"accounts"`
+	Checksum string      `json:"checksum"`
+}
+
+// archiveWriter is a gzip compressing file writer that checksums the data
+// written to disk.
+type archiveWriter struct {
+	file *os.File
+	hash io.Writer
+	gzip *gzip.Writer
+	sum  func() string
+}
+
+// newArchiveWriter creates a compressed, checksummed file in the archive.
+func newArchiveWriter(path string) (*archiveWriter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	hasher := sha256.New()
+	return &archiveWriter{
+		file: file,
+		hash: hasher,
+		gzip: gzip.NewWriter(io.MultiWriter(file, hasher)),
+		sum:  func() string { return hex.EncodeToString(hasher.Sum(nil)) },
+	}, nil
+}
+
+func (w *archiveWriter) Write(b []byte) (int, error) { return w.gzip.Write(b) }
+
+// Close flushes and closes the file, returning the checksum of its content.
+func (w *archiveWriter) Close() (string, error) {
+	if err := w.gzip.Close(); err != nil {
+		w.file.Close()
+		return "", err
+	}
+	if err := w.file.Close(); err != nil {
+		return "", err
+	}
+	return w.sum(), nil
+}
+
+// openArchiveFile opens a file of the archive, verifying its checksum before
+// returning a decompressing reader over its content.
+func openArchiveFile(dir, name, checksum string) (io.ReadCloser, error) {
+	path := filepath.Join(dir, name)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if have := hex.EncodeToString(hasher.Sum(nil)); have != checksum {
+		file.Close()
+		return nil, fmt.Errorf("checksum mismatch: have %s, want %s", have, checksum)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &archiveReader{Reader: reader, file: file}, nil
+}
+
+// archiveReader closes both the decompressor and the underlying file.
+type archiveReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *archiveReader) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
+
+// ReadArchiveManifest loads the manifest of a segmented chain archive.
+func ReadArchiveManifest(dir string) (*ArchiveManifest, error) {
+	blob, err := ioutil.ReadFile(filepath.Join(dir, archiveManifest))
+	if err != nil {
+		return nil, err
+	}
+	manifest := new(ArchiveManifest)
+	if err := json.Unmarshal(blob, manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if manifest.Version != archiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", manifest.Version)
+	}
+	return manifest, nil
+}
+
+// writeArchiveManifest atomically replaces the manifest of an archive.
+func writeArchiveManifest(dir string, manifest *ArchiveManifest) error {
+	blob, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, archiveManifest+".tmp")
+	if err := ioutil.WriteFile(tmp, blob, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, archiveManifest))
+}
+
+// ExportArchive writes the blocks first..last of the chain into a segmented
+// archive in dir, optionally followed by a snapshot of the state at the last
+// exported block.
+func ExportArchive(chain *core.BlockChain, dir string, first, last, segment uint64, withState bool) error {
+	if first > last {
+		return fmt.Errorf("invalid block range %d-%d", first, last)
+	}
+	if segment == 0 {
+		segment = DefaultArchiveSegment
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	log.Info("Exporting blockchain archive", "dir", dir, "first", first, "last", last, "segment", segment)
+
+	manifest := &ArchiveManifest{
+		Version: archiveVersion,
+		Genesis: chain.Genesis().Hash(),
+	}
+	for start := first; start <= last; start += segment {
+		end := start + segment - 1
+		if end > last || end < start {
+			end = last
+		}
+		seg, err := exportSegment(chain, dir, start, end)
+		if err != nil {
+			return err
+		}
+		manifest.Segments = append(manifest.Segments, *seg)
+
+		// Keep the manifest up to date so an interrupted export is still usable
+		if err := writeArchiveManifest(dir, manifest); err != nil {
+			return err
+		}
+		log.Info("Exported archive segment", "first", seg.First, "last", seg.Last, "hash", seg.LastHash)
+	}
+	if withState {
+		snapshot, err := exportState(chain, dir, last)
+		if err != nil {
+			return err
+		}
+		manifest.State = snapshot
+		if err := writeArchiveManifest(dir, manifest); err != nil {
+			return err
+		}
+		log.Info("Exported state snapshot", "number", snapshot.Number, "root", snapshot.Root, "accounts", snapshot.Accounts)
+	}
+	log.Info("Exported blockchain archive", "dir", dir, "segments", len(manifest.Segments))
+	return nil
+}
+
+// exportSegment writes a single range of blocks into its own archive file.
+func exportSegment(chain *core.BlockChain, dir string, first, last uint64) (*ArchiveSegment, error) {
+	seg := &ArchiveSegment{
+		File:  fmt.Sprintf("blocks-%08d-%08d.rlp.gz", first, last),
+		First: first,
+		Last:  last,
+	}
+	out, err := newArchiveWriter(filepath.Join(dir, seg.File))
+	if err != nil {
+		return nil, err
+	}
+	for nr := first; nr <= last; nr++ {
+		block := chain.GetBlockByNumber(nr)
+		if block == nil {
+			out.Close()
+			return nil, fmt.Errorf("export failed on #%d: not found", nr)
+		}
+		if nr == first {
+			seg.ParentHash, seg.FirstHash = block.ParentHash(), block.Hash()
+		}
+		seg.LastHash, seg.StateRoot = block.Hash(), block.Root()
+
+		if err := block.EncodeRLP(out); err != nil {
+			out.Close()
+			return nil, err
+		}
+	}
+	if seg.Checksum, err = out.Close(); err != nil {
+		return nil, err
+	}
+	return seg, nil
+}
+
+// exportState streams the state of the given block into the archive.
+func exportState(chain *core.BlockChain, dir string, number uint64) (*ArchiveState, error) {
+	block := chain.GetBlockByNumber(number)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	statedb, err := chain.StateAt(block.Root())
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &ArchiveState{
+		File:   fmt.Sprintf("state-%08d.rlp.gz", number),
+		Number: number,
+		Hash:   block.Hash(),
+		Root:   block.Root(),
+	}
+	out, err := newArchiveWriter(filepath.Join(dir, snapshot.File))
+	if err != nil {
+		return nil, err
+	}
+	err = statedb.ExportAccounts(func(account *state.AccountRecord) error {
+		snapshot.Accounts++
+		return rlp.Encode(out, account)
+	})
+	if err != nil {
+		out.Close()
+		return nil, err
+	}
+	if snapshot.Checksum, err = out.Close(); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// verifiedSegment is the result of checking a single archive segment.
+type verifiedSegment struct {
+	index  int
+	blocks types.Blocks
+	err    error
+}
+
+// ImportArchive imports a segmented chain archive into the chain. Segments are
+// checksummed, decoded and verified (header linkage, body roots and, for engines
+// whose seals stand on their own, seals) in parallel, and then inserted in order.
+// Segments already present in the chain are skipped, so an interrupted import
+// can simply be restarted. In verify-only mode nothing is imported and no
+// transactions are executed, the headers are verified in order against their
+// parents from the archive instead.
+func ImportArchive(chain *core.BlockChain, dir string, verifyOnly bool) error {
+	manifest, err := ReadArchiveManifest(dir)
+	if err != nil {
+		return err
+	}
+	if manifest.Genesis != chain.Genesis().Hash() {
+		return fmt.Errorf("archive genesis mismatch: have %x, want %x", manifest.Genesis, chain.Genesis().Hash())
+	}
+	// Make sure the segments form a contiguous chain before touching any data
+	for i, seg := range manifest.Segments {
+		if seg.First > seg.Last {
+			return fmt.Errorf("segment %s: invalid range %d-%d", seg.File, seg.First, seg.Last)
+		}
+		if i > 0 {
+			prev := manifest.Segments[i-1]
+			if seg.First != prev.Last+1 || seg.ParentHash != prev.LastHash {
+				return fmt.Errorf("segment %s does not link to %s", seg.File, prev.File)
+			}
+		}
+	}
+	// Skip all the segments that were already imported in a previous run
+	start := 0
+	if !verifyOnly {
+		for start < len(manifest.Segments) && chain.HasBlock(manifest.Segments[start].LastHash) {
+			start++
+		}
+		if start > 0 {
+			log.Info("Skipping imported archive segments", "segments", start, "last", manifest.Segments[start-1].Last)
+		}
+	}
+	log.Info("Importing blockchain archive", "dir", dir, "segments", len(manifest.Segments)-start, "verify", verifyOnly)
+
+	// Verify the remaining segments concurrently, feeding them back in order. Only
+	// a few segments per worker may be in flight, the rest wait for a slot.
+	var (
+		pending = manifest.Segments[start:]
+		workers = runtime.NumCPU()
+		slots   = make(chan struct{}, workers*archiveLookahead)
+		tasks   = make(chan int)
+		results = make(chan *verifiedSegment, cap(slots))
+		abort   = make(chan struct{})
+		sealed  = hasStandaloneSeals(chain.Engine())
+	)
+	defer close(abort)
+
+	go func() {
+		defer close(tasks)
+		for i := range pending {
+			select {
+			case slots <- struct{}{}:
+			case <-abort:
+				return
+			}
+			select {
+			case tasks <- i:
+			case <-abort:
+				return
+			}
+		}
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for index := range tasks {
+				blocks, err := verifyArchiveSegment(chain, dir, &pending[index], sealed)
+				select {
+				case results <- &verifiedSegment{index: index, blocks: blocks, err: err}:
+				case <-abort:
+					return
+				}
+			}
+		}()
+	}
+	var (
+		done   = make(map[int]*verifiedSegment)
+		reader = newArchiveChain(chain)
+	)
+	for next := 0; next < len(pending); {
+		res := <-results
+		done[res.index] = res
+
+		for ; done[next] != nil; next++ {
+			res, seg := done[next], &pending[next]
+			delete(done, next)
+			<-slots
+
+			if res.err != nil {
+				return fmt.Errorf("segment %s: %v", seg.File, res.err)
+			}
+			if verifyOnly {
+				if err := verifyArchiveHeaders(reader, res.blocks, !sealed); err != nil {
+					return fmt.Errorf("segment %s: %v", seg.File, err)
+				}
+				log.Info("Verified archive segment", "first", seg.First, "last", seg.Last, "hash", seg.LastHash)
+				continue
+			}
+			if err := importArchiveSegment(chain, seg, res.blocks); err != nil {
+				return fmt.Errorf("segment %s: %v", seg.File, err)
+			}
+			log.Info("Imported archive segment", "first", seg.First, "last", seg.Last, "hash", seg.LastHash)
+		}
+	}
+	// Segments done, check the state snapshot if one's attached
+	if manifest.State != nil {
+		if err := verifyArchiveState(chain, dir, manifest, verifyOnly); err != nil {
+			return fmt.Errorf("state %s: %v", manifest.State.File, err)
+		}
+	}
+	return nil
+}
+
+// hasStandaloneSeals returns whether the seals of an engine can be verified
+// without the parents of a header, which is only the case for proof-of-work.
+// Signature based engines need the signer set of the parent to check a seal.
+func hasStandaloneSeals(engine consensus.Engine) bool {
+	_, ok := engine.(*ethash.Ethash)
+	return ok
+}
+
+// archiveChain is a chain reader serving the headers of the archive segments
+// being verified on top of the local chain, so that the consensus engine can
+// check blocks whose parents were never imported.
+type archiveChain struct {
+	*core.BlockChain
+
+	prev, curr map[common.Hash]*types.Header // Headers of the last two segments
+	numbers    map[uint64]*types.Header      // Headers of the last two segments by number
+}
+
+// newArchiveChain creates a chain reader over the local chain, extended by the
+// archive segments added to it.
+func newArchiveChain(chain *core.BlockChain) *archiveChain {
+	return &archiveChain{
+		BlockChain: chain,
+		prev:       make(map[common.Hash]*types.Header),
+		curr:       make(map[common.Hash]*types.Header),
+		numbers:    make(map[uint64]*types.Header),
+	}
+}
+
+// add makes the headers of a segment available to the engine, dropping those
+// of the segment before the previous one.
+func (c *archiveChain) add(blocks types.Blocks) {
+	for _, header := range c.prev {
+		delete(c.numbers, header.Number.Uint64())
+	}
+	c.prev, c.curr = c.curr, make(map[common.Hash]*types.Header, len(blocks))
+	for _, block := range blocks {
+		header := block.Header()
+		c.curr[header.Hash()] = header
+		c.numbers[header.Number.Uint64()] = header
+	}
+}
+
+// header retrieves an archive header by hash, if it's still being tracked.
+func (c *archiveChain) header(hash common.Hash) *types.Header {
+	if header := c.curr[hash]; header != nil {
+		return header
+	}
+	return c.prev[hash]
+}
+
+func (c *archiveChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if header := c.header(hash); header != nil {
+		return header
+	}
+	return c.BlockChain.GetHeader(hash, number)
+}
+
+func (c *archiveChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	if header := c.header(hash); header != nil {
+		return header
+	}
+	return c.BlockChain.GetHeaderByHash(hash)
+}
+
+func (c *archiveChain) GetHeaderByNumber(number uint64) *types.Header {
+	if header := c.numbers[number]; header != nil {
+		return header
+	}
+	return c.BlockChain.GetHeaderByNumber(number)
+}
+
+// verifyArchiveHeaders checks the headers of a segment against the consensus
+// rules, resolving their parents from the previously verified segments.
+func verifyArchiveHeaders(chain *archiveChain, blocks types.Blocks, seal bool) error {
+	// The genesis is never verified, it's already known to be ours
+	if blocks[0].NumberU64() == 0 {
+		blocks = blocks[1:]
+	}
+	headers := make([]*types.Header, len(blocks))
+	seals := make([]bool, len(blocks))
+	for i, block := range blocks {
+		headers[i], seals[i] = block.Header(), seal
+	}
+	abort, results := chain.Engine().VerifyHeaders(chain, headers, seals)
+	defer close(abort)
+
+	for i := range headers {
+		if err := <-results; err != nil {
+			return fmt.Errorf("block #%d: %v", headers[i].Number, err)
+		}
+	}
+	chain.add(blocks)
+	return nil
+}
+
+// verifyArchiveSegment loads the blocks of a segment, checking them against the
+// manifest without executing any transactions. If requested, the seals of the
+// blocks are verified too, which needs an engine not depending on the parents.
+func verifyArchiveSegment(chain *core.BlockChain, dir string, seg *ArchiveSegment, seal bool) (types.Blocks, error) {
+	in, err := openArchiveFile(dir, seg.File, seg.Checksum)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	var (
+		stream = rlp.NewStream(in, 0)
+		blocks = make(types.Blocks, 0, seg.Last-seg.First+1)
+		engine = chain.Engine()
+		parent = seg.ParentHash
+	)
+	for nr := seg.First; nr <= seg.Last; nr++ {
+		block := new(types.Block)
+		if err := stream.Decode(block); err != nil {
+			return nil, fmt.Errorf("block #%d: %v", nr, err)
+		}
+		header := block.Header()
+		switch {
+		case block.NumberU64() != nr:
+			return nil, fmt.Errorf("block #%d: unexpected number %d", nr, block.NumberU64())
+		case block.ParentHash() != parent:
+			return nil, fmt.Errorf("block #%d: parent hash mismatch: have %x, want %x", nr, block.ParentHash(), parent)
+		case types.DeriveSha(block.Transactions()) != header.TxHash:
+			return nil, fmt.Errorf("block #%d: transaction root mismatch", nr)
+		case types.CalcUncleHash(block.Uncles()) != header.UncleHash:
+			return nil, fmt.Errorf("block #%d: uncle root mismatch", nr)
+		}
+		if seal && nr > 0 {
+			if err := engine.VerifySeal(chain, header); err != nil {
+				return nil, fmt.Errorf("block #%d: invalid seal: %v", nr, err)
+			}
+		}
+		parent = block.Hash()
+		blocks = append(blocks, block)
+	}
+	if err := stream.Decode(new(types.Block)); err != io.EOF {
+		return nil, errors.New("trailing data after last block")
+	}
+	if blocks[0].Hash() != seg.FirstHash || parent != seg.LastHash {
+		return nil, errors.New("segment boundaries don't match the manifest")
+	}
+	if root := blocks[len(blocks)-1].Root(); root != seg.StateRoot {
+		return nil, fmt.Errorf("state root mismatch: have %x, want %x", root, seg.StateRoot)
+	}
+	return blocks, nil
+}
+
+// importArchiveSegment inserts the verified blocks of a segment into the chain,
+// skipping any blocks (e.g. the genesis) that are already present.
+func importArchiveSegment(chain *core.BlockChain, seg *ArchiveSegment, blocks types.Blocks) error {
+	for len(blocks) > 0 && chain.HasBlock(blocks[0].Hash()) {
+		blocks = blocks[1:]
+	}
+	for len(blocks) > 0 {
+		batch := blocks
+		if len(batch) > importBatchSize {
+			batch = batch[:importBatchSize]
+		}
+		if n, err := chain.InsertChain(batch); err != nil {
+			return fmt.Errorf("invalid block #%d: %v", batch[n].NumberU64(), err)
+		}
+		blocks = blocks[len(batch):]
+	}
+	return nil
+}
+
+// verifyArchiveState checks the state snapshot of an archive by rebuilding it in
+// a scratch database, the resulting root has to match the one of the snapshot
+// block. Importing the segments executes every block, so the state is never
+// imported from the snapshot, only checked against the chain it was taken from.
+// In verify-only mode the root is checked against the manifest instead.
+func verifyArchiveState(chain *core.BlockChain, dir string, manifest *ArchiveManifest, verifyOnly bool) error {
+	snapshot := manifest.State
+
+	in, err := openArchiveFile(dir, snapshot.File, snapshot.Checksum)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	db, _ := ethdb.NewMemDatabase()
+	root, accounts, err := ImportStateSnapshot(db, in)
+	if err != nil {
+		return err
+	}
+	if root != snapshot.Root {
+		return fmt.Errorf("state root mismatch: have %x, want %x", root, snapshot.Root)
+	}
+	if accounts != snapshot.Accounts {
+		return fmt.Errorf("account count mismatch: have %d, want %d", accounts, snapshot.Accounts)
+	}
+	if verifyOnly {
+		for _, seg := range manifest.Segments {
+			if seg.Last == snapshot.Number && seg.LastHash == snapshot.Hash {
+				if seg.StateRoot != snapshot.Root {
+					return fmt.Errorf("snapshot root mismatch: have %x, want %x", snapshot.Root, seg.StateRoot)
+				}
+				log.Info("Verified state snapshot", "number", snapshot.Number, "root", snapshot.Root, "accounts", accounts)
+				return nil
+			}
+		}
+		return fmt.Errorf("snapshot block #%d [%x] not in archive", snapshot.Number, snapshot.Hash[:4])
+	}
+	block := chain.GetBlockByHash(snapshot.Hash)
+	if block == nil || block.NumberU64() != snapshot.Number {
+		return fmt.Errorf("snapshot block #%d [%x] not in chain", snapshot.Number, snapshot.Hash[:4])
+	}
+	if block.Root() != snapshot.Root {
+		return fmt.Errorf("state root mismatch: have %x, want %x", block.Root(), snapshot.Root)
+	}
+	log.Info("Verified state snapshot against the chain", "number", snapshot.Number, "root", snapshot.Root, "accounts", accounts)
+	return nil
+}
+
+// ImportStateSnapshot rebuilds a state from a stream of RLP encoded account
+// records into the database, returning the resulting state root.
+func ImportStateSnapshot(db ethdb.Database, r io.Reader) (common.Hash, uint64, error) {
+	importer, err := state.NewAccountImporter(db)
+	if err != nil {
+		return common.Hash{}, 0, err
+	}
+	stream := rlp.NewStream(r, 0)
+	for {
+		account := new(state.AccountRecord)
+		if err := stream.Decode(account); err == io.EOF {
+			break
+		} else if err != nil {
+			return common.Hash{}, 0, fmt.Errorf("account %d: %v", importer.Accounts(), err)
+		}
+		if err := importer.Add(account); err != nil {
+			return common.Hash{}, 0, err
+		}
+	}
+	root, err := importer.Commit()
+	return root, importer.Accounts(), err
+}
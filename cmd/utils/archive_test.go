@@ -0,0 +1,172 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	testArchiveFunded   = common.HexToAddress("0x0000000001a94f5374fce5edbc8e2a8697c15331677e6ebf0b")
+	testArchiveContract = common.HexToAddress("0x0000000001000000000000000000000000000000000000c0de")
+	testArchiveMiners   = []common.Address{
+		common.HexToAddress("0x00000000017e5f4552091a69125d5dfcb7b8c2659029395bdf"),
+		common.HexToAddress("0x00000000012b5ad5c4795c026514f8317c7a215e218dccd6cf"),
+	}
+)
+
+// testArchiveGenesis is the genesis of the archived chains, with an account
+// carrying ledger fields and a contract with storage.
+var testArchiveGenesis = &core.Genesis{
+	Config: params.TestChainConfig,
+	Alloc: core.GenesisAlloc{
+		testArchiveFunded: {
+			Balance: new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether)),
+			Coinage: "12.500000",
+			Last:    1,
+		},
+		testArchiveContract: {
+			Balance: big.NewInt(0),
+			Code:    common.FromHex("0x600160005500"),
+			Storage: map[common.Hash]common.Hash{
+				common.HexToHash("0x01"): common.HexToHash("0x2a"),
+			},
+		},
+	},
+}
+
+// newTestArchiveChain creates a chain on top of the archive test genesis.
+func newTestArchiveChain(t *testing.T) (*core.BlockChain, ethdb.Database) {
+	db, _ := ethdb.NewMemDatabase()
+	testArchiveGenesis.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, testArchiveGenesis.Config, ethash.NewFaker(), new(event.TypeMux), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	return chain, db
+}
+
+// tamperArchiveState rewrites the state snapshot of an archive with the given
+// change applied to its accounts, updating the checksum in the manifest so that
+// only rebuilding the state can tell.
+func tamperArchiveState(t *testing.T, dir string, tamper func(*state.AccountRecord)) {
+	manifest, err := ReadArchiveManifest(dir)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	in, err := openArchiveFile(dir, manifest.State.File, manifest.State.Checksum)
+	if err != nil {
+		t.Fatalf("failed to open state snapshot: %v", err)
+	}
+	var accounts []*state.AccountRecord
+	for stream := rlp.NewStream(in, 0); ; {
+		account := new(state.AccountRecord)
+		if err := stream.Decode(account); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("failed to decode account: %v", err)
+		}
+		tamper(account)
+		accounts = append(accounts, account)
+	}
+	in.Close()
+
+	out, err := newArchiveWriter(filepath.Join(dir, manifest.State.File))
+	if err != nil {
+		t.Fatalf("failed to rewrite state snapshot: %v", err)
+	}
+	for _, account := range accounts {
+		if err := rlp.Encode(out, account); err != nil {
+			t.Fatalf("failed to encode account: %v", err)
+		}
+	}
+	if manifest.State.Checksum, err = out.Close(); err != nil {
+		t.Fatalf("failed to close state snapshot: %v", err)
+	}
+	if err := writeArchiveManifest(dir, manifest); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+}
+
+// Tests that a chain exported into an archive with a state snapshot imports and
+// verifies, and that a snapshot not matching the chain's state is rejected even
+// if its checksum is intact.
+func TestArchiveStateRoundTrip(t *testing.T) {
+	// The default miner agents of the genesis header depend on the coinage of
+	// the last head seen, so create all the chains upfront
+	source, db := newTestArchiveChain(t)
+	defer source.Stop()
+	imported, _ := newTestArchiveChain(t)
+	defer imported.Stop()
+	rejected, _ := newTestArchiveChain(t)
+	defer rejected.Stop()
+
+	blocks, _ := core.GenerateChain(testArchiveGenesis.Config, source.Genesis(), db, 10, func(i int, block *core.BlockGen) {
+		block.SetCoinbase(testArchiveMiners[i%len(testArchiveMiners)])
+	})
+	if _, err := source.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "archive-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ExportArchive(source, dir, 0, 10, 4, true); err != nil {
+		t.Fatalf("failed to export archive: %v", err)
+	}
+	// The untouched archive verifies and imports
+	if err := ImportArchive(imported, dir, true); err != nil {
+		t.Fatalf("failed to verify archive: %v", err)
+	}
+	if err := ImportArchive(imported, dir, false); err != nil {
+		t.Fatalf("failed to import archive: %v", err)
+	}
+	if head := imported.CurrentBlock().Hash(); head != blocks[9].Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head, blocks[9].Hash())
+	}
+	// A snapshot crediting an account more than the chain did is rejected
+	tamperArchiveState(t, dir, func(account *state.AccountRecord) {
+		if account.Address == testArchiveFunded {
+			account.Balance = "1000000.000000"
+		}
+	})
+	for _, verifyOnly := range []bool{true, false} {
+		err := ImportArchive(rejected, dir, verifyOnly)
+		if err == nil || !strings.Contains(err.Error(), "state root mismatch") {
+			t.Errorf("verify only %v: tampered snapshot not rejected: %v", verifyOnly, err)
+		}
+	}
+}
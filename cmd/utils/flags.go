@@ -4,6 +4,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/big"
@@ -16,7 +17,11 @@ import (
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/bft"
+	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/contracts/permission"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -30,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/les"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
@@ -37,7 +43,10 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/params"
-//	whisper "github.com/ethereum/go-ethereum/whisper/whisperv5"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/whisper/mailserver"
+	"github.com/ethereum/go-ethereum/whisper/notify"
+	whisper "github.com/ethereum/go-ethereum/whisper/whisperv5"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -100,6 +109,15 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	AncientFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
+	}
+	FreezerThresholdFlag = cli.Uint64Flag{
+		Name:  "freezer.threshold",
+		Usage: "Number of blocks after which chain data is moved into the ancient store (0 = disabled)",
+		Value: eth.DefaultConfig.FreezerThreshold,
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -145,7 +163,7 @@ var (
 	defaultSyncMode = eth.DefaultConfig.SyncMode
 	SyncModeFlag    = TextMarshalerFlag{
 		Name:  "syncmode",
-		Usage: `Blockchain sync mode ("fast", "full", or "light")`,
+		Usage: `Blockchain sync mode ("fast", "full", "snap" or "light")`,
 		Value: &defaultSyncMode,
 	}
 
@@ -159,6 +177,10 @@ var (
 		Usage: "Maximum number of LES client peers",
 		Value: 20,
 	}
+	LightAuthTokenFlag = cli.StringFlag{
+		Name:  "lightauth",
+		Usage: "Token proven to LES servers to claim a priority service tier (the token itself is never sent)",
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -283,6 +305,42 @@ var (
 		Name:  "extradata",
 		Usage: "Block extra data set by the miner (default = client version)",
 	}
+	MinerOrderingFlag = cli.StringFlag{
+		Name:  "miner.ordering",
+		Usage: "Transaction ordering policy of the mined blocks (price, fifo, priceage)",
+		Value: miner.OrderByPrice,
+	}
+	MinerPrioritySendersFlag = cli.StringFlag{
+		Name:  "miner.prioritysenders",
+		Usage: "Comma separated hex prefixes of the sender addresses included ahead of other transactions",
+	}
+	MinerPriorityRecipientsFlag = cli.StringFlag{
+		Name:  "miner.priorityrecipients",
+		Usage: "Comma separated system contract addresses whose transactions are included ahead of others",
+	}
+	MinerPriorityGasFlag = cli.Uint64Flag{
+		Name:  "miner.prioritygas",
+		Usage: "Gas of each block reserved for the priority transactions",
+	}
+	// Stratum server settings
+	StratumAddrFlag = cli.StringFlag{
+		Name:  "stratum.addr",
+		Usage: "Listening address of the stratum mining server for pool workers (empty = disabled)",
+	}
+	StratumDifficultyFlag = cli.Uint64Flag{
+		Name:  "stratum.difficulty",
+		Usage: "Difficulty of the shares submitted by stratum workers",
+		Value: eth.DefaultConfig.Stratum.Difficulty,
+	}
+	StratumWorkersFlag = cli.StringFlag{
+		Name:  "stratum.workers",
+		Usage: "JSON file mapping the stratum worker logins allowed in to their passwords (required by --stratum.addr)",
+	}
+	StratumShareWindowFlag = cli.IntFlag{
+		Name:  "stratum.sharewindow",
+		Usage: "Number of recent stratum shares the block reward is split by",
+		Value: eth.DefaultConfig.Stratum.ShareWindow,
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -316,6 +374,23 @@ var (
 		Name:  "nocompaction",
 		Usage: "Disables db compaction after import",
 	}
+	ArchiveFlag = cli.BoolFlag{
+		Name:  "archive",
+		Usage: "Import/export a segmented, verifiable chain archive directory instead of a flat RLP file",
+	}
+	ArchiveSegmentFlag = cli.Uint64Flag{
+		Name:  "archive.segment",
+		Usage: "Number of blocks per chain archive segment",
+		Value: DefaultArchiveSegment,
+	}
+	ArchiveStateFlag = cli.BoolFlag{
+		Name:  "archive.state",
+		Usage: "Include a snapshot of the state at the last exported block in the chain archive",
+	}
+	VerifyOnlyFlag = cli.BoolFlag{
+		Name:  "verify-only",
+		Usage: "Verify the chain archive (checksums, linkage, seals) without importing it",
+	}
 	// RPC settings
 	RPCEnabledFlag = cli.BoolFlag{
 		Name:  "rpc",
@@ -440,6 +515,14 @@ var (
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
 	}
+	PermissionedFlag = cli.BoolFlag{
+		Name:  "permissioned",
+		Usage: "Only connect to the nodes whitelisted in <datadir>/permissioned-nodes.json",
+	}
+	PermissionRegistryFlag = cli.StringFlag{
+		Name:  "permissioned.registry",
+		Usage: "Whitelist the nodes listed in the node registry contract at this address too (implies --permissioned)",
+	}
 
 	// ATM the url is left to the user and deployment to
 	JSpathFlag = cli.StringFlag{
@@ -459,7 +542,6 @@ var (
 		Usage: "Suggested gas price is the given percentile of a set of recent transaction gas prices",
 		Value: eth.DefaultConfig.GPO.Percentile,
 	}
-/*
 	WhisperEnabledFlag = cli.BoolFlag{
 		Name:  "shh",
 		Usage: "Enable Whisper",
@@ -474,7 +556,18 @@ var (
 		Usage: "Minimum POW accepted",
 		Value: whisper.DefaultMinimumPoW,
 	}
-*/
+	WhisperMailServerFlag = cli.BoolFlag{
+		Name:  "shh.mailserver",
+		Usage: "Archive whisper envelopes and deliver them to offline peers on request",
+	}
+	WhisperMailServerPasswordFlag = cli.StringFlag{
+		Name:  "shh.mailserver.password",
+		Usage: "Password deriving the symmetric key mail requests are encrypted with",
+	}
+	WhisperNotifyFlag = cli.BoolFlag{
+		Name:  "shh.notify",
+		Usage: "Publish whisper notifications of mined payments to subscribed addresses",
+	}
 )
 
 // MakeDataDir retrieves the currently requested data directory, terminating
@@ -732,6 +825,59 @@ func setEtherbase(ctx *cli.Context, ks *keystore.KeyStore, cfg *eth.Config) {
 	}
 }
 
+// setOrdering applies the transaction ordering related command line flags to
+// the config, gathering the priority flags into a single lane.
+func setOrdering(ctx *cli.Context, cfg *miner.OrderingConfig) {
+	if ctx.GlobalIsSet(MinerOrderingFlag.Name) {
+		cfg.Policy = ctx.GlobalString(MinerOrderingFlag.Name)
+	}
+	if !ctx.GlobalIsSet(MinerPrioritySendersFlag.Name) && !ctx.GlobalIsSet(MinerPriorityRecipientsFlag.Name) {
+		return
+	}
+	lane := miner.PriorityLane{
+		Name:        "priority",
+		ReservedGas: ctx.GlobalUint64(MinerPriorityGasFlag.Name),
+	}
+	for _, prefix := range strings.Split(ctx.GlobalString(MinerPrioritySendersFlag.Name), ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			lane.Senders = append(lane.Senders, prefix)
+		}
+	}
+	for _, recipient := range strings.Split(ctx.GlobalString(MinerPriorityRecipientsFlag.Name), ",") {
+		if recipient = strings.TrimSpace(recipient); recipient != "" {
+			if !common.IsHexAddress(recipient) {
+				Fatalf("Option %q: invalid address %q", MinerPriorityRecipientsFlag.Name, recipient)
+			}
+			lane.Recipients = append(lane.Recipients, common.HexToAddress(recipient))
+		}
+	}
+	cfg.Lanes = append(cfg.Lanes, lane)
+}
+
+// setStratum applies the stratum mining server related command line flags to
+// the config.
+func setStratum(ctx *cli.Context, cfg *miner.StratumConfig) {
+	if ctx.GlobalIsSet(StratumAddrFlag.Name) {
+		cfg.Addr = ctx.GlobalString(StratumAddrFlag.Name)
+	}
+	if ctx.GlobalIsSet(StratumDifficultyFlag.Name) {
+		cfg.Difficulty = ctx.GlobalUint64(StratumDifficultyFlag.Name)
+	}
+	if ctx.GlobalIsSet(StratumShareWindowFlag.Name) {
+		cfg.ShareWindow = ctx.GlobalInt(StratumShareWindowFlag.Name)
+	}
+	if ctx.GlobalIsSet(StratumWorkersFlag.Name) {
+		path := ctx.GlobalString(StratumWorkersFlag.Name)
+		blob, err := ioutil.ReadFile(path)
+		if err != nil {
+			Fatalf("Failed to read stratum workers file: %v", err)
+		}
+		if err := json.Unmarshal(blob, &cfg.Workers); err != nil {
+			Fatalf("Failed to parse stratum workers file %s: %v", path, err)
+		}
+	}
+}
+
 // MakePasswordList reads password lines from the file specified by the global --password flag.
 func MakePasswordList(ctx *cli.Context) []string {
 	path := ctx.GlobalString(PasswordFileFlag.Name)
@@ -785,6 +931,9 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		}
 		cfg.NetRestrict = list
 	}
+	if ctx.GlobalBool(PermissionedFlag.Name) || ctx.GlobalIsSet(PermissionRegistryFlag.Name) {
+		cfg.Permissioned = true
+	}
 
 	if ctx.GlobalBool(DevModeFlag.Name) {
 		// --dev mode can't use p2p networking.
@@ -901,7 +1050,6 @@ func checkExclusive(ctx *cli.Context, flags ...cli.Flag) {
 	}
 }
 
-/*
 // SetShhConfig applies shh-related command line flags to the config.
 func SetShhConfig(ctx *cli.Context, stack *node.Node, cfg *whisper.Config) {
 	if ctx.GlobalIsSet(WhisperMaxMessageSizeFlag.Name) {
@@ -911,7 +1059,6 @@ func SetShhConfig(ctx *cli.Context, stack *node.Node, cfg *whisper.Config) {
 		cfg.MinimumAcceptedPOW = ctx.GlobalFloat64(WhisperMinPOWFlag.Name)
 	}
 }
-*/
 
 // SetEthConfig applies eth-related command line flags to the config.
 func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
@@ -924,6 +1071,8 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	setGPO(ctx, &cfg.GPO)
 	setTxPool(ctx, &cfg.TxPool)
 	setEthash(ctx, cfg)
+	setStratum(ctx, &cfg.Stratum)
+	setOrdering(ctx, &cfg.Ordering)
 
 	switch {
 	case ctx.GlobalIsSet(SyncModeFlag.Name):
@@ -939,6 +1088,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(LightPeersFlag.Name) {
 		cfg.LightPeers = ctx.GlobalInt(LightPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(LightAuthTokenFlag.Name) {
+		cfg.LightAuthToken = ctx.GlobalString(LightAuthTokenFlag.Name)
+	}
 	if ctx.GlobalIsSet(NetworkIdFlag.Name) {
 		cfg.NetworkId = ctx.GlobalUint64(NetworkIdFlag.Name)
 	}
@@ -951,6 +1103,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		cfg.DatabaseCache = ctx.GlobalInt(CacheFlag.Name)
 	}
 	cfg.DatabaseHandles = makeDatabaseHandles()
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
+	}
+	if ctx.GlobalIsSet(FreezerThresholdFlag.Name) {
+		cfg.FreezerThreshold = ctx.GlobalUint64(FreezerThresholdFlag.Name)
+	}
 
 	if ctx.GlobalIsSet(MinerThreadsFlag.Name) {
 		cfg.MinerThreads = ctx.GlobalInt(MinerThreadsFlag.Name)
@@ -1006,7 +1164,10 @@ func RegisterEthService(stack *node.Node, cfg *eth.Config) {
 		err = stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
 			fullNode, err := eth.New(ctx, cfg)
 			if fullNode != nil && cfg.LightServ > 0 {
-				ls, _ := les.NewLesServer(fullNode, cfg)
+				ls, err := les.NewLesServer(fullNode, cfg)
+				if err != nil {
+					return nil, err
+				}
 				fullNode.AddLesServer(ls)
 			}
 			return fullNode, err
@@ -1017,7 +1178,6 @@ func RegisterEthService(stack *node.Node, cfg *eth.Config) {
 	}
 }
 
-/*
 // RegisterShhService configures Whisper and adds it to the given node.
 func RegisterShhService(stack *node.Node, cfg *whisper.Config) {
 	if err := stack.Register(func(n *node.ServiceContext) (node.Service, error) {
@@ -1026,7 +1186,62 @@ func RegisterShhService(stack *node.Node, cfg *whisper.Config) {
 		Fatalf("Failed to register the Whisper service: %v", err)
 	}
 }
-*/
+
+// mailServerService runs a whisper mail server alongside the node, closing its
+// archive when the node stops.
+type mailServerService struct {
+	server mailserver.WMailServer
+}
+
+func (s *mailServerService) Protocols() []p2p.Protocol { return nil }
+func (s *mailServerService) APIs() []rpc.API           { return nil }
+func (s *mailServerService) Start(*p2p.Server) error   { return nil }
+func (s *mailServerService) Stop() error               { s.server.Close(); return nil }
+
+// RegisterShhMailServer attaches a mail server to the Whisper service of the
+// given node, archiving envelopes in the data directory for offline peers.
+func RegisterShhMailServer(stack *node.Node, password string, pow float64) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var shh *whisper.Whisper
+		if err := ctx.Service(&shh); err != nil {
+			return nil, err
+		}
+		path := ctx.ResolvePath("shhmail")
+		if path == "" {
+			return nil, fmt.Errorf("mail server requires a persistent data directory")
+		}
+		service := new(mailServerService)
+		service.server.Init(shh, path, password, pow)
+		shh.RegisterServer(&service.server)
+		return service, nil
+	}); err != nil {
+		Fatalf("Failed to register the Whisper mail server: %v", err)
+	}
+}
+
+// RegisterPaymentNotifyService adds the whisper payment notification service
+// to the given node.
+func RegisterPaymentNotifyService(stack *node.Node, cfg *notify.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return notify.New(ctx, cfg)
+	}); err != nil {
+		Fatalf("Failed to register the payment notification service: %v", err)
+	}
+}
+
+// RegisterPermissionService configures the node registry tracker of a
+// permissioned network and adds it to the given node.
+func RegisterPermissionService(stack *node.Node, registry string) {
+	if !common.IsHexAddress(registry) {
+		Fatalf("Invalid node registry address: %s", registry)
+	}
+	config := permission.Config{Registry: common.HexToAddress(registry)}
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		return permission.NewPermissionService(ctx, config)
+	}); err != nil {
+		Fatalf("Failed to register the node registry service: %v", err)
+	}
+}
 
 // RegisterEthStatsService configures the Ethereum Stats daemon and adds it to
 // th egiven node.
@@ -1052,16 +1267,20 @@ func SetupNetwork(ctx *cli.Context) {
 }
 
 // MakeChainDatabase open an LevelDB using the flags passed to the client and will hard crash if it fails.
+// Full node databases get the ancient freezer attached.
 func MakeChainDatabase(ctx *cli.Context, stack *node.Node) ethdb.Database {
 	var (
 		cache   = ctx.GlobalInt(CacheFlag.Name)
 		handles = makeDatabaseHandles()
+
+		chainDb ethdb.Database
+		err     error
 	)
-	name := "chaindata"
 	if ctx.GlobalBool(LightModeFlag.Name) {
-		name = "lightchaindata"
+		chainDb, err = stack.OpenDatabase("lightchaindata", cache, handles)
+	} else {
+		chainDb, err = stack.OpenDatabaseWithFreezer("chaindata", cache, handles, ctx.GlobalString(AncientFlag.Name), "")
 	}
-	chainDb, err := stack.OpenDatabase(name, cache, handles)
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
@@ -1086,22 +1305,30 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	var err error
 	chainDb = MakeChainDatabase(ctx, stack)
 
-	engine := ethash.NewFaker()
-	if !ctx.GlobalBool(FakePoWFlag.Name) {
-		engine = ethash.New(
-			stack.ResolvePath(eth.DefaultConfig.EthashCacheDir), eth.DefaultConfig.EthashCachesInMem, eth.DefaultConfig.EthashCachesOnDisk,
-			stack.ResolvePath(eth.DefaultConfig.EthashDatasetDir), eth.DefaultConfig.EthashDatasetsInMem, eth.DefaultConfig.EthashDatasetsOnDisk,
-		)
-	}
 	config, _, err := core.SetupGenesisBlock(chainDb, MakeGenesis(ctx))
 	if err != nil {
 		Fatalf("%v", err)
 	}
+	var engine consensus.Engine
+	if config.Clique != nil {
+		engine = clique.New(config.Clique, chainDb)
+	} else if config.BFT != nil {
+		engine = bft.New(config.BFT, chainDb)
+	} else {
+		engine = ethash.NewFaker()
+		if !ctx.GlobalBool(FakePoWFlag.Name) {
+			engine = ethash.New(
+				stack.ResolvePath(eth.DefaultConfig.EthashCacheDir), eth.DefaultConfig.EthashCachesInMem, eth.DefaultConfig.EthashCachesOnDisk,
+				stack.ResolvePath(eth.DefaultConfig.EthashDatasetDir), eth.DefaultConfig.EthashDatasetsInMem, eth.DefaultConfig.EthashDatasetsOnDisk,
+			)
+		}
+	}
 	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
 	chain, err = core.NewBlockChain(chainDb, config, engine, new(event.TypeMux), vmcfg)
 	if err != nil {
 		Fatalf("Can't create BlockChain: %v", err)
 	}
+	chain.SetFreezeThreshold(ctx.GlobalUint64(FreezerThresholdFlag.Name))
 	return chain, chainDb
 }
 
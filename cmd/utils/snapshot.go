@@ -0,0 +1,193 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// snapshotVersion is the version of the state snapshot file format.
+const snapshotVersion = 1
+
+// snapshotHeader is the first item of a state snapshot file, followed by the
+// RLP encoded account records of the state, in account trie order.
+type snapshotHeader struct {
+	Version uint
+	Root    common.Hash
+	Block   []byte   // RLP encoded block the state belongs to, empty if unknown
+	Td      *big.Int // Total difficulty of the block, zero if unknown
+}
+
+// ExportState writes a flat dump of every account in the state, including its
+// code and storage, into a file. Accounts are streamed in the order of the
+// hashes of their addresses and are never held in memory. If the block the
+// state belongs to is known, it's stored alongside so that an import can make
+// it the head of the chain.
+func ExportState(statedb *state.StateDB, root common.Hash, block *types.Block, td *big.Int, fn string) error {
+	log.Info("Exporting state snapshot", "file", fn, "root", root)
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var (
+		writer io.Writer = fh
+		zipper *gzip.Writer
+	)
+	if strings.HasSuffix(fn, ".gz") {
+		zipper = gzip.NewWriter(writer)
+		writer = zipper
+	}
+	header := &snapshotHeader{Version: snapshotVersion, Root: root, Td: new(big.Int)}
+	if block != nil {
+		if header.Block, err = rlp.EncodeToBytes(block); err != nil {
+			return err
+		}
+		if td != nil {
+			header.Td = td
+		}
+	}
+	if err := rlp.Encode(writer, header); err != nil {
+		return err
+	}
+	var accounts uint64
+	err = statedb.ExportAccounts(func(account *state.AccountRecord) error {
+		if accounts++; accounts%100000 == 0 {
+			log.Info("Exporting state snapshot", "accounts", accounts, "address", account.Address)
+		}
+		return rlp.Encode(writer, account)
+	})
+	if err != nil {
+		return err
+	}
+	if zipper != nil {
+		if err := zipper.Close(); err != nil {
+			return err
+		}
+	}
+	log.Info("Exported state snapshot", "file", fn, "root", root, "accounts", accounts)
+	return nil
+}
+
+// ImportState rebuilds the state stored in a snapshot file into the database and
+// verifies its root. If the snapshot carries the block the state belongs to and
+// the block is ahead of the local chain, it is written out and marked as the
+// chain head, so the node can continue syncing from that point.
+func ImportState(db ethdb.Database, fn string) error {
+	log.Info("Importing state snapshot", "file", fn)
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	}
+	// Share a single buffered reader between the header and the account streams
+	buffer := bufio.NewReader(reader)
+
+	header := new(snapshotHeader)
+	if err := rlp.NewStream(buffer, 0).Decode(header); err != nil {
+		return fmt.Errorf("invalid snapshot header: %v", err)
+	}
+	if header.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", header.Version)
+	}
+	var block *types.Block
+	if len(header.Block) > 0 {
+		block = new(types.Block)
+		if err := rlp.DecodeBytes(header.Block, block); err != nil {
+			return fmt.Errorf("invalid snapshot block: %v", err)
+		}
+		if block.Root() != header.Root {
+			return fmt.Errorf("snapshot block #%d root mismatch: have %x, want %x", block.NumberU64(), block.Root(), header.Root)
+		}
+	}
+	root, accounts, err := ImportStateSnapshot(db, buffer)
+	if err != nil {
+		return err
+	}
+	if root != header.Root {
+		return fmt.Errorf("state root mismatch: have %x, want %x", root, header.Root)
+	}
+	log.Info("Imported state snapshot", "root", root, "accounts", accounts)
+
+	if block == nil {
+		log.Warn("State snapshot has no block attached, chain head unchanged")
+		return nil
+	}
+	return markSnapshotHead(db, block, header.Td)
+}
+
+// markSnapshotHead writes the block of an imported state snapshot into the
+// database and makes it the head of the canonical chain, unless the local chain
+// is already past it. The chain below the snapshot block is left empty, so the
+// block is recorded as the history base, disabling the ancient freezer and the
+// light server which both need the full history.
+func markSnapshotHead(db ethdb.Database, block *types.Block, td *big.Int) error {
+	if core.GetCanonicalHash(db, 0) == (common.Hash{}) {
+		return fmt.Errorf("database has no genesis block, initialize it first")
+	}
+	if head := core.GetHeadBlockHash(db); head != (common.Hash{}) {
+		if current := core.GetHeader(db, head, core.GetBlockNumber(db, head)); current != nil && current.Number.Uint64() >= block.NumberU64() {
+			log.Warn("Local chain ahead of snapshot, head unchanged", "local", current.Number, "snapshot", block.NumberU64())
+			return nil
+		}
+	}
+	if err := core.WriteHistoryBase(db, block.NumberU64()); err != nil {
+		return err
+	}
+	if err := core.WriteBlock(db, block); err != nil {
+		return err
+	}
+	if err := core.WriteTd(db, block.Hash(), block.NumberU64(), td); err != nil {
+		return err
+	}
+	if err := core.WriteCanonicalHash(db, block.Hash(), block.NumberU64()); err != nil {
+		return err
+	}
+	if err := core.WriteHeadHeaderHash(db, block.Hash()); err != nil {
+		return err
+	}
+	if err := core.WriteHeadFastBlockHash(db, block.Hash()); err != nil {
+		return err
+	}
+	if err := core.WriteHeadBlockHash(db, block.Hash()); err != nil {
+		return err
+	}
+	log.Info("Marked snapshot block as chain head", "number", block.NumberU64(), "hash", block.Hash())
+	return nil
+}
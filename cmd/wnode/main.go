@@ -72,6 +72,10 @@ var (
 	msPassword string
 )
 
+// countryCode is the country code embedded into the sender addresses printed
+// next to the messages.
+var countryCode = []int{0, 0, 0, 0, 156}
+
 // cmd arguments
 var (
 	bootstrapMode  = flag.Bool("standalone", false, "boostrap node: don't actively connect to peers, wait for incoming connections")
@@ -433,7 +437,7 @@ func sendLoop() {
 			// print your own message for convenience,
 			// because in asymmetric mode it is impossible to decrypt it
 			timestamp := time.Now().Unix()
-			from := crypto.PubkeyToAddress(asymKey.PublicKey)
+			from := crypto.PubkeyToAddress(asymKey.PublicKey, countryCode)
 			fmt.Printf("\n%d <%x>: %s\n", timestamp, from, s)
 		}
 	}
@@ -457,7 +461,7 @@ func sendFilesLoop() {
 				fmt.Printf(">>> Error: message was not sent \n")
 			} else {
 				timestamp := time.Now().Unix()
-				from := crypto.PubkeyToAddress(asymKey.PublicKey)
+				from := crypto.PubkeyToAddress(asymKey.PublicKey, countryCode)
 				fmt.Printf("\n%d <%x>: sent message with hash %x\n", timestamp, from, h)
 			}
 		}
@@ -552,7 +556,7 @@ func printMessageInfo(msg *whisper.ReceivedMessage) {
 
 	var address common.Address
 	if msg.Src != nil {
-		address = crypto.PubkeyToAddress(*msg.Src)
+		address = crypto.PubkeyToAddress(*msg.Src, countryCode)
 	}
 
 	if whisper.IsPubKeyEqual(msg.Src, &asymKey.PublicKey) {
@@ -568,7 +572,7 @@ func writeMessageToFile(dir string, msg *whisper.ReceivedMessage) {
 
 	var address common.Address
 	if msg.Src != nil {
-		address = crypto.PubkeyToAddress(*msg.Src)
+		address = crypto.PubkeyToAddress(*msg.Src, countryCode)
 	}
 
 	if whisper.IsPubKeyEqual(msg.Src, &asymKey.PublicKey) {
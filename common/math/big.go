@@ -104,6 +104,20 @@ func FirstBitSet(v *big.Int) int {
 	return v.BitLen()
 }
 
+// ParseDecimal parses s as a non-negative decimal number, like the ones the
+// state stores balances and coinage as, and returns it as an integer number of
+// units each worth 1/unit. Finer fractions are truncated, malformed or negative
+// numbers parse as zero. This is the exact value of a balance; the transaction
+// pools only count whole coins, see core.BalanceToWei.
+func ParseDecimal(s string, unit *big.Int) *big.Int {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || r.Sign() < 0 {
+		return new(big.Int)
+	}
+	r.Mul(r, new(big.Rat).SetInt(unit))
+	return new(big.Int).Quo(r.Num(), r.Denom())
+}
+
 // PaddedBigBytes encodes a big integer as a big-endian byte slice. The length
 // of the slice is at least n bytes.
 func PaddedBigBytes(bigint *big.Int, n int) []byte {
@@ -0,0 +1,692 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	maxBacklog      = 1024 // Maximum number of messages of future rounds and heights to keep
+	maxTimeoutShift = 6    // Maximum number of times the round timeout is doubled
+)
+
+// Chain is the blockchain the validators agree on blocks for. Proposals are
+// executed on top of it before being accepted.
+type Chain interface {
+	consensus.ChainReader
+
+	// CurrentBlock retrieves the head of the local chain.
+	CurrentBlock() *types.Block
+
+	// Validator returns the validator checking block bodies and resulting states.
+	Validator() core.Validator
+
+	// Processor returns the processor executing the transactions of blocks.
+	Processor() core.Processor
+
+	// StateAt returns the state database at the given root.
+	StateAt(root common.Hash) (*state.StateDB, error)
+}
+
+// Consensus states of a round.
+const (
+	stateAcceptRequest = iota // Waiting for the proposal of the round
+	statePreprepared          // Proposal accepted, gathering prepares
+	statePrepared             // Prepare quorum reached and proposal locked, gathering commits
+	stateCommitted            // Commit quorum reached, block final
+)
+
+var stateNames = []string{"acceptRequest", "preprepared", "prepared", "committed"}
+
+// sealRequest is a block handed over by the local miner, to be proposed once
+// it's our turn.
+type sealRequest struct {
+	proposal *types.Block
+	result   chan *types.Block
+}
+
+// RoundState is the progress of the validators in agreeing on the next block.
+type RoundState struct {
+	Sequence     uint64           `json:"sequence"`     // Number of the block being agreed on
+	Round        uint64           `json:"round"`        // Current attempt at agreeing on it
+	State        string           `json:"state"`        // Phase of the three-phase commit reached
+	Proposer     common.Address   `json:"proposer"`     // Validator proposing in the current round
+	Proposal     common.Hash      `json:"proposal"`     // Hash of the accepted proposal (zero if none)
+	Locked       common.Hash      `json:"locked"`       // Hash of the proposal locked on (zero if none)
+	Prepares     int              `json:"prepares"`     // Prepares received for the accepted proposal
+	Commits      int              `json:"commits"`      // Commits received for the accepted proposal
+	RoundChanges map[uint64]int   `json:"roundChanges"` // Round changes received by requested round
+	Quorum       int              `json:"quorum"`       // Number of validators needed to agree
+	Validators   []common.Address `json:"validators"`   // Validators of the block being agreed on
+}
+
+// agreement is the consensus state machine of the BFT engine. For every block
+// the proposer of the round sends a preprepare with its proposal, validators
+// accepting it broadcast a prepare, and once a quorum prepared, they lock on the
+// proposal and broadcast a commit carrying their committed seal. A quorum of
+// commits finalizes the block. If a round doesn't commit in time, validators
+// ask to change round, passing the turn to the next proposer.
+type agreement struct {
+	engine *BFT
+	chain  Chain
+	commit func(*types.Block) error // Imports blocks agreed on with another proposer
+
+	msgCh     chan *message
+	requestCh chan *sealRequest
+	timeoutCh chan view
+	headSub   *event.TypeMuxSubscription
+	quit      chan struct{}
+	wg        sync.WaitGroup
+
+	// Consensus state, only accessed from the loop goroutine
+	head      *types.Header                          // Parent of the block being agreed on
+	snap      *Snapshot                              // Validators of the block being agreed on
+	view      view                                   // Current height and round
+	state     int                                    // Phase of the current round
+	proposal  *types.Block                           // Proposal accepted in the current round
+	locked    *types.Block                           // Proposal a quorum prepared, the only one to accept
+	prepares  map[common.Address]*message            // Prepares of the current round by validator
+	commits   map[common.Address]*message            // Commits of the current round by validator
+	changes   map[uint64]map[common.Address]*message // Round changes of later rounds by validator
+	desired   uint64                                 // Round we asked to change to (0 = none)
+	pending   *sealRequest                           // Local proposal waiting for our turn
+	backlog   []*message                             // Messages of future rounds and heights
+	timer     *time.Timer                            // Timer of the current round
+	timerView view                                   // Round the timer belongs to
+
+	status     *RoundState      // Round state published for the API
+	validators []common.Address // Validators published for checking relayed messages
+	statusLock sync.RWMutex
+}
+
+// newAgreement creates and starts the consensus state machine on top of the
+// current head of the chain.
+func newAgreement(engine *BFT, chain Chain, mux *event.TypeMux, commit func(*types.Block) error) *agreement {
+	a := &agreement{
+		engine:    engine,
+		chain:     chain,
+		commit:    commit,
+		msgCh:     make(chan *message, 256),
+		requestCh: make(chan *sealRequest),
+		timeoutCh: make(chan view),
+		headSub:   mux.Subscribe(core.ChainHeadEvent{}),
+		quit:      make(chan struct{}),
+	}
+	a.wg.Add(1)
+	go a.loop()
+	return a
+}
+
+// stop terminates the state machine and waits for it to exit.
+func (a *agreement) stop() {
+	a.headSub.Unsubscribe()
+	close(a.quit)
+	a.wg.Wait()
+}
+
+// request hands a block over to be proposed once it's our turn. The returned
+// channel receives the committed block, or nil if another block was agreed on.
+func (a *agreement) request(proposal *types.Block) (<-chan *types.Block, error) {
+	req := &sealRequest{proposal: proposal, result: make(chan *types.Block, 1)}
+	select {
+	case a.requestCh <- req:
+		return req.result, nil
+	case <-a.quit:
+		return nil, errNotStarted
+	}
+}
+
+// deliver hands a consensus message received from the network over.
+func (a *agreement) deliver(msg *message) {
+	select {
+	case a.msgCh <- msg:
+	case <-a.quit:
+	}
+}
+
+// currentValidators returns the validators of the block being agreed on.
+func (a *agreement) currentValidators() []common.Address {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+
+	return a.validators
+}
+
+// roundState returns the progress in agreeing on the next block.
+func (a *agreement) roundState() *RoundState {
+	a.statusLock.RLock()
+	defer a.statusLock.RUnlock()
+
+	return a.status
+}
+
+// loop is the event loop of the state machine, processing all the messages,
+// proposals, timeouts and new chain heads one at a time.
+func (a *agreement) loop() {
+	defer a.wg.Done()
+
+	a.startSequence(a.chain.CurrentBlock().Header())
+	for {
+		select {
+		case ev, ok := <-a.headSub.Chan():
+			if !ok {
+				return
+			}
+			// A block was imported, move on to agreeing on the next one
+			if head, ok := ev.Data.(core.ChainHeadEvent); ok {
+				if a.snap == nil || head.Block.NumberU64() >= a.view.Sequence {
+					a.startSequence(head.Block.Header())
+				}
+			}
+
+		case req := <-a.requestCh:
+			a.handleRequest(req)
+
+		case msg := <-a.msgCh:
+			a.handleMessage(msg)
+
+		case v := <-a.timeoutCh:
+			if v == a.timerView {
+				a.handleTimeout()
+			}
+
+		case <-a.quit:
+			if a.timer != nil {
+				a.timer.Stop()
+			}
+			if a.pending != nil {
+				a.pending.result <- nil
+			}
+			return
+		}
+	}
+}
+
+// startSequence starts agreeing on the block following the given head.
+func (a *agreement) startSequence(head *types.Header) {
+	snap, err := a.engine.snapshot(a.chain, head.Number.Uint64(), head.Hash(), nil)
+	if err != nil {
+		log.Error("Failed to retrieve validator snapshot", "number", head.Number, "hash", head.Hash(), "err", err)
+		return
+	}
+	a.head, a.snap = head, snap
+	a.view = view{Sequence: head.Number.Uint64() + 1}
+	a.locked = nil
+	a.changes = make(map[uint64]map[common.Address]*message)
+	a.desired = 0
+
+	// Drop the local proposal if it was built on another head
+	if a.pending != nil && (a.pending.proposal.NumberU64() != a.view.Sequence || a.pending.proposal.ParentHash() != head.Hash()) {
+		a.pending.result <- nil
+		a.pending = nil
+	}
+	a.statusLock.Lock()
+	a.validators = snap.validators()
+	a.statusLock.Unlock()
+
+	a.startRound(0, nil)
+}
+
+// startRound starts a new attempt at agreeing on the current block. The hint is
+// a proposal prepared in an earlier round, to be proposed again if we're not
+// locked on one ourselves.
+func (a *agreement) startRound(round uint64, hint *types.Block) {
+	a.view.Round = round
+	if a.desired <= round {
+		a.desired = 0
+	}
+	a.state = stateAcceptRequest
+	a.proposal = nil
+	a.prepares = make(map[common.Address]*message)
+	a.commits = make(map[common.Address]*message)
+	for r := range a.changes {
+		if r <= round {
+			delete(a.changes, r)
+		}
+	}
+	a.resetTimer(round)
+
+	log.Debug("Starting consensus round", "number", a.view.Sequence, "round", round, "proposer", a.snap.proposer(a.view.Sequence, round))
+	a.propose(hint)
+	a.replayBacklog()
+	a.publish()
+}
+
+// resetTimer (re)starts the timer of a round. Later rounds wait longer, to give
+// slow validators a chance to catch up.
+func (a *agreement) resetTimer(round uint64) {
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+	shift := round
+	if shift > maxTimeoutShift {
+		shift = maxTimeoutShift
+	}
+	timeout := time.Duration(a.engine.config.Period)*time.Second + time.Duration(a.engine.config.RequestTimeout)*time.Millisecond
+	timeout <<= shift
+
+	v := view{Sequence: a.view.Sequence, Round: round}
+	a.timerView = v
+	a.timer = time.AfterFunc(timeout, func() {
+		select {
+		case a.timeoutCh <- v:
+		case <-a.quit:
+		}
+	})
+}
+
+// signer returns the local validator address.
+func (a *agreement) signer() common.Address {
+	a.engine.lock.RLock()
+	defer a.engine.lock.RUnlock()
+
+	return a.engine.signer
+}
+
+// isValidator returns whether the local key validates the current block.
+func (a *agreement) isValidator() bool {
+	_, ok := a.snap.Validators[a.signer()]
+	return ok
+}
+
+// isProposer returns whether it's our turn to propose in the current round.
+func (a *agreement) isProposer() bool {
+	return a.isValidator() && a.snap.proposer(a.view.Sequence, a.view.Round) == a.signer()
+}
+
+// broadcast signs a message with the local validator key, gossips it to the
+// network and processes it locally.
+func (a *agreement) broadcast(msg *message) {
+	if !a.isValidator() {
+		return
+	}
+	if err := msg.sign(a.engine); err != nil {
+		log.Warn("Failed to sign consensus message", "err", err)
+		return
+	}
+	a.engine.gossip(msg)
+	a.handleMessage(msg)
+}
+
+// propose sends the preprepare of the current round if it's our turn, with the
+// locked proposal if any, or else the hinted or the local one.
+func (a *agreement) propose(hint *types.Block) {
+	if a.state != stateAcceptRequest || !a.isProposer() {
+		return
+	}
+	block := a.locked
+	if block == nil && hint != nil && hint.NumberU64() == a.view.Sequence && hint.ParentHash() == a.head.Hash() {
+		block = hint
+	}
+	if block == nil && a.pending != nil {
+		block = a.pending.proposal
+	}
+	if block == nil {
+		return
+	}
+	payload, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		log.Error("Failed to encode proposal", "err", err)
+		return
+	}
+	log.Debug("Proposing block", "number", block.Number(), "hash", block.Hash(), "round", a.view.Round)
+	a.broadcast(&message{Code: msgPreprepare, View: a.view, Digest: block.Hash(), Payload: payload})
+}
+
+// handleRequest takes over a block from the local miner, proposing it right away
+// if it's our turn.
+func (a *agreement) handleRequest(req *sealRequest) {
+	if a.snap == nil || req.proposal.NumberU64() != a.view.Sequence || req.proposal.ParentHash() != a.head.Hash() {
+		req.result <- nil
+		return
+	}
+	if a.pending != nil {
+		a.pending.result <- nil
+	}
+	a.pending = req
+	a.propose(nil)
+}
+
+// handleMessage dispatches a consensus message, keeping the ones of future
+// rounds and heights for later.
+func (a *agreement) handleMessage(msg *message) {
+	if a.snap == nil {
+		return
+	}
+	switch {
+	case msg.View.Sequence < a.view.Sequence:
+		return
+	case msg.View.Sequence > a.view.Sequence:
+		a.store(msg)
+		return
+	}
+	sender, err := msg.recover(a.snap.validators())
+	if err != nil {
+		log.Trace("Discarding consensus message", "number", msg.View.Sequence, "round", msg.View.Round, "err", err)
+		return
+	}
+	msg.sender = sender
+
+	if msg.Code == msgRoundChange {
+		a.handleRoundChange(msg)
+		a.publish()
+		return
+	}
+	switch {
+	case msg.View.Round < a.view.Round:
+		return
+	case msg.View.Round > a.view.Round:
+		a.store(msg)
+		return
+	}
+	switch msg.Code {
+	case msgPreprepare:
+		a.handlePreprepare(msg)
+	case msgPrepare:
+		a.handlePrepare(msg)
+	case msgCommit:
+		a.handleCommit(msg)
+	}
+	a.publish()
+}
+
+// store keeps a message of a future round or height for later.
+func (a *agreement) store(msg *message) {
+	if len(a.backlog) >= maxBacklog {
+		a.backlog = a.backlog[1:]
+	}
+	a.backlog = append(a.backlog, msg)
+}
+
+// replayBacklog processes the kept messages that became current.
+func (a *agreement) replayBacklog() {
+	backlog := a.backlog
+	a.backlog = nil
+
+	for _, msg := range backlog {
+		switch {
+		case msg.View.Sequence < a.view.Sequence:
+			continue
+		case msg.View.Sequence > a.view.Sequence, msg.Code != msgRoundChange && msg.View.Round > a.view.Round:
+			a.backlog = append(a.backlog, msg)
+		default:
+			a.handleMessage(msg)
+		}
+	}
+}
+
+// handlePreprepare accepts the proposal of the current round if it's valid and
+// doesn't conflict with the locked one, and prepares it.
+func (a *agreement) handlePreprepare(msg *message) {
+	if a.state != stateAcceptRequest {
+		return
+	}
+	if proposer := a.snap.proposer(a.view.Sequence, a.view.Round); msg.sender != proposer {
+		log.Debug("Discarding preprepare of non-proposer", "number", a.view.Sequence, "round", a.view.Round, "sender", msg.sender, "proposer", proposer)
+		return
+	}
+	block, err := msg.proposal()
+	if err != nil || block.Hash() != msg.Digest {
+		log.Debug("Discarding malformed proposal", "sender", msg.sender, "err", err)
+		return
+	}
+	if block.NumberU64() != a.view.Sequence || block.ParentHash() != a.head.Hash() {
+		log.Debug("Discarding proposal for another parent", "number", block.Number(), "parent", block.ParentHash())
+		return
+	}
+	if a.locked != nil && a.locked.Hash() != block.Hash() {
+		log.Debug("Rejecting proposal, locked on another", "hash", block.Hash(), "locked", a.locked.Hash())
+		return
+	}
+	if err := a.verify(block); err != nil {
+		log.Warn("Rejecting invalid proposal", "number", block.Number(), "hash", block.Hash(), "proposer", msg.sender, "err", err)
+		return
+	}
+	a.proposal = block
+	a.state = statePreprepared
+
+	a.broadcast(&message{Code: msgPrepare, View: a.view, Digest: block.Hash()})
+	a.checkPrepared()
+	a.checkCommitted()
+}
+
+// verify checks a proposal in full, executing its transactions on top of the
+// chain.
+func (a *agreement) verify(block *types.Block) error {
+	if err := a.engine.verifyHeader(a.chain, block.Header(), nil, false); err != nil {
+		return err
+	}
+	if err := a.chain.Validator().ValidateBody(block); err != nil {
+		return err
+	}
+	parent := a.chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	statedb, err := a.chain.StateAt(parent.Root())
+	if err != nil {
+		return err
+	}
+	receipts, _, usedGas, err := a.chain.Processor().Process(block, statedb, vm.Config{})
+	if err != nil {
+		return err
+	}
+	return a.chain.Validator().ValidateState(block, parent, statedb, receipts, usedGas)
+}
+
+// handlePrepare counts a prepare of the current round.
+func (a *agreement) handlePrepare(msg *message) {
+	a.prepares[msg.sender] = msg
+	a.checkPrepared()
+}
+
+// checkPrepared locks on the accepted proposal and commits it once a quorum of
+// validators prepared it.
+func (a *agreement) checkPrepared() {
+	if a.state != statePreprepared || count(a.prepares, a.proposal.Hash()) < a.snap.quorum() {
+		return
+	}
+	a.locked = a.proposal
+	a.state = statePrepared
+
+	if !a.isValidator() {
+		return
+	}
+	seal, err := a.engine.sign(commitHash(a.proposal.Hash()))
+	if err != nil {
+		log.Warn("Failed to sign committed seal", "err", err)
+		return
+	}
+	a.broadcast(&message{Code: msgCommit, View: a.view, Digest: a.proposal.Hash(), CommittedSeal: seal})
+}
+
+// handleCommit counts a commit of the current round.
+func (a *agreement) handleCommit(msg *message) {
+	if _, err := recoverValidator(commitHash(msg.Digest), msg.CommittedSeal, []common.Address{msg.sender}); err != nil {
+		log.Debug("Discarding commit with invalid seal", "sender", msg.sender, "err", err)
+		return
+	}
+	a.commits[msg.sender] = msg
+	a.checkCommitted()
+}
+
+// checkCommitted finalizes the accepted proposal once a quorum of validators
+// committed it, returning it to the local miner if we proposed it or importing
+// it otherwise.
+func (a *agreement) checkCommitted() {
+	if a.proposal == nil || a.state == stateCommitted {
+		return
+	}
+	digest := a.proposal.Hash()
+	if count(a.commits, digest) < a.snap.quorum() {
+		return
+	}
+	// Gather the committed seals in validator order
+	var seals [][]byte
+	for _, validator := range a.snap.validators() {
+		if msg, ok := a.commits[validator]; ok && msg.Digest == digest {
+			seals = append(seals, msg.CommittedSeal)
+		}
+	}
+	header := a.proposal.Header()
+	extra, err := types.ExtractBFTExtra(header)
+	if err != nil {
+		log.Error("Failed to decode proposal extra-data", "err", err)
+		return
+	}
+	extra.CommittedSeal = seals
+	if err := types.SetBFTExtra(header, extra); err != nil {
+		log.Error("Failed to encode committed seals", "err", err)
+		return
+	}
+	block := a.proposal.WithSeal(header)
+
+	a.state = stateCommitted
+	a.timer.Stop()
+
+	log.Info("Committed new block", "number", block.Number(), "hash", block.Hash(), "round", a.view.Round, "seals", len(seals))
+	if a.pending != nil && a.pending.proposal.Hash() == block.Hash() {
+		a.pending.result <- block
+	} else {
+		if a.pending != nil {
+			a.pending.result <- nil
+		}
+		go func() {
+			if err := a.commit(block); err != nil {
+				log.Warn("Failed to import committed block", "number", block.Number(), "hash", block.Hash(), "err", err)
+			}
+		}()
+	}
+	a.pending = nil
+}
+
+// handleRoundChange counts a request to move to a later round. Once enough
+// validators ask for a round for one of them to be honest, we ask for it too,
+// and once a quorum asks for it, it starts.
+func (a *agreement) handleRoundChange(msg *message) {
+	round := msg.View.Round
+	if a.state == stateCommitted || round <= a.view.Round {
+		return
+	}
+	if a.changes[round] == nil {
+		a.changes[round] = make(map[common.Address]*message)
+	}
+	a.changes[round][msg.sender] = msg
+
+	if len(a.changes[round]) > a.snap.faulty() && round > a.desired {
+		a.desired = round
+		a.resetTimer(round)
+		a.sendRoundChange(round)
+	}
+	if len(a.changes[round]) >= a.snap.quorum() && round > a.view.Round {
+		// Carry over a proposal prepared in an earlier round, if any
+		var hint *types.Block
+		for _, change := range a.changes[round] {
+			if len(change.Payload) > 0 {
+				if block, err := change.proposal(); err == nil && block.Hash() == change.Digest {
+					hint = block
+					break
+				}
+			}
+		}
+		a.startRound(round, hint)
+	}
+}
+
+// sendRoundChange asks the validators to move to the given round, passing on
+// the proposal we're locked on.
+func (a *agreement) sendRoundChange(round uint64) {
+	msg := &message{Code: msgRoundChange, View: view{Sequence: a.view.Sequence, Round: round}}
+	if a.locked != nil {
+		payload, err := rlp.EncodeToBytes(a.locked)
+		if err != nil {
+			log.Error("Failed to encode locked proposal", "err", err)
+			return
+		}
+		msg.Digest, msg.Payload = a.locked.Hash(), payload
+	}
+	a.broadcast(msg)
+}
+
+// handleTimeout asks to move to the next round if the current one didn't commit
+// in time.
+func (a *agreement) handleTimeout() {
+	if a.state == stateCommitted {
+		return
+	}
+	round := a.view.Round
+	if a.desired > round {
+		round = a.desired
+	}
+	round++
+
+	log.Debug("Consensus round timed out", "number", a.view.Sequence, "round", a.view.Round, "next", round)
+	a.desired = round
+	a.resetTimer(round)
+	a.sendRoundChange(round)
+	a.publish()
+}
+
+// publish updates the round state reported through the API.
+func (a *agreement) publish() {
+	status := &RoundState{
+		Sequence:     a.view.Sequence,
+		Round:        a.view.Round,
+		State:        stateNames[a.state],
+		Proposer:     a.snap.proposer(a.view.Sequence, a.view.Round),
+		RoundChanges: make(map[uint64]int),
+		Quorum:       a.snap.quorum(),
+		Validators:   a.snap.validators(),
+	}
+	if a.proposal != nil {
+		status.Proposal = a.proposal.Hash()
+		status.Prepares = count(a.prepares, status.Proposal)
+		status.Commits = count(a.commits, status.Proposal)
+	}
+	if a.locked != nil {
+		status.Locked = a.locked.Hash()
+	}
+	for round, changes := range a.changes {
+		status.RoundChanges[round] = len(changes)
+	}
+	a.statusLock.Lock()
+	a.status = status
+	a.statusLock.Unlock()
+}
+
+// count returns the number of messages about the given proposal.
+func count(msgs map[common.Address]*message, digest common.Hash) int {
+	n := 0
+	for _, msg := range msgs {
+		if msg.Digest == digest {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Tests that a full set of validators agrees on blocks through the three-phase
+// commit, every block being proposed in turn and committed by a quorum.
+func TestThreePhaseCommit(t *testing.T) {
+	net := newTestNetwork(t, 4, 200)
+	defer net.stop()
+
+	net.start(0, 1, 2, 3)
+	net.waitHeight(3, 20*time.Second, 0, 1, 2, 3)
+
+	for number := uint64(1); number <= 3; number++ {
+		block := net.nodes[0].chain.GetBlockByNumber(number)
+		for i, node := range net.nodes[1:] {
+			if hash := node.chain.GetBlockByNumber(number).Hash(); hash != block.Hash() {
+				t.Fatalf("block %d: node %d hash mismatch: have %x, want %x", number, i+1, hash, block.Hash())
+			}
+		}
+		// Without round changes the validators propose in turn
+		if want := net.nodes[number%4].address; block.Coinbase() != want {
+			t.Errorf("block %d: proposer mismatch: have %x, want %x", number, block.Coinbase(), want)
+		}
+		extra, err := types.ExtractBFTExtra(block.Header())
+		if err != nil {
+			t.Fatalf("block %d: failed to decode extra-data: %v", number, err)
+		}
+		if len(extra.CommittedSeal) < 3 {
+			t.Errorf("block %d: committed seals mismatch: have %d, want at least 3", number, len(extra.CommittedSeal))
+		}
+		if err := net.nodes[0].engine.VerifySeal(net.nodes[0].chain, block.Header()); err != nil {
+			t.Errorf("block %d: invalid seal: %v", number, err)
+		}
+	}
+}
+
+// Tests that if the proposer of a round is offline, the other validators time
+// out, agree on changing round, and commit the block of the next proposer.
+func TestRoundChange(t *testing.T) {
+	net := newTestNetwork(t, 4, 200)
+	defer net.stop()
+
+	// The proposer of the first block in round zero never shows up
+	net.start(0, 2, 3)
+	net.waitHeight(1, 20*time.Second, 0, 2, 3)
+
+	block := net.nodes[0].chain.GetBlockByNumber(1)
+	if block.Coinbase() == net.nodes[1].address {
+		t.Fatalf("block proposed by the offline validator")
+	}
+	if want := net.nodes[2].address; block.Coinbase() != want {
+		t.Errorf("proposer mismatch: have %x, want %x", block.Coinbase(), want)
+	}
+	for _, i := range []int{2, 3} {
+		if hash := net.nodes[i].chain.GetBlockByNumber(1).Hash(); hash != block.Hash() {
+			t.Fatalf("node %d hash mismatch: have %x, want %x", i, hash, block.Hash())
+		}
+	}
+	// A quorum still commits without the offline validator
+	if err := net.nodes[0].engine.VerifySeal(net.nodes[0].chain, block.Header()); err != nil {
+		t.Errorf("invalid seal: %v", err)
+	}
+}
+
+// Tests that the hash of a block doesn't cover the committed seals, which are
+// only gathered after the validators agreed on that very hash, while it does
+// cover the seal of the proposer.
+func TestHeaderHashExcludesCommittedSeals(t *testing.T) {
+	net := newTestNetwork(t, 4, 200)
+	defer net.stop()
+
+	net.start(0, 1, 2, 3)
+	net.waitHeight(1, 20*time.Second, 0)
+
+	header := net.nodes[0].chain.GetHeaderByNumber(1)
+	extra, err := types.ExtractBFTExtra(header)
+	if err != nil {
+		t.Fatalf("failed to decode extra-data: %v", err)
+	}
+	// Dropping or replacing committed seals must keep the hash
+	for _, seals := range [][][]byte{{}, extra.CommittedSeal[:1], {make([]byte, 65)}} {
+		cpy := types.CopyHeader(header)
+		modified := *extra
+		modified.CommittedSeal = seals
+		if err := types.SetBFTExtra(cpy, &modified); err != nil {
+			t.Fatalf("failed to encode extra-data: %v", err)
+		}
+		if cpy.Hash() != header.Hash() {
+			t.Errorf("hash changed with %d committed seals: have %x, want %x", len(seals), cpy.Hash(), header.Hash())
+		}
+	}
+	// Changing the proposer seal must change the hash
+	cpy := types.CopyHeader(header)
+	modified := *extra
+	modified.Seal = make([]byte, 65)
+	if err := types.SetBFTExtra(cpy, &modified); err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	if cpy.Hash() == header.Hash() {
+		t.Errorf("hash unchanged with a different proposer seal")
+	}
+	// Headers of other engines hash everything
+	cpy = types.CopyHeader(header)
+	cpy.MixDigest[0]++
+	plain := types.CopyHeader(cpy)
+	modified = *extra
+	modified.CommittedSeal = nil
+	if err := types.SetBFTExtra(plain, &modified); err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	if plain.Hash() == cpy.Hash() {
+		t.Errorf("committed seals excluded from the hash of a non-BFT header")
+	}
+}
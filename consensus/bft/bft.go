@@ -0,0 +1,715 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bft implements a byzantine fault tolerant consensus engine with
+// instant finality. Every block is agreed on by a set of validators through a
+// three-phase commit before it is added to the chain, so blocks are never
+// reverted once imported.
+package bft
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	checkpointInterval = 1024 // Number of blocks after which to save the vote snapshot to the database
+	inmemorySnapshots  = 128  // Number of recent vote snapshots to keep in memory
+	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
+	inmemoryMessages   = 4096 // Number of recent consensus messages to remember for gossiping
+)
+
+// BFT protocol constants.
+var (
+	epochLength    = uint64(30000) // Default number of blocks after which to checkpoint and reset the pending votes
+	blockPeriod    = uint64(1)     // Default minimum difference between two consecutive block's timestamps
+	requestTimeout = uint64(10000) // Default milliseconds to wait for a round to commit before changing it
+
+	countryCodeLength = common.AddressLength - 20 // Length of the country code prefixing ofbank addresses
+
+	uncleHash = types.CalcUncleHash(nil) // Always Keccak256(RLP([])) as uncles are meaningless outside of PoW.
+
+	defaultDifficulty = big.NewInt(1) // Block difficulty, all committed blocks are equally final
+)
+
+// Various error messages to mark blocks invalid. These should be private to
+// prevent engine specific errors from being referenced in the remainder of the
+// codebase, inherently breaking if the engine is swapped out. Please put common
+// error types into the consensus package.
+var (
+	// errUnknownBlock is returned when the list of validators is requested for a
+	// block that is not part of the local blockchain.
+	errUnknownBlock = errors.New("unknown block")
+
+	// errInvalidMixDigest is returned if a block's mix digest isn't the BFT digest.
+	errInvalidMixDigest = errors.New("invalid bft mix digest")
+
+	// errInvalidNonce is returned if a block's nonce is non-zero.
+	errInvalidNonce = errors.New("non-zero nonce")
+
+	// errInvalidUncleHash is returned if a block contains an non-empty uncle list.
+	errInvalidUncleHash = errors.New("non empty uncle hash")
+
+	// errInvalidDifficulty is returned if the difficulty of a block is not 1.
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// ErrInvalidTimestamp is returned if the timestamp of a block is lower than
+	// the previous block's timestamp + the minimum block period.
+	ErrInvalidTimestamp = errors.New("invalid timestamp")
+
+	// errInvalidVote is returned if a block casts more than one validator vote.
+	errInvalidVote = errors.New("more than one validator vote")
+
+	// errInvalidCheckpointVote is returned if a checkpoint/epoch transition block
+	// casts a validator vote.
+	errInvalidCheckpointVote = errors.New("validator vote in checkpoint block")
+
+	// errInvalidValidators is returned if a block records a validator set other
+	// than the one resulting from the votes up to it.
+	errInvalidValidators = errors.New("invalid validator set")
+
+	// errInvalidVotingChain is returned if a validator set is attempted to be
+	// modified via out-of-range or non-contiguous headers.
+	errInvalidVotingChain = errors.New("invalid voting chain")
+
+	// errUnauthorized is returned if a header or message is signed by an entity
+	// that isn't a validator.
+	errUnauthorized = errors.New("unauthorized")
+
+	// errInvalidMinerAgents is returned if a block's first miner agent isn't the
+	// proposer.
+	errInvalidMinerAgents = errors.New("invalid miner agents")
+
+	// errInsufficientCommittedSeals is returned if a block isn't committed by a
+	// quorum of the validators.
+	errInsufficientCommittedSeals = errors.New("insufficient committed seals")
+
+	// errInvalidCommittedSeals is returned if a committed seal isn't signed by a
+	// validator, or by the same validator twice.
+	errInvalidCommittedSeals = errors.New("invalid committed seals")
+
+	// errNotStarted is returned if a block is sealed while the consensus state
+	// machine isn't running.
+	errNotStarted = errors.New("bft consensus not started")
+)
+
+// SignerFn is a signer callback function to request a hash to be signed by a
+// backing account.
+type SignerFn func(accounts.Account, []byte) ([]byte, error)
+
+// sigHash returns the hash which is used as input for the proposer signature. It
+// is the hash of the header without any of the seals in the extra-data.
+func sigHash(header *types.Header) (hash common.Hash, err error) {
+	filtered := types.BFTFilteredHeader(header, false)
+	if filtered == nil {
+		return common.Hash{}, types.ErrInvalidBFTHeaderExtra
+	}
+	hasher := sha3.NewKeccak256()
+	rlp.Encode(hasher, filtered)
+	hasher.Sum(hash[:0])
+	return hash, nil
+}
+
+// commitHash returns the hash a validator signs to commit a proposal.
+func commitHash(digest common.Hash) []byte {
+	return crypto.Keccak256(digest[:], []byte{byte(msgCommit)})
+}
+
+// ecrecover extracts the address of the proposer from a signed header. The
+// signature only yields the key hash, the country code is taken from the
+// coinbase, which has to be the proposer itself.
+func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
+	// If the signature's already cached, return that
+	hash := header.Hash()
+	if address, known := sigcache.Get(hash); known {
+		return address.(common.Address), nil
+	}
+	extra, err := types.ExtractBFTExtra(header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	sighash, err := sigHash(header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	pubkey, err := crypto.Ecrecover(sighash.Bytes(), extra.Seal)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var proposer common.Address
+	copy(proposer[:countryCodeLength], header.Coinbase[:countryCodeLength])
+	copy(proposer[countryCodeLength:], crypto.Keccak256(pubkey[1:])[12:])
+
+	sigcache.Add(hash, proposer)
+	return proposer, nil
+}
+
+// recoverValidator resolves the validator that signed a hash. The signature only
+// yields the key hash of an ofbank address, so it's looked up among the given
+// validators.
+func recoverValidator(hash []byte, sig []byte, validators []common.Address) (common.Address, error) {
+	pubkey, err := crypto.Ecrecover(hash, sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	keyHash := crypto.Keccak256(pubkey[1:])[12:]
+	for _, validator := range validators {
+		if bytes.Equal(validator[countryCodeLength:], keyHash) {
+			return validator, nil
+		}
+	}
+	return common.Address{}, errUnauthorized
+}
+
+// BFT is the byzantine fault tolerant consensus engine, agreeing on each block
+// through a three-phase commit among a set of validators kept in the header
+// extra-data.
+type BFT struct {
+	config *params.BFTConfig // Consensus engine configuration parameters
+	db     ethdb.Database    // Database to store and retrieve snapshot checkpoints
+
+	recents    *lru.ARCCache // Snapshots for recent block to speed up reorgs
+	signatures *lru.ARCCache // Signatures of recent blocks to speed up mining
+	messages   *lru.ARCCache // Hashes of recent consensus messages to avoid gossiping them twice
+
+	proposals map[common.Address]bool // Current list of proposals we are pushing
+
+	signer common.Address // Ethereum address of the signing key
+	signFn SignerFn       // Signer function to authorize hashes with
+	lock   sync.RWMutex   // Protects the signer and proposals fields
+
+	peers     *peerSet   // Peers running the consensus sub-protocol
+	agreement *agreement // Consensus state machine, nil if not started
+	agreeLock sync.RWMutex
+}
+
+// New creates a BFT consensus engine with the initial validators set to the
+// ones in the genesis extra-data.
+func New(config *params.BFTConfig, db ethdb.Database) *BFT {
+	// Set any missing consensus parameters to their defaults
+	conf := *config
+	if conf.Epoch == 0 {
+		conf.Epoch = epochLength
+	}
+	if conf.Period == 0 {
+		conf.Period = blockPeriod
+	}
+	if conf.RequestTimeout == 0 {
+		conf.RequestTimeout = requestTimeout
+	}
+	// Allocate the snapshot caches and create the engine
+	recents, _ := lru.NewARC(inmemorySnapshots)
+	signatures, _ := lru.NewARC(inmemorySignatures)
+	messages, _ := lru.NewARC(inmemoryMessages)
+
+	return &BFT{
+		config:     &conf,
+		db:         db,
+		recents:    recents,
+		signatures: signatures,
+		messages:   messages,
+		proposals:  make(map[common.Address]bool),
+		peers:      newPeerSet(),
+	}
+}
+
+// Author implements consensus.Engine, returning the address of the validator
+// that proposed the block.
+func (b *BFT) Author(header *types.Header) (common.Address, error) {
+	return ecrecover(header, b.signatures)
+}
+
+// VerifyHeader checks whether a header conforms to the consensus rules.
+func (b *BFT) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool) error {
+	return b.verifyHeader(chain, header, nil, true)
+}
+
+// VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers. The
+// method returns a quit channel to abort the operations and a results channel to
+// retrieve the async verifications (the order is that of the input slice).
+func (b *BFT) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+
+	go func() {
+		for i, header := range headers {
+			err := b.verifyHeader(chain, header, headers[:i], true)
+
+			select {
+			case <-abort:
+				return
+			case results <- err:
+			}
+		}
+	}()
+	return abort, results
+}
+
+// verifyHeader checks whether a header conforms to the consensus rules. The
+// caller may optionally pass in a batch of parents (ascending order) to avoid
+// looking those up from the database. Proposals being agreed on are verified
+// with committed unset, as they don't carry committed seals yet.
+func (b *BFT) verifyHeader(chain consensus.ChainReader, header *types.Header, parents []*types.Header, committed bool) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	number := header.Number.Uint64()
+
+	// Don't waste time checking blocks from the future
+	if header.Time.Cmp(big.NewInt(time.Now().Unix())) > 0 {
+		return consensus.ErrFutureBlock
+	}
+	// Ensure that the extra-data holds the consensus fields
+	extra, err := types.ExtractBFTExtra(header)
+	if err != nil {
+		return types.ErrInvalidBFTHeaderExtra
+	}
+	if number > 0 {
+		// Ensure that the header is marked as having its committed seals unhashed
+		if header.MixDigest != types.BFTDigest {
+			return errInvalidMixDigest
+		}
+		if header.Nonce != (types.BlockNonce{}) {
+			return errInvalidNonce
+		}
+		// Ensure that the block doesn't contain any uncles which are meaningless in BFT
+		if header.UncleHash != uncleHash {
+			return errInvalidUncleHash
+		}
+		if header.Difficulty == nil || header.Difficulty.Cmp(defaultDifficulty) != 0 {
+			return errInvalidDifficulty
+		}
+		// Ensure that the block names its proposer as the reward recipient
+		if len(header.MinerAgents) == 0 || header.MinerAgents[0].Minerbase != header.Coinbase {
+			return errInvalidMinerAgents
+		}
+		// Validator votes are one per block and never on checkpoints
+		if len(extra.Votes) > 1 {
+			return errInvalidVote
+		}
+		if number%b.config.Epoch == 0 && len(extra.Votes) > 0 {
+			return errInvalidCheckpointVote
+		}
+	}
+	// All basic checks passed, verify cascading fields
+	return b.verifyCascadingFields(chain, header, parents, committed)
+}
+
+// verifyCascadingFields verifies all the header fields that are not standalone,
+// rather depend on a batch of previous headers. The caller may optionally pass
+// in a batch of parents (ascending order) to avoid looking those up from the
+// database. This is useful for concurrently verifying a batch of new headers.
+func (b *BFT) verifyCascadingFields(chain consensus.ChainReader, header *types.Header, parents []*types.Header, committed bool) error {
+	// The genesis block is the always valid dead-end
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil
+	}
+	// Ensure that the block's timestamp isn't too close to it's parent
+	var parent *types.Header
+	if len(parents) > 0 {
+		parent = parents[len(parents)-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, number-1)
+	}
+	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
+		return consensus.ErrUnknownAncestor
+	}
+	if parent.Time.Uint64()+b.config.Period > header.Time.Uint64() {
+		return ErrInvalidTimestamp
+	}
+	// Retrieve the snapshot needed to verify this header and cache it
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, parents)
+	if err != nil {
+		return err
+	}
+	// Ensure the proposer is a validator and the recorded validator set is right
+	if _, err := snap.apply([]*types.Header{header}); err != nil {
+		return err
+	}
+	// All basic checks passed, verify the seals and return
+	return b.verifySeals(snap, header, committed)
+}
+
+// snapshot retrieves the validator snapshot at a given point in time.
+func (b *BFT) snapshot(chain consensus.ChainReader, number uint64, hash common.Hash, parents []*types.Header) (*Snapshot, error) {
+	// Search for a snapshot in memory or on disk for checkpoints
+	var (
+		headers []*types.Header
+		snap    *Snapshot
+	)
+	for snap == nil {
+		// If an in-memory snapshot was found, use that
+		if s, ok := b.recents.Get(hash); ok {
+			snap = s.(*Snapshot)
+			break
+		}
+		// If an on-disk checkpoint snapshot can be found, use that
+		if number%checkpointInterval == 0 {
+			if s, err := loadSnapshot(b.config, b.signatures, b.db, hash); err == nil {
+				log.Trace("Loaded validator snapshot form disk", "number", number, "hash", hash)
+				snap = s
+				break
+			}
+		}
+		// If we're at block zero, make a snapshot of the genesis validators
+		if number == 0 {
+			genesis := chain.GetHeaderByNumber(0)
+			extra, err := types.ExtractBFTExtra(genesis)
+			if err != nil {
+				return nil, err
+			}
+			if len(extra.Validators) == 0 {
+				return nil, errInvalidValidators
+			}
+			snap = newSnapshot(b.config, b.signatures, 0, genesis.Hash(), extra.Validators)
+			if err := snap.store(b.db); err != nil {
+				return nil, err
+			}
+			log.Trace("Stored genesis validator snapshot to disk")
+			break
+		}
+		// No snapshot for this header, gather the header and move backward
+		var header *types.Header
+		if len(parents) > 0 {
+			// If we have explicit parents, pick from there (enforced)
+			header = parents[len(parents)-1]
+			if header.Hash() != hash || header.Number.Uint64() != number {
+				return nil, consensus.ErrUnknownAncestor
+			}
+			parents = parents[:len(parents)-1]
+		} else {
+			// No explicit parents (or no more left), reach out to the database
+			header = chain.GetHeader(hash, number)
+			if header == nil {
+				return nil, consensus.ErrUnknownAncestor
+			}
+		}
+		headers = append(headers, header)
+		number, hash = number-1, header.ParentHash
+	}
+	// Previous snapshot found, apply any pending headers on top of it
+	for i := 0; i < len(headers)/2; i++ {
+		headers[i], headers[len(headers)-1-i] = headers[len(headers)-1-i], headers[i]
+	}
+	snap, err := snap.apply(headers)
+	if err != nil {
+		return nil, err
+	}
+	b.recents.Add(snap.Hash, snap)
+
+	// If we've generated a new checkpoint snapshot, save to disk
+	if snap.Number%checkpointInterval == 0 && len(headers) > 0 {
+		if err = snap.store(b.db); err != nil {
+			return nil, err
+		}
+		log.Trace("Stored validator snapshot to disk", "number", snap.Number, "hash", snap.Hash)
+	}
+	return snap, err
+}
+
+// VerifyUncles implements consensus.Engine, always returning an error for any
+// uncles as this consensus mechanism doesn't permit uncles.
+func (b *BFT) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errors.New("uncles not allowed")
+	}
+	return nil
+}
+
+// VerifySeal implements consensus.Engine, checking whether the block was proposed
+// by a validator and committed by a quorum of them.
+func (b *BFT) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	// Verifying the genesis block is not supported
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	// Retrieve the snapshot needed to verify this header and cache it
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	return b.verifySeals(snap, header, true)
+}
+
+// verifySeals checks that the proposer of a header is a validator of the given
+// snapshot and, if committed, that a quorum of distinct validators committed it.
+func (b *BFT) verifySeals(snap *Snapshot, header *types.Header, committed bool) error {
+	proposer, err := ecrecover(header, b.signatures)
+	if err != nil {
+		return err
+	}
+	if _, ok := snap.Validators[proposer]; !ok {
+		return errUnauthorized
+	}
+	if proposer != header.Coinbase {
+		return errUnauthorized
+	}
+	if !committed {
+		return nil
+	}
+	extra, err := types.ExtractBFTExtra(header)
+	if err != nil {
+		return err
+	}
+	var (
+		hash       = commitHash(header.Hash())
+		validators = snap.validators()
+		seen       = make(map[common.Address]bool)
+	)
+	for _, seal := range extra.CommittedSeal {
+		validator, err := recoverValidator(hash, seal, validators)
+		if err != nil || seen[validator] {
+			return errInvalidCommittedSeals
+		}
+		seen[validator] = true
+	}
+	if len(seen) < snap.quorum() {
+		return errInsufficientCommittedSeals
+	}
+	return nil
+}
+
+// Prepare implements consensus.Engine, preparing all the consensus fields of the
+// header for running the transactions on top.
+func (b *BFT) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	number := header.Number.Uint64()
+
+	// Assemble the voting snapshot to check which votes make sense
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	next := snap.copy()
+	if number%b.config.Epoch == 0 {
+		next.Votes = nil
+		next.Tally = make(map[common.Address]Tally)
+	}
+	b.lock.RLock()
+	signer := b.signer
+
+	// If the block isn't a checkpoint, cast a random vote (good enough for now)
+	var votes []types.BFTVote
+	if number%b.config.Epoch != 0 {
+		addresses := make([]common.Address, 0, len(b.proposals))
+		for address, authorize := range b.proposals {
+			if snap.validVote(address, authorize) {
+				addresses = append(addresses, address)
+			}
+		}
+		if len(addresses) > 0 {
+			address := addresses[rand.Intn(len(addresses))]
+			votes = append(votes, types.BFTVote{Address: address, Authorize: b.proposals[address]})
+		}
+	}
+	b.lock.RUnlock()
+
+	// Record the validator set in effect once the vote is counted
+	for _, vote := range votes {
+		next.vote(signer, number, vote)
+	}
+	extra := &types.BFTExtra{
+		Validators:    next.validators(),
+		Votes:         votes,
+		Seal:          []byte{},
+		CommittedSeal: [][]byte{},
+	}
+	if err := types.SetBFTExtra(header, extra); err != nil {
+		return err
+	}
+	// The proposer is the reward recipient, name it as the first miner agent too
+	header.Coinbase = signer
+	header.MinerAgents = types.NewMinerAgents(signer)
+
+	header.Nonce = types.BlockNonce{}
+	header.MixDigest = types.BFTDigest
+	header.Difficulty = defaultDifficulty
+
+	// Ensure the timestamp has the correct delay
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(b.config.Period))
+	if header.Time.Int64() < time.Now().Unix() {
+		header.Time = big.NewInt(time.Now().Unix())
+	}
+	return nil
+}
+
+// Finalize implements consensus.Engine, crediting the proposer's coinage,
+// ensuring no uncles are set, and returns the final block.
+func (b *BFT) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	if header.Coinbase != (common.Address{}) {
+		misc.AccumulateCoinage(chain.Config(), state, header.Coinbase, header.Number)
+	}
+	// Uncles are meaningless in BFT, drop them
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	header.UncleHash = types.CalcUncleHash(nil)
+
+	// Assemble and return the final block for sealing
+	return types.NewBlock(header, txs, nil, receipts), nil
+}
+
+// Authorize injects a private key into the consensus engine to propose and
+// commit blocks with.
+func (b *BFT) Authorize(signer common.Address, signFn SignerFn) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.signer = signer
+	b.signFn = signFn
+}
+
+// sign signs a hash with the local validator key.
+func (b *BFT) sign(hash []byte) ([]byte, error) {
+	b.lock.RLock()
+	signer, signFn := b.signer, b.signFn
+	b.lock.RUnlock()
+
+	if signFn == nil {
+		return nil, errUnauthorized
+	}
+	return signFn(accounts.Account{Address: signer}, hash)
+}
+
+// Seal implements consensus.Engine, signing the block as its proposer and
+// handing it to the validators to agree on. The sealed block carrying the
+// committed seals is returned once a quorum committed it, or nil if another
+// block was agreed on at its height.
+func (b *BFT) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
+	header := block.Header()
+
+	// Sealing the genesis block is not supported
+	number := header.Number.Uint64()
+	if number == 0 {
+		return nil, errUnknownBlock
+	}
+	// Bail out if we're not a validator
+	snap, err := b.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return nil, err
+	}
+	b.lock.RLock()
+	signer := b.signer
+	b.lock.RUnlock()
+
+	if _, authorized := snap.Validators[signer]; !authorized {
+		return nil, errUnauthorized
+	}
+	// Sign the proposal, leaving the committed seals to the validators
+	extra, err := types.ExtractBFTExtra(header)
+	if err != nil {
+		return nil, err
+	}
+	sighash, err := sigHash(header)
+	if err != nil {
+		return nil, err
+	}
+	if extra.Seal, err = b.sign(sighash.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := types.SetBFTExtra(header, extra); err != nil {
+		return nil, err
+	}
+	proposal := block.WithSeal(header)
+
+	// Wait for our time before proposing the block
+	delay := time.Unix(header.Time.Int64(), 0).Sub(time.Now())
+	log.Trace("Waiting for slot to propose", "delay", common.PrettyDuration(delay))
+
+	select {
+	case <-stop:
+		return nil, nil
+	case <-time.After(delay):
+	}
+	b.agreeLock.RLock()
+	agreement := b.agreement
+	b.agreeLock.RUnlock()
+
+	if agreement == nil {
+		return nil, errNotStarted
+	}
+	result, err := agreement.request(proposal)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-stop:
+		return nil, nil
+	case sealed := <-result:
+		return sealed, nil
+	}
+}
+
+// Start launches the consensus state machine, taking part in agreeing on the
+// blocks built on top of the chain if authorized as a validator. Blocks agreed
+// on with another proposer are handed to the commit callback to be imported.
+func (b *BFT) Start(chain Chain, mux *event.TypeMux, commit func(*types.Block) error) error {
+	b.agreeLock.Lock()
+	defer b.agreeLock.Unlock()
+
+	if b.agreement != nil {
+		return nil
+	}
+	b.agreement = newAgreement(b, chain, mux, commit)
+	return nil
+}
+
+// Stop terminates the consensus state machine.
+func (b *BFT) Stop() error {
+	b.agreeLock.Lock()
+	agreement := b.agreement
+	b.agreement = nil
+	b.agreeLock.Unlock()
+
+	if agreement != nil {
+		agreement.stop()
+	}
+	return nil
+}
+
+// APIs implements consensus.Engine, returning the user facing RPC API to allow
+// controlling the validator voting and inspecting the consensus rounds.
+func (b *BFT) APIs(chain consensus.ChainReader) []rpc.API {
+	return []rpc.API{{
+		Namespace: "bft",
+		Version:   "1.0",
+		Service:   &API{chain: chain, bft: b},
+		Public:    false,
+	}}
+}
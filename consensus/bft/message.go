@@ -0,0 +1,93 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Consensus message codes of the three-phase commit.
+const (
+	msgPreprepare  uint64 = iota // Proposer announcing the block of a round
+	msgPrepare                   // Validator accepting the proposal of a round
+	msgCommit                    // Validator committing to the proposal after a prepare quorum
+	msgRoundChange               // Validator asking to move to a later round
+)
+
+// view is the position of the consensus in the chain: the height of the block
+// being agreed on and the attempt at it.
+type view struct {
+	Sequence uint64 // Number of the block being agreed on
+	Round    uint64 // Attempt at agreeing on the block, increased on timeouts
+}
+
+// message is a signed consensus message exchanged among the validators.
+type message struct {
+	Code          uint64      // Phase of the three-phase commit the message belongs to
+	View          view        // Height and round the message is about
+	Digest        common.Hash // Hash of the proposal the message is about
+	Payload       []byte      // RLP encoded proposal (preprepare, or locked proposal on round change)
+	CommittedSeal []byte      // Signature of the validator over the commit hash (commit)
+	Signature     []byte      // Signature of the validator over the fields above
+
+	sender common.Address // Validator that sent the message, resolved on receipt
+}
+
+// sigHash returns the hash of the message signed by its sender.
+func (m *message) sigHash() (hash common.Hash) {
+	hasher := sha3.NewKeccak256()
+
+	rlp.Encode(hasher, []interface{}{
+		m.Code,
+		m.View,
+		m.Digest,
+		m.Payload,
+		m.CommittedSeal,
+	})
+	hasher.Sum(hash[:0])
+	return hash
+}
+
+// sign signs the message with the local validator key.
+func (m *message) sign(b *BFT) error {
+	sighash := m.sigHash()
+
+	signature, err := b.sign(sighash[:])
+	if err != nil {
+		return err
+	}
+	m.Signature = signature
+	return nil
+}
+
+// recover resolves the validator that sent the message among the given ones.
+func (m *message) recover(validators []common.Address) (common.Address, error) {
+	sighash := m.sigHash()
+	return recoverValidator(sighash[:], m.Signature, validators)
+}
+
+// proposal decodes the block carried by the message.
+func (m *message) proposal() (*types.Block, error) {
+	block := new(types.Block)
+	if err := rlp.DecodeBytes(m.Payload, block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
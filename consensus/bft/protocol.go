@@ -0,0 +1,254 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+	"gopkg.in/fatih/set.v0"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	ProtocolName       = "bft"            // Official short name of the consensus sub-protocol
+	ProtocolVersion    = 1                // Version of the consensus sub-protocol
+	ProtocolLength     = 1                // Number of message codes of the consensus sub-protocol
+	ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
+
+	consensusMsg = 0x00 // Signed consensus message, gossiped among all peers
+
+	maxKnownMessages  = 4096 // Maximum message hashes to keep in the known list (prevent DOS)
+	maxQueuedMessages = 256  // Maximum messages waiting to be sent to a peer
+)
+
+var (
+	errAlreadyRegistered = errors.New("peer is already registered")
+	errNotRegistered     = errors.New("peer is not registered")
+)
+
+// peer is a remote node running the consensus sub-protocol.
+type peer struct {
+	*p2p.Peer
+	rw p2p.MsgReadWriter
+	id string
+
+	known *set.Set      // Hashes of the messages known to be known by this peer
+	queue chan []byte   // Encoded messages waiting to be sent
+	term  chan struct{} // Termination channel to stop the sender
+}
+
+func newPeer(p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
+	id := p.ID()
+
+	return &peer{
+		Peer:  p,
+		rw:    rw,
+		id:    fmt.Sprintf("%x", id[:8]),
+		known: set.New(),
+		queue: make(chan []byte, maxQueuedMessages),
+		term:  make(chan struct{}),
+	}
+}
+
+// markMessage marks a message as known for the peer, ensuring that it will never
+// be gossiped to this particular peer.
+func (p *peer) markMessage(hash common.Hash) {
+	// If we reached the memory allowance, drop a previously known message hash
+	for p.known.Size() >= maxKnownMessages {
+		p.known.Pop()
+	}
+	p.known.Add(hash)
+}
+
+// asyncSend queues a message for sending to the peer, dropping it if the peer
+// can't keep up.
+func (p *peer) asyncSend(hash common.Hash, payload []byte) {
+	select {
+	case p.queue <- payload:
+		p.markMessage(hash)
+	default:
+		p.Log().Debug("Dropping consensus message, queue full")
+	}
+}
+
+// sendLoop writes the queued messages to the peer until it disconnects.
+func (p *peer) sendLoop() {
+	for {
+		select {
+		case payload := <-p.queue:
+			if err := p2p.Send(p.rw, consensusMsg, rlp.RawValue(payload)); err != nil {
+				return
+			}
+		case <-p.term:
+			return
+		}
+	}
+}
+
+// peerSet is the set of peers running the consensus sub-protocol.
+type peerSet struct {
+	peers map[string]*peer
+	lock  sync.RWMutex
+}
+
+func newPeerSet() *peerSet {
+	return &peerSet{peers: make(map[string]*peer)}
+}
+
+// register adds a peer to the set and starts its sender.
+func (ps *peerSet) register(p *peer) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	if _, ok := ps.peers[p.id]; ok {
+		return errAlreadyRegistered
+	}
+	ps.peers[p.id] = p
+	go p.sendLoop()
+	return nil
+}
+
+// unregister removes a peer from the set and stops its sender.
+func (ps *peerSet) unregister(id string) error {
+	ps.lock.Lock()
+	defer ps.lock.Unlock()
+
+	p, ok := ps.peers[id]
+	if !ok {
+		return errNotRegistered
+	}
+	delete(ps.peers, id)
+	close(p.term)
+	return nil
+}
+
+// peersWithoutMessage retrieves the peers that don't know the given message yet.
+func (ps *peerSet) peersWithoutMessage(hash common.Hash) []*peer {
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	list := make([]*peer, 0, len(ps.peers))
+	for _, p := range ps.peers {
+		if !p.known.Has(hash) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// Protocols returns the consensus sub-protocol the validators exchange their
+// messages over, to be run next to the eth protocol.
+func (b *BFT) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    ProtocolName,
+		Version: ProtocolVersion,
+		Length:  ProtocolLength,
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			return b.handle(newPeer(p, rw))
+		},
+	}}
+}
+
+// handle is the callback invoked to manage the life cycle of a consensus peer.
+// When this function terminates, the peer is disconnected.
+func (b *BFT) handle(p *peer) error {
+	if err := b.peers.register(p); err != nil {
+		return err
+	}
+	defer b.peers.unregister(p.id)
+
+	p.Log().Debug("BFT peer connected", "name", p.Name())
+	for {
+		if err := b.handleMsg(p); err != nil {
+			p.Log().Debug("BFT message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMsg is invoked whenever an inbound message is received from a remote
+// peer. Messages of current validators are gossiped on and handed over to the
+// consensus state machine, anything else is dropped.
+func (b *BFT) handleMsg(p *peer) error {
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	defer msg.Discard()
+
+	if msg.Size > ProtocolMaxMsgSize {
+		return fmt.Errorf("message too large: %v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	if msg.Code != consensusMsg {
+		return fmt.Errorf("invalid message code: %v", msg.Code)
+	}
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	m := new(message)
+	if err := rlp.DecodeBytes(payload, m); err != nil {
+		return fmt.Errorf("invalid message: %v", err)
+	}
+	hash := crypto.Keccak256Hash(payload)
+	p.markMessage(hash)
+
+	if _, seen := b.messages.Get(hash); seen {
+		return nil
+	}
+	b.messages.Add(hash, struct{}{})
+
+	b.agreeLock.RLock()
+	agreement := b.agreement
+	b.agreeLock.RUnlock()
+
+	if agreement == nil {
+		return nil
+	}
+	// Only relay messages of the current validators to keep spam off the network
+	if _, err := m.recover(agreement.currentValidators()); err != nil {
+		return nil
+	}
+	b.relay(hash, payload)
+	agreement.deliver(m)
+	return nil
+}
+
+// gossip sends a locally created message to all peers.
+func (b *BFT) gossip(m *message) {
+	payload, err := rlp.EncodeToBytes(m)
+	if err != nil {
+		return
+	}
+	hash := crypto.Keccak256Hash(payload)
+	b.messages.Add(hash, struct{}{})
+	b.relay(hash, payload)
+}
+
+// relay sends a message to all peers not knowing it yet.
+func (b *BFT) relay(hash common.Hash, payload []byte) {
+	for _, p := range b.peers.peersWithoutMessage(hash) {
+		p.asyncSend(hash, payload)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// testNode is a validator of the simulated network, running its own chain and
+// consensus engine, and proposing blocks like a miner would.
+type testNode struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+
+	db     ethdb.Database
+	mux    *event.TypeMux
+	chain  *core.BlockChain
+	engine *BFT
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// testNetwork is an in-memory network of validators, exchanging their consensus
+// messages over message pipes.
+type testNetwork struct {
+	t       *testing.T
+	nodes   []*testNode
+	genesis *types.Block
+	pipes   []*p2p.MsgPipeRW
+}
+
+// newTestNetwork creates a network of the given number of validators, all of
+// them in the genesis validator set, sorted by address. The nodes are neither
+// connected nor started.
+func newTestNetwork(t *testing.T, validators int, timeout uint64) *testNetwork {
+	nodes := make([]*testNode, validators)
+	for i := range nodes {
+		key, _ := crypto.GenerateKey()
+		nodes[i] = &testNode{key: key, address: crypto.PubkeyToAddress(key.PublicKey, []int{0, 0, 0, 0, 156})}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return bytes.Compare(nodes[i].address[:], nodes[j].address[:]) < 0 })
+
+	addresses := make([]common.Address, len(nodes))
+	for i, node := range nodes {
+		addresses[i] = node.address
+	}
+	extra := &types.BFTExtra{Validators: addresses, Seal: []byte{}, CommittedSeal: [][]byte{}}
+	payload, err := rlp.EncodeToBytes(extra)
+	if err != nil {
+		t.Fatalf("failed to encode genesis extra-data: %v", err)
+	}
+	config := *params.TestChainConfig
+	config.Ethash = nil
+	config.BFT = &params.BFTConfig{Period: 1, RequestTimeout: timeout}
+
+	genspec := &core.Genesis{
+		Config:     &config,
+		ExtraData:  string(append(make([]byte, types.BFTExtraVanity), payload...)),
+		GasLimit:   4712388,
+		Difficulty: big.NewInt(1),
+	}
+	net := &testNetwork{t: t, nodes: nodes}
+	for _, node := range nodes {
+		node.db, _ = ethdb.NewMemDatabase()
+		net.genesis = genspec.MustCommit(node.db)
+
+		node.mux = new(event.TypeMux)
+		node.engine = New(config.BFT, node.db)
+		if node.chain, err = core.NewBlockChain(node.db, &config, node.engine, node.mux, vm.Config{}); err != nil {
+			t.Fatalf("failed to create chain: %v", err)
+		}
+		key := node.key
+		node.engine.Authorize(node.address, func(account accounts.Account, hash []byte) ([]byte, error) {
+			return crypto.Sign(hash, key)
+		})
+	}
+	return net
+}
+
+// connect links two nodes with a message pipe running the consensus protocol.
+func (net *testNetwork) connect(a, b int) {
+	rwa, rwb := p2p.MsgPipe()
+	net.pipes = append(net.pipes, rwa, rwb)
+
+	run := func(node *testNode, id int, rw p2p.MsgReadWriter) {
+		var nodeID discover.NodeID
+		copy(nodeID[:], fmt.Sprintf("node-%d", id))
+
+		proto := node.engine.Protocols()[0]
+		go proto.Run(p2p.NewPeer(nodeID, fmt.Sprintf("node-%d", id), nil), rw)
+	}
+	run(net.nodes[a], b, rwa)
+	run(net.nodes[b], a, rwb)
+}
+
+// start connects all the given nodes to each other and starts them. Proposing
+// only begins once all of them are connected, as consensus messages aren't
+// retransmitted.
+func (net *testNetwork) start(nodes ...int) {
+	for _, i := range nodes {
+		net.nodes[i].start()
+	}
+	for i := range nodes {
+		for j := i + 1; j < len(nodes); j++ {
+			net.connect(nodes[i], nodes[j])
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for _, i := range nodes {
+		for net.nodes[i].peerCount() < len(nodes)-1 {
+			if time.Now().After(deadline) {
+				net.t.Fatalf("node %d: peers not connected in time", i)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	for _, i := range nodes {
+		node := net.nodes[i]
+		node.stop, node.done = make(chan struct{}), make(chan struct{})
+		go node.mine(net.t)
+	}
+}
+
+// stop terminates all the nodes and their connections.
+func (net *testNetwork) stop() {
+	for _, pipe := range net.pipes {
+		pipe.Close()
+	}
+	for _, node := range net.nodes {
+		node.close()
+	}
+}
+
+// waitHeight waits until all the given nodes imported a block of the given
+// number, failing the test on timeout.
+func (net *testNetwork) waitHeight(number uint64, timeout time.Duration, nodes ...int) {
+	deadline := time.Now().Add(timeout)
+	for _, i := range nodes {
+		for net.nodes[i].chain.CurrentBlock().NumberU64() < number {
+			if time.Now().After(deadline) {
+				net.t.Fatalf("node %d: block %d not committed in time, head %d", i, number, net.nodes[i].chain.CurrentBlock().NumberU64())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// start launches the consensus state machine of the node.
+func (node *testNode) start() {
+	node.engine.Start(node.chain, node.mux, func(block *types.Block) error {
+		_, err := node.chain.InsertChain(types.Blocks{block})
+		return err
+	})
+}
+
+// peerCount returns the number of peers the node exchanges consensus messages
+// with.
+func (node *testNode) peerCount() int {
+	node.engine.peers.lock.RLock()
+	defer node.engine.peers.lock.RUnlock()
+
+	return len(node.engine.peers.peers)
+}
+
+// close stops the miner loop and the consensus state machine of the node.
+func (node *testNode) close() {
+	if node.stop != nil {
+		close(node.stop)
+		<-node.done
+	}
+	node.engine.Stop()
+	node.chain.Stop()
+}
+
+// mine keeps proposing blocks on top of the local head, importing the ones the
+// validators commit, until the node is stopped.
+func (node *testNode) mine(t *testing.T) {
+	defer close(node.done)
+
+	for {
+		head := node.chain.CurrentBlock()
+
+		block, err := node.proposal(head)
+		if err != nil {
+			t.Errorf("failed to assemble proposal: %v", err)
+			return
+		}
+		sealed, err := node.engine.Seal(node.chain, block, node.stop)
+		if err != nil {
+			t.Errorf("failed to seal proposal: %v", err)
+			return
+		}
+		if sealed != nil {
+			if _, err := node.chain.InsertChain(types.Blocks{sealed}); err != nil {
+				t.Errorf("failed to import sealed block: %v", err)
+				return
+			}
+		}
+		// Wait for the block agreed on to be imported before proposing again
+		for node.chain.CurrentBlock().Hash() == head.Hash() {
+			select {
+			case <-node.stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+}
+
+// proposal assembles an empty block on top of the given head.
+func (node *testNode) proposal(parent *types.Block) (*types.Block, error) {
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   core.CalcGasLimit(parent),
+	}
+	if err := node.engine.Prepare(node.chain, header); err != nil {
+		return nil, err
+	}
+	statedb, err := node.chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	return node.engine.Finalize(node.chain, header, statedb, nil, nil, nil)
+}
@@ -0,0 +1,304 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package bft
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Vote represents a single vote that a block proposer made to modify the
+// validator set.
+type Vote struct {
+	Validator common.Address `json:"validator"` // Validator that proposed the block casting this vote
+	Block     uint64         `json:"block"`     // Block number the vote was cast in (expire old votes)
+	Address   common.Address `json:"address"`   // Account being voted on to change its validator status
+	Authorize bool           `json:"authorize"` // Whether to add or remove the voted account
+}
+
+// Tally is a simple vote tally to keep the current score of votes. Votes that
+// go against the proposal aren't counted since it's equivalent to not voting.
+type Tally struct {
+	Authorize bool `json:"authorize"` // Whether the vote is about adding or removing someone
+	Votes     int  `json:"votes"`     // Number of votes until now wanting to pass the proposal
+}
+
+// Snapshot is the state of the validator set and its voting at a given point
+// in time.
+type Snapshot struct {
+	config   *params.BFTConfig // Consensus engine parameters to fine tune behavior
+	sigcache *lru.ARCCache     // Cache of recent block signatures to speed up ecrecover
+
+	Number     uint64                      `json:"number"`     // Block number where the snapshot was created
+	Hash       common.Hash                 `json:"hash"`       // Block hash where the snapshot was created
+	Validators map[common.Address]struct{} `json:"validators"` // Set of validators at this moment
+	Votes      []*Vote                     `json:"votes"`      // List of votes cast in chronological order
+	Tally      map[common.Address]Tally    `json:"tally"`      // Current vote tally to avoid recalculating
+}
+
+// newSnapshot creates a new snapshot with the specified startup parameters. Only
+// ever use it for the genesis block.
+func newSnapshot(config *params.BFTConfig, sigcache *lru.ARCCache, number uint64, hash common.Hash, validators []common.Address) *Snapshot {
+	snap := &Snapshot{
+		config:     config,
+		sigcache:   sigcache,
+		Number:     number,
+		Hash:       hash,
+		Validators: make(map[common.Address]struct{}),
+		Tally:      make(map[common.Address]Tally),
+	}
+	for _, validator := range validators {
+		snap.Validators[validator] = struct{}{}
+	}
+	return snap
+}
+
+// loadSnapshot loads an existing snapshot from the database.
+func loadSnapshot(config *params.BFTConfig, sigcache *lru.ARCCache, db ethdb.Database, hash common.Hash) (*Snapshot, error) {
+	blob, err := db.Get(append([]byte("bft-"), hash[:]...))
+	if err != nil {
+		return nil, err
+	}
+	snap := new(Snapshot)
+	if err := json.Unmarshal(blob, snap); err != nil {
+		return nil, err
+	}
+	snap.config = config
+	snap.sigcache = sigcache
+
+	return snap, nil
+}
+
+// store inserts the snapshot into the database.
+func (s *Snapshot) store(db ethdb.Database) error {
+	blob, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return db.Put(append([]byte("bft-"), s.Hash[:]...), blob)
+}
+
+// copy creates a deep copy of the snapshot, though not the individual votes.
+func (s *Snapshot) copy() *Snapshot {
+	cpy := &Snapshot{
+		config:     s.config,
+		sigcache:   s.sigcache,
+		Number:     s.Number,
+		Hash:       s.Hash,
+		Validators: make(map[common.Address]struct{}),
+		Votes:      make([]*Vote, len(s.Votes)),
+		Tally:      make(map[common.Address]Tally),
+	}
+	for validator := range s.Validators {
+		cpy.Validators[validator] = struct{}{}
+	}
+	for address, tally := range s.Tally {
+		cpy.Tally[address] = tally
+	}
+	copy(cpy.Votes, s.Votes)
+
+	return cpy
+}
+
+// validVote returns whether it makes sense to cast the specified vote in the
+// given snapshot context (e.g. don't try to add an existing validator, or to
+// remove the last one).
+func (s *Snapshot) validVote(address common.Address, authorize bool) bool {
+	_, validator := s.Validators[address]
+	return (validator && !authorize && len(s.Validators) > 1) || (!validator && authorize)
+}
+
+// cast adds a new vote into the tally.
+func (s *Snapshot) cast(address common.Address, authorize bool) bool {
+	// Ensure the vote is meaningful
+	if !s.validVote(address, authorize) {
+		return false
+	}
+	// Cast the vote into an existing or new tally
+	if old, ok := s.Tally[address]; ok {
+		old.Votes++
+		s.Tally[address] = old
+	} else {
+		s.Tally[address] = Tally{Authorize: authorize, Votes: 1}
+	}
+	return true
+}
+
+// uncast removes a previously cast vote from the tally.
+func (s *Snapshot) uncast(address common.Address, authorize bool) bool {
+	// If there's no tally, it's a dangling vote, just drop
+	tally, ok := s.Tally[address]
+	if !ok {
+		return false
+	}
+	// Ensure we only revert counted votes
+	if tally.Authorize != authorize {
+		return false
+	}
+	// Otherwise revert the vote
+	if tally.Votes > 1 {
+		tally.Votes--
+		s.Tally[address] = tally
+	} else {
+		delete(s.Tally, address)
+	}
+	return true
+}
+
+// vote tallies up a vote cast by the proposer of a block, updating the validator
+// set in place if it passes.
+func (s *Snapshot) vote(proposer common.Address, number uint64, vote types.BFTVote) {
+	// Discard any previous vote of the proposer on the same account
+	for i, old := range s.Votes {
+		if old.Validator == proposer && old.Address == vote.Address {
+			s.uncast(old.Address, old.Authorize)
+			s.Votes = append(s.Votes[:i], s.Votes[i+1:]...)
+			break // only one vote allowed
+		}
+	}
+	if s.cast(vote.Address, vote.Authorize) {
+		s.Votes = append(s.Votes, &Vote{
+			Validator: proposer,
+			Block:     number,
+			Address:   vote.Address,
+			Authorize: vote.Authorize,
+		})
+	}
+	// If the vote passed, update the validator set
+	if tally := s.Tally[vote.Address]; tally.Votes > len(s.Validators)/2 {
+		if tally.Authorize {
+			s.Validators[vote.Address] = struct{}{}
+		} else {
+			delete(s.Validators, vote.Address)
+
+			// Discard any previous votes the removed validator cast
+			for i := 0; i < len(s.Votes); i++ {
+				if s.Votes[i].Validator == vote.Address {
+					s.uncast(s.Votes[i].Address, s.Votes[i].Authorize)
+					s.Votes = append(s.Votes[:i], s.Votes[i+1:]...)
+					i--
+				}
+			}
+		}
+		// Discard any previous votes around the just changed account
+		for i := 0; i < len(s.Votes); i++ {
+			if s.Votes[i].Address == vote.Address {
+				s.Votes = append(s.Votes[:i], s.Votes[i+1:]...)
+				i--
+			}
+		}
+		delete(s.Tally, vote.Address)
+	}
+}
+
+// apply creates a new validator snapshot by applying the given headers to the
+// original one, checking that each header records the resulting validator set.
+func (s *Snapshot) apply(headers []*types.Header) (*Snapshot, error) {
+	// Allow passing in no headers for cleaner code
+	if len(headers) == 0 {
+		return s, nil
+	}
+	// Sanity check that the headers can be applied
+	for i := 0; i < len(headers)-1; i++ {
+		if headers[i+1].Number.Uint64() != headers[i].Number.Uint64()+1 {
+			return nil, errInvalidVotingChain
+		}
+	}
+	if headers[0].Number.Uint64() != s.Number+1 {
+		return nil, errInvalidVotingChain
+	}
+	// Iterate through the headers and create a new snapshot
+	snap := s.copy()
+
+	for _, header := range headers {
+		// Remove any votes on checkpoint blocks
+		number := header.Number.Uint64()
+		if number%s.config.Epoch == 0 {
+			snap.Votes = nil
+			snap.Tally = make(map[common.Address]Tally)
+		}
+		// Resolve the proposer and check against the validators
+		proposer, err := ecrecover(header, s.sigcache)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := snap.Validators[proposer]; !ok {
+			return nil, errUnauthorized
+		}
+		extra, err := types.ExtractBFTExtra(header)
+		if err != nil {
+			return nil, err
+		}
+		if len(extra.Votes) > 1 {
+			return nil, errInvalidVote
+		}
+		for _, vote := range extra.Votes {
+			snap.vote(proposer, number, vote)
+		}
+		// Ensure the header carries the validator set resulting from its vote
+		validators := snap.validators()
+		if len(extra.Validators) != len(validators) {
+			return nil, errInvalidValidators
+		}
+		for i, validator := range validators {
+			if extra.Validators[i] != validator {
+				return nil, errInvalidValidators
+			}
+		}
+	}
+	snap.Number += uint64(len(headers))
+	snap.Hash = headers[len(headers)-1].Hash()
+
+	return snap, nil
+}
+
+// validators retrieves the list of validators in ascending order.
+func (s *Snapshot) validators() []common.Address {
+	validators := make([]common.Address, 0, len(s.Validators))
+	for validator := range s.Validators {
+		validators = append(validators, validator)
+	}
+	sort.Slice(validators, func(i, j int) bool {
+		return bytes.Compare(validators[i][:], validators[j][:]) < 0
+	})
+	return validators
+}
+
+// proposer returns the validator whose turn it is to propose the block at the
+// given height in the given round, rotating over the validators round-robin.
+func (s *Snapshot) proposer(number, round uint64) common.Address {
+	validators := s.validators()
+	return validators[(number+round)%uint64(len(validators))]
+}
+
+// quorum returns the number of validators needed to agree on a block, enough
+// for any two quorums to share an honest validator with a third of them faulty.
+func (s *Snapshot) quorum() int {
+	return (2*len(s.Validators) + 2) / 3
+}
+
+// faulty returns the maximum number of faulty validators the set tolerates.
+func (s *Snapshot) faulty() int {
+	return (len(s.Validators) - 1) / 3
+}
@@ -2,12 +2,41 @@
 package clique
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+const (
+	defaultStatusWindow = 1024  // Default number of blocks to report signer liveness over
+	maxHistoryRange     = 65536 // Maximum number of blocks to scan in a single request
+)
+
+// TallyRecord is a vote cast on chain together with the tally it resulted in.
+type TallyRecord struct {
+	Block     uint64         `json:"block"`     // Block number the vote was cast in
+	Signer    common.Address `json:"signer"`    // Authorized signer that cast the vote
+	Address   common.Address `json:"address"`   // Account being voted on
+	Authorize bool           `json:"authorize"` // Whether to authorize or deauthorize the account
+	Votes     int            `json:"votes"`     // Votes for the proposal after this one
+	Signers   int            `json:"signers"`   // Number of signers at the time of the vote
+	Passed    bool           `json:"passed"`    // Whether this vote pushed the proposal through
+}
+
+// SignerStatus is the liveness of a signer over a window of recent blocks.
+type SignerStatus struct {
+	Signed     uint64 `json:"signed"`     // Number of blocks sealed by the signer
+	InTurn     uint64 `json:"inTurn"`     // Number of slots the signer was in-turn for
+	Missed     uint64 `json:"missed"`     // Number of in-turn slots sealed by another signer
+	LastSigned uint64 `json:"lastSigned"` // Number of the last block sealed (0 = none in the window)
+}
+
 // API is a user facing RPC API to allow controlling the signer and voting
 // mechanisms of the proof-of-authority scheme.
 type API struct {
@@ -102,3 +131,204 @@ func (api *API) Discard(address common.Address) {
 
 	delete(api.clique.proposals, address)
 }
+
+// header retrieves the requested header, or the current one if none requested.
+func (api *API) header(number *rpc.BlockNumber) *types.Header {
+	if number == nil || *number == rpc.LatestBlockNumber {
+		return api.chain.CurrentHeader()
+	}
+	return api.chain.GetHeaderByNumber(uint64(number.Int64()))
+}
+
+// blockRange resolves a block range, defaulting to the last window blocks.
+func (api *API) blockRange(from, to *rpc.BlockNumber, window uint64) (uint64, uint64, error) {
+	end := api.header(to)
+	if end == nil {
+		return 0, 0, errUnknownBlock
+	}
+	last := end.Number.Uint64()
+
+	var first uint64
+	if from != nil {
+		first = uint64(from.Int64())
+	} else if last >= window {
+		first = last - window + 1
+	}
+	if first > last {
+		return 0, 0, errors.New("invalid block range")
+	}
+	if last-first >= maxHistoryRange {
+		return 0, 0, fmt.Errorf("block range too large (max %d blocks)", maxHistoryRange)
+	}
+	return first, last, nil
+}
+
+// GetVoteLog retrieves the signer votes cast in the given block range, as kept in
+// the on-chain vote log of the range's last block.
+func (api *API) GetVoteLog(from, to *rpc.BlockNumber) ([]*VoteRecord, error) {
+	chain, ok := api.chain.(interface {
+		StateAt(root common.Hash) (*state.StateDB, error)
+	})
+	if !ok {
+		return nil, errors.New("chain state unavailable")
+	}
+	end := api.header(to)
+	if end == nil {
+		return nil, errUnknownBlock
+	}
+	statedb, err := chain.StateAt(end.Root)
+	if err != nil {
+		return nil, err
+	}
+	var first uint64
+	if from != nil {
+		first = uint64(from.Int64())
+	}
+	return readVoteLog(statedb, first, end.Number.Uint64()), nil
+}
+
+// GetTallyHistory replays the votes cast in the given block range (the last epoch
+// by default), reporting the tally of each proposal after every vote.
+func (api *API) GetTallyHistory(from, to *rpc.BlockNumber) ([]*TallyRecord, error) {
+	first, last, err := api.blockRange(from, to, api.clique.config.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	if first == 0 {
+		first = 1
+	}
+	history := []*TallyRecord{}
+	for number := first; number <= last; number++ {
+		header := api.chain.GetHeaderByNumber(number)
+		if header == nil {
+			return nil, errUnknownBlock
+		}
+		if header.Coinbase == (common.Address{}) || number%api.clique.config.Epoch == 0 {
+			continue
+		}
+		before, err := api.clique.snapshot(api.chain, number-1, header.ParentHash, nil)
+		if err != nil {
+			return nil, err
+		}
+		after, err := api.clique.snapshot(api.chain, number, header.Hash(), nil)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ecrecover(api.clique.config, header, api.clique.signatures, before.Signers)
+		if err != nil {
+			return nil, err
+		}
+		_, was := before.Signers[header.Coinbase]
+		_, is := after.Signers[header.Coinbase]
+
+		record := &TallyRecord{
+			Block:     number,
+			Signer:    signer,
+			Address:   header.Coinbase,
+			Authorize: bytes.Equal(header.Nonce[:], nonceAuthVote),
+			Votes:     after.Tally[header.Coinbase].Votes,
+			Signers:   len(before.Signers),
+			Passed:    was != is,
+		}
+		if record.Passed {
+			record.Votes = before.Tally[header.Coinbase].Votes + 1
+		}
+		history = append(history, record)
+	}
+	return history, nil
+}
+
+// GetSignerStatus reports the liveness of the signers over the given number of
+// most recent blocks: how many blocks each sealed and how many of its in-turn
+// slots were sealed by someone else instead.
+func (api *API) GetSignerStatus(blocks *uint64) (map[common.Address]*SignerStatus, error) {
+	window := uint64(defaultStatusWindow)
+	if blocks != nil && *blocks > 0 {
+		window = *blocks
+	}
+	first, last, err := api.blockRange(nil, nil, window)
+	if err != nil {
+		return nil, err
+	}
+	if first == 0 {
+		first = 1
+	}
+	status := make(map[common.Address]*SignerStatus)
+	for number := first; number <= last; number++ {
+		header := api.chain.GetHeaderByNumber(number)
+		if header == nil {
+			return nil, errUnknownBlock
+		}
+		snap, err := api.clique.snapshot(api.chain, number-1, header.ParentHash, nil)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ecrecover(api.clique.config, header, api.clique.signatures, snap.Signers)
+		if err != nil {
+			return nil, err
+		}
+		signers := snap.signers()
+		for _, address := range signers {
+			if status[address] == nil {
+				status[address] = new(SignerStatus)
+			}
+		}
+		if status[signer] == nil {
+			status[signer] = new(SignerStatus)
+		}
+		status[signer].Signed++
+		status[signer].LastSigned = number
+
+		inturn := signers[number%uint64(len(signers))]
+		status[inturn].InTurn++
+		if inturn != signer {
+			status[inturn].Missed++
+		}
+	}
+	return status, nil
+}
+
+// ScheduleProposal schedules an authorization proposal that the signer starts
+// voting on once the chain reaches the given block, allowing signer rotations to
+// be planned ahead.
+func (api *API) ScheduleProposal(number uint64, address common.Address, auth bool) error {
+	if head := api.chain.CurrentHeader().Number.Uint64(); number <= head {
+		return fmt.Errorf("block #%d already reached (head #%d)", number, head)
+	}
+	api.clique.lock.Lock()
+	defer api.clique.lock.Unlock()
+
+	if api.clique.scheduled[number] == nil {
+		api.clique.scheduled[number] = make(map[common.Address]bool)
+	}
+	api.clique.scheduled[number][address] = auth
+	return nil
+}
+
+// ScheduledProposals returns the proposals scheduled for future blocks.
+func (api *API) ScheduledProposals() map[uint64]map[common.Address]bool {
+	api.clique.lock.RLock()
+	defer api.clique.lock.RUnlock()
+
+	scheduled := make(map[uint64]map[common.Address]bool)
+	for number, proposals := range api.clique.scheduled {
+		scheduled[number] = make(map[common.Address]bool)
+		for address, auth := range proposals {
+			scheduled[number][address] = auth
+		}
+	}
+	return scheduled
+}
+
+// DiscardScheduled drops a proposal scheduled for a future block.
+func (api *API) DiscardScheduled(number uint64, address common.Address) {
+	api.clique.lock.Lock()
+	defer api.clique.lock.Unlock()
+
+	if proposals := api.clique.scheduled[number]; proposals != nil {
+		delete(proposals, address)
+		if len(proposals) == 0 {
+			delete(api.clique.scheduled, number)
+		}
+	}
+}
@@ -124,6 +124,14 @@ var (
 
 	// errUnauthorized is returned if a header is signed by a non-authorized entity.
 	errUnauthorized = errors.New("unauthorized")
+
+	// errInvalidMinerAgents is returned if a block's miner agents are missing or
+	// contain a malformed reward percentage.
+	errInvalidMinerAgents = errors.New("invalid miner agents")
+
+	// errAmbiguousSigner is returned if the key sealing a block is shared by more
+	// than one authorized signer, which differ in their country code only.
+	errAmbiguousSigner = errors.New("ambiguous signer")
 )
 
 // SignerFn is a signer callback function to request a hash to be signed by a
@@ -134,16 +142,24 @@ type SignerFn func(accounts.Account, []byte) ([]byte, error)
 // signing. It is the hash of the entire header apart from the 65 byte signature
 // contained at the end of the extra data.
 //
+// The miner agents are only sealed from the miner agents fork on, so that the
+// signatures of blocks produced before it remain valid.
+//
 // Note, the method requires the extra data to be at least 65 bytes, otherwise it
 // panics. This is done to avoid accidentally using both forms (signature present
 // or not), which could be abused to produce different hashes for the same header.
-func sigHash(header *types.Header) (hash common.Hash) {
+func sigHash(config *params.CliqueConfig, header *types.Header) (hash common.Hash) {
 	hasher := sha3.NewKeccak256()
 
-	rlp.Encode(hasher, []interface{}{
+	fields := []interface{}{
 		header.ParentHash,
 		header.UncleHash,
 		header.Coinbase,
+	}
+	if config.IsAgents(header.Number) {
+		fields = append(fields, header.MinerAgents)
+	}
+	fields = append(fields,
 		header.Root,
 		header.TxHash,
 		header.ReceiptHash,
@@ -156,34 +172,73 @@ func sigHash(header *types.Header) (hash common.Hash) {
 		header.Extra[:len(header.Extra)-65], // Yes, this will panic if extra is too short
 		header.MixDigest,
 		header.Nonce,
-	})
+	)
+	rlp.Encode(hasher, fields)
 	hasher.Sum(hash[:0])
 	return hash
 }
 
-// ecrecover extracts the Ethereum account address from a signed header.
-func ecrecover(header *types.Header, sigcache *lru.ARCCache) (common.Address, error) {
+// ecrecover extracts the ofbank account address from a signed header. The
+// signature only yields the 20 byte key hash, from the miner agents fork on the
+// country code is taken from the authorized signer owning that key.
+func ecrecover(config *params.CliqueConfig, header *types.Header, sigcache *lru.ARCCache, signers map[common.Address]struct{}) (common.Address, error) {
 	// If the signature's already cached, return that
 	hash := header.Hash()
 	if address, known := sigcache.Get(hash); known {
 		return address.(common.Address), nil
 	}
+	keyhash, err := recoverKeyHash(config, header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	var signer common.Address
+	if !config.IsAgents(header.Number) {
+		copy(signer[:], keyhash)
+	} else {
+		found := false
+		for candidate := range signers {
+			if ownsKey(candidate, keyhash) {
+				if found {
+					return common.Address{}, errAmbiguousSigner
+				}
+				signer, found = candidate, true
+			}
+		}
+		if !found {
+			return common.Address{}, errUnauthorized
+		}
+	}
+	sigcache.Add(hash, signer)
+	return signer, nil
+}
+
+// ownsKey returns whether a signer is derived from the given key hash, either as
+// an ofbank address or in the legacy layout signers have before the miner agents
+// fork, the key hash followed by zeroes.
+func ownsKey(signer common.Address, keyhash []byte) bool {
+	if bytes.Equal(signer[countryCodeLength:], keyhash) {
+		return true
+	}
+	var legacy common.Address
+	copy(legacy[:], keyhash)
+	return signer == legacy
+}
+
+// recoverKeyHash recovers the public key that sealed a header and returns the
+// 20 byte hash of it.
+func recoverKeyHash(config *params.CliqueConfig, header *types.Header) ([]byte, error) {
 	// Retrieve the signature from the header extra-data
 	if len(header.Extra) < extraSeal {
-		return common.Address{}, errMissingSignature
+		return nil, errMissingSignature
 	}
 	signature := header.Extra[len(header.Extra)-extraSeal:]
 
-	// Recover the public key and the Ethereum address
-	pubkey, err := crypto.Ecrecover(sigHash(header).Bytes(), signature)
+	// Recover the public key and hash it the Ethereum way
+	pubkey, err := crypto.Ecrecover(sigHash(config, header).Bytes(), signature)
 	if err != nil {
-		return common.Address{}, err
+		return nil, err
 	}
-	var signer common.Address
-	copy(signer[:], crypto.Keccak256(pubkey[1:])[12:])
-
-	sigcache.Add(hash, signer)
-	return signer, nil
+	return crypto.Keccak256(pubkey[1:])[12:], nil
 }
 
 // Clique is the proof-of-authority consensus engine proposed to support the
@@ -195,7 +250,8 @@ type Clique struct {
 	recents    *lru.ARCCache // Snapshots for recent block to speed up reorgs
 	signatures *lru.ARCCache // Signatures of recent blocks to speed up mining
 
-	proposals map[common.Address]bool // Current list of proposals we are pushing
+	proposals map[common.Address]bool            // Current list of proposals we are pushing
+	scheduled map[uint64]map[common.Address]bool // Proposals to start pushing at future blocks
 
 	signer common.Address // Ethereum address of the signing key
 	signFn SignerFn       // Signer function to authorize hashes with
@@ -223,13 +279,34 @@ func New(config *params.CliqueConfig, db ethdb.Database) *Clique {
 		recents:    recents,
 		signatures: signatures,
 		proposals:  make(map[common.Address]bool),
+		scheduled:  make(map[uint64]map[common.Address]bool),
 	}
 }
 
 // Author implements consensus.Engine, returning the Ethereum address recovered
 // from the signature in the header's extra-data section.
 func (c *Clique) Author(header *types.Header) (common.Address, error) {
-	return ecrecover(header, c.signatures)
+	if !c.config.IsAgents(header.Number) {
+		return ecrecover(c.config, header, c.signatures, nil)
+	}
+	if address, known := c.signatures.Get(header.Hash()); known {
+		return address.(common.Address), nil
+	}
+	// Only verified headers are attributed, whose first miner agent has been
+	// checked against the signer set, so the key hash needs to match it. The
+	// result isn't cached as the header might not have been verified by us.
+	if len(header.MinerAgents) == 0 {
+		return common.Address{}, errInvalidMinerAgents
+	}
+	keyhash, err := recoverKeyHash(c.config, header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	signer := header.MinerAgents[0].Minerbase
+	if !ownsKey(signer, keyhash) {
+		return common.Address{}, errUnauthorized
+	}
+	return signer, nil
 }
 
 // VerifyHeader checks whether a header conforms to the consensus rules.
@@ -312,6 +389,12 @@ func (c *Clique) verifyHeader(chain consensus.ChainReader, header *types.Header,
 		if header.Difficulty == nil || (header.Difficulty.Cmp(diffInTurn) != 0 && header.Difficulty.Cmp(diffNoTurn) != 0) {
 			return errInvalidDifficulty
 		}
+		// Ensure that the block names its signer and a valid reward split
+		if c.config.IsAgents(header.Number) {
+			if err := verifyMinerAgents(header); err != nil {
+				return err
+			}
+		}
 	}
 	// All basic checks passed, verify cascading fields
 	return c.verifyCascadingFields(chain, header, parents)
@@ -469,13 +552,16 @@ func (c *Clique) verifySeal(chain consensus.ChainReader, header *types.Header, p
 	}
 
 	// Resolve the authorization key and check against signers
-	signer, err := ecrecover(header, c.signatures)
+	signer, err := ecrecover(c.config, header, c.signatures, snap.Signers)
 	if err != nil {
 		return err
 	}
 	if _, ok := snap.Signers[signer]; !ok {
 		return errUnauthorized
 	}
+	if c.config.IsAgents(header.Number) && header.MinerAgents[0].Minerbase != signer {
+		return errInvalidMinerAgents
+	}
 	for seen, recent := range snap.Recents {
 		if recent == signer {
 			// Signer is among recents, only fail if the current block doesn't shift it out
@@ -509,6 +595,19 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 	if err != nil {
 		return err
 	}
+	// Start pushing the proposals scheduled up to this block
+	c.lock.Lock()
+	for at, proposals := range c.scheduled {
+		if at <= number {
+			for address, authorize := range proposals {
+				c.proposals[address] = authorize
+			}
+			delete(c.scheduled, at)
+		}
+	}
+	signer := c.signer
+	c.lock.Unlock()
+
 	if number%c.config.Epoch != 0 {
 		c.lock.RLock()
 
@@ -532,9 +631,15 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 	}
 	// Set the correct difficulty
 	header.Difficulty = diffNoTurn
-	if snap.inturn(header.Number.Uint64(), c.signer) {
+	if snap.inturn(header.Number.Uint64(), signer) {
 		header.Difficulty = diffInTurn
 	}
+	// Name the signer as the first miner agent, sharing the reward with the public
+	// miners registered on this node
+	if c.config.IsAgents(header.Number) {
+		header.MinerAgents = types.NewMinerAgents(signer)
+	}
+
 	// Ensure the extra data has all it's components
 	if len(header.Extra) < extraVanity {
 		header.Extra = append(header.Extra, bytes.Repeat([]byte{0x00}, extraVanity-len(header.Extra))...)
@@ -563,10 +668,17 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 	return nil
 }
 
-// Finalize implements consensus.Engine, ensuring no uncles are set, nor block
-// rewards given, and returns the final block.
+// Finalize implements consensus.Engine, crediting the signer's coinage, sharing
+// the block reward among the miner agents and logging any vote cast, ensuring no
+// uncles are set, and returns the final block.
 func (c *Clique) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
-	// No block rewards in PoA, so the state remains as is and uncles are dropped
+	if c.config.IsAgents(header.Number) && len(header.MinerAgents) > 0 {
+		accumulateRewards(chain.Config(), c.config, state, header)
+		if header.Coinbase != (common.Address{}) {
+			recordVote(state, header)
+		}
+	}
+	// Uncles are meaningless in PoA, drop them
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 	header.UncleHash = types.CalcUncleHash(nil)
 
@@ -635,7 +747,7 @@ func (c *Clique) Seal(chain consensus.ChainReader, block *types.Block, stop <-ch
 	case <-time.After(delay):
 	}
 	// Sign all the things!
-	sighash, err := signFn(accounts.Account{Address: signer}, sigHash(header).Bytes())
+	sighash, err := signFn(accounts.Account{Address: signer}, sigHash(c.config, header).Bytes())
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,135 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// countryCodeLength is the length of the country code prefixing the public key
+// hash in ofbank addresses.
+const countryCodeLength = common.AddressLength - 20
+
+var (
+	// voteLogAddress is the system account whose storage holds the log of all the
+	// signer votes cast on chain, making them auditable against the state root.
+	voteLogAddress = common.BytesToAddress([]byte("clique-vote-log"))
+)
+
+// VoteRecord is a signer vote as kept in the on-chain vote log.
+type VoteRecord struct {
+	Index     uint64         `json:"index"`     // Position of the vote in the log
+	Block     uint64         `json:"block"`     // Block number the vote was cast in
+	Signer    common.Address `json:"signer"`    // Authorized signer that cast the vote
+	Address   common.Address `json:"address"`   // Account being voted on
+	Authorize bool           `json:"authorize"` // Whether to authorize or deauthorize the account
+}
+
+// verifyMinerAgents checks that a header lists at least one miner agent, the
+// signer, and that all reward percentages are well formed.
+func verifyMinerAgents(header *types.Header) error {
+	if !misc.ValidMinerAgents(header.MinerAgents) {
+		return errInvalidMinerAgents
+	}
+	return nil
+}
+
+// accumulateRewards credits the signer of a block, its first miner agent, with
+// the coinage accrued since it was last credited, and shares the block reward
+// among the miner agents according to their percentages. Shares are paid in
+// order until the reward runs out, whatever remains goes to the signer.
+func accumulateRewards(chainConfig *params.ChainConfig, config *params.CliqueConfig, state *state.StateDB, header *types.Header) {
+	signer := header.MinerAgents[0].Minerbase
+	misc.AccumulateCoinage(chainConfig, state, signer, header.Number)
+
+	if config.BlockReward == nil || config.BlockReward.Sign() <= 0 {
+		return
+	}
+	misc.ShareReward(state, header.MinerAgents, config.BlockReward, signer)
+}
+
+// voteSlot returns the storage slot of a field of the vote log entry at index.
+// The number of entries is kept in the zero slot.
+func voteSlot(index uint64, field int64) common.Hash {
+	var key [8]byte
+	binary.BigEndian.PutUint64(key[:], index)
+
+	slot := new(big.Int).SetBytes(crypto.Keccak256(key[:]))
+	return common.BigToHash(slot.Add(slot, big.NewInt(field)))
+}
+
+// recordVote appends the vote cast by a block to the on-chain vote log.
+func recordVote(state *state.StateDB, header *types.Header) {
+	count := state.GetState(voteLogAddress, common.Hash{}).Big().Uint64()
+
+	var meta common.Hash
+	if bytes.Equal(header.Nonce[:], nonceAuthVote) {
+		meta[0] = 1
+	}
+	binary.BigEndian.PutUint64(meta[common.HashLength-8:], header.Number.Uint64())
+
+	state.SetState(voteLogAddress, voteSlot(count, 0), common.BytesToHash(header.MinerAgents[0].Minerbase[:]))
+	state.SetState(voteLogAddress, voteSlot(count, 1), common.BytesToHash(header.Coinbase[:]))
+	state.SetState(voteLogAddress, voteSlot(count, 2), meta)
+	state.SetState(voteLogAddress, common.Hash{}, common.BigToHash(new(big.Int).SetUint64(count+1)))
+
+	// Keep the log account from being swept away as empty
+	if state.GetNonce(voteLogAddress) == 0 {
+		state.SetNonce(voteLogAddress, 1)
+	}
+}
+
+// readVote retrieves the vote log entry at index.
+func readVote(state *state.StateDB, index uint64) *VoteRecord {
+	meta := state.GetState(voteLogAddress, voteSlot(index, 2))
+	return &VoteRecord{
+		Index:     index,
+		Block:     binary.BigEndian.Uint64(meta[common.HashLength-8:]),
+		Signer:    common.BytesToAddress(state.GetState(voteLogAddress, voteSlot(index, 0)).Bytes()),
+		Address:   common.BytesToAddress(state.GetState(voteLogAddress, voteSlot(index, 1)).Bytes()),
+		Authorize: meta[0] == 1,
+	}
+}
+
+// readVoteLog retrieves the logged votes cast in blocks from..to (inclusive).
+func readVoteLog(state *state.StateDB, from, to uint64) []*VoteRecord {
+	count := state.GetState(voteLogAddress, common.Hash{}).Big().Uint64()
+
+	// Votes are logged in block order, find the first one in range
+	start := sort.Search(int(count), func(i int) bool {
+		return readVote(state, uint64(i)).Block >= from
+	})
+	votes := []*VoteRecord{}
+	for index := uint64(start); index < count; index++ {
+		vote := readVote(state, index)
+		if vote.Block > to {
+			break
+		}
+		votes = append(votes, vote)
+	}
+	return votes
+}
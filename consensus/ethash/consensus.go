@@ -17,8 +17,6 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/params"
 	set "gopkg.in/fatih/set.v0"
-
-	"strconv"
 )
 
 // Ethash proof-of-work protocol constants.
@@ -42,6 +40,7 @@ var (
 	errInvalidDifficulty = errors.New("non-positive difficulty")
 	errInvalidMixDigest  = errors.New("invalid mix digest")
 	errInvalidPoW        = errors.New("invalid proof-of-work")
+	errInvalidAgents     = errors.New("invalid miner agents")
 )
 
 // Author implements consensus.Engine, returning the header's coinbase as the
@@ -148,7 +147,28 @@ func (ethash *Ethash) verifyHeaderWorker(chain consensus.ChainReader, headers []
 	if chain.GetHeader(headers[index].Hash(), headers[index].Number.Uint64()) != nil {
 		return nil // known block
 	}
-	return ethash.verifyHeader(chain, headers[index], parent, false, seals[index])
+	return ethash.verifyHeader(&batchChain{chain, headers[:index]}, headers[index], parent, false, seals[index])
+}
+
+// batchChain is a chain reader which also knows about a batch of headers being
+// verified, so the ancestors needed by the difficulty adjustment are found even
+// if they are not yet in the database.
+type batchChain struct {
+	consensus.ChainReader
+	headers []*types.Header // Consecutive headers preceding the one being verified
+}
+
+// GetHeader retrieves a header from the batch, or from the database if it is
+// not part of it.
+func (c *batchChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if len(c.headers) > 0 {
+		if first := c.headers[0].Number.Uint64(); number >= first && number-first < uint64(len(c.headers)) {
+			if header := c.headers[number-first]; header.Hash() == hash {
+				return header
+			}
+		}
+	}
+	return c.ChainReader.GetHeader(hash, number)
 }
 
 // VerifyUncles verifies that the given block's uncles conform to the consensus
@@ -225,8 +245,7 @@ func (ethash *Ethash) verifyHeader(chain consensus.ChainReader, header, parent *
 		return errZeroBlockTime
 	}
 	// Verify the block's difficulty based in it's timestamp and parent's difficulty
-	//expected := big.NewInt(131072) // Water Morning
-	 expected := CalcDifficulty(chain.Config(), header.Time.Uint64(), parent)	// Nancy Nasty
+	expected := CalcDifficultyChain(chain, header.Time.Uint64(), parent)
 
 	if expected.Cmp(header.Difficulty) != 0 {
 		return fmt.Errorf("invalid difficulty: have %v, want %v", header.Difficulty, expected)
@@ -261,6 +280,10 @@ func (ethash *Ethash) verifyHeader(chain consensus.ChainReader, header, parent *
 			return err
 		}
 	}
+	// Ensure that the reward split is well formed once it's paid out
+	if config := chain.Config().Ethash; config != nil && config.IsAgents(header.Number) && !misc.ValidMinerAgents(header.MinerAgents) {
+		return errInvalidAgents
+	}
 	// If all checks passed, validate any special fields for hard forks
 	if err := misc.VerifyDAOHeaderExtraData(chain.Config(), header); err != nil {
 		return err
@@ -482,8 +505,7 @@ func (ethash *Ethash) Prepare(chain consensus.ChainReader, header *types.Header)
 		return consensus.ErrUnknownAncestor
 	}
 
-//	header.Difficulty = big.NewInt(131072) 	// Water Morning
- header.Difficulty = CalcDifficulty(chain.Config(), header.Time.Uint64(), parent)	// Water Nasty
+	header.Difficulty = CalcDifficultyChain(chain, header.Time.Uint64(), parent)
 
 	return nil
 }
@@ -492,7 +514,7 @@ func (ethash *Ethash) Prepare(chain consensus.ChainReader, header *types.Header)
 // setting the final state and assembling the block.
 func (ethash *Ethash) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
 	// Accumulate any block and uncle rewards and commit the final state root
-	AccumulateRewards(state, header, uncles)
+	AccumulateRewards(chain.Config(), state, header, uncles)
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 
 	// Header seems complete, assemble into a block and return
@@ -507,19 +529,13 @@ var (
 
 // AccumulateRewards credits the coinbase of the given block with the mining
 // reward. The total reward consists of the static block reward and rewards for
-// included uncles. The coinbase of each uncle block is also rewarded.
+// included uncles. The coinbase of each uncle block is also rewarded. From the
+// miner agents fork on, the reward is shared among the miner agents of the block
+// instead, with whatever remains going to the coinbase.
 // TODO (karalabe): Move the chain maker into this package and make this private!
-func AccumulateRewards(state *state.StateDB, header *types.Header, uncles []*types.Header) {
+func AccumulateRewards(config *params.ChainConfig, state *state.StateDB, header *types.Header, uncles []*types.Header) {
 
-	pervbn_i64,_ := strconv.ParseInt(state.GetLast(header.Coinbase),10, 64)
-	CurBlknum,_ := strconv.ParseInt(header.Number.String(), 10, 64)
-	blk_diff := CurBlknum - pervbn_i64 // int64
-	if blk_diff < 0 {
-		blk_diff = 0	// OR blk_diff > MAX_COINAGE
-	}
-	cur_bal, _ := strconv.ParseFloat(state.GetBalance(header.Coinbase), 64)	// float64
-	coinint := float64(blk_diff) * cur_bal * 10 / 3600
-	ca_gain := big.NewInt(int64(coinint*1E6))		// coinage_per_hour
+	ca_gain := misc.CoinageGain(config, state, header.Coinbase, header.Number)
 	reward := new(big.Int).Set(blockReward)
 	r := new(big.Int)
 	for _, uncle := range uncles {
@@ -539,5 +555,9 @@ func AccumulateRewards(state *state.StateDB, header *types.Header, uncles []*typ
 
 	state.AddCoinage(header.Coinbase, ca_gain.String())
 	state.SetLast(header.Coinbase, header.Number.String())
+	if config.Ethash != nil && config.Ethash.IsAgents(header.Number) {
+		misc.ShareReward(state, header.MinerAgents, reward, header.Coinbase)
+		return
+	}
 	state.AddBalance(header.Coinbase, reward.String())	// Water Cherry
 }
@@ -0,0 +1,149 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	defaultTargetBlockTime = 15 // Default number of seconds between blocks to aim for
+	defaultWindow          = 60 // Default number of recent blocks to average the solve times of
+
+	maxSolveTimeFactor = 6  // Solve times are capped at this many target block times
+	maxDifficultyJump  = 10 // Difficulty may rise at most this many times the window average
+)
+
+// CalcDifficultyChain is the difficulty adjustment algorithm of the chain. It
+// returns the difficulty that a new block should have when created at time
+// given its parent. Before the configured difficulty fork the stock rules are
+// used, afterwards the configured algorithm, which may look at as many ancestors
+// of the parent as its window is long.
+func CalcDifficultyChain(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	config := chain.Config()
+
+	next := new(big.Int).Add(parent.Number, big1)
+	if !config.IsDifficultyFork(next) {
+		return CalcDifficulty(config, time, parent)
+	}
+	difficulty := config.Ethash.Difficulty
+
+	switch difficulty.Algorithm {
+	case params.DifficultyFixed:
+		return minimumDifficulty(difficulty)
+
+	case params.DifficultyLWMA:
+		// Gather the window of ancestors, oldest first, ending with the parent
+		window := make([]*types.Header, DifficultyWindow(difficulty)+1)
+
+		i := len(window) - 1
+		for window[i] = parent; i > 0 && window[i].Number.Sign() > 0; i-- {
+			ancestor := chain.GetHeader(window[i].ParentHash, window[i].Number.Uint64()-1)
+			if ancestor == nil {
+				break
+			}
+			window[i-1] = ancestor
+		}
+		return CalcDifficultyWindow(difficulty, window[i:])
+
+	default:
+		// Unknown algorithms are rejected when the chain config is loaded
+		panic(fmt.Sprintf("unknown difficulty algorithm %q", difficulty.Algorithm))
+	}
+}
+
+// DifficultyWindow returns the number of solve times the difficulty adjustment
+// averages, i.e. one less than the number of headers it needs to look at.
+func DifficultyWindow(config *params.DifficultyConfig) int {
+	if config.Window == 0 {
+		return defaultWindow
+	}
+	return int(config.Window)
+}
+
+// CalcDifficultyWindow is the linearly weighted moving average difficulty
+// adjustment. It returns the difficulty of the block following the last one of
+// the window, which holds consecutive headers, oldest first. The more recent a
+// solve time is, the more weight it gets, so the difficulty reacts to hashpower
+// changes quickly without oscillating.
+//
+// The calculation only depends on the timestamps and difficulties of the window,
+// so it can be replayed on historical headers.
+func CalcDifficultyWindow(config *params.DifficultyConfig, window []*types.Header) *big.Int {
+	if n := DifficultyWindow(config) + 1; len(window) > n {
+		window = window[len(window)-n:]
+	}
+	minimum := minimumDifficulty(config)
+
+	// Without any solve time to go by, stick to the last difficulty
+	if len(window) < 2 {
+		if len(window) == 0 || window[0].Difficulty.Cmp(minimum) < 0 {
+			return new(big.Int).Set(minimum)
+		}
+		return new(big.Int).Set(window[len(window)-1].Difficulty)
+	}
+	target := config.TargetBlockTime
+	if target == 0 {
+		target = defaultTargetBlockTime
+	}
+	// algorithm:
+	// weighted = sum(i * clamp(time_i - time_(i-1), 1, 6 * target)) for i in 1..n
+	// weighted = max(weighted, n * (n + 1) / 2 * target / 10)
+	// diff = sum(diff_i) * target * (n + 1) / (2 * weighted)
+	var (
+		n        = uint64(len(window) - 1)
+		weighted = uint64(0)
+		total    = new(big.Int)
+	)
+	for i := uint64(1); i <= n; i++ {
+		solve := uint64(1)
+		if prev, cur := window[i-1].Time.Uint64(), window[i].Time.Uint64(); cur > prev {
+			solve = cur - prev
+		}
+		if solve > maxSolveTimeFactor*target {
+			solve = maxSolveTimeFactor * target
+		}
+		weighted += i * solve
+		total.Add(total, window[i].Difficulty)
+	}
+	if floor := n * (n + 1) / 2 * target / maxDifficultyJump; weighted < floor {
+		weighted = floor
+	}
+	if weighted == 0 {
+		weighted = 1
+	}
+	diff := new(big.Int).Mul(total, new(big.Int).SetUint64(target*(n+1)))
+	diff.Div(diff, new(big.Int).SetUint64(2*weighted))
+
+	if diff.Cmp(minimum) < 0 {
+		diff.Set(minimum)
+	}
+	return diff
+}
+
+// minimumDifficulty returns the difficulty the adjustment may never drop under.
+func minimumDifficulty(config *params.DifficultyConfig) *big.Int {
+	if config.MinimumDifficulty == nil || config.MinimumDifficulty.Sign() <= 0 {
+		return new(big.Int).Set(params.MinimumDifficulty)
+	}
+	return new(big.Int).Set(config.MinimumDifficulty)
+}
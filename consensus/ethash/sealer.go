@@ -30,6 +30,27 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 )
 
+// Hashimoto computes the mix digest and the proof-of-work value of a sealing
+// hash and nonce, letting remote miners report only the nonces they found.
+func (ethash *Ethash) Hashimoto(number uint64, hash common.Hash, nonce uint64) (common.Hash, *big.Int) {
+	// If we're running a fake PoW, every nonce is a valid one
+	if ethash.fakeMode {
+		return common.Hash{}, new(big.Int)
+	}
+	// If we're running a shared PoW, delegate the computation to it
+	if ethash.shared != nil {
+		return ethash.shared.Hashimoto(number, hash, nonce)
+	}
+	cache := ethash.cache(number)
+
+	size := datasetSize(number)
+	if ethash.tester {
+		size = 32 * 1024
+	}
+	digest, result := hashimotoLight(size, cache, hash.Bytes(), nonce)
+	return common.BytesToHash(digest), new(big.Int).SetBytes(result)
+}
+
 // Seal implements consensus.Engine, attempting to find a nonce that satisfies
 // the block's difficulty requirements.
 func (ethash *Ethash) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}) (*types.Block, error) {
@@ -0,0 +1,81 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package misc
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// sharePrecision is the number of parts 100% is split into, shares are parsed
+// with 6 decimals of the percentage.
+var sharePrecision = big.NewInt(100000000)
+
+// agentShare parses the reward percentage of a miner agent (e.g. "12.5%") into
+// parts of sharePrecision. The decimal is parsed exactly, so that all nodes agree
+// on the split irrespective of floating point behavior.
+func agentShare(percentage string) (*big.Int, bool) {
+	rat, ok := new(big.Rat).SetString(strings.TrimSuffix(percentage, "%"))
+	if !ok || rat.Sign() < 0 || rat.Cmp(big.NewRat(100, 1)) > 0 {
+		return nil, false
+	}
+	rat.Mul(rat, new(big.Rat).SetInt(new(big.Int).Div(sharePrecision, big.NewInt(100))))
+	return new(big.Int).Quo(rat.Num(), rat.Denom()), true
+}
+
+// ValidMinerAgents returns whether a block lists at least one miner agent and
+// all their reward percentages are well formed.
+func ValidMinerAgents(agents []types.MinerAgents) bool {
+	if len(agents) == 0 {
+		return false
+	}
+	for _, agent := range agents {
+		if _, ok := agentShare(agent.Percentage); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ShareReward credits the miner agents of a block with their percentage of the
+// reward. Shares are paid in order until the reward runs out, whatever remains
+// goes to rest.
+func ShareReward(state *state.StateDB, agents []types.MinerAgents, reward *big.Int, rest common.Address) {
+	remaining := new(big.Int).Set(reward)
+	for _, agent := range agents {
+		share, ok := agentShare(agent.Percentage)
+		if !ok {
+			continue
+		}
+		amount := new(big.Int).Mul(reward, share)
+		amount.Div(amount, sharePrecision)
+		if amount.Cmp(remaining) > 0 {
+			amount.Set(remaining)
+		}
+		if amount.Sign() > 0 {
+			state.AddBalance(agent.Minerbase, amount.String())
+			remaining.Sub(remaining, amount)
+		}
+	}
+	if remaining.Sign() > 0 {
+		state.AddBalance(rest, remaining.String())
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package misc
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// CoinageState is the part of the state coinage is accrued in, implemented by
+// both the state database and the EVM's view of it.
+type CoinageState interface {
+	GetBalance(common.Address) string
+	AddCoinage(common.Address, string)
+	GetLast(common.Address) string
+	SetLast(common.Address, string)
+}
+
+// Coinage calculates the coinage of holding balance for the given number of
+// blocks, with the arithmetic in force at block number. Before the coinage fork
+// it's computed in floating point, from the fork on with exact rationals.
+func Coinage(config *params.ChainConfig, balance string, blocks int64, number *big.Int) *big.Int {
+	if !config.IsCoinage(number) {
+		f64Balance, _ := strconv.ParseFloat(balance, 64)
+		coinage := float64(blocks) * f64Balance * 10 / 3600
+
+		return big.NewInt(int64(coinage * 1e6)) // coinage_per_hour
+	}
+	rat, ok := new(big.Rat).SetString(balance)
+	if !ok {
+		return new(big.Int)
+	}
+	// Coinage is accounted in millionths, accruing 10 units per 3600 blocks held
+	rate := new(big.Rat).SetFrac(new(big.Int).Mul(big.NewInt(blocks), big.NewInt(10*1e6)), big.NewInt(3600))
+	coinage := new(big.Rat).Mul(rat, rate)
+
+	return new(big.Int).Quo(coinage.Num(), coinage.Denom()) // coinage_per_hour
+}
+
+// CoinageBlocks returns the number of blocks an account held its balance for,
+// since the block it was last credited in.
+func CoinageBlocks(state CoinageState, addr common.Address, number *big.Int) int64 {
+	last, _ := strconv.ParseInt(state.GetLast(addr), 10, 64)
+	if blocks := number.Int64() - last; blocks > 0 {
+		return blocks
+	}
+	return 0
+}
+
+// CoinageGain calculates the coinage an account accrued since the block it was
+// last credited in, proportional to its balance and the number of blocks passed.
+func CoinageGain(config *params.ChainConfig, state CoinageState, addr common.Address, number *big.Int) *big.Int {
+	return Coinage(config, state.GetBalance(addr), CoinageBlocks(state, addr, number), number)
+}
+
+// AccumulateCoinage credits an account with the coinage accrued up to the given
+// block and marks it as last credited there.
+func AccumulateCoinage(config *params.ChainConfig, state CoinageState, addr common.Address, number *big.Int) {
+	state.AddCoinage(addr, CoinageGain(config, state, addr, number).String())
+	state.SetLast(addr, number.String())
+}
@@ -0,0 +1,87 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package misc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testCoinageState is an in-memory coinage state of a single account.
+type testCoinageState struct {
+	balance string
+	coinage *big.Int
+	last    string
+}
+
+func (s *testCoinageState) GetBalance(common.Address) string { return s.balance }
+func (s *testCoinageState) GetLast(common.Address) string    { return s.last }
+func (s *testCoinageState) SetLast(_ common.Address, last string) {
+	s.last = last
+}
+func (s *testCoinageState) AddCoinage(_ common.Address, amount string) {
+	gain, _ := new(big.Int).SetString(amount, 10)
+	s.coinage.Add(s.coinage, gain)
+}
+
+// Tests that coinage is computed in floating point before the coinage fork, as
+// blocks were accepted with, and exactly from the fork on.
+func TestCoinageFork(t *testing.T) {
+	config := &params.ChainConfig{CoinageBlock: big.NewInt(100)}
+
+	tests := []struct {
+		balance string
+		last    int64
+		number  int64
+		gain    int64
+	}{
+		// Floating point rounding loses a unit before the fork
+		{"0.300000", 0, 9, 7499},
+		{"0.300000", 91, 100, 7500},
+		{"0.300000", 991, 1000, 7500},
+
+		// Gains representable in floating point agree on both sides
+		{"3.000000", 0, 9, 75000},
+		{"3.000000", 91, 100, 75000},
+
+		// Fractions of a unit are truncated on both sides
+		{"0.700000", 0, 3, 5833},
+		{"0.700000", 97, 100, 5833},
+
+		// Accounts credited ahead of the block accrue nothing
+		{"1.000000", 50, 40, 0},
+		{"1.000000", 150, 140, 0},
+	}
+	for i, tt := range tests {
+		state := &testCoinageState{balance: tt.balance, coinage: new(big.Int), last: big.NewInt(tt.last).String()}
+		number := big.NewInt(tt.number)
+
+		if gain := CoinageGain(config, state, common.Address{}, number); gain.Cmp(big.NewInt(tt.gain)) != 0 {
+			t.Errorf("test %d: gain mismatch: have %v, want %v", i, gain, tt.gain)
+		}
+		AccumulateCoinage(config, state, common.Address{}, number)
+		if state.coinage.Cmp(big.NewInt(tt.gain)) != 0 {
+			t.Errorf("test %d: accumulated coinage mismatch: have %v, want %v", i, state.coinage, tt.gain)
+		}
+		if state.last != number.String() {
+			t.Errorf("test %d: last credited block mismatch: have %s, want %v", i, state.last, number)
+		}
+	}
+}
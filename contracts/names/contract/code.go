@@ -0,0 +1,6 @@
+package contract
+
+// RegistryDeployedCode is the code of the registry after deployment, as placed
+// in the genesis state. This constant needs to be updated when the contract
+// code is changed.
+const RegistryDeployedCode = "0x346070576000357c01000000000000000000000000000000000000000000000000000000009004806302571be31460755780633b3b57de14608f578063e472b3521460a957806391f0c8be1460de578063315c207f1461015357806389d12940146101dc5780635ac801fe14610252575b600080fd5b600435600052600060205260406000205460005260206000f35b600435600052600160205260406000205460005260206000f35b60043578ffffffffffffffffffffffffffffffffffffffffffffffffff16600052600260205260406000205460005260206000f35b600435806000526000602052604060002054331460fa57600080fd5b60243578ffffffffffffffffffffffffffffffffffffffffffffffffff16808260005260006020526040600020556000527f68e096119f2de23ffd6c912e51998e6b17bf6fbfcbb08dfcf0bb996d93dbab0a60206000a2005b600435806000526000602052604060002054331461017057600080fd5b60443578ffffffffffffffffffffffffffffffffffffffffffffffffff1681600052602435602052604060002060005260006020526040600020819055600052602435907fb23b00eaecf231369b7738878435a6959361f03c746caf80a1ccc87ee46d744760206000a3005b60043580600052600060205260406000205433146101f957600080fd5b60243578ffffffffffffffffffffffffffffffffffffffffffffffffff16808260005260016020526040600020556000527fe015afcbd0e57e67ff98e2f2bc77b764e0b80bb6709e42ca80c20dab4914f92260206000a2005b60043580336000526002602052604060002055600052337f29388a12068b7d04600be5f72ab12d9d0e1fd3a316a6df8ec08be45581ac08a960206000a200"
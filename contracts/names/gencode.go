@@ -0,0 +1,63 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// +build none
+
+// This program assembles the registry, generating contract/registry.bin with
+// the code deploying it, and contract/code.go with the code after deployment.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ethereum/go-ethereum/core/asm"
+)
+
+func main() {
+	deployer := assemble("contract/deploy.easm")
+	code := assemble("contract/registry.easm")
+
+	if err := ioutil.WriteFile("contract/registry.bin", []byte(deployer+code), 0644); err != nil {
+		panic(err)
+	}
+	content := fmt.Sprintf(`package contract
+
+// RegistryDeployedCode is the code of the registry after deployment, as placed
+// in the genesis state. This constant needs to be updated when the contract
+// code is changed.
+const RegistryDeployedCode = "0x%s"
+`, code)
+	if err := ioutil.WriteFile("contract/code.go", []byte(content), 0644); err != nil {
+		panic(err)
+	}
+}
+
+// assemble compiles the source file, returning the code in hex.
+func assemble(file string) string {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		panic(err)
+	}
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex(file, src, false))
+
+	code, errs := compiler.Compile()
+	if len(errs) > 0 {
+		panic(fmt.Sprintf("%s: %v", file, errs))
+	}
+	return code
+}
@@ -0,0 +1,262 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package names wraps the name service of bank accounts, resolving human
+// readable names like alice.cn.ofbank to full 25 byte accounts and back.
+//
+// Names are hashed into nodes as in ENS. The registry is deployed at genesis at
+// RegistryAddress, with the root node owned by the account given at that time,
+// who hands out the top level names.
+package names
+
+//go:generate go run ./gencode.go
+//go:generate abigen --abi contract/registry.abi --bin contract/registry.bin --pkg contract --type Registry --out contract/registry.go
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/contracts/names/contract"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// RegistryAddress is the address the registry is deployed at in the genesis
+// state, next to the bank precompiles.
+var RegistryAddress = common.BytesToAddress([]byte{0x01, 0x10})
+
+var (
+	ErrNotFound    = errors.New("name not registered")
+	ErrInvalidName = errors.New("invalid name")
+	ErrNameTooLong = errors.New("name too long for a reverse record")
+)
+
+// maxReverseLength is the length limit of names accounts are known by, which
+// the registry keeps in a single word.
+const maxReverseLength = 32
+
+// IsName reports whether s is meant as a name rather than a hex address. Names
+// have a top level domain, so they contain at least one dot.
+func IsName(s string) bool {
+	return strings.Contains(s, ".") && !common.IsHexAddress(s)
+}
+
+// Normalise lower cases the name and checks that none of its labels is empty.
+func Normalise(name string) (string, error) {
+	name = strings.ToLower(name)
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return "", ErrInvalidName
+		}
+	}
+	return name, nil
+}
+
+// NameHash returns the node of a normalised name: the hash of the node of its
+// parent and the hash of its first label, with the root node all zeroes.
+func NameHash(name string) common.Hash {
+	var node common.Hash
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = crypto.Keccak256Hash(node[:], crypto.Keccak256([]byte(labels[i])))
+	}
+	return node
+}
+
+// split returns the node of the parent of a name and the hash of its label.
+func split(name string) (common.Hash, common.Hash) {
+	parts := strings.SplitN(name, ".", 2)
+	label := crypto.Keccak256Hash([]byte(parts[0]))
+	if len(parts) == 1 {
+		return common.Hash{}, label
+	}
+	return NameHash(parts[1]), label
+}
+
+// Resolver resolves names through the registry without transacting.
+type Resolver struct {
+	registry *contract.RegistryCaller
+}
+
+// NewResolver creates a resolver calling the registry at the given address.
+func NewResolver(registryAddr common.Address, caller bind.ContractCaller) (*Resolver, error) {
+	registry, err := contract.NewRegistryCaller(registryAddr, caller)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{registry}, nil
+}
+
+// Resolve returns the account a name resolves to.
+func (r *Resolver) Resolve(opts *bind.CallOpts, name string) (common.Address, error) {
+	name, err := Normalise(name)
+	if err != nil {
+		return common.Address{}, err
+	}
+	account, err := r.registry.Addr(opts, NameHash(name))
+	if err != nil {
+		return common.Address{}, err
+	}
+	if account == (common.Address{}) {
+		return common.Address{}, ErrNotFound
+	}
+	return account, nil
+}
+
+// Account returns the account given either as a hex address or a name.
+func (r *Resolver) Account(opts *bind.CallOpts, account string) (common.Address, error) {
+	if !IsName(account) {
+		if !common.IsHexAddress(account) {
+			return common.Address{}, fmt.Errorf("invalid address or name %q", account)
+		}
+		return common.HexToAddress(account), nil
+	}
+	return r.Resolve(opts, account)
+}
+
+// Lookup returns the name an account is known by, or an empty string if it has
+// none. Accounts set their names themselves, so the name is only returned if
+// it also resolves to the account.
+func (r *Resolver) Lookup(opts *bind.CallOpts, account common.Address) (string, error) {
+	word, err := r.registry.Name(opts, account)
+	if err != nil {
+		return "", err
+	}
+	name := string(bytes.TrimRight(word[:], "\x00"))
+	if name == "" {
+		return "", nil
+	}
+	resolved, err := r.Resolve(opts, name)
+	switch {
+	case err == ErrNotFound || err == ErrInvalidName:
+		return "", nil
+	case err != nil:
+		return "", err
+	case resolved != account:
+		return "", nil
+	}
+	return name, nil
+}
+
+// Registry manages names through the registry, transacting with the options
+// it was created with.
+type Registry struct {
+	*contract.RegistrySession
+	*Resolver
+}
+
+// NewRegistry creates a registry manager for the registry at the given address.
+func NewRegistry(transactOpts *bind.TransactOpts, registryAddr common.Address, contractBackend bind.ContractBackend) (*Registry, error) {
+	registry, err := contract.NewRegistry(registryAddr, contractBackend)
+	if err != nil {
+		return nil, err
+	}
+	return &Registry{
+		&contract.RegistrySession{
+			Contract:     registry,
+			TransactOpts: *transactOpts,
+		},
+		&Resolver{&registry.RegistryCaller},
+	}, nil
+}
+
+// DeployRegistry deploys a new registry, owned by the deploying account. The
+// registry of a chain is usually deployed at genesis instead.
+func DeployRegistry(transactOpts *bind.TransactOpts, contractBackend bind.ContractBackend) (common.Address, *Registry, error) {
+	registryAddr, _, _, err := contract.DeployRegistry(transactOpts, contractBackend)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	registry, err := NewRegistry(transactOpts, registryAddr, contractBackend)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	return registryAddr, registry, nil
+}
+
+// Register hands out a name to an owner. It only works if the caller owns the
+// parent of the name.
+func (self *Registry) Register(name string, owner common.Address) (*types.Transaction, error) {
+	name, err := Normalise(name)
+	if err != nil {
+		return nil, err
+	}
+	parent, label := split(name)
+	return self.RegistrySession.SetSubnodeOwner(parent, label, owner)
+}
+
+// SetAccount points a name to an account. It only works if the caller owns
+// the name.
+func (self *Registry) SetAccount(name string, account common.Address) (*types.Transaction, error) {
+	name, err := Normalise(name)
+	if err != nil {
+		return nil, err
+	}
+	return self.RegistrySession.SetAddr(NameHash(name), account)
+}
+
+// SetReverse sets the name the calling account is known by. Lookups only return
+// it while the name resolves to the account.
+func (self *Registry) SetReverse(name string) (*types.Transaction, error) {
+	name, err := Normalise(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(name) > maxReverseLength {
+		return nil, ErrNameTooLong
+	}
+	var word [32]byte
+	copy(word[:], name)
+	return self.RegistrySession.SetName(word)
+}
+
+// GenesisAccount returns the registry as deployed at genesis, with the root
+// node owned by the given account.
+func GenesisAccount(owner common.Address) core.GenesisAccount {
+	return core.GenesisAccount{
+		Code:    common.FromHex(contract.RegistryDeployedCode),
+		Storage: map[common.Hash]common.Hash{ownerSlot(common.Hash{}): owner.Hash()},
+		Balance: new(big.Int),
+	}
+}
+
+// ownerSlot returns the storage slot of the registry holding the owner of a
+// node, see contract/registry.easm for the layout.
+func ownerSlot(node common.Hash) common.Hash {
+	return crypto.Keccak256Hash(node[:], common.Hash{}.Bytes())
+}
+
+// AddToGenesis deploys the registry in the genesis state, with the root node
+// owned by the given account.
+func AddToGenesis(genesis *core.Genesis, owner common.Address) error {
+	if genesis.Alloc == nil {
+		genesis.Alloc = make(core.GenesisAlloc)
+	}
+	if _, ok := genesis.Alloc[RegistryAddress]; ok {
+		return fmt.Errorf("genesis already allocates the registry address %x", RegistryAddress)
+	}
+	genesis.Alloc[RegistryAddress] = GenesisAccount(owner)
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package names_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/contracts/names"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// registryTester is a simulated chain with the registry deployed at genesis and
+// a registry manager for each of its funded accounts.
+type registryTester struct {
+	sim *backends.SimulatedBackend
+
+	root    *names.Registry // Owner of the root node
+	alice   *names.Registry // Account the test names are handed out to
+	mallory *names.Registry // Account owning no name
+}
+
+func newRegistryTester(t *testing.T) *registryTester {
+	var auths []*bind.TransactOpts
+	alloc := make(core.GenesisAlloc)
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		auth := bind.NewKeyedTransactor(key)
+
+		// Rejected calls are mined as failed instead of failing the estimation
+		auth.GasLimit = big.NewInt(200000)
+
+		alloc[auth.From] = core.GenesisAccount{Balance: new(big.Int).Mul(big.NewInt(100), big.NewInt(params.Ether))}
+		auths = append(auths, auth)
+	}
+	alloc[names.RegistryAddress] = names.GenesisAccount(auths[0].From)
+	sim := backends.NewSimulatedBackend(alloc)
+
+	rt := &registryTester{sim: sim}
+	for i, registry := range []**names.Registry{&rt.root, &rt.alice, &rt.mallory} {
+		var err error
+		if *registry, err = names.NewRegistry(auths[i], names.RegistryAddress, sim); err != nil {
+			t.Fatalf("failed to create registry manager: %v", err)
+		}
+	}
+	return rt
+}
+
+// owner returns the owner of a name.
+func (rt *registryTester) owner(t *testing.T, name string) common.Address {
+	owner, err := rt.root.Owner(names.NameHash(name))
+	if err != nil {
+		t.Fatalf("failed to retrieve owner of %s: %v", name, err)
+	}
+	return owner
+}
+
+// Tests that the owner of a node can delegate its subnodes, and that nobody else
+// can claim or change them.
+func TestRegistryDelegation(t *testing.T) {
+	rt := newRegistryTester(t)
+	alice, mallory := rt.alice.TransactOpts.From, rt.mallory.TransactOpts.From
+
+	// The root owner hands out the top level name, and delegates a subnode of it
+	for _, name := range []string{"ofbank", "cn.ofbank"} {
+		if _, err := rt.root.Register(name, rt.root.TransactOpts.From); err != nil {
+			t.Fatalf("failed to register %s: %v", name, err)
+		}
+	}
+	if _, err := rt.root.Register("alice.cn.ofbank", alice); err != nil {
+		t.Fatalf("failed to register alice.cn.ofbank: %v", err)
+	}
+	rt.sim.Commit()
+
+	if owner := rt.owner(t, "alice.cn.ofbank"); owner != alice {
+		t.Fatalf("delegated owner mismatch: have %x, want %x", owner, alice)
+	}
+	// The new owner manages the subnodes of its name on its own
+	if _, err := rt.alice.Register("pay.alice.cn.ofbank", alice); err != nil {
+		t.Fatalf("failed to register subnode: %v", err)
+	}
+	// Others can neither claim names nor take over existing ones
+	if _, err := rt.mallory.Register("mallory.cn.ofbank", mallory); err != nil {
+		t.Fatalf("failed to send claim: %v", err)
+	}
+	if _, err := rt.mallory.Register("alice.cn.ofbank", mallory); err != nil {
+		t.Fatalf("failed to send takeover: %v", err)
+	}
+	if _, err := rt.mallory.SetAccount("alice.cn.ofbank", mallory); err != nil {
+		t.Fatalf("failed to send redirection: %v", err)
+	}
+	rt.sim.Commit()
+
+	if owner := rt.owner(t, "pay.alice.cn.ofbank"); owner != alice {
+		t.Errorf("subnode owner mismatch: have %x, want %x", owner, alice)
+	}
+	if owner := rt.owner(t, "mallory.cn.ofbank"); owner != (common.Address{}) {
+		t.Errorf("name claimed by non-owner: %x", owner)
+	}
+	if owner := rt.owner(t, "alice.cn.ofbank"); owner != alice {
+		t.Errorf("name taken over by non-owner: %x", owner)
+	}
+	if account, err := rt.root.Addr(names.NameHash("alice.cn.ofbank")); err != nil || account != (common.Address{}) {
+		t.Errorf("name redirected by non-owner: %x (%v)", account, err)
+	}
+}
+
+// Tests that names resolve to the accounts their owners point them to, and that
+// reverse records are only reported if they resolve back to the account.
+func TestRegistryResolution(t *testing.T) {
+	rt := newRegistryTester(t)
+	alice, mallory := rt.alice.TransactOpts.From, rt.mallory.TransactOpts.From
+
+	for _, name := range []string{"ofbank", "cn.ofbank"} {
+		if _, err := rt.root.Register(name, rt.root.TransactOpts.From); err != nil {
+			t.Fatalf("failed to register %s: %v", name, err)
+		}
+	}
+	if _, err := rt.root.Register("alice.cn.ofbank", alice); err != nil {
+		t.Fatalf("failed to register alice.cn.ofbank: %v", err)
+	}
+	rt.sim.Commit()
+
+	// Names resolve once their owner points them to an account
+	if _, err := rt.root.Resolve(nil, "alice.cn.ofbank"); err != names.ErrNotFound {
+		t.Fatalf("unset name resolution error mismatch: have %v, want %v", err, names.ErrNotFound)
+	}
+	if _, err := rt.alice.SetAccount("Alice.CN.ofbank", alice); err != nil {
+		t.Fatalf("failed to set account: %v", err)
+	}
+	rt.sim.Commit()
+
+	for _, name := range []string{"alice.cn.ofbank", "ALICE.cn.OFBANK"} {
+		if account, err := rt.mallory.Resolve(nil, name); err != nil || account != alice {
+			t.Errorf("%s resolution mismatch: have %x (%v), want %x", name, account, err, alice)
+		}
+	}
+	if account, err := rt.mallory.Account(nil, "alice.cn.ofbank"); err != nil || account != alice {
+		t.Errorf("account mismatch: have %x (%v), want %x", account, err, alice)
+	}
+	if _, err := rt.mallory.Resolve(nil, "bob.cn.ofbank"); err != names.ErrNotFound {
+		t.Errorf("unregistered name resolution error mismatch: have %v, want %v", err, names.ErrNotFound)
+	}
+	// Reverse records are only reported while they resolve back to the account,
+	// so no account can pass itself off under another's name
+	if _, err := rt.alice.SetReverse("alice.cn.ofbank"); err != nil {
+		t.Fatalf("failed to set reverse record: %v", err)
+	}
+	if _, err := rt.mallory.SetReverse("alice.cn.ofbank"); err != nil {
+		t.Fatalf("failed to set reverse record: %v", err)
+	}
+	rt.sim.Commit()
+
+	if name, err := rt.root.Lookup(nil, alice); err != nil || name != "alice.cn.ofbank" {
+		t.Errorf("reverse lookup mismatch: have %q (%v), want %q", name, err, "alice.cn.ofbank")
+	}
+	if word, _ := rt.root.Name(mallory); word == ([32]byte{}) {
+		t.Fatalf("reverse record not stored")
+	}
+	if name, err := rt.root.Lookup(nil, mallory); err != nil || name != "" {
+		t.Errorf("reverse record resolving elsewhere reported: %q (%v)", name, err)
+	}
+	// Names too long for a single word can't be reverse records
+	if _, err := rt.alice.SetReverse("a-rather-long-label.alice.cn.ofbank"); err != names.ErrNameTooLong {
+		t.Errorf("long reverse record error mismatch: have %v, want %v", err, names.ErrNameTooLong)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// NodeRegistry is the on-chain whitelist of the nodes allowed to join a
+// permissioned network. Nodes running in permissioned mode periodically read
+// the enode URLs from it and only accept connections from the listed nodes.
+//
+// The registry is administered by a set of admins, each of whom may add and
+// remove nodes as well as other admins.
+contract NodeRegistry {
+  mapping(address => bool) admins; // Set of accounts allowed to modify the registry
+  string[]                 enodes; // Enode URLs of the whitelisted nodes
+
+  // isAdmin is a modifier to authorize registry transactions.
+  modifier isAdmin() {
+    if (admins[msg.sender]) {
+      _
+    }
+  }
+
+  // Constructor to assign the creator as the sole admin.
+  function NodeRegistry() {
+    admins[msg.sender] = true;
+  }
+
+  // count returns the number of whitelisted nodes.
+  function count() constant returns (uint) {
+    return enodes.length;
+  }
+
+  // enode returns the URL of the whitelisted node at the given index.
+  function enode(uint index) constant returns (string) {
+    return enodes[index];
+  }
+
+  // addNode whitelists a node.
+  function addNode(string url) isAdmin {
+    enodes.push(url);
+  }
+
+  // removeNode removes the node at the given index from the whitelist.
+  function removeNode(uint index) isAdmin {
+    enodes[index] = enodes[enodes.length - 1];
+    enodes.length--;
+  }
+
+  // promote grants admin rights to an account.
+  function promote(address user) isAdmin {
+    admins[user] = true;
+  }
+
+  // demote revokes the admin rights of an account.
+  function demote(address user) isAdmin {
+    admins[user] = false;
+  }
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package permission contains the node service that keeps the p2p whitelist of a
+// permissioned network in sync with an on-chain node registry.
+package permission
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/les"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Interval to check the registry for whitelist changes
+const registryRecheckInterval = time.Minute
+
+// ContractWhitelist is the whitelist source of the nodes listed in the registry.
+const ContractWhitelist = "contract"
+
+// NodeRegistryABI is the read-only subset of the node registry's interface,
+// see contract.sol for the full contract.
+const NodeRegistryABI = `[{"constant":true,"inputs":[],"name":"count","outputs":[{"name":"","type":"uint256"}],"type":"function"},{"constant":true,"inputs":[{"name":"index","type":"uint256"}],"name":"enode","outputs":[{"name":"","type":"string"}],"type":"function"}]`
+
+// NodeRegistry is a read-only Go binding around the node registry contract.
+type NodeRegistry struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// NewNodeRegistry creates a new read-only binding to a deployed node registry.
+func NewNodeRegistry(address common.Address, caller bind.ContractCaller) (*NodeRegistry, error) {
+	parsed, err := abi.JSON(strings.NewReader(NodeRegistryABI))
+	if err != nil {
+		return nil, err
+	}
+	return &NodeRegistry{contract: bind.NewBoundContract(address, parsed, caller, nil, nil)}, nil
+}
+
+// Nodes retrieves and parses all the enode URLs listed in the registry.
+func (r *NodeRegistry) Nodes(opts *bind.CallOpts) ([]*discover.Node, error) {
+	count := new(big.Int)
+	if err := r.contract.Call(opts, &count, "count"); err != nil {
+		return nil, err
+	}
+	var nodes []*discover.Node
+	for i := int64(0); i < count.Int64(); i++ {
+		var url string
+		if err := r.contract.Call(opts, &url, "enode", big.NewInt(i)); err != nil {
+			return nil, err
+		}
+		node, err := discover.ParseNode(url)
+		if err != nil {
+			log.Warn("Skipping invalid registry entry", "index", i, "url", url, "err", err)
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// Config contains the configurations of the permission service.
+type Config struct {
+	Registry common.Address // Address of the node registry contract
+}
+
+// PermissionService is a node service that periodically reads the whitelisted
+// nodes from the node registry and updates the p2p server's whitelist with them.
+type PermissionService struct {
+	config   Config          // Registry to track
+	registry *NodeRegistry   // Native binding to the node registry contract
+	server   *p2p.Server     // P2P server whose whitelist to maintain
+	quit     chan chan error // Quit channel to terminate the registry checker
+}
+
+// NewPermissionService creates a new service to keep the p2p whitelist in sync
+// with the node registry.
+func NewPermissionService(ctx *node.ServiceContext, config Config) (node.Service, error) {
+	// Retrieve the Ethereum service dependency to access the blockchain
+	var apiBackend ethapi.Backend
+	var ethereum *eth.Ethereum
+	if err := ctx.Service(&ethereum); err == nil {
+		apiBackend = ethereum.ApiBackend
+	} else {
+		var ethereum *les.LightEthereum
+		if err := ctx.Service(&ethereum); err == nil {
+			apiBackend = ethereum.ApiBackend
+		} else {
+			return nil, err
+		}
+	}
+	// Construct the permission service
+	registry, err := NewNodeRegistry(config.Registry, eth.NewContractBackend(apiBackend))
+	if err != nil {
+		return nil, err
+	}
+	return &PermissionService{
+		config:   config,
+		registry: registry,
+		quit:     make(chan chan error),
+	}, nil
+}
+
+// Protocols returns an empty list of P2P protocols as the permission service
+// does not have a networking component.
+func (p *PermissionService) Protocols() []p2p.Protocol { return nil }
+
+// APIs returns an empty list of RPC descriptors as the whitelist is exposed by
+// the admin API of the node.
+func (p *PermissionService) APIs() []rpc.API { return nil }
+
+// Start spawns the periodic registry checker goroutine.
+func (p *PermissionService) Start(server *p2p.Server) error {
+	p.server = server
+	go p.checker()
+	return nil
+}
+
+// Stop terminates all goroutines belonging to the service, blocking until they
+// are all terminated.
+func (p *PermissionService) Stop() error {
+	errc := make(chan error)
+	p.quit <- errc
+	return <-errc
+}
+
+// checker runs indefinitely in the background, periodically syncing the
+// whitelist with the registry.
+func (p *PermissionService) checker() {
+	timer := time.NewTimer(0) // Immediately fire a registry check
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			timer.Reset(registryRecheckInterval)
+			p.checkRegistry()
+		case errc := <-p.quit:
+			errc <- nil
+			return
+		}
+	}
+}
+
+func (p *PermissionService) checkRegistry() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	opts := &bind.CallOpts{Context: ctx}
+	defer cancel()
+
+	// Retrieve the whitelist, keeping the previous one if the registry can't be read
+	nodes, err := p.registry.Nodes(opts)
+	if err != nil {
+		if err == bind.ErrNoCode {
+			log.Debug("Node registry not found", "contract", p.config.Registry)
+		} else {
+			log.Error("Failed to retrieve whitelisted nodes", "err", err)
+		}
+		return
+	}
+	p.server.SetAllowedNodes(ContractWhitelist, nodes)
+}
@@ -15,11 +15,45 @@
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
 // Provides support for dealing with EVM assembly instructions (e.g., disassembling them).
+//
+// The assembler takes one instruction, label or directive per line, with ;;
+// starting a comment:
+//
+//	.include "lib.easm"        ;; compiles another file, relative to this one
+//	.const owner 0x9c00        ;; constants can be used wherever a value can
+//	.macro guard who           ;; macros take parameters and end with .endm
+//	        caller
+//	        push who
+//	        eq
+//	        jumpi @ok          ;; labels defined in a macro are local to it
+//	        push 0
+//	        dup1
+//	        revert
+//	ok:
+//	.endm
+//
+//	        guard owner
+//	        push #table        ;; #name is the length of a data section
+//	        push @table        ;; @name is the offset of a label or data section
+//	        push1 0
+//	        codecopy
+//	        jump @done
+//	done:                      ;; labels place a jumpdest
+//	        stop
+//	.data table 0x0102 "ab"    ;; data sections place raw bytes in the code
+//
+// Pushes without size take the fewest bytes fitting their value, labels
+// included, while pushN have a fixed size. Jumps given a destination push it
+// first. .label name marks an offset without a jumpdest, .bytes places raw
+// bytes without a name.
 package asm
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
+	"math/big"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/core/vm"
 )
@@ -137,3 +171,58 @@ func Disassemble(script []byte) ([]string, error) {
 	}
 	return instrs, nil
 }
+
+// DisassembleSource disassembles the code into assembler source that compiles
+// back to the very same bytes. Jump destinations are labelled and pushes of
+// their offsets refer to them, bytes that are no instructions become data.
+func DisassembleSource(script []byte) string {
+	// Label the jump destinations first, pushes may refer to later ones
+	dests := make(map[uint64]string)
+	for pc := uint64(0); pc < uint64(len(script)); pc++ {
+		op := vm.OpCode(script[pc])
+		if op == vm.JUMPDEST {
+			dests[pc] = fmt.Sprintf("dest_%04x", pc)
+		}
+		if op.IsPush() {
+			pc += uint64(op - vm.PUSH1 + 1)
+		}
+	}
+	var buf bytes.Buffer
+	for pc := uint64(0); pc < uint64(len(script)); pc++ {
+		var (
+			op    = vm.OpCode(script[pc])
+			instr string
+		)
+		switch {
+		case op == vm.JUMPDEST:
+			fmt.Fprintf(&buf, "%s:\n", dests[pc])
+			continue
+
+		case op.IsPush():
+			size := uint64(op - vm.PUSH1 + 1)
+			if pc+size >= uint64(len(script)) {
+				// Truncated push at the end of the code
+				instr = fmt.Sprintf(".bytes 0x%x", script[pc:])
+				size = uint64(len(script)) - pc - 1
+			} else {
+				arg := new(big.Int).SetBytes(script[pc+1 : pc+1+size])
+				if dest, ok := dests[arg.Uint64()]; ok && arg.BitLen() <= 64 {
+					instr = fmt.Sprintf("push%d @%s", size, dest)
+				} else {
+					instr = fmt.Sprintf("push%d 0x%x", size, script[pc+1:pc+1+size])
+				}
+			}
+			fmt.Fprintf(&buf, "\t%-32s;; %04x\n", instr, pc)
+			pc += size
+			continue
+
+		case vm.StringToOp(op.String()) == op:
+			instr = strings.ToLower(op.String())
+
+		default:
+			instr = fmt.Sprintf(".bytes 0x%02x", byte(op))
+		}
+		fmt.Fprintf(&buf, "\t%-32s;; %04x\n", instr, pc)
+	}
+	return buf.String()
+}
@@ -17,25 +17,79 @@
 package asm
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/core/vm"
 )
 
+// maxNesting is the maximum depth of nested includes and macro
+// expansions, guarding against recursive definitions.
+const maxNesting = 64
+
+// itemKind are the different kinds of items a program is made of.
+type itemKind int
+
+const (
+	opItem   itemKind = iota // a single opcode
+	pushItem                 // a push instruction with its operand
+	markItem                 // a position in the code, taking no space
+	dataItem                 // raw bytes copied verbatim
+)
+
+// item is an instruction or data laid out in the program.
+type item struct {
+	kind  itemKind
+	op    vm.OpCode // opcode of opItems
+	arg   token     // operand of pushItems, resolved during layout
+	size  int       // operand size of pushItems
+	fixed bool      // whether the operand size was given explicitly
+	data  []byte    // content of dataItems
+	tok   token     // token the item was compiled from
+	pc    int       // offset of the item in the code
+}
+
+// length returns the number of bytes the item takes in the code.
+func (it *item) length() int {
+	switch it.kind {
+	case opItem:
+		return 1
+	case pushItem:
+		return 1 + it.size
+	case dataItem:
+		return len(it.data)
+	}
+	return 0
+}
+
+// macro is a named sequence of lines, expanded with its parameters
+// substituted by the arguments of each invocation.
+type macro struct {
+	name   string
+	params []string
+	body   [][]token
+}
+
 // Compiler contains information about the parsed source
 // and holds the tokens for the program.
 type Compiler struct {
 	tokens []token
-	binary []interface{}
-
-	labels map[string]int
+	items  []*item
 
-	pc, pos int
+	labels map[string]*item  // positions of the labels and data sections
+	data   map[string]*item  // data sections by name, for their length
+	consts map[string]token  // constants with their values
+	macros map[string]*macro // macro definitions
+	nested int               // current depth of includes and macro expansions
+	expand int               // number of macro expansions so far
+	errors []error           // errors collected while compiling
 
 	debug bool
 }
@@ -43,7 +97,10 @@ type Compiler struct {
 // newCompiler returns a new allocated compiler.
 func NewCompiler(debug bool) *Compiler {
 	return &Compiler{
-		labels: make(map[string]int),
+		labels: make(map[string]*item),
+		data:   make(map[string]*item),
+		consts: make(map[string]token),
+		macros: make(map[string]*macro),
 		debug:  debug,
 	}
 }
@@ -51,187 +108,514 @@ func NewCompiler(debug bool) *Compiler {
 // Feed feeds tokens in to ch and are interpreted by
 // the compiler.
 //
-// feed is the first pass in the compile stage as it
-// collect the used labels in the program and keeps a
-// program counter which is used to determine the locations
-// of the jump dests. The labels can than be used in the
-// second stage to push labels and determine the right
-// position.
+// The tokens are only collected, the program is assembled
+// by Compile once all of them were fed.
 func (c *Compiler) Feed(ch <-chan token) {
 	for i := range ch {
-		switch i.typ {
-		case number:
-			num := math.MustParseBig256(i.text).Bytes()
-			if len(num) == 0 {
-				num = []byte{0}
-			}
-			c.pc += len(num)
-		case stringValue:
-			c.pc += len(i.text) - 2
-		case element:
-			c.pc++
-		case labelDef:
-			c.labels[i.text] = c.pc
-			c.pc++
-		case label:
-			c.pc += 5
-		}
-
 		c.tokens = append(c.tokens, i)
 	}
-	if c.debug {
-		fmt.Fprintln(os.Stderr, "found", len(c.labels), "labels")
-	}
 }
 
 // Compile compiles the current tokens and returns a
 // binary string that can be interpreted by the EVM
 // and an error if it failed.
 //
-// compile is the second stage in the compile phase
-// which compiles the tokens to EVM instructions.
+// Compiling happens in three stages. The lines of the source
+// are first turned into items, expanding includes, constants
+// and macros. The items are then laid out, growing the pushes
+// of labels until their offsets fit. Finally the items are
+// encoded into the binary.
 func (c *Compiler) Compile() (string, []error) {
-	var errors []error
-	// continue looping over the tokens until
-	// the stack has been exhausted.
-	for c.pos < len(c.tokens) {
-		if err := c.compileLine(); err != nil {
-			errors = append(errors, err)
-		}
+	c.compileLines(splitLines(c.tokens))
+	if len(c.errors) > 0 {
+		return "", c.errors
 	}
+	if err := c.layout(); err != nil {
+		return "", []error{err}
+	}
+	bin, err := c.encode()
+	if err != nil {
+		return "", []error{err}
+	}
+	return hex.EncodeToString(bin), nil
+}
 
-	// turn the binary to hex
-	var bin string
-	for _, v := range c.binary {
-		switch v := v.(type) {
-		case vm.OpCode:
-			bin += fmt.Sprintf("%x", []byte{byte(v)})
-		case []byte:
-			bin += fmt.Sprintf("%x", v)
+// splitLines groups the tokens by source line, dropping the
+// empty ones.
+func splitLines(tokens []token) [][]token {
+	var (
+		lines [][]token
+		line  []token
+	)
+	for _, t := range tokens {
+		switch t.typ {
+		case lineStart:
+			line = nil
+		case lineEnd, eof:
+			if len(line) > 0 {
+				lines = append(lines, line)
+			}
+			line = nil
+		default:
+			line = append(line, t)
 		}
 	}
-	return bin, errors
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
 }
 
-// next returns the next token and increments the
-// posititon.
-func (c *Compiler) next() token {
-	token := c.tokens[c.pos]
-	c.pos++
-	return token
+// compileLines compiles the given lines, collecting the macros
+// defined by them.
+func (c *Compiler) compileLines(lines [][]token) {
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if line[0].typ != directive || line[0].text != "macro" {
+			if err := c.compileLine(line); err != nil {
+				c.errors = append(c.errors, err)
+			}
+			continue
+		}
+		// Collect the body of the macro up to its end
+		end := i + 1
+		for ; end < len(lines); end++ {
+			if lines[end][0].typ == directive && (lines[end][0].text == "endm" || lines[end][0].text == "macro") {
+				break
+			}
+		}
+		if end == len(lines) || lines[end][0].text != "endm" {
+			c.errors = append(c.errors, errorAt(line[0], "macro without .endm"))
+			return
+		}
+		if err := c.defineMacro(line, lines[i+1:end]); err != nil {
+			c.errors = append(c.errors, err)
+		}
+		i = end
+	}
 }
 
-// compile line compiles a single line instruction e.g.
-// "push 1", "jump @labal".
-func (c *Compiler) compileLine() error {
-	n := c.next()
-	if n.typ != lineStart {
-		return compileErr(n, n.typ.String(), lineStart.String())
+// compileLine compiles a single line instruction e.g.
+// "push 1", "jump @label", "loop:" or ".const size 32".
+func (c *Compiler) compileLine(line []token) error {
+	for _, t := range line {
+		if t.typ == invalidStatement {
+			return errorAt(t, "invalid statement %q", t.text)
+		}
 	}
-
-	lvalue := c.next()
+	lvalue := line[0]
 	switch lvalue.typ {
-	case eof:
-		return nil
+	case labelDef:
+		if len(line) > 1 {
+			return compileErr(line[1], line[1].text, lineEnd.String())
+		}
+		return c.compileLabel(lvalue)
+	case directive:
+		return c.compileDirective(line)
 	case element:
-		if err := c.compileElement(lvalue); err != nil {
-			return err
+		if m, ok := c.macros[lvalue.text]; ok {
+			return c.expandMacro(m, lvalue, line[1:])
 		}
-	case labelDef:
-		c.compileLabel()
-	case lineEnd:
-		return nil
+		return c.compileElement(line)
 	default:
-		return compileErr(lvalue, lvalue.text, fmt.Sprintf("%v or %v", labelDef, element))
+		return compileErr(lvalue, lvalue.text, fmt.Sprintf("%v, %v or %v", labelDef, element, directive))
 	}
+}
 
-	if n := c.next(); n.typ != lineEnd {
-		return compileErr(n, n.text, lineEnd.String())
-	}
+// compileElement compiles the element (push & label or both)
+// to a binary representation and may error if incorrect statements
+// where fed.
+func (c *Compiler) compileElement(line []token) error {
+	instr := line[0]
+	name := strings.ToLower(instr.text)
+
+	switch {
+	case isPush(name):
+		// pushes infer the size of their operand
+		if len(line) != 2 {
+			return compileErr(instr, fmt.Sprintf("%d operands", len(line)-1), "1 operand")
+		}
+		arg, err := c.operand(line[1])
+		if err != nil {
+			return err
+		}
+		c.pushItem(&item{kind: pushItem, arg: arg, tok: instr})
 
+	case strings.HasPrefix(name, "push") && toBinary(name).IsPush():
+		// pushN have a fixed operand size
+		if len(line) != 2 {
+			return compileErr(instr, fmt.Sprintf("%d operands", len(line)-1), "1 operand")
+		}
+		arg, err := c.operand(line[1])
+		if err != nil {
+			return err
+		}
+		size := int(toBinary(name)-vm.PUSH1) + 1
+		c.pushItem(&item{kind: pushItem, arg: arg, size: size, fixed: true, tok: instr})
+
+	case isJump(name) && len(line) == 2:
+		// jumps to a destination push it first
+		arg, err := c.operand(line[1])
+		if err != nil {
+			return err
+		}
+		c.pushItem(&item{kind: pushItem, arg: arg, tok: instr})
+		c.pushItem(&item{kind: opItem, op: toBinary(name), tok: instr})
+
+	default:
+		op := toBinary(name)
+		if op == vm.STOP && name != "stop" {
+			return errorAt(instr, "unknown instruction %q", instr.text)
+		}
+		if len(line) > 1 {
+			return compileErr(line[1], line[1].text, lineEnd.String())
+		}
+		c.pushItem(&item{kind: opItem, op: op, tok: instr})
+	}
 	return nil
 }
 
-// compileNumber compiles the number to bytes
-func (c *Compiler) compileNumber(element token) (int, error) {
-	num := math.MustParseBig256(element.text).Bytes()
-	if len(num) == 0 {
-		num = []byte{0}
+// compileLabel pushes a jumpdest to the binary slice.
+func (c *Compiler) compileLabel(label token) error {
+	it := &item{kind: opItem, op: vm.JUMPDEST, tok: label}
+	if err := c.defineLabel(label, it); err != nil {
+		return err
 	}
-	c.pushBin(num)
-	return len(num), nil
+	c.pushItem(it)
+	return nil
 }
 
-// compileElement compiles the element (push & label or both)
-// to a binary representation and may error if incorrect statements
-// where fed.
-func (c *Compiler) compileElement(element token) error {
-	// check for a jump. jumps must be read and compiled
-	// from right to left.
-	if isJump(element.text) {
-		rvalue := c.next()
-		switch rvalue.typ {
-		case number:
-			// TODO figure out how to return the error properly
-			c.compileNumber(rvalue)
-		case stringValue:
-			// strings are quoted, remove them.
-			c.pushBin(rvalue.text[1 : len(rvalue.text)-2])
-		case label:
-			c.pushBin(vm.PUSH4)
-			pos := big.NewInt(int64(c.labels[rvalue.text])).Bytes()
-			pos = append(make([]byte, 4-len(pos)), pos...)
-			c.pushBin(pos)
-		default:
-			return compileErr(rvalue, rvalue.text, "number, string or label")
+// compileDirective compiles an assembler directive:
+//
+//	.const name value     defines a constant usable as an operand
+//	.include "file"       compiles the file, relative to the current one
+//	.label name           marks a position in the code without a jumpdest
+//	.data name value...   places bytes in the code, @name and #name give
+//	                      their offset and length
+//	.bytes value...       places bytes in the code
+//
+// Macros are defined with .macro name params... and end with .endm.
+func (c *Compiler) compileDirective(line []token) error {
+	dir := line[0]
+	switch dir.text {
+	case "const":
+		if len(line) != 3 || line[1].typ != element {
+			return errorAt(dir, "want .const name value")
+		}
+		name := line[1]
+		if _, ok := c.consts[name.text]; ok {
+			return errorAt(name, "constant %q redefined", name.text)
+		}
+		value, err := c.operand(line[2])
+		if err != nil {
+			return err
+		}
+		c.consts[name.text] = value
+		return nil
+
+	case "include":
+		if len(line) != 2 || line[1].typ != stringValue {
+			return errorAt(dir, `want .include "file"`)
+		}
+		return c.include(dir, unquote(line[1]))
+
+	case "label":
+		if len(line) != 2 || line[1].typ != element {
+			return errorAt(dir, "want .label name")
+		}
+		it := &item{kind: markItem, tok: line[1]}
+		if err := c.defineLabel(line[1], it); err != nil {
+			return err
 		}
-		// push the operation
-		c.pushBin(toBinary(element.text))
+		c.pushItem(it)
 		return nil
-	} else if isPush(element.text) {
-		// handle pushes. pushes are read from left to right.
-		var value []byte
-
-		rvalue := c.next()
-		switch rvalue.typ {
-		case number:
-			value = math.MustParseBig256(rvalue.text).Bytes()
-			if len(value) == 0 {
-				value = []byte{0}
+
+	case "data", "bytes":
+		args := line[1:]
+		if dir.text == "data" {
+			if len(line) < 3 || line[1].typ != element {
+				return errorAt(dir, "want .data name value...")
 			}
-		case stringValue:
-			value = []byte(rvalue.text[1 : len(rvalue.text)-1])
-		case label:
-			value = make([]byte, 4)
-			copy(value, big.NewInt(int64(c.labels[rvalue.text])).Bytes())
-		default:
-			return compileErr(rvalue, rvalue.text, "number, string or label")
+			args = line[2:]
+		} else if len(line) < 2 {
+			return errorAt(dir, "want .bytes value...")
+		}
+		var blob []byte
+		for _, arg := range args {
+			b, err := c.rawBytes(arg)
+			if err != nil {
+				return err
+			}
+			blob = append(blob, b...)
 		}
+		it := &item{kind: dataItem, data: blob, tok: dir}
+		if dir.text == "data" {
+			if err := c.defineLabel(line[1], it); err != nil {
+				return err
+			}
+			c.data[line[1].text] = it
+		}
+		c.pushItem(it)
+		return nil
 
-		if len(value) > 32 {
-			return fmt.Errorf("%d type error: unsupported string or number with size > 32", rvalue.lineno)
+	case "endm":
+		return errorAt(dir, ".endm without macro")
+	}
+	return errorAt(dir, "unknown directive .%s", dir.text)
+}
+
+// include compiles the lines of the named file, resolved relative to the
+// file including it.
+func (c *Compiler) include(dir token, name string) error {
+	if c.nested >= maxNesting {
+		return errorAt(dir, "includes nested too deeply")
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(dir.file), name)
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errorAt(dir, "%v", err)
+	}
+	var tokens []token
+	for t := range Lex(path, src, c.debug) {
+		tokens = append(tokens, t)
+	}
+	c.nested++
+	c.compileLines(splitLines(tokens))
+	c.nested--
+	return nil
+}
+
+// defineMacro registers the macro declared by the given line with its body.
+func (c *Compiler) defineMacro(decl []token, body [][]token) error {
+	if len(decl) < 2 || decl[1].typ != element {
+		return errorAt(decl[0], "want .macro name params...")
+	}
+	name := decl[1]
+	if _, ok := c.macros[name.text]; ok {
+		return errorAt(name, "macro %q redefined", name.text)
+	}
+	if lower := strings.ToLower(name.text); isPush(lower) || isJump(lower) || toBinary(lower) != vm.STOP || lower == "stop" {
+		return errorAt(name, "macro %q shadows an instruction", name.text)
+	}
+	m := &macro{name: name.text, body: body}
+	for _, param := range decl[2:] {
+		if param.typ != element {
+			return compileErr(param, param.text, "parameter name")
 		}
+		m.params = append(m.params, param.text)
+	}
+	c.macros[m.name] = m
+	return nil
+}
 
-		c.pushBin(vm.OpCode(int(vm.PUSH1) - 1 + len(value)))
-		c.pushBin(value)
-	} else {
-		c.pushBin(toBinary(element.text))
+// expandMacro compiles the body of the macro with its parameters substituted
+// by the given arguments. Labels defined in the body are renamed, so that each
+// expansion has its own.
+func (c *Compiler) expandMacro(m *macro, call token, args []token) error {
+	if len(args) != len(m.params) {
+		return errorAt(call, "macro %q takes %d arguments, got %d", m.name, len(m.params), len(args))
 	}
+	if c.nested >= maxNesting {
+		return errorAt(call, "macro expansions nested too deeply")
+	}
+	c.expand++
+
+	locals := make(map[string]string)
+	for _, line := range m.body {
+		switch {
+		case line[0].typ == labelDef:
+			locals[line[0].text] = fmt.Sprintf("%s_%d_%s", m.name, c.expand, line[0].text)
+		case line[0].typ == directive && (line[0].text == "label" || line[0].text == "data") && len(line) > 1:
+			locals[line[1].text] = fmt.Sprintf("%s_%d_%s", m.name, c.expand, line[1].text)
+		}
+	}
+	params := make(map[string]token)
+	for i, param := range m.params {
+		params[param] = args[i]
+	}
+	lines := make([][]token, len(m.body))
+	for i, line := range m.body {
+		lines[i] = make([]token, len(line))
+		for j, t := range line {
+			switch {
+			case t.typ == element && j > 0:
+				if arg, ok := params[t.text]; ok {
+					t = arg
+				} else if local, ok := locals[t.text]; ok && line[0].typ == directive {
+					t.text = local
+				}
+			case t.typ == labelDef || t.typ == label || t.typ == lengthRef:
+				if local, ok := locals[t.text]; ok {
+					t.text = local
+				}
+			}
+			lines[i][j] = t
+		}
+	}
+	c.nested++
+	c.compileLines(lines)
+	c.nested--
+	return nil
+}
 
+// defineLabel associates the label with the position of the item.
+func (c *Compiler) defineLabel(label token, it *item) error {
+	if _, ok := c.labels[label.text]; ok {
+		return errorAt(label, "label %q redefined", label.text)
+	}
+	c.labels[label.text] = it
 	return nil
 }
 
-// compileLabel pushes a jumpdest to the binary slice.
-func (c *Compiler) compileLabel() {
-	c.pushBin(vm.JUMPDEST)
+// operand checks the operand of an instruction, replacing constants by their
+// value.
+func (c *Compiler) operand(arg token) (token, error) {
+	switch arg.typ {
+	case number, stringValue, label, lengthRef:
+		return arg, nil
+	case element:
+		if value, ok := c.consts[arg.text]; ok {
+			return value, nil
+		}
+		return arg, errorAt(arg, "undefined constant %q", arg.text)
+	}
+	return arg, compileErr(arg, arg.text, "number, string, label or constant")
 }
 
-// pushBin pushes the value v to the binary stack.
-func (c *Compiler) pushBin(v interface{}) {
+// rawBytes returns the bytes placed in the code by a data operand. Hex numbers
+// keep their leading zeros.
+func (c *Compiler) rawBytes(arg token) ([]byte, error) {
+	arg, err := c.operand(arg)
+	if err != nil {
+		return nil, err
+	}
+	switch arg.typ {
+	case number:
+		if text := strings.ToLower(arg.text); strings.HasPrefix(text, "0x") {
+			b, err := hex.DecodeString(text[2:])
+			if err != nil {
+				return nil, errorAt(arg, "invalid hex data %q", arg.text)
+			}
+			return b, nil
+		}
+		return c.value(arg)
+	case stringValue:
+		return []byte(unquote(arg)), nil
+	}
+	return nil, errorAt(arg, "data must be a number or string, not a %v", arg.typ)
+}
+
+// value returns the minimal big endian encoding of an operand, at least one
+// byte long. Labels and lengths are only known once laid out.
+func (c *Compiler) value(arg token) ([]byte, error) {
+	var n *big.Int
+	switch arg.typ {
+	case number:
+		v, ok := math.ParseBig256(arg.text)
+		if !ok {
+			return nil, errorAt(arg, "invalid number %q", arg.text)
+		}
+		n = v
+	case stringValue:
+		return []byte(unquote(arg)), nil
+	case label:
+		it, ok := c.labels[arg.text]
+		if !ok {
+			return nil, errorAt(arg, "undefined label %q", arg.text)
+		}
+		n = big.NewInt(int64(it.pc))
+	case lengthRef:
+		it, ok := c.data[arg.text]
+		if !ok {
+			return nil, errorAt(arg, "undefined data %q", arg.text)
+		}
+		n = big.NewInt(int64(len(it.data)))
+	default:
+		return nil, compileErr(arg, arg.text, "number, string, label or length")
+	}
+	if n.Sign() == 0 {
+		return []byte{0}, nil
+	}
+	return n.Bytes(), nil
+}
+
+// layout assigns the offsets of the items. Pushes with an inferred size start
+// at the size of their known value and grow until the offsets they refer to
+// fit, which terminates as sizes never shrink.
+func (c *Compiler) layout() error {
+	for _, it := range c.items {
+		if it.kind == pushItem && !it.fixed {
+			it.size = 1
+		}
+	}
+	for changed := true; changed; {
+		pc := 0
+		for _, it := range c.items {
+			it.pc = pc
+			pc += it.length()
+		}
+		changed = false
+		for _, it := range c.items {
+			if it.kind != pushItem || it.fixed {
+				continue
+			}
+			v, err := c.value(it.arg)
+			if err != nil {
+				return err
+			}
+			if len(v) > 32 {
+				return errorAt(it.arg, "operand of %d bytes does not fit a push", len(v))
+			}
+			if len(v) > it.size {
+				it.size, changed = len(v), true
+			}
+		}
+	}
 	if c.debug {
-		fmt.Printf("%d: %v\n", len(c.binary), v)
+		fmt.Fprintln(os.Stderr, "found", len(c.labels), "labels")
 	}
-	c.binary = append(c.binary, v)
+	return nil
+}
+
+// encode returns the binary of the laid out items.
+func (c *Compiler) encode() ([]byte, error) {
+	var bin []byte
+	for _, it := range c.items {
+		switch it.kind {
+		case opItem:
+			bin = append(bin, byte(it.op))
+		case pushItem:
+			v, err := c.value(it.arg)
+			if err != nil {
+				return nil, err
+			}
+			if len(v) > it.size {
+				return nil, errorAt(it.arg, "operand of %d bytes does not fit push%d", len(v), it.size)
+			}
+			bin = append(bin, byte(vm.PUSH1)+byte(it.size-1))
+			bin = append(bin, make([]byte, it.size-len(v))...)
+			bin = append(bin, v...)
+		case dataItem:
+			bin = append(bin, it.data...)
+		}
+		if c.debug {
+			fmt.Fprintf(os.Stderr, "%06d: %x\n", it.pc, bin[it.pc:])
+		}
+	}
+	return bin, nil
+}
+
+// pushItem appends the item to the program.
+func (c *Compiler) pushItem(it *item) {
+	c.items = append(c.items, it)
+}
+
+// unquote returns the content of a string token.
+func unquote(t token) string {
+	return t.text[1 : len(t.text)-1]
 }
 
 // isPush returns whether the string op is either any of
@@ -260,11 +644,12 @@ type compileError struct {
 	got  string
 	want string
 
+	file   string
 	lineno int
 }
 
 func (err compileError) Error() string {
-	return fmt.Sprintf("%d syntax error: unexpected %v, expected %v", err.lineno, err.got, err.want)
+	return fmt.Sprintf("%s:%d: syntax error: unexpected %v, expected %v", err.file, err.lineno, err.got, err.want)
 }
 
 var (
@@ -276,6 +661,12 @@ func compileErr(c token, got, want string) error {
 	return compileError{
 		got:    got,
 		want:   want,
+		file:   c.file,
 		lineno: c.lineno,
 	}
 }
+
+// errorAt returns an error located at the given token.
+func errorAt(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", t.file, t.lineno, fmt.Sprintf(format, args...))
+}
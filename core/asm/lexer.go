@@ -34,6 +34,7 @@ type stateFn func(*lexer) stateFn
 // the tokens channels of the lexer
 type token struct {
 	typ    tokenType
+	file   string
 	lineno int
 	text   string
 }
@@ -52,6 +53,8 @@ const (
 	labelDef                          // label definition is emitted when a new label is found
 	number                            // number is emitted when a number is found
 	stringValue                       // stringValue is emitted when a string has been found
+	directive                         // directive is emitted when an assembler directive is found
+	lengthRef                         // lengthRef is emitted when the length of a data section is referenced
 
 	Numbers            = "1234567890"                                           // characters representing any decimal number
 	HexadecimalNumbers = Numbers + "aAbBcCdDeEfF"                               // characters representing any hexadecimal
@@ -76,12 +79,15 @@ var stringtokenTypes = []string{
 	labelDef:         "label definition",
 	number:           "number",
 	stringValue:      "string",
+	directive:        "directive",
+	lengthRef:        "length reference",
 }
 
 // lexer is the basic construct for parsing
 // source code and turning them in to tokens.
 // Tokens are interpreted by the compiler.
 type lexer struct {
+	name  string // name of the source file, used to report errors and resolve includes
 	input string // input contains the source code of the program
 
 	tokens chan token // tokens is used to deliver tokens to the listener
@@ -98,9 +104,11 @@ type lexer struct {
 func Lex(name string, source []byte, debug bool) <-chan token {
 	ch := make(chan token)
 	l := &lexer{
+		name:   name,
 		input:  string(source),
 		tokens: ch,
 		state:  lexLine,
+		lineno: 1,
 		debug:  debug,
 	}
 	go func() {
@@ -182,7 +190,7 @@ func (l *lexer) blob() string {
 
 // Emits a new token on to token channel for processing
 func (l *lexer) emit(t tokenType) {
-	token := token{t, l.lineno, l.blob()}
+	token := token{t, l.name, l.lineno, l.blob()}
 
 	if l.debug {
 		fmt.Fprintf(os.Stderr, "%04d: (%-20v) %s\n", token.lineno, token.typ, token.text)
@@ -215,7 +223,16 @@ func lexLine(l *lexer) stateFn {
 			return lexLabel
 		case r == '"':
 			return lexInsideString
+		case r == '.':
+			l.ignore()
+			return lexDirective
+		case r == '#':
+			l.ignore()
+			return lexLength
+		case r == 0 && l.pos >= len(l.input):
+			return nil
 		default:
+			l.emit(invalidStatement)
 			return nil
 		}
 	}
@@ -224,7 +241,10 @@ func lexLine(l *lexer) stateFn {
 // lexComment parses the current position until the end
 // of the line and discards the text.
 func lexComment(l *lexer) stateFn {
-	l.acceptRunUntil('\n')
+	if l.acceptRunUntil('\n') {
+		// leave the newline to end the line
+		l.backup()
+	}
 	l.ignore()
 
 	return lexLine
@@ -234,20 +254,42 @@ func lexComment(l *lexer) stateFn {
 // the lex text state function to advance the parsing
 // process.
 func lexLabel(l *lexer) stateFn {
-	l.acceptRun(Alpha + "_")
+	l.acceptRun(Alpha + "_" + Numbers)
 
 	l.emit(label)
 
 	return lexLine
 }
 
+// lexDirective parses the name of an assembler directive,
+// e.g. ".const" or ".include", and emits it without the dot.
+func lexDirective(l *lexer) stateFn {
+	l.acceptRun(Alpha)
+
+	l.emit(directive)
+
+	return lexLine
+}
+
+// lexLength parses the name of the data section whose length
+// is referenced, e.g. "#table".
+func lexLength(l *lexer) stateFn {
+	l.acceptRun(Alpha + "_" + Numbers)
+
+	l.emit(lengthRef)
+
+	return lexLine
+}
+
 // lexInsideString lexes the inside of a string until
 // until the state function finds the closing quote.
 // It returns the lex text state function.
 func lexInsideString(l *lexer) stateFn {
-	if l.acceptRunUntil('"') {
-		l.emit(stringValue)
+	if !l.acceptRunUntil('"') {
+		l.emit(invalidStatement)
+		return nil
 	}
+	l.emit(stringValue)
 
 	return lexLine
 }
@@ -96,6 +96,9 @@ type BlockChain struct {
 	vmConfig  vm.Config
 
 	badBlocks *lru.Cache // Bad block cache
+
+	freezeThreshold uint64     // Number of blocks after which data is moved into the freezer (atomic)
+	freezeLock      sync.Mutex // Lock serialising moves into the freezer
 }
 
 // NewBlockChain returns a fully initialised block chain using information
@@ -121,6 +124,8 @@ func NewBlockChain(chainDb ethdb.Database, config *params.ChainConfig, engine co
 		engine:       engine,
 		vmConfig:     vmConfig,
 		badBlocks:    badBlocks,
+
+		freezeThreshold: params.ImmutabilityThreshold,
 	}
 	bc.SetValidator(NewBlockValidator(config, bc, engine))
 	bc.SetProcessor(NewStateProcessor(config, bc, engine))
@@ -137,6 +142,10 @@ func NewBlockChain(chainDb ethdb.Database, config *params.ChainConfig, engine co
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+	// Make sure the ancient freezer is consistent with the key-value store
+	if err := bc.repairAncients(); err != nil {
+		return nil, err
+	}
 	// Check the current state of the block hashes and make sure that we do not have any of the bad blocks in our chain
 	for hash := range BadHashes {
 		if header := bc.GetHeaderByHash(hash); header != nil {
@@ -152,6 +161,17 @@ func NewBlockChain(chainDb ethdb.Database, config *params.ChainConfig, engine co
 	}
 	// Take ownership of this particular state
 	go bc.update()
+
+	// Start moving ancient data into the freezer if the database has one. The
+	// freezer needs the full history, which snapshot initialized nodes lack.
+	if _, ok := chainDb.(ethdb.AncientStore); ok {
+		if base := GetHistoryBase(chainDb); base > 0 {
+			log.Warn("Chain history pruned, ancient freezer disabled", "base", base)
+		} else {
+			bc.wg.Add(1)
+			go bc.freeze()
+		}
+	}
 	return bc, nil
 }
 
@@ -231,6 +251,12 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
 
+	// Drop any frozen blocks above the new head, they are not canonical anymore
+	if ancients, ok := bc.chainDb.(ethdb.AncientStore); ok {
+		if err := ancients.TruncateAncients(currentHeader.Number.Uint64() + 1); err != nil {
+			log.Error("Failed to truncate ancient store", "err", err)
+		}
+	}
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
@@ -0,0 +1,239 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// freezerRecheckInterval is the frequency to check the key-value database for
+	// chain progression that might permit new blocks to be frozen into immutable
+	// storage.
+	freezerRecheckInterval = time.Minute
+
+	// freezerBatchLimit is the maximum number of blocks to freeze in one batch
+	// before doing an fsync and deleting it from the key-value store.
+	freezerBatchLimit = 30000
+)
+
+// SetFreezeThreshold sets the number of blocks a canonical block needs to be
+// buried under before it is moved from the key-value store into the ancient
+// freezer. A zero threshold disables freezing altogether.
+func (bc *BlockChain) SetFreezeThreshold(threshold uint64) {
+	atomic.StoreUint64(&bc.freezeThreshold, threshold)
+}
+
+// Ancients returns the number of blocks stored in the chain's ancient freezer,
+// or zero if the chain database isn't backed by one.
+func (bc *BlockChain) Ancients() uint64 {
+	ancients, ok := bc.chainDb.(ethdb.AncientStore)
+	if !ok {
+		return 0
+	}
+	frozen, _ := ancients.Ancients()
+	return frozen
+}
+
+// freeze is a background thread that periodically checks the blockchain for any
+// import progress and moves ancient data from the fast database into the freezer.
+func (bc *BlockChain) freeze() {
+	defer bc.wg.Done()
+
+	timer := time.NewTimer(freezerRecheckInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-bc.quit:
+			return
+		case <-timer.C:
+		}
+		for {
+			frozen, err := bc.FreezeAncients()
+			if err != nil {
+				log.Error("Failed to freeze ancient blocks", "err", err)
+				break
+			}
+			// Keep going while full batches are being moved, otherwise wait for
+			// the chain to progress
+			if frozen < freezerBatchLimit || atomic.LoadInt32(&bc.running) != 0 {
+				break
+			}
+		}
+		timer.Reset(freezerRecheckInterval)
+	}
+}
+
+// FreezeAncients moves a single batch of canonical blocks older than the freeze
+// threshold from the key-value store into the ancient freezer, returning the
+// number of blocks moved.
+func (bc *BlockChain) FreezeAncients() (int, error) {
+	ancients, ok := bc.chainDb.(ethdb.AncientStore)
+	if !ok {
+		return 0, nil
+	}
+	threshold := atomic.LoadUint64(&bc.freezeThreshold)
+	if threshold == 0 {
+		return 0, nil
+	}
+	if base := GetHistoryBase(bc.chainDb); base > 0 {
+		return 0, fmt.Errorf("chain history pruned below block %d, can't freeze", base)
+	}
+	bc.freezeLock.Lock()
+	defer bc.freezeLock.Unlock()
+
+	bc.mu.RLock()
+	head := bc.currentBlock.NumberU64()
+	bc.mu.RUnlock()
+
+	if head <= threshold {
+		return 0, nil
+	}
+	limit := head - threshold
+	frozen, err := ancients.Ancients()
+	if err != nil {
+		return 0, err
+	}
+	if frozen >= limit {
+		return 0, nil
+	}
+	if limit-frozen > freezerBatchLimit {
+		limit = frozen + freezerBatchLimit
+	}
+	// Seems we have data ready to be frozen, process in usable batches
+	var (
+		start  = time.Now()
+		first  = frozen
+		hashes []common.Hash
+	)
+	for number := first; number < limit; number++ {
+		hash := GetCanonicalHash(bc.chainDb, number)
+		if hash == (common.Hash{}) {
+			return len(hashes), fmt.Errorf("canonical hash missing, can't freeze block %d", number)
+		}
+		header := GetHeaderRLP(bc.chainDb, hash, number)
+		if len(header) == 0 {
+			return len(hashes), fmt.Errorf("block header missing, can't freeze block %d", number)
+		}
+		body := GetBodyRLP(bc.chainDb, hash, number)
+		if len(body) == 0 {
+			return len(hashes), fmt.Errorf("block body missing, can't freeze block %d", number)
+		}
+		receipts := GetBlockReceiptsRLP(bc.chainDb, hash, number)
+		if len(receipts) == 0 {
+			return len(hashes), fmt.Errorf("block receipts missing, can't freeze block %d", number)
+		}
+		td := GetTdRLP(bc.chainDb, hash, number)
+		if len(td) == 0 {
+			return len(hashes), fmt.Errorf("total difficulty missing, can't freeze block %d", number)
+		}
+		// Inject all the components into the relevant data tables
+		if err := ancients.AppendAncient(number, hash[:], header, body, receipts, td); err != nil {
+			return len(hashes), err
+		}
+		hashes = append(hashes, hash)
+	}
+	// Flush the freezer to disk before irreversibly deleting the key-value data
+	if err := ancients.SyncAncient(); err != nil {
+		return 0, err
+	}
+	// Wipe out all data from the active database. The genesis block is retained
+	// in the key-value store as it's needed to open the chain in the first place.
+	for i, hash := range hashes {
+		if number := first + uint64(i); number > 0 {
+			deleteFrozenBlock(bc.chainDb, hash, number)
+		}
+	}
+	bc.purgeCaches()
+
+	context := []interface{}{
+		"blocks", len(hashes), "elapsed", common.PrettyDuration(time.Since(start)), "number", limit - 1,
+	}
+	if n := len(hashes); n > 0 {
+		context = append(context, []interface{}{"hash", hashes[n-1]}...)
+	}
+	log.Info("Deep froze chain segment", context...)
+	return len(hashes), nil
+}
+
+// purgeCaches drops all the block data caches, which might be referencing data
+// that has moved into the freezer.
+func (bc *BlockChain) purgeCaches() {
+	bc.bodyCache.Purge()
+	bc.bodyRLPCache.Purge()
+	bc.blockCache.Purge()
+}
+
+// repairAncients cross checks the freezer against the canonical chain stored in
+// the key-value database on startup. Frozen blocks that are no longer canonical
+// (or above the current head header) are truncated, and a gap between the
+// freezer and the key-value store is reported as an unrecoverable error.
+func (bc *BlockChain) repairAncients() error {
+	ancients, ok := bc.chainDb.(ethdb.AncientStore)
+	if !ok {
+		return nil
+	}
+	frozen, err := ancients.Ancients()
+	if err != nil {
+		return err
+	}
+	if frozen == 0 {
+		return nil
+	}
+	// Never keep frozen blocks above the current chain head
+	head := bc.hc.CurrentHeader().Number.Uint64()
+	if frozen > head+1 {
+		log.Warn("Truncating ancients above chain head", "frozen", frozen, "head", head)
+		if err := ancients.TruncateAncients(head + 1); err != nil {
+			return err
+		}
+		frozen = head + 1
+	}
+	// Drop any frozen blocks that are not part of the canonical chain anymore
+	for frozen > 0 {
+		blob, err := ancients.Ancient(ethdb.FreezerHashTable, frozen-1)
+		if err != nil {
+			return err
+		}
+		if common.BytesToHash(blob) == GetCanonicalHash(bc.chainDb, frozen-1) {
+			break
+		}
+		frozen--
+	}
+	if current, _ := ancients.Ancients(); current != frozen {
+		log.Warn("Truncating non-canonical ancients", "frozen", current, "canonical", frozen)
+		if err := ancients.TruncateAncients(frozen); err != nil {
+			return err
+		}
+	}
+	// Ensure there's no gap between the freezer and the key-value store
+	if frozen <= head {
+		hash := GetCanonicalHash(bc.chainDb, frozen)
+		if len(GetHeaderRLP(bc.chainDb, hash, frozen)) == 0 {
+			return fmt.Errorf("gap in the chain between ancients (#%d) and the key-value store", frozen)
+		}
+	}
+	log.Info("Verified ancient chain data", "frozen", frozen, "head", head)
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/consensus/misc"
 	"github.com/ethereum/go-ethereum/core/state"
@@ -37,6 +38,7 @@ type BlockGen struct {
 	uncles   []*types.Header
 
 	config *params.ChainConfig
+	db     ethdb.Database
 }
 
 // SetCoinbase sets the coinbase of the generated block.
@@ -57,6 +59,12 @@ func (b *BlockGen) SetExtra(data []byte) {
 	b.header.Extra = data
 }
 
+// ModifyState calls fn with the state the block is generated on, e.g. to seed
+// account balances and coinage. It should be called before adding transactions.
+func (b *BlockGen) ModifyState(fn func(statedb *state.StateDB)) {
+	fn(b.statedb)
+}
+
 // AddTx adds a transaction to the generated block. If no coinbase has
 // been set, the block's coinbase is set to the zero address.
 //
@@ -127,7 +135,7 @@ func (b *BlockGen) OffsetTime(seconds int64) {
 	if b.header.Time.Cmp(b.parent.Header().Time) <= 0 {
 		panic("block time out of range")
 	}
-	b.header.Difficulty = ethash.CalcDifficulty(b.config, b.header.Time.Uint64(), b.parent.Header())
+	b.header.Difficulty = ethash.CalcDifficultyChain(&chainReader{b.config, b.db, b.chain[:b.i]}, b.header.Time.Uint64(), b.parent.Header())
 }
 
 // GenerateChain creates a chain of n blocks. The first block's parent will
@@ -148,7 +156,7 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, db ethdb.Dat
 	}
 	blocks, receipts := make(types.Blocks, n), make([]types.Receipts, n)
 	genblock := func(i int, h *types.Header, statedb *state.StateDB) (*types.Block, types.Receipts) {
-		b := &BlockGen{parent: parent, i: i, chain: blocks, header: h, statedb: statedb, config: config}
+		b := &BlockGen{parent: parent, i: i, chain: blocks, header: h, statedb: statedb, config: config, db: db}
 		// Mutate the state and block according to any hard-fork specs
 		if daoBlock := config.DAOForkBlock; daoBlock != nil {
 			limit := new(big.Int).Add(daoBlock, params.DAOForkExtraRange)
@@ -165,7 +173,7 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, db ethdb.Dat
 		if gen != nil {
 			gen(i, b)
 		}
-		ethash.AccumulateRewards(statedb, h, b.uncles)
+		ethash.AccumulateRewards(config, statedb, h, b.uncles)
 		root, err := statedb.CommitTo(db, config.IsEIP158(h.Number))
 		if err != nil {
 			panic(fmt.Sprintf("state write error: %v", err))
@@ -178,7 +186,7 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, db ethdb.Dat
 		if err != nil {
 			panic(err)
 		}
-		header := makeHeader(config, parent, statedb)
+		header := makeHeader(&chainReader{config, db, blocks[:i]}, parent, statedb)
 		block, receipt := genblock(i, header, statedb)
 		blocks[i] = block
 		receipts[i] = receipt
@@ -187,7 +195,9 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, db ethdb.Dat
 	return blocks, receipts
 }
 
-func makeHeader(config *params.ChainConfig, parent *types.Block, state *state.StateDB) *types.Header {
+func makeHeader(chain consensus.ChainReader, parent *types.Block, state *state.StateDB) *types.Header {
+	config := chain.Config()
+
 	var time *big.Int
 	if parent.Time() == nil {
 		time = big.NewInt(10)
@@ -195,15 +205,7 @@ func makeHeader(config *params.ChainConfig, parent *types.Block, state *state.St
 		time = new(big.Int).Add(parent.Time(), big.NewInt(10)) // block time is fixed at 10 seconds
 	}
 
-	bbbb := types.Dahong  { Difficulty : parent.Difficulty() }	// Water Key
-	cccc := types.Dahong  { Difficulty : 
-							ethash.CalcDifficulty(config, time.Uint64(), &types.Header {
-													Number:     parent.Number(),
-													Time:       new(big.Int).Sub(time, big.NewInt(10)),
-													Dahong: 	bbbb,						// Water Key
-													UncleHash:  parent.UncleHash(),
-												}),
-					   	  }	
+	cccc := types.Dahong{Difficulty: ethash.CalcDifficultyChain(chain, time.Uint64(), parent.Header())}
 
 	return &types.Header{
 		Root:       state.IntermediateRoot(config.IsEIP158(parent.Number())),
@@ -218,6 +220,55 @@ func makeHeader(config *params.ChainConfig, parent *types.Block, state *state.St
 	}
 }
 
+// chainReader is a consensus.ChainReader over the blocks generated so far and
+// the database holding their ancestors, letting the difficulty adjustment look
+// further back than the parent.
+type chainReader struct {
+	config *params.ChainConfig
+	db     ethdb.Database
+	blocks []*types.Block
+}
+
+func (cr *chainReader) Config() *params.ChainConfig { return cr.config }
+
+func (cr *chainReader) CurrentHeader() *types.Header {
+	if len(cr.blocks) == 0 {
+		return nil
+	}
+	return cr.blocks[len(cr.blocks)-1].Header()
+}
+
+func (cr *chainReader) GetBlock(hash common.Hash, number uint64) *types.Block {
+	for _, block := range cr.blocks {
+		if block.Hash() == hash {
+			return block
+		}
+	}
+	return GetBlock(cr.db, hash, number)
+}
+
+func (cr *chainReader) GetHeader(hash common.Hash, number uint64) *types.Header {
+	for _, block := range cr.blocks {
+		if block.Hash() == hash {
+			return block.Header()
+		}
+	}
+	return GetHeader(cr.db, hash, number)
+}
+
+func (cr *chainReader) GetHeaderByNumber(number uint64) *types.Header {
+	for _, block := range cr.blocks {
+		if block.NumberU64() == number {
+			return block.Header()
+		}
+	}
+	return GetHeader(cr.db, GetCanonicalHash(cr.db, number), number)
+}
+
+func (cr *chainReader) GetHeaderByHash(hash common.Hash) *types.Header {
+	return cr.GetHeader(hash, GetBlockNumber(cr.db, hash))
+}
+
 // newCanonical creates a chain database, and injects a deterministic canonical
 // chain. Depending on the full flag, if creates either a full block chain or a
 // header only chain.
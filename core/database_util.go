@@ -23,6 +23,7 @@ var (
 	headHeaderKey = []byte("LastHeader")
 	headBlockKey  = []byte("LastBlock")
 	headFastKey   = []byte("LastFast")
+	historyKey    = []byte("HistoryBase")
 
 	headerPrefix        = []byte("h")   // headerPrefix + num (uint64 big endian) + hash -> header
 	tdSuffix            = []byte("t")   // headerPrefix + num (uint64 big endian) + hash + tdSuffix -> td
@@ -122,10 +123,24 @@ func GetHeadFastBlockHash(db ethdb.Database) common.Hash {
 	return common.BytesToHash(data)
 }
 
+// GetHistoryBase retrieves the number of the block the chain history starts at,
+// which is non-zero if the node was initialized from a state snapshot and holds
+// no canonical blocks between the genesis and the snapshot block.
+func GetHistoryBase(db ethdb.Database) uint64 {
+	data, _ := db.Get(historyKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
 // GetHeaderRLP retrieves a block header in its raw RLP database encoding, or nil
 // if the header's not found.
 func GetHeaderRLP(db ethdb.Database, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
+	if len(data) == 0 {
+		data = readAncient(db, ethdb.FreezerHeaderTable, hash, number)
+	}
 	return data
 }
 
@@ -148,6 +163,45 @@ func GetHeader(db ethdb.Database, hash common.Hash, number uint64) *types.Header
 // GetBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func GetBodyRLP(db ethdb.Database, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(append(append(bodyPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
+	if len(data) == 0 {
+		data = readAncient(db, ethdb.FreezerBodiesTable, hash, number)
+	}
+	return data
+}
+
+// GetTdRLP retrieves a block's total difficulty in its raw RLP database encoding.
+func GetTdRLP(db ethdb.Database, hash common.Hash, number uint64) rlp.RawValue {
+	data, _ := db.Get(append(append(append(headerPrefix, encodeBlockNumber(number)...), hash[:]...), tdSuffix...))
+	if len(data) == 0 {
+		data = readAncient(db, ethdb.FreezerDifficultyTable, hash, number)
+	}
+	return data
+}
+
+// GetBlockReceiptsRLP retrieves the receipts of a block in their raw RLP
+// storage encoding.
+func GetBlockReceiptsRLP(db ethdb.Database, hash common.Hash, number uint64) rlp.RawValue {
+	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash[:]...))
+	if len(data) == 0 {
+		data = readAncient(db, ethdb.FreezerReceiptTable, hash, number)
+	}
+	return data
+}
+
+// readAncient retrieves a block's data blob from the freezer backing the
+// database, if there is one. Since the freezer only holds canonical blocks,
+// the data is only returned if the frozen canonical hash matches the one
+// requested.
+func readAncient(db ethdb.Database, kind string, hash common.Hash, number uint64) []byte {
+	ancients, ok := db.(ethdb.AncientReader)
+	if !ok {
+		return nil
+	}
+	frozen, err := ancients.Ancient(ethdb.FreezerHashTable, number)
+	if err != nil || common.BytesToHash(frozen) != hash {
+		return nil
+	}
+	data, _ := ancients.Ancient(kind, number)
 	return data
 }
 
@@ -169,7 +223,7 @@ func GetBody(db ethdb.Database, hash common.Hash, number uint64) *types.Body {
 // GetTd retrieves a block's total difficulty corresponding to the hash, nil if
 // none found.
 func GetTd(db ethdb.Database, hash common.Hash, number uint64) *big.Int {
-	data, _ := db.Get(append(append(append(headerPrefix, encodeBlockNumber(number)...), hash[:]...), tdSuffix...))
+	data := GetTdRLP(db, hash, number)
 	if len(data) == 0 {
 		return nil
 	}
@@ -208,7 +262,7 @@ func GetBlock(db ethdb.Database, hash common.Hash, number uint64) *types.Block {
 // GetBlockReceipts retrieves the receipts generated by the transactions included
 // in a block given by its hash.
 func GetBlockReceipts(db ethdb.Database, hash common.Hash, number uint64) types.Receipts {
-	data, _ := db.Get(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash[:]...))
+	data := GetBlockReceiptsRLP(db, hash, number)
 	if len(data) == 0 {
 		return nil
 	}
@@ -336,6 +390,14 @@ func WriteHeadFastBlockHash(db ethdb.Database, hash common.Hash) error {
 	return nil
 }
 
+// WriteHistoryBase stores the number of the block the chain history starts at.
+func WriteHistoryBase(db ethdb.Database, number uint64) error {
+	if err := db.Put(historyKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store history base", "err", err)
+	}
+	return nil
+}
+
 // WriteHeader serializes a block header into the database.
 func WriteHeader(db ethdb.Database, header *types.Header) error {
 	data, err := rlp.EncodeToBytes(header)
@@ -478,6 +540,16 @@ func DeleteBlock(db ethdb.Database, hash common.Hash, number uint64) {
 	DeleteTd(db, hash, number)
 }
 
+// deleteFrozenBlock removes the block data that has been moved into the freezer
+// from the key-value store. The hash to number mapping and the canonical hash
+// are retained, as they are still needed to look up frozen blocks.
+func deleteFrozenBlock(db ethdb.Database, hash common.Hash, number uint64) {
+	DeleteBlockReceipts(db, hash, number)
+	db.Delete(append(append(headerPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
+	DeleteBody(db, hash, number)
+	DeleteTd(db, hash, number)
+}
+
 // DeleteBlockReceipts removes all receipt data associated with a block hash.
 func DeleteBlockReceipts(db ethdb.Database, hash common.Hash, number uint64) {
 	db.Delete(append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...))
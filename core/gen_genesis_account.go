@@ -20,6 +20,8 @@ func (g GenesisAccount) MarshalJSON() ([]byte, error) {
 		Storage    map[storageJSON]storageJSON `json:"storage,omitempty"`
 		Balance    *math.HexOrDecimal256       `json:"balance" gencodec:"required"`
 		Nonce      math.HexOrDecimal64         `json:"nonce,omitempty"`
+		Coinage    string                      `json:"coinage,omitempty"`
+		Last       math.HexOrDecimal64         `json:"last,omitempty"`
 		PrivateKey hexutil.Bytes               `json:"secretKey,omitempty"`
 	}
 	var enc GenesisAccount
@@ -32,6 +34,8 @@ func (g GenesisAccount) MarshalJSON() ([]byte, error) {
 	}
 	enc.Balance = (*math.HexOrDecimal256)(g.Balance)
 	enc.Nonce = math.HexOrDecimal64(g.Nonce)
+	enc.Coinage = g.Coinage
+	enc.Last = math.HexOrDecimal64(g.Last)
 	enc.PrivateKey = g.PrivateKey
 	return json.Marshal(&enc)
 }
@@ -42,6 +46,8 @@ func (g *GenesisAccount) UnmarshalJSON(input []byte) error {
 		Storage    map[storageJSON]storageJSON `json:"storage,omitempty"`
 		Balance    *math.HexOrDecimal256       `json:"balance" gencodec:"required"`
 		Nonce      *math.HexOrDecimal64        `json:"nonce,omitempty"`
+		Coinage    *string                     `json:"coinage,omitempty"`
+		Last       *math.HexOrDecimal64        `json:"last,omitempty"`
 		PrivateKey hexutil.Bytes               `json:"secretKey,omitempty"`
 	}
 	var dec GenesisAccount
@@ -64,6 +70,12 @@ func (g *GenesisAccount) UnmarshalJSON(input []byte) error {
 	if dec.Nonce != nil {
 		g.Nonce = uint64(*dec.Nonce)
 	}
+	if dec.Coinage != nil {
+		g.Coinage = *dec.Coinage
+	}
+	if dec.Last != nil {
+		g.Last = uint64(*dec.Last)
+	}
 	if dec.PrivateKey != nil {
 		g.PrivateKey = dec.PrivateKey
 	}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"strconv"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -67,6 +68,8 @@ type GenesisAccount struct {
 	Storage    map[common.Hash]common.Hash `json:"storage,omitempty"`
 	Balance    *big.Int                    `json:"balance" gencodec:"required"`
 	Nonce      uint64                      `json:"nonce,omitempty"`
+	Coinage    string                      `json:"coinage,omitempty"`   // Decimal coinage accrued by the account
+	Last       uint64                      `json:"last,omitempty"`      // Block the coinage was last accrued at
 	PrivateKey []byte                      `json:"secretKey,omitempty"` // for tests
 }
 
@@ -86,6 +89,7 @@ type genesisAccountMarshaling struct {
 	Code       hexutil.Bytes
 	Balance    *math.HexOrDecimal256
 	Nonce      math.HexOrDecimal64
+	Last       math.HexOrDecimal64
 	Storage    map[storageJSON]storageJSON
 	PrivateKey hexutil.Bytes
 }
@@ -138,6 +142,11 @@ func SetupGenesisBlock(db ethdb.Database, genesis *Genesis) (*params.ChainConfig
 	if genesis != nil && genesis.Config == nil {
 		return params.AllProtocolChanges, common.Hash{}, errGenesisNoConfig
 	}
+	if genesis != nil {
+		if err := genesis.Config.Validate(); err != nil {
+			return genesis.Config, common.Hash{}, err
+		}
+	}
 
 	// Just commit the new block if there is no stored genesis block.
 	stored := GetCanonicalHash(db, 0)
@@ -213,6 +222,12 @@ func (g *Genesis) ToBlock() (*types.Block, *state.StateDB) {
 		statedb.AddBalance(addr, account.Balance.String())
 		statedb.SetCode(addr, account.Code)
 		statedb.SetNonce(addr, account.Nonce)
+		if account.Coinage != "" {
+			statedb.SetCoinage(addr, account.Coinage)
+		}
+		if account.Last != 0 {
+			statedb.SetLast(addr, strconv.FormatUint(account.Last, 10))
+		}
 		for key, value := range account.Storage {
 			statedb.SetState(addr, key, value)
 		}
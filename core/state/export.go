@@ -0,0 +1,185 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// importCommitInterval is the number of accounts after which an importer
+// flushes the account trie to disk to keep its memory use bounded.
+const importCommitInterval = 10000
+
+// AccountRecord is the flat, self contained representation of an account used
+// to stream state out of and back into the database. Next to the consensus
+// fields it carries the contract code and the complete storage, so a state can
+// be rebuilt from a sequence of records alone.
+type AccountRecord struct {
+	Address common.Address
+	Nonce   uint64
+	Balance string
+	Coinage string
+	LastCBN string
+	Code    []byte
+	Storage []StorageRecord
+}
+
+// StorageRecord is a single slot of a contract's storage trie. The key is the
+// hashed slot as stored in the trie and the value is its raw trie encoding, so
+// slots can be exported even if the preimages of the keys are not known.
+type StorageRecord struct {
+	Key   common.Hash
+	Value []byte
+}
+
+// ExportAccounts streams every account of the committed state, ordered by the
+// hash of its address (i.e. the account trie's key order), into fn. Unlike
+// RawDump, accounts are never accumulated in memory.
+func (self *StateDB) ExportAccounts(fn func(*AccountRecord) error) error {
+	it := trie.NewIterator(self.trie.NodeIterator(nil))
+	for it.Next() {
+		addr := self.trie.GetKey(it.Key)
+		if addr == nil {
+			return fmt.Errorf("missing address preimage for account %x", it.Key)
+		}
+		var data Account
+		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
+			return fmt.Errorf("invalid account %x: %v", addr, err)
+		}
+		obj := newObject(nil, common.BytesToAddress(addr), data, nil)
+		record := &AccountRecord{
+			Address: obj.address,
+			Nonce:   data.Nonce,
+			Balance: data.Balance,
+			Coinage: data.Coinage,
+			LastCBN: data.LastCBN,
+			Code:    obj.Code(self.db),
+		}
+		if obj.dbErr != nil {
+			return obj.dbErr
+		}
+		storageIt := trie.NewIterator(obj.getTrie(self.db).NodeIterator(nil))
+		for storageIt.Next() {
+			record.Storage = append(record.Storage, StorageRecord{
+				Key:   common.BytesToHash(storageIt.Key),
+				Value: common.CopyBytes(storageIt.Value),
+			})
+		}
+		if storageIt.Err != nil {
+			return storageIt.Err
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return it.Err
+}
+
+// AccountImporter rebuilds a state trie from a stream of account records,
+// writing the tries and contract codes directly into the database. Accounts
+// are stored verbatim, so the resulting root matches the exported one.
+type AccountImporter struct {
+	db       ethdb.Database
+	trie     *trie.SecureTrie
+	accounts uint64
+}
+
+// NewAccountImporter creates an importer building a fresh state trie on top of
+// the given database.
+func NewAccountImporter(db ethdb.Database) (*AccountImporter, error) {
+	tr, err := trie.NewSecure(common.Hash{}, db, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &AccountImporter{db: db, trie: tr}, nil
+}
+
+// Add inserts a single account, together with its code and storage, into the
+// state being rebuilt.
+func (imp *AccountImporter) Add(record *AccountRecord) error {
+	// Rebuild the storage trie of the account and flush it out
+	storage, err := trie.New(common.Hash{}, imp.db)
+	if err != nil {
+		return err
+	}
+	for _, slot := range record.Storage {
+		if err := storage.TryUpdate(slot.Key[:], slot.Value); err != nil {
+			return err
+		}
+	}
+	root, err := storage.CommitTo(imp.db)
+	if err != nil {
+		return err
+	}
+	// Store the contract code, if any
+	codeHash := emptyCodeHash
+	if len(record.Code) > 0 {
+		codeHash = crypto.Keccak256(record.Code)
+		if err := imp.db.Put(codeHash, record.Code); err != nil {
+			return err
+		}
+	}
+	// Insert the account itself into the account trie
+	blob, err := rlp.EncodeToBytes(Account{
+		Nonce:    record.Nonce,
+		Balance:  record.Balance,
+		Coinage:  record.Coinage,
+		LastCBN:  record.LastCBN,
+		Root:     root,
+		CodeHash: codeHash,
+	})
+	if err != nil {
+		return err
+	}
+	if err := imp.trie.TryUpdate(record.Address[:], blob); err != nil {
+		return err
+	}
+	imp.accounts++
+
+	// Periodically flush the account trie to keep memory use in check
+	if imp.accounts%importCommitInterval == 0 {
+		if _, err := imp.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Accounts returns the number of accounts imported so far.
+func (imp *AccountImporter) Accounts() uint64 {
+	return imp.accounts
+}
+
+// Commit flushes the account trie (and the address preimages) to the database
+// and returns the root of the state imported so far.
+func (imp *AccountImporter) Commit() (common.Hash, error) {
+	root, err := imp.trie.CommitTo(imp.db)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	// Reopen the trie from disk to drop all the committed nodes from memory
+	if imp.trie, err = trie.NewSecure(root, imp.db, 0); err != nil {
+		return common.Hash{}, err
+	}
+	return root, nil
+}
@@ -254,6 +254,30 @@ func (self *StateDB) StorageTrie(a common.Address) Trie {
 	return cpy.updateTrie(self.db)
 }
 
+// GetProof returns the Merkle proof of the account at the given address against
+// the state root. The proof of a non-existent account proves its absence.
+func (self *StateDB) GetProof(a common.Address) ([]rlp.RawValue, error) {
+	proof := self.trie.Prove(a[:])
+	if proof == nil {
+		return nil, fmt.Errorf("failed to prove account %x", a)
+	}
+	return proof, nil
+}
+
+// GetStorageProof returns the Merkle proof of a storage slot against the storage
+// root of the account at the given address.
+func (self *StateDB) GetStorageProof(a common.Address, key common.Hash) ([]rlp.RawValue, error) {
+	stateObject := self.getStateObject(a)
+	if stateObject == nil {
+		return nil, nil
+	}
+	proof := stateObject.getTrie(self.db).Prove(key[:])
+	if proof == nil {
+		return nil, fmt.Errorf("failed to prove storage slot %x of %x", key, a)
+	}
+	return proof, nil
+}
+
 func (self *StateDB) HasSuicided(addr common.Address) bool {
 	stateObject := self.getStateObject(addr)
 	if stateObject != nil {
@@ -518,7 +542,7 @@ func (self *StateDB) Copy() *StateDB {
 	// Copy all the basic fields, initialize the memory ones
 	state := &StateDB{
 		db:                     self.db,
-		trie:                   self.trie,
+		trie:                   self.db.CopyTrie(self.trie),
 		stateObjects:           make(map[common.Address]*stateObject, len(self.stateObjectsDirty)),
 		stateObjectsDirty:      make(map[common.Address]struct{}, len(self.stateObjectsDirty)),
 		stateObjectsDestructed: make(map[common.Address]struct{}, len(self.stateObjectsDestructed)),
@@ -42,6 +42,20 @@ func NewStateSync(root common.Hash, database trie.DatabaseReader) *StateSync {
 	return (*StateSync)(syncer)
 }
 
+// AddAccount schedules the retrieval of the storage trie and contract code of an
+// account whose leaf was obtained outside of the trie sync (e.g. downloaded in an
+// account range), as the sync never visits the leaves of tries already on disk.
+// Empty hashes are skipped.
+func (s *StateSync) AddAccount(root common.Hash, codeHash common.Hash) {
+	syncer := (*trie.TrieSync)(s)
+	if root != (common.Hash{}) {
+		syncer.AddSubTrie(root, 64, common.Hash{}, nil)
+	}
+	if codeHash != (common.Hash{}) {
+		syncer.AddRawEntry(codeHash, 64, common.Hash{})
+	}
+}
+
 // Missing retrieves the known missing nodes from the state trie for retrieval.
 func (s *StateSync) Missing(max int) []common.Hash {
 	return (*trie.TrieSync)(s).Missing(max)
@@ -9,9 +9,9 @@ import (
 	"sort"
 	"sync"
 	"time"
-	"strconv"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
@@ -442,6 +442,18 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 	return txs
 }
 	
+// BalanceToWei converts an account balance, stored in the state as a decimal
+// string of coins, into the wei denomination transaction costs are expressed in.
+// Only whole coins count: the state transition charges gas against the balance
+// truncated to whole coins (see buyGas), so the pools round down as well lest
+// they accept transactions that would fail in a block. The exact value of a
+// balance, as the bank precompile, tracers and APIs report it, is
+// math.ParseDecimal's.
+func BalanceToWei(balance string) *big.Int {
+	coins := math.ParseDecimal(balance, common.Big1)
+	return coins.Mul(coins, big.NewInt(params.Ether))
+}
+
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
@@ -478,10 +490,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	}
 	// Transactor should have enough funds to cover the costs
 	// cost == V + GP * GL
-	xxx_f64, _ := strconv.ParseFloat(currentState.GetBalance(from), 64)
-	xx := new(big.Int).Mul(big.NewInt(int64(xxx_f64)), big.NewInt(1E18))
-		
-	if xx.Cmp(tx.Cost()) < 0 {	// Water Cherry
+	if BalanceToWei(currentState.GetBalance(from)).Cmp(tx.Cost()) < 0 {	// Water Cherry
 		return ErrInsufficientFunds
 	}
 	intrGas := IntrinsicGas(tx.Data(), tx.To() == nil, pool.homestead)
@@ -820,9 +829,7 @@ func (pool *TxPool) promoteExecutables(state *state.StateDB, accounts []common.A
 			pool.priced.Removed()
 		}
 		// Drop all transactions that are too costly (low balance or out of gas)
-		sga, _ := strconv.ParseFloat(state.GetBalance(addr), 64)
-		sgB := new(big.Int).Mul(big.NewInt(int64(sga)), big.NewInt(1E18))	
-		drops, _ := list.Filter(sgB, gaslimit)		// Water Cherry
+		drops, _ := list.Filter(BalanceToWei(state.GetBalance(addr)), gaslimit)		// Water Cherry
 		for _, tx := range drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable11 nnnn queued transaction", "hash", hash)
@@ -983,9 +990,7 @@ func (pool *TxPool) demoteUnexecutables(state *state.StateDB) {
 			pool.priced.Removed()
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
-		sga, _ := strconv.ParseFloat(state.GetBalance(addr), 64)
-		sgB := new(big.Int).Mul(big.NewInt(int64(sga)), big.NewInt(1E18))
-		drops, invalids := list.Filter(sgB, gaslimit)	// Water Cherry
+		drops, invalids := list.Filter(BalanceToWei(state.GetBalance(addr)), gaslimit)	// Water Cherry
 		for _, tx := range drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
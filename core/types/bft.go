@@ -0,0 +1,99 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	// BFTDigest is the mix digest of the headers sealed by the BFT consensus
+	// engine, marking them as having committed seals outside of their hash.
+	BFTDigest = common.HexToHash("0x63746963616c2062797a616e74696e65206661756c7420746f6c6572616e6365")
+
+	// BFTExtraVanity is the number of extra-data prefix bytes reserved for the
+	// proposer vanity of BFT headers.
+	BFTExtraVanity = 32
+
+	// ErrInvalidBFTHeaderExtra is returned if the extra-data of a header doesn't
+	// hold the BFT consensus fields.
+	ErrInvalidBFTHeaderExtra = errors.New("invalid bft header extra-data")
+)
+
+// BFTVote is a vote of a block proposer to change the validator set.
+type BFTVote struct {
+	Address   common.Address // Account being voted on
+	Authorize bool           // Whether to add or remove the account as validator
+}
+
+// BFTExtra is the consensus data of a BFT header, RLP encoded in the extra-data
+// after the vanity.
+type BFTExtra struct {
+	Validators    []common.Address // Validator set in effect after the block
+	Votes         []BFTVote        // Validator set change voted on by the proposer, at most one
+	Seal          []byte           // Signature of the proposer over the header
+	CommittedSeal [][]byte         // Signatures of the validators committing the block
+}
+
+// ExtractBFTExtra decodes the BFT consensus fields from the header extra-data.
+func ExtractBFTExtra(h *Header) (*BFTExtra, error) {
+	if len(h.Extra) < BFTExtraVanity {
+		return nil, ErrInvalidBFTHeaderExtra
+	}
+	extra := new(BFTExtra)
+	if err := rlp.DecodeBytes(h.Extra[BFTExtraVanity:], extra); err != nil {
+		return nil, err
+	}
+	return extra, nil
+}
+
+// SetBFTExtra encodes the BFT consensus fields into the header extra-data,
+// keeping the vanity in place.
+func SetBFTExtra(h *Header, extra *BFTExtra) error {
+	payload, err := rlp.EncodeToBytes(extra)
+	if err != nil {
+		return err
+	}
+	vanity := make([]byte, BFTExtraVanity)
+	copy(vanity, h.Extra)
+
+	h.Extra = append(vanity, payload...)
+	return nil
+}
+
+// BFTFilteredHeader returns a copy of the header with the committed seals, and
+// optionally the proposer seal, removed from the extra-data. It returns nil if
+// the extra-data can't be decoded.
+func BFTFilteredHeader(h *Header, keepSeal bool) *Header {
+	extra, err := ExtractBFTExtra(h)
+	if err != nil {
+		return nil
+	}
+	if !keepSeal {
+		extra.Seal = []byte{}
+	}
+	extra.CommittedSeal = [][]byte{}
+
+	cpy := *h
+	if err := SetBFTExtra(&cpy, extra); err != nil {
+		return nil
+	}
+	return &cpy
+}
@@ -104,6 +104,13 @@ type headerMarshaling struct {
 // Hash returns the block hash of the header, which is simply the keccak256 hash of its
 // RLP encoding.
 func (h *Header) Hash() common.Hash {
+	// The committed seals of BFT headers are only gathered once the block was
+	// proposed, so leave them out for all validators to agree on the hash
+	if h.MixDigest == BFTDigest {
+		if filtered := BFTFilteredHeader(h, true); filtered != nil {
+			return rlpHash(filtered)
+		}
+	}
 	return rlpHash(h)
 }
 
@@ -282,39 +289,7 @@ func CopyHeader(h *Header) *Header {
 		copy(cpy.MinerAgents, h.MinerAgents)
 	}*/
 	if len(h.MinerAgents) == 0 {
-
-		sumCoinage, _ := strconv.ParseFloat(CoinbaseCa, 64)
-		if len(PubMinerCa) > 0{
-			for _, value := range PubMinerCa {
-				f64Coinage, _ := strconv.ParseFloat(value.Coinage, 64)
-				sumCoinage += f64Coinage
-			}
-		}
-		var tmpv MinerAgents
-		tmpv.Minerbase = h.Coinbase
-		if sumCoinage == 0 {
-			sumCoinage = 1
-		}
-
-		basic, _ := strconv.ParseFloat(CoinbaseCa, 64)
-		res := basic /  sumCoinage
-		kk := fmt.Sprintf("%f%%", res*100)
-
-		tmpv.Percentage =   kk		// first 3yrs shares of 1:99
-		fmt.Println(tmpv.Percentage)
-		h.MinerAgents = append(h.MinerAgents, tmpv)
-
-		if len(PubMinerCa) > 0 {
-			for _,value := range PubMinerCa{
-				var tmpv MinerAgents
-				tmpv.Minerbase = value.Coinbase
-				f64Coinage, _ := strconv.ParseFloat(value.Coinage, 64)
-				res := f64Coinage / sumCoinage
-				kk := fmt.Sprintf("%f%%", res*100)
-				tmpv.Percentage = kk	// Nancy
-				h.MinerAgents = append(h.MinerAgents, tmpv)
-			}
-		}
+		h.MinerAgents = NewMinerAgents(h.Coinbase)
 
 		cpy.MinerAgents = make([]MinerAgents, len(h.MinerAgents))
 		copy(cpy.MinerAgents, h.MinerAgents)
@@ -323,6 +298,33 @@ func CopyHeader(h *Header) *Header {
 	return &cpy
 }
 
+// NewMinerAgents returns the reward split of a block produced by coinbase: the
+// coinbase first, followed by the public miners registered on this node, each
+// sharing in proportion to its coinage.
+func NewMinerAgents(coinbase common.Address) []MinerAgents {
+	sumCoinage, _ := strconv.ParseFloat(CoinbaseCa, 64)
+	for _, value := range PubMinerCa {
+		f64Coinage, _ := strconv.ParseFloat(value.Coinage, 64)
+		sumCoinage += f64Coinage
+	}
+	if sumCoinage == 0 {
+		sumCoinage = 1
+	}
+	basic, _ := strconv.ParseFloat(CoinbaseCa, 64)
+	agents := []MinerAgents{{
+		Minerbase:  coinbase,
+		Percentage: fmt.Sprintf("%f%%", basic/sumCoinage*100), // first 3yrs shares of 1:99
+	}}
+	for _, value := range PubMinerCa {
+		f64Coinage, _ := strconv.ParseFloat(value.Coinage, 64)
+		agents = append(agents, MinerAgents{
+			Minerbase:  value.Coinbase,
+			Percentage: fmt.Sprintf("%f%%", f64Coinage/sumCoinage*100), // Nancy
+		})
+	}
+	return agents
+}
+
 // DecodeRLP decodes the Ethereum
 func (b *Block) DecodeRLP(s *rlp.Stream) error {
 	var eb extblock
@@ -23,6 +23,15 @@ import (
 	"github.com/ethereum/go-ethereum/common/math"
 )
 
+// addressMask masks a stack word to the width of an account address.
+var addressMask = new(big.Int).Sub(new(big.Int).Lsh(common.Big1, common.AddressLength*8), common.Big1)
+
+// bigToAddress converts a stack word into an account address. Only the low 200
+// bits of the word are significant, the high 56 bits are ignored.
+func bigToAddress(word *big.Int) common.Address {
+	return common.BigToAddress(new(big.Int).And(word, addressMask))
+}
+
 // calculates the memory size required for a step
 func calcMemSize(off, l *big.Int) *big.Int {
 	if l.Sign() == 0 {
@@ -0,0 +1,164 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// addressPrefixLength is the length of the country/app prefix of an address,
+// preceding the 20 byte hash of the account key.
+const addressPrefixLength = common.AddressLength - 20
+
+// Addresses of the bank precompiles, as of the Bank fork.
+var (
+	BankBalanceAddress  = common.BytesToAddress([]byte{0x01, 0x01})
+	BankCoinageAddress  = common.BytesToAddress([]byte{0x01, 0x02})
+	BankPrefixAddress   = common.BytesToAddress([]byte{0x01, 0x03})
+	BankTransferAddress = common.BytesToAddress([]byte{0x01, 0x04})
+)
+
+// PrecompiledContractsBank contains the precompiled contracts exposing the
+// ofbank ledger fields to the EVM, used from the Bank fork on.
+var PrecompiledContractsBank = map[common.Address]StatefulPrecompiledContract{
+	BankBalanceAddress:  &bankBalance{},
+	BankCoinageAddress:  &bankCoinage{},
+	BankPrefixAddress:   &bankPrefix{},
+	BankTransferAddress: &bankTransfer{},
+}
+
+var (
+	errBankPrefixMismatch    = errors.New("recipient prefix mismatch")
+	errBankDelegatedTransfer = errors.New("bank transfer in delegated call")
+
+	// weiPerCoin is the number of wei the state's coin balances are denominated in.
+	weiPerCoin = big.NewInt(params.Ether)
+)
+
+// readAccount reads the address from the given word of the input.
+func readAccount(input []byte, word int64) common.Address {
+	return bigToAddress(new(big.Int).SetBytes(getData(input, big.NewInt(word*32), big32)))
+}
+
+// bankBalance implements a native contract returning the exact balance of an
+// account in wei.
+type bankBalance struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *bankBalance) RequiredGas(input []byte) uint64 {
+	return params.BankBalanceGas
+}
+
+func (c *bankBalance) Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	balance := math.ParseDecimal(evm.StateDB.GetBalance(readAccount(input, 0)), weiPerCoin)
+	return math.PaddedBigBytes(balance, 32), nil
+}
+
+// bankCoinage implements a native contract returning the coinage of an account,
+// in the units it is accrued in, and the block it was last accrued at.
+type bankCoinage struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *bankCoinage) RequiredGas(input []byte) uint64 {
+	return params.BankCoinageGas
+}
+
+func (c *bankCoinage) Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	addr := readAccount(input, 0)
+
+	// Reading the last accrual block creates the account, don't for missing ones
+	ret := make([]byte, 64)
+	if !evm.StateDB.Exist(addr) {
+		return ret, nil
+	}
+	coinage := math.ParseDecimal(evm.StateDB.GetCoinage(addr), common.Big1)
+	last, ok := new(big.Int).SetString(evm.StateDB.GetLast(addr), 10)
+	if !ok || last.Sign() < 0 {
+		last = new(big.Int)
+	}
+	copy(ret[:32], math.PaddedBigBytes(coinage, 32))
+	copy(ret[32:], math.PaddedBigBytes(last, 32))
+	return ret, nil
+}
+
+// bankPrefix implements a native contract splitting the country/app prefix off
+// an address.
+type bankPrefix struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract.
+func (c *bankPrefix) RequiredGas(input []byte) uint64 {
+	return params.BankPrefixGas
+}
+
+func (c *bankPrefix) Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	addr := readAccount(input, 0)
+	return common.LeftPadBytes(addr[:addressPrefixLength], 32), nil
+}
+
+// bankTransfer implements a native contract transferring value from the caller
+// to a recipient, provided the recipient's address carries the expected prefix.
+// The input is the recipient, the expected prefix and the amount in wei, each a
+// 32 byte word. Transfers creating the recipient are charged for it like calls.
+type bankTransfer struct{}
+
+// RequiredGas returns the gas required to execute the pre-compiled contract,
+// without the gas for creating the recipient, which depends on the state.
+func (c *bankTransfer) RequiredGas(input []byte) uint64 {
+	return params.BankTransferGas
+}
+
+func (c *bankTransfer) Run(evm *EVM, contract *Contract, input []byte) ([]byte, error) {
+	// Transfers modify the state, and a delegated call would spend the funds of
+	// the delegating contract's caller instead of its own.
+	if evm.interpreter.readonly {
+		return nil, ErrWriteProtection
+	}
+	if contract.DelegateCall {
+		return nil, errBankDelegatedTransfer
+	}
+	var (
+		from   = contract.Caller()
+		to     = readAccount(input, 0)
+		prefix = new(big.Int).SetBytes(getData(input, big32, big32))
+		amount = new(big.Int).SetBytes(getData(input, big64, big32))
+	)
+	if prefix.Cmp(new(big.Int).SetBytes(to[:addressPrefixLength])) != 0 {
+		return nil, errBankPrefixMismatch
+	}
+	if !evm.CanTransfer(evm.StateDB, from, amount.String()) {
+		return nil, ErrInsufficientBalance
+	}
+	// Charge for creating the recipient the same as a value transferring call
+	var creates bool
+	if evm.ChainConfig().IsEIP158(evm.BlockNumber) {
+		creates = evm.StateDB.Empty(to) && amount.Sign() > 0
+	} else {
+		creates = !evm.StateDB.Exist(to)
+	}
+	if creates && !contract.UseGas(params.CallNewAccountGas) {
+		return nil, ErrOutOfGas
+	}
+	evm.accrueCoinage(from, to)
+	evm.Transfer(evm.StateDB, from, to, amount.String())
+
+	return true32Byte, nil
+}
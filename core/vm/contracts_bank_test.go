@@ -0,0 +1,91 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/tests"
+)
+
+// Tests that bank transfers are charged for creating their recipient like value
+// transferring calls, and fail without side effects if the gas doesn't cover it.
+func TestBankTransferNewAccountGas(t *testing.T) {
+	var (
+		sender   = common.HexToAddress("0x000000009c00000000000000000000000000000000000001")
+		existing = common.HexToAddress("0x000000009c00000000000000000000000000000000000002")
+		fresh    = common.HexToAddress("0x000000009c00000000000000000000000000000000000003")
+
+		config = tests.Forks["Bank"]
+		cost   = params.BankTransferGas + params.CallNewAccountGas
+	)
+	tests := []struct {
+		to     common.Address
+		amount int64
+		gas    uint64
+		used   uint64
+		fail   bool
+	}{
+		// Transfers to existing accounts cost the flat price
+		{existing, params.Ether, 100000, params.BankTransferGas, false},
+
+		// Transfers creating the recipient are charged for it
+		{fresh, params.Ether, 100000, cost, false},
+		{fresh, params.Ether, cost - 1, cost - 1, true},
+
+		// Empty transfers don't create the recipient after EIP158
+		{fresh, 0, 100000, params.BankTransferGas, false},
+	}
+	for i, tt := range tests {
+		db, _ := ethdb.NewMemDatabase()
+		statedb, _ := state.New(common.Hash{}, state.NewDatabase(db))
+		statedb.AddBalance(sender, new(big.Int).Mul(big.NewInt(2), big.NewInt(params.Ether)).String())
+		statedb.AddBalance(existing, big.NewInt(params.Ether).String())
+
+		ctx := vm.Context{
+			CanTransfer: core.CanTransfer,
+			Transfer:    core.Transfer,
+			BlockNumber: big.NewInt(1),
+			Time:        big.NewInt(0),
+			Difficulty:  big.NewInt(1),
+			GasLimit:    params.GenesisGasLimit,
+		}
+		evm := vm.NewEVM(ctx, statedb, config, vm.Config{})
+
+		input := append(common.LeftPadBytes(tt.to[:], 32), common.LeftPadBytes(tt.to[:5], 32)...)
+		input = append(input, math.PaddedBigBytes(big.NewInt(tt.amount), 32)...)
+
+		_, left, err := evm.Call(vm.AccountRef(sender), vm.BankTransferAddress, input, tt.gas, new(big.Int))
+		if tt.fail != (err != nil) {
+			t.Errorf("test %d: failure mismatch: have %v, want failure %v", i, err, tt.fail)
+		}
+		if used := tt.gas - left; used != tt.used {
+			t.Errorf("test %d: gas used mismatch: have %d, want %d", i, used, tt.used)
+		}
+		if tt.fail && statedb.Exist(tt.to) {
+			t.Errorf("test %d: recipient created by failed transfer", i)
+		}
+	}
+}
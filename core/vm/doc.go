@@ -31,5 +31,23 @@ such as multiple PUSH operations and static JUMPs. It does this by analysing the
 opcodes and attempts to match certain regions to known sets. Whenever the
 optimiser finds said segments it creates a new instruction and replaces the
 first occurrence in the sequence.
+
+# Addresses
+
+Account addresses are common.AddressLength (25) bytes wide: a 5 byte country or
+application prefix followed by the 20 byte hash of the account key. On the stack
+an address occupies the low 200 bits of a word.
+
+Addresses pushed by the EVM (ADDRESS, CALLER, ORIGIN, COINBASE and the result of
+CREATE) are zero extended to a full word. Words consumed as addresses (BALANCE,
+EXTCODESIZE, EXTCODECOPY, CALL, CALLCODE, DELEGATECALL, STATICCALL and SELFDESTRUCT)
+are masked to their low 200 bits, the high 56 bits being ignored. A word masked
+to 160 bits, as done by Solidity for its address type, loses the prefix and thus
+names a different account: contracts handling ofbank accounts have to keep the
+full 200 bits, and are called through the address25 ABI type.
+
+The ECRECOVER precompile returns the 160 bit hash of the recovered key only,
+since the prefix of an account is not derivable from its key. It is to be
+compared against the low 160 bits of an address.
 */
 package vm
@@ -6,10 +6,9 @@ import (
 	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/misc"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
-
-	"strconv"
 )
 
 type (
@@ -23,10 +22,12 @@ type (
 // run runs the given contract and takes care of running precompiles with a fallback to the byte code interpreter.
 func run(evm *EVM, snapshot int, contract *Contract, input []byte) ([]byte, error) {
 	if contract.CodeAddr != nil {
-		precompiledContracts := PrecompiledContracts
-		if p := precompiledContracts[*contract.CodeAddr]; p != nil {
+		if p := evm.precompiles()[*contract.CodeAddr]; p != nil {
 			return RunPrecompiledContract(p, input, contract)
 		}
+		if p := evm.statefulPrecompiles()[*contract.CodeAddr]; p != nil {
+			return RunStatefulPrecompiledContract(p, evm, input, contract)
+		}
 	}
 
 	return evm.interpreter.Run(snapshot, contract, input)
@@ -112,6 +113,46 @@ func (evm *EVM) SyncCoinage(addr common.Address){
 
 }
 
+// precompiles returns the set of precompiled contracts of the current fork.
+func (evm *EVM) precompiles() map[common.Address]PrecompiledContract {
+	if evm.ChainConfig().IsMetropolis(evm.BlockNumber) {
+		return PrecompiledContractsMetropolis
+	}
+	return PrecompiledContractsHomestead
+}
+
+// statefulPrecompiles returns the set of precompiled contracts with state access
+// of the current fork.
+func (evm *EVM) statefulPrecompiles() map[common.Address]StatefulPrecompiledContract {
+	if evm.ChainConfig().IsBank(evm.BlockNumber) {
+		return PrecompiledContractsBank
+	}
+	return nil
+}
+
+// precompiled returns whether a precompiled contract of the current fork resides
+// at the given address.
+func (evm *EVM) precompiled(addr common.Address) bool {
+	return evm.precompiles()[addr] != nil || evm.statefulPrecompiles()[addr] != nil
+}
+
+// accrueCoinage credits the coinage gained since the last accrual to both sides
+// of a value transfer, ahead of the transfer changing their balances.
+func (evm *EVM) accrueCoinage(from, to common.Address) {
+	if evm.ChainConfig().IsCoinage(evm.BlockNumber) {
+		misc.AccumulateCoinage(evm.ChainConfig(), evm.StateDB, from, evm.BlockNumber)
+		misc.AccumulateCoinage(evm.ChainConfig(), evm.StateDB, to, evm.BlockNumber)
+		return
+	}
+	// Before the coinage fork both sides accrued over the blocks since the
+	// sender's last accrual, and only the sender was marked as credited
+	blocks := misc.CoinageBlocks(evm.StateDB, from, evm.BlockNumber)
+
+	evm.StateDB.AddCoinage(from, misc.Coinage(evm.ChainConfig(), evm.StateDB.GetBalance(from), blocks, evm.BlockNumber).String())
+	evm.StateDB.SetLast(from, evm.BlockNumber.String())
+	evm.StateDB.AddCoinage(to, misc.Coinage(evm.ChainConfig(), evm.StateDB.GetBalance(to), blocks, evm.BlockNumber).String())
+}
+
 // Call executes the contract associated with the addr with the given input as parameters. It also handles any
 // necessary value transfer required and takes the necessary steps to create accounts and reverses the state in
 // case of an execution error or failed value transfer.
@@ -135,7 +176,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 		snapshot = evm.StateDB.Snapshot()
 	)
 	if !evm.StateDB.Exist(addr) {
-		if PrecompiledContracts[addr] == nil && evm.ChainConfig().IsEIP158(evm.BlockNumber) && value.Sign() == 0 {
+		if !evm.precompiled(addr) && evm.ChainConfig().IsEIP158(evm.BlockNumber) && value.Sign() == 0 {
 			return nil, gas, nil
 		}
 
@@ -146,25 +187,10 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	//evm.SyncCoinage(caller.Address())
 	//evm.SyncCoinage(to.Address())
 
-	pervbn_i64,_ := strconv.ParseInt(evm.StateDB.GetLast(caller.Address()),10, 64)
-	CurBlknum,_ := strconv.ParseInt(evm.BlockNumber.String(), 10, 64)
-	blk_diff := CurBlknum - pervbn_i64 // int64
-	if blk_diff < 0 {
-		blk_diff = 0	// OR blk_diff > MAX_COINAGE
+	// Coinage is state too, so it's left alone in static calls
+	if !evm.interpreter.readonly {
+		evm.accrueCoinage(caller.Address(), to.Address())
 	}
-	cur_bal, _ := strconv.ParseFloat(evm.StateDB.GetBalance(caller.Address()), 64)	// float64
-	coinint := float64(blk_diff) * cur_bal * 10 / 3600
-	ca_gain := big.NewInt(int64(coinint*1E6))		// coinage_per_hour
-	evm.StateDB.AddCoinage(caller.Address(), ca_gain.String())//header.Number)
-	evm.StateDB.SetLast(caller.Address(), evm.BlockNumber.String())//.Add(evm.BlockNumber, big.NewInt(0)))//big.NewInt(CurBlknum))
-
-
-	cur_bal, _ = strconv.ParseFloat(evm.StateDB.GetBalance(to.Address()), 64)	// float64
-	coinint = float64(blk_diff) * cur_bal * 10 / 3600
-	ca_gain = big.NewInt(int64(coinint*1E6))		// coinage_per_hour
-	evm.StateDB.AddCoinage(to.Address(), ca_gain.String())//header.Number)
-	evm.StateDB.SetLast(caller.Address(), evm.BlockNumber.String())//.Add(evm.BlockNumber, big.NewInt(0)))//big.NewInt(CurBlknum))
-
 
 	evm.Transfer(evm.StateDB, caller.Address(), to.Address(), value.String())
 
@@ -179,8 +205,10 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	// above we revert to the snapshot and consume any gas remaining. Additionally
 	// when we're in homestead this also counts for code storage gas errors.
 	if err != nil {
-		contract.UseGas(contract.Gas)
 		evm.StateDB.RevertToSnapshot(snapshot)
+		if err != ErrExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
 	}
 	return ret, contract.Gas, err
 }
@@ -216,8 +244,10 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 
 	ret, err = run(evm, snapshot, contract, input)
 	if err != nil {
-		contract.UseGas(contract.Gas)
 		evm.StateDB.RevertToSnapshot(snapshot)
+		if err != ErrExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
 	}
 
 	return ret, contract.Gas, err
@@ -250,10 +280,53 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 
 	ret, err = run(evm, snapshot, contract, input)
 	if err != nil {
-		contract.UseGas(contract.Gas)
 		evm.StateDB.RevertToSnapshot(snapshot)
+		if err != ErrExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
+	}
+
+	return ret, contract.Gas, err
+}
+
+// StaticCall executes the contract associated with the addr with the given input
+// as parameters while disallowing any modifications to the state during the call.
+// Opcodes that attempt to perform such modifications will result in exceptions
+// instead of performing the modifications.
+func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte, gas uint64) (ret []byte, leftOverGas uint64, err error) {
+	if evm.vmConfig.NoRecursion && evm.depth > 0 {
+		return nil, gas, nil
+	}
+
+	// Depth check execution. Fail if we're trying to execute above the
+	// limit.
+	if evm.depth > int(params.CallCreateDepth) {
+		return nil, gas, ErrDepth
 	}
+	// Make sure the readonly is only set if we aren't in readonly yet, this
+	// also makes sure that the readonly flag isn't removed for child calls.
+	if !evm.interpreter.readonly {
+		evm.interpreter.readonly = true
+		defer func() { evm.interpreter.readonly = false }()
+	}
+
+	var (
+		to       = AccountRef(addr)
+		snapshot = evm.StateDB.Snapshot()
+	)
+	// Initialise a new contract and set the code that is to be used by the
+	// EVM. The contract is a scoped environment for this execution context
+	// only.
+	contract := NewContract(caller, to, new(big.Int), gas)
+	contract.SetCallCode(&addr, evm.StateDB.GetCodeHash(addr), evm.StateDB.GetCode(addr))
 
+	ret, err = run(evm, snapshot, contract, input)
+	if err != nil {
+		evm.StateDB.RevertToSnapshot(snapshot)
+		if err != ErrExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
+	}
 	return ret, contract.Gas, err
 }
 
@@ -314,13 +387,16 @@ func (evm *EVM) Create(caller ContractRef, code []byte, gas uint64, value *big.I
 	// when we're in homestead this also counts for code storage gas errors.
 	if maxCodeSizeExceeded ||
 		(err != nil && (evm.ChainConfig().IsHomestead(evm.BlockNumber) || err != ErrCodeStoreOutOfGas)) {
-		contract.UseGas(contract.Gas)
 		evm.StateDB.RevertToSnapshot(snapshot)
+		if err != ErrExecutionReverted {
+			contract.UseGas(contract.Gas)
+		}
 	}
-	// If the vm returned with an error the return value should be set to nil.
-	// This isn't consensus critical but merely to for behaviour reasons such as
-	// tests, RPC calls, etc.
-	if err != nil {
+	// If the vm returned with an error the return value should be set to nil,
+	// unless the creation was reverted, returning the revert reason. This isn't
+	// consensus critical but merely to for behaviour reasons such as tests, RPC
+	// calls, etc.
+	if err != nil && err != ErrExecutionReverted {
 		ret = nil
 	}
 
@@ -0,0 +1,59 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/tests"
+)
+
+// Tests the EVM against the state test fixtures in testdata: a subset of the
+// standard Byzantium state tests and the conformance tests of the 200 bit
+// address semantics. The fixtures use 25 byte addresses and give the post
+// states as account expectations, as the state roots differ from the ones of
+// Ethereum.
+func TestStateTests(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "st*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no state test fixtures found")
+	}
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("%s: failed to read fixtures: %v", file, err)
+		}
+		var suite map[string]tests.StateTest
+		if err := json.Unmarshal(src, &suite); err != nil {
+			t.Fatalf("%s: failed to parse fixtures: %v", file, err)
+		}
+		for name, test := range suite {
+			for _, st := range test.Subtests() {
+				if _, err := test.Run(st, vm.Config{}); err != nil {
+					t.Errorf("%s/%s/%s/%d: %v", filepath.Base(file), name, st.Fork, st.Index, err)
+				}
+			}
+		}
+	}
+}
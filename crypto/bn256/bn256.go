@@ -18,10 +18,17 @@ package bn256
 
 import (
 	"crypto/rand"
+	"errors"
 	"io"
 	"math/big"
 )
 
+var (
+	errNotEnoughData    = errors.New("bn256: not enough data")
+	errCoordinateTooBig = errors.New("bn256: coordinate exceeds modulus")
+	errMalformedPoint   = errors.New("bn256: malformed point")
+)
+
 // BUG(agl): this implementation is not constant time.
 // TODO(agl): keep GF(p²) elements in Mongomery form.
 
@@ -98,15 +105,19 @@ func (e *G1) Neg(a *G1) *G1 {
 
 // Marshal converts n to a byte slice.
 func (n *G1) Marshal() []byte {
+	// Each value is a 256-bit number.
+	const numBytes = 256 / 8
+
+	// The point at infinity is encoded as all zeroes
+	ret := make([]byte, numBytes*2)
+	if n.p.IsInfinity() {
+		return ret
+	}
 	n.p.MakeAffine(nil)
 
 	xBytes := new(big.Int).Mod(n.p.x, P).Bytes()
 	yBytes := new(big.Int).Mod(n.p.y, P).Bytes()
 
-	// Each value is a 256-bit number.
-	const numBytes = 256 / 8
-
-	ret := make([]byte, numBytes*2)
 	copy(ret[1*numBytes-len(xBytes):], xBytes)
 	copy(ret[2*numBytes-len(yBytes):], yBytes)
 
@@ -114,13 +125,14 @@ func (n *G1) Marshal() []byte {
 }
 
 // Unmarshal sets e to the result of converting the output of Marshal back into
-// a group element and then returns e.
-func (e *G1) Unmarshal(m []byte) (*G1, bool) {
+// a group element and then returns e. Encodings of points not on the curve are
+// rejected.
+func (e *G1) Unmarshal(m []byte) (*G1, error) {
 	// Each value is a 256-bit number.
 	const numBytes = 256 / 8
 
 	if len(m) != 2*numBytes {
-		return nil, false
+		return nil, errNotEnoughData
 	}
 
 	if e.p == nil {
@@ -128,7 +140,13 @@ func (e *G1) Unmarshal(m []byte) (*G1, bool) {
 	}
 
 	e.p.x.SetBytes(m[0*numBytes : 1*numBytes])
+	if e.p.x.Cmp(P) >= 0 {
+		return nil, errCoordinateTooBig
+	}
 	e.p.y.SetBytes(m[1*numBytes : 2*numBytes])
+	if e.p.y.Cmp(P) >= 0 {
+		return nil, errCoordinateTooBig
+	}
 
 	if e.p.x.Sign() == 0 && e.p.y.Sign() == 0 {
 		// This is the point at infinity.
@@ -140,11 +158,11 @@ func (e *G1) Unmarshal(m []byte) (*G1, bool) {
 		e.p.t.SetInt64(1)
 
 		if !e.p.IsOnCurve() {
-			return nil, false
+			return nil, errMalformedPoint
 		}
 	}
 
-	return e, true
+	return e, nil
 }
 
 // G2 is an abstract cyclic group. The zero value is suitable for use as the
@@ -212,6 +230,14 @@ func (e *G2) Add(a, b *G2) *G2 {
 
 // Marshal converts n into a byte slice.
 func (n *G2) Marshal() []byte {
+	// Each value is a 256-bit number.
+	const numBytes = 256 / 8
+
+	// The point at infinity is encoded as all zeroes
+	ret := make([]byte, numBytes*4)
+	if n.p.IsInfinity() {
+		return ret
+	}
 	n.p.MakeAffine(nil)
 
 	xxBytes := new(big.Int).Mod(n.p.x.x, P).Bytes()
@@ -219,10 +245,6 @@ func (n *G2) Marshal() []byte {
 	yxBytes := new(big.Int).Mod(n.p.y.x, P).Bytes()
 	yyBytes := new(big.Int).Mod(n.p.y.y, P).Bytes()
 
-	// Each value is a 256-bit number.
-	const numBytes = 256 / 8
-
-	ret := make([]byte, numBytes*4)
 	copy(ret[1*numBytes-len(xxBytes):], xxBytes)
 	copy(ret[2*numBytes-len(xyBytes):], xyBytes)
 	copy(ret[3*numBytes-len(yxBytes):], yxBytes)
@@ -232,23 +254,26 @@ func (n *G2) Marshal() []byte {
 }
 
 // Unmarshal sets e to the result of converting the output of Marshal back into
-// a group element and then returns e.
-func (e *G2) Unmarshal(m []byte) (*G2, bool) {
+// a group element and then returns e. Encodings of points not on the twist, or
+// not in its subgroup of order Order, are rejected.
+func (e *G2) Unmarshal(m []byte) (*G2, error) {
 	// Each value is a 256-bit number.
 	const numBytes = 256 / 8
 
 	if len(m) != 4*numBytes {
-		return nil, false
+		return nil, errNotEnoughData
 	}
 
 	if e.p == nil {
 		e.p = newTwistPoint(nil)
 	}
 
-	e.p.x.x.SetBytes(m[0*numBytes : 1*numBytes])
-	e.p.x.y.SetBytes(m[1*numBytes : 2*numBytes])
-	e.p.y.x.SetBytes(m[2*numBytes : 3*numBytes])
-	e.p.y.y.SetBytes(m[3*numBytes : 4*numBytes])
+	for i, coord := range []*big.Int{e.p.x.x, e.p.x.y, e.p.y.x, e.p.y.y} {
+		coord.SetBytes(m[i*numBytes : (i+1)*numBytes])
+		if coord.Cmp(P) >= 0 {
+			return nil, errCoordinateTooBig
+		}
+	}
 
 	if e.p.x.x.Sign() == 0 &&
 		e.p.x.y.Sign() == 0 &&
@@ -263,11 +288,11 @@ func (e *G2) Unmarshal(m []byte) (*G2, bool) {
 		e.p.t.SetOne()
 
 		if !e.p.IsOnCurve() {
-			return nil, false
+			return nil, errMalformedPoint
 		}
 	}
 
-	return e, true
+	return e, nil
 }
 
 // GT is an abstract cyclic group. The zero value is suitable for use as the
@@ -379,11 +404,17 @@ func Pair(g1 *G1, g2 *G2) *GT {
 	return &GT{optimalAte(g2.p, g1.p, new(bnPool))}
 }
 
+// PairingCheck calculates the Optimal Ate pairing for a set of points and checks
+// whether the product of the pairings is one. Pairs with a point at infinity
+// contribute one to the product, so they are skipped.
 func PairingCheck(a []*G1, b []*G2) bool {
 	pool := new(bnPool)
 	e := newGFp12(pool)
 	e.SetOne()
 	for i := 0; i < len(a); i++ {
+		if a[i].p.IsInfinity() || b[i].p.IsInfinity() {
+			continue
+		}
 		new_e := miller(b[i].p, a[i].p, pool)
 		e.Mul(e, new_e, pool)
 	}
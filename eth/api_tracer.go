@@ -0,0 +1,474 @@
+package eth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// defaultTraceTimeout is the amount of time a single transaction can execute
+	// by default before being forcefully aborted.
+	defaultTraceTimeout = 5 * time.Second
+
+	// txStateCacheLimit is the number of blocks whose last computed intermediate
+	// transaction state is cached, so tracing a block's transactions one by one
+	// doesn't replay the block from its start every time.
+	txStateCacheLimit = 16
+)
+
+// TraceArgs holds extra parameters to trace functions. Tracer is either the name
+// of a native tracer (see package tracers) or a JavaScript tracer expression.
+type TraceArgs struct {
+	*vm.LogConfig
+	Tracer  *string
+	Timeout *string
+}
+
+// TraceCallArgs holds extra parameters to trace calls: besides the tracer
+// settings, the accounts to override in the state the call executes on.
+type TraceCallArgs struct {
+	TraceArgs
+	StateOverrides map[common.Address]AccountOverride `json:"stateOverrides"`
+}
+
+// AccountOverride specifies the fields of an account to replace before tracing
+// a call. Unset fields and storage slots keep their values from the state.
+type AccountOverride struct {
+	Nonce   *hexutil.Uint64             `json:"nonce"`
+	Code    *hexutil.Bytes              `json:"code"`
+	Balance *hexutil.Big                `json:"balance"` // Balance in wei
+	Coinage *string                     `json:"coinage"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// txTraceResult is the result of a single transaction trace.
+type txTraceResult struct {
+	Result interface{} `json:"result,omitempty"` // Trace results produced by the tracer
+	Error  string      `json:"error,omitempty"`  // Trace failure produced by the tracer
+}
+
+// blockTraceResult is the result of tracing a block within a chain trace.
+type blockTraceResult struct {
+	Block  hexutil.Uint64   `json:"block"`           // Number of the traced block
+	Hash   common.Hash      `json:"hash"`            // Hash of the traced block
+	Traces []*txTraceResult `json:"traces"`          // Traces of the block's transactions
+	Error  string           `json:"error,omitempty"` // Failure to trace the block
+}
+
+// txTraceTask is a transaction to trace, along with the state it executes on.
+type txTraceTask struct {
+	statedb *state.StateDB // Intermediate state prepared for tracing
+	index   int            // Transaction offset in the block
+}
+
+// txState is an intermediate state of a block, the one a transaction executes on.
+type txState struct {
+	statedb *state.StateDB // State before executing the transaction
+	index   int            // Transaction offset in the block
+}
+
+type timeoutError struct{}
+
+func (t *timeoutError) Error() string {
+	return "Execution time exceeded"
+}
+
+// TraceBlock processes the given block's RLP but does not import the block in to
+// the chain, returning the traces of all its transactions.
+func (api *PrivateDebugAPI) TraceBlock(ctx context.Context, blockRlp []byte, config *TraceArgs) ([]*txTraceResult, error) {
+	block := new(types.Block)
+	if err := rlp.Decode(bytes.NewReader(blockRlp), block); err != nil {
+		return nil, fmt.Errorf("could not decode block: %v", err)
+	}
+	return api.traceBlock(ctx, block, config)
+}
+
+// TraceBlockFromFile loads the block's RLP from the given file name and attempts to
+// process it but does not import the block in to the chain.
+func (api *PrivateDebugAPI) TraceBlockFromFile(ctx context.Context, file string, config *TraceArgs) ([]*txTraceResult, error) {
+	blockRlp, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file: %v", err)
+	}
+	return api.TraceBlock(ctx, blockRlp, config)
+}
+
+// TraceBlockByNumber traces the transactions of the block by canonical number.
+func (api *PrivateDebugAPI) TraceBlockByNumber(ctx context.Context, blockNr rpc.BlockNumber, config *TraceArgs) ([]*txTraceResult, error) {
+	block := api.blockByNumber(blockNr)
+	if block == nil {
+		return nil, fmt.Errorf("block #%d not found", blockNr)
+	}
+	return api.traceBlock(ctx, block, config)
+}
+
+// TraceBlockByHash traces the transactions of the block by hash.
+func (api *PrivateDebugAPI) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceArgs) ([]*txTraceResult, error) {
+	block := api.eth.BlockChain().GetBlockByHash(hash)
+	if block == nil {
+		return nil, fmt.Errorf("block #%x not found", hash)
+	}
+	return api.traceBlock(ctx, block, config)
+}
+
+// TraceChain traces the transactions of all the blocks between start and end,
+// both inclusive, streaming the traces of every block as a subscription.
+func (api *PrivateDebugAPI) TraceChain(ctx context.Context, start, end rpc.BlockNumber, config *TraceArgs) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	from, to := api.blockByNumber(start), api.blockByNumber(end)
+	if from == nil {
+		return nil, fmt.Errorf("block #%d not found", start)
+	}
+	if to == nil {
+		return nil, fmt.Errorf("block #%d not found", end)
+	}
+	if from.NumberU64() > to.NumberU64() {
+		return nil, fmt.Errorf("end block #%d before start block #%d", to.NumberU64(), from.NumberU64())
+	}
+	sub := notifier.CreateSubscription()
+
+	// Abort any running trace if the subscription is dropped
+	traceCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-sub.Err():
+		case <-notifier.Closed():
+		case <-traceCtx.Done():
+		}
+		cancel()
+	}()
+	go func() {
+		defer cancel()
+
+		for number := from.NumberU64(); number <= to.NumberU64(); number++ {
+			block := api.eth.BlockChain().GetBlockByNumber(number)
+			if block == nil {
+				notifier.Notify(sub.ID, &blockTraceResult{Block: hexutil.Uint64(number), Error: "block not found"})
+				return
+			}
+			traces, err := api.traceBlock(traceCtx, block, config)
+			if traceCtx.Err() != nil {
+				return
+			}
+			if err := notifier.Notify(sub.ID, &blockTraceResult{
+				Block:  hexutil.Uint64(number),
+				Hash:   block.Hash(),
+				Traces: traces,
+				Error:  formatError(err),
+			}); err != nil {
+				log.Debug("Chain trace notification failed", "block", number, "err", err)
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// blockByNumber retrieves a block by number, including the pending one.
+func (api *PrivateDebugAPI) blockByNumber(blockNr rpc.BlockNumber) *types.Block {
+	switch blockNr {
+	case rpc.PendingBlockNumber:
+		// Pending block is only known by the miner
+		return api.eth.miner.PendingBlock()
+	case rpc.LatestBlockNumber:
+		return api.eth.blockchain.CurrentBlock()
+	default:
+		return api.eth.blockchain.GetBlockByNumber(uint64(blockNr))
+	}
+}
+
+// traceBlock traces all the transactions of the given block without saving the
+// state. The block is re-executed once to produce the intermediate states, while
+// the transactions are traced concurrently on copies of them.
+func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block, config *TraceArgs) ([]*txTraceResult, error) {
+	blockchain := api.eth.BlockChain()
+
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	parent := blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+	}
+	statedb, err := blockchain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	var (
+		signer  = types.MakeSigner(api.config, block.Number())
+		txs     = block.Transactions()
+		results = make([]*txTraceResult, len(txs))
+		tasks   = make(chan *txTraceTask, len(txs))
+		pend    = new(sync.WaitGroup)
+	)
+	threads := runtime.NumCPU()
+	if threads > len(txs) {
+		threads = len(txs)
+	}
+	for th := 0; th < threads; th++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+
+			for task := range tasks {
+				msg, _ := txs[task.index].AsMessage(signer)
+				context := core.NewEVMContext(msg, block.Header(), blockchain, nil)
+
+				res, err := api.traceTx(ctx, msg, context, task.statedb, config)
+				if err != nil {
+					results[task.index] = &txTraceResult{Error: err.Error()}
+					continue
+				}
+				results[task.index] = &txTraceResult{Result: res}
+			}
+		}()
+	}
+	// Feed the transactions to the tracers, advancing the state in the meantime
+	var failed error
+	for i, tx := range txs {
+		if ctx.Err() != nil {
+			failed = ctx.Err()
+			break
+		}
+		tasks <- &txTraceTask{statedb: statedb.Copy(), index: i}
+
+		msg, _ := tx.AsMessage(signer)
+		context := core.NewEVMContext(msg, block.Header(), blockchain, nil)
+
+		vmenv := vm.NewEVM(context, statedb, api.config, vm.Config{})
+		if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+			failed = fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
+			break
+		}
+		api.finaliseTx(statedb, block.Number())
+	}
+	close(tasks)
+	pend.Wait()
+
+	if failed != nil {
+		return nil, failed
+	}
+	return results, nil
+}
+
+// TraceTransaction returns the structured logs created during the execution of EVM
+// and returns them as a JSON object.
+func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, txHash common.Hash, config *TraceArgs) (interface{}, error) {
+	// Retrieve the tx from the chain and the containing block
+	tx, blockHash, _, txIndex := core.GetTransaction(api.eth.ChainDb(), txHash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %x not found", txHash)
+	}
+	msg, context, statedb, err := api.computeTxEnv(blockHash, int(txIndex))
+	if err != nil {
+		return nil, err
+	}
+	return api.traceTx(ctx, msg, context, statedb, config)
+}
+
+// TraceCall traces an arbitrary call on top of the state of the given block,
+// after applying the requested account overrides to it.
+func (api *PrivateDebugAPI) TraceCall(ctx context.Context, args ethapi.CallArgs, blockNr rpc.BlockNumber, config *TraceCallArgs) (interface{}, error) {
+	statedb, header, err := api.eth.ApiBackend.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		if err == nil {
+			err = fmt.Errorf("block #%d not found", blockNr)
+		}
+		return nil, err
+	}
+	var traceConfig *TraceArgs
+	if config != nil {
+		for addr, account := range config.StateOverrides {
+			if account.Nonce != nil {
+				statedb.SetNonce(addr, uint64(*account.Nonce))
+			}
+			if account.Code != nil {
+				statedb.SetCode(addr, *account.Code)
+			}
+			if account.Balance != nil {
+				statedb.SetBalance(addr, account.Balance.ToInt().String())
+			}
+			if account.Coinage != nil {
+				statedb.SetCoinage(addr, *account.Coinage)
+			}
+			for key, value := range account.Storage {
+				statedb.SetState(addr, key, value)
+			}
+		}
+		traceConfig = &config.TraceArgs
+	}
+	// Default to the block's gas limit, the call isn't constrained by a gas pool
+	gas := args.Gas.ToInt()
+	if gas.Sign() == 0 {
+		gas = header.GasLimit
+	}
+	msg := types.NewMessage(args.From, args.To, 0, args.Value.ToInt(), gas, args.GasPrice.ToInt(), args.Data, false)
+	context := core.NewEVMContext(msg, header, api.eth.BlockChain(), nil)
+
+	return api.traceTx(ctx, msg, context, statedb, traceConfig)
+}
+
+// traceTx traces a single message on the given state, with the tracer selected
+// by config: a native tracer, a JavaScript one or by default the struct logger.
+func (api *PrivateDebugAPI) traceTx(ctx context.Context, msg core.Message, vmctx vm.Context, statedb *state.StateDB, config *TraceArgs) (interface{}, error) {
+	var (
+		tracer      vm.Tracer
+		native      tracers.Tracer
+		timeout     = defaultTraceTimeout
+		deadlineCtx context.Context
+	)
+	if config != nil && config.Timeout != nil {
+		var err error
+		if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	if config != nil && config.Tracer != nil {
+		if t, ok := tracers.New(*config.Tracer); ok {
+			native, tracer = t, t
+
+			// Handle timeouts and RPC cancellations by aborting the EVM once created
+			var cancel context.CancelFunc
+			deadlineCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		} else {
+			var err error
+			if tracer, err = ethapi.NewJavascriptTracer(*config.Tracer); err != nil {
+				return nil, err
+			}
+			// Handle timeouts and RPC cancellations
+			deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+			go func() {
+				<-deadlineCtx.Done()
+				tracer.(*ethapi.JavascriptTracer).Stop(&timeoutError{})
+			}()
+			defer cancel()
+		}
+	} else if config == nil {
+		tracer = vm.NewStructLogger(nil)
+	} else {
+		tracer = vm.NewStructLogger(config.LogConfig)
+	}
+
+	// Run the transaction with tracing enabled.
+	vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{Debug: true, Tracer: tracer})
+	if native != nil {
+		go func() {
+			<-deadlineCtx.Done()
+			vmenv.Cancel()
+		}()
+		if err := native.CaptureStart(vmenv, msg.From(), msg.To(), msg.Data(), msg.Gas().Uint64(), msg.Value()); err != nil {
+			return nil, err
+		}
+	}
+	start := time.Now()
+	ret, gas, failed, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+	switch tracer := tracer.(type) {
+	case *vm.StructLogger:
+		return &ethapi.ExecutionResult{
+			Gas:         gas,
+			Failed:      failed,
+			ReturnValue: fmt.Sprintf("%x", ret),
+			StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
+		}, nil
+	case *ethapi.JavascriptTracer:
+		return tracer.GetResult()
+	case tracers.Tracer:
+		// An aborted EVM silently stops, don't return the partial trace
+		if deadlineCtx.Err() != nil {
+			return nil, &timeoutError{}
+		}
+		if err := tracer.CaptureEnd(ret, gas.Uint64(), time.Since(start)); err != nil {
+			return nil, err
+		}
+		return tracer.GetResult()
+	default:
+		panic(fmt.Sprintf("bad tracer type %T", tracer))
+	}
+}
+
+// computeTxEnv returns the execution environment of a certain transaction. The
+// block is replayed from the closest cached intermediate state before it, and
+// the state the transaction executes on is cached in turn.
+func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int) (core.Message, vm.Context, *state.StateDB, error) {
+	block := api.eth.BlockChain().GetBlockByHash(blockHash)
+	if block == nil {
+		return nil, vm.Context{}, nil, fmt.Errorf("block %x not found", blockHash)
+	}
+	txs := block.Transactions()
+	if txIndex < 0 || txIndex >= len(txs) {
+		return nil, vm.Context{}, nil, fmt.Errorf("tx index %d out of range for block %x", txIndex, blockHash)
+	}
+	// Resume from a cached intermediate state if possible, else the parent state
+	var (
+		statedb *state.StateDB
+		start   int
+	)
+	if cached, ok := api.txStates.Get(blockHash); ok && cached.(*txState).index <= txIndex {
+		statedb, start = cached.(*txState).statedb.Copy(), cached.(*txState).index
+	} else {
+		parent := api.eth.BlockChain().GetBlock(block.ParentHash(), block.NumberU64()-1)
+		if parent == nil {
+			return nil, vm.Context{}, nil, fmt.Errorf("block parent %x not found", block.ParentHash())
+		}
+		var err error
+		if statedb, err = api.eth.BlockChain().StateAt(parent.Root()); err != nil {
+			return nil, vm.Context{}, nil, err
+		}
+	}
+	// Recompute transactions up to the target index.
+	signer := types.MakeSigner(api.config, block.Number())
+	for idx := start; ; idx++ {
+		// Assemble the transaction call message
+		tx := txs[idx]
+		msg, _ := tx.AsMessage(signer)
+		context := core.NewEVMContext(msg, block.Header(), api.eth.BlockChain(), nil)
+		if idx == txIndex {
+			api.txStates.Add(blockHash, &txState{statedb: statedb.Copy(), index: idx})
+			return msg, context, statedb, nil
+		}
+
+		vmenv := vm.NewEVM(context, statedb, api.config, vm.Config{})
+		gp := new(core.GasPool).AddGas(tx.Gas())
+		_, _, _, err := core.ApplyMessage(vmenv, msg, gp)
+		if err != nil {
+			return nil, vm.Context{}, nil, fmt.Errorf("tx %x failed: %v", tx.Hash(), err)
+		}
+		api.finaliseTx(statedb, block.Number())
+	}
+}
+
+// finaliseTx updates the state in between transactions the same way the state
+// processor does, which also makes the changes visible to copies of the state.
+func (api *PrivateDebugAPI) finaliseTx(statedb *state.StateDB, number *big.Int) {
+	if api.config.IsMetropolis(number) {
+		statedb.Finalise()
+	} else {
+		statedb.IntermediateRoot(api.config.IsEIP158(number))
+	}
+}
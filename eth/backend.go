@@ -14,6 +14,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/bft"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
@@ -38,6 +39,7 @@ type LesServer interface {
 	Start(srvr *p2p.Server)
 	Stop()
 	Protocols() []p2p.Protocol
+	APIs() []rpc.API
 }
 
 // Ethereum implements the Ethereum full node service.
@@ -61,6 +63,7 @@ type Ethereum struct {
 	ApiBackend *EthApiBackend
 
 	miner     *miner.Miner
+	stratum   *miner.StratumServer // Stratum mining server of pool workers, if enabled
 	gasPrice  *big.Int
 	etherbase common.Address
 
@@ -85,7 +88,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		return nil, fmt.Errorf("invalid sync mode %d", config.SyncMode)
 	}
 
-	chainDb, err := CreateDB(ctx, config, "chaindata")
+	chainDb, err := CreateFreezerDB(ctx, config, "chaindata")
 	if err != nil {
 		return nil, err
 	}
@@ -127,6 +130,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if err != nil {
 		return nil, err
 	}
+	eth.blockchain.SetFreezeThreshold(config.FreezerThreshold)
 	// Rewind the chain in case of an incompatible config upgrade.
 	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
 		log.Warn("Rewinding chain to upgrade configuration", "err", compat)
@@ -156,6 +160,28 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine)
 	eth.miner.SetExtra(makeExtraData(config.ExtraData))
+	if err := eth.miner.SetOrdering(config.Ordering); err != nil {
+		return nil, err
+	}
+
+	if config.Stratum.Addr != "" {
+		pow, ok := eth.engine.(*ethash.Ethash)
+		if !ok {
+			return nil, errors.New("stratum server requires the ethash engine")
+		}
+		if len(config.Stratum.Workers) == 0 {
+			return nil, errors.New("stratum server requires worker logins (--stratum.workers)")
+		}
+		agent := miner.NewRemoteAgent(eth.blockchain, eth.engine)
+		eth.miner.Register(agent)
+
+		eth.stratum = miner.NewStratumServer(config.Stratum, agent, pow, func() error {
+			if eth.IsMining() {
+				return nil
+			}
+			return eth.StartMining(false)
+		})
+	}
 
 	eth.ApiBackend = &EthApiBackend{eth, nil}
 	gpoParams := config.GPO
@@ -196,12 +222,29 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (ethdb.Data
 	return db, nil
 }
 
+// CreateFreezerDB creates the chain database with an ancient freezer attached,
+// into which the blockchain moves chain segments old enough to be immutable.
+func CreateFreezerDB(ctx *node.ServiceContext, config *Config, name string) (ethdb.Database, error) {
+	db, err := ctx.OpenDatabaseWithFreezer(name, config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/")
+	if err != nil {
+		return nil, err
+	}
+	if db, ok := ethdb.KeyValueStore(db).(*ethdb.LDBDatabase); ok {
+		db.Meter("eth/db/chaindata/")
+	}
+	return db, nil
+}
+
 // CreateConsensusEngine creates the required type of consensus engine instance for an Ethereum service
 func CreateConsensusEngine(ctx *node.ServiceContext, config *Config, chainConfig *params.ChainConfig, db ethdb.Database) consensus.Engine {
 	// If proof-of-authority is requested, set it up
 	if chainConfig.Clique != nil {
 		return clique.New(chainConfig.Clique, db)
 	}
+	// If byzantine fault tolerance is requested, set it up
+	if chainConfig.BFT != nil {
+		return bft.New(chainConfig.BFT, db)
+	}
 	// Otherwise assume proof-of-work
 	switch {
 	case config.PowFake:
@@ -229,6 +272,11 @@ func (s *Ethereum) APIs() []rpc.API {
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
+	// Append the APIs of the light server, if running
+	if s.lesServer != nil {
+		apis = append(apis, s.lesServer.APIs()...)
+	}
+
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -314,6 +362,22 @@ func (self *Ethereum) SetEtherbase(etherbase common.Address) {
 	self.etherbase = etherbase
 	self.lock.Unlock()
 
+	// Under proof-of-authority the etherbase is the signing key, switch to it
+	if clique, ok := self.engine.(*clique.Clique); ok && self.IsMining() {
+		if wallet, err := self.accountManager.Find(accounts.Account{Address: etherbase}); err == nil {
+			clique.Authorize(etherbase, wallet.SignHash)
+		} else {
+			log.Warn("Etherbase account unavailable locally, keeping previous signer", "err", err)
+		}
+	}
+	if engine, ok := self.engine.(*bft.BFT); ok && self.IsMining() {
+		if wallet, err := self.accountManager.Find(accounts.Account{Address: etherbase}); err == nil {
+			engine.Authorize(etherbase, wallet.SignHash)
+		} else {
+			log.Warn("Etherbase account unavailable locally, keeping previous validator", "err", err)
+		}
+	}
+
 	self.miner.SetEtherbase(etherbase)
 }
 
@@ -331,6 +395,14 @@ func (s *Ethereum) StartMining(local bool) error {
 		}
 		clique.Authorize(eb, wallet.SignHash)
 	}
+	if engine, ok := s.engine.(*bft.BFT); ok {
+		wallet, err := s.accountManager.Find(accounts.Account{Address: eb})
+		if wallet == nil || err != nil {
+			log.Error("Etherbase account unavailable locally", "err", err)
+			return fmt.Errorf("validator missing: %v", err)
+		}
+		engine.Authorize(eb, wallet.SignHash)
+	}
 	if local {
 		// If local (CPU) mining is started, we can disable the transaction rejection
 		// mechanism introduced to speed sync times. CPU mining on mainnet is ludicrous
@@ -360,11 +432,14 @@ func (s *Ethereum) Downloader() *downloader.Downloader { return s.protocolManage
 // Protocols implements node.Service, returning all the currently configured
 // network protocols to start.
 func (s *Ethereum) Protocols() []p2p.Protocol {
-	if s.lesServer == nil {
-		return s.protocolManager.SubProtocols
-	} else {
-		return append(s.protocolManager.SubProtocols, s.lesServer.Protocols()...)
+	protos := append([]p2p.Protocol{}, s.protocolManager.SubProtocols...)
+	if engine, ok := s.engine.(*bft.BFT); ok {
+		protos = append(protos, engine.Protocols()...)
+	}
+	if s.lesServer != nil {
+		protos = append(protos, s.lesServer.Protocols()...)
 	}
+	return protos
 }
 
 // Start implements node.Service, starting all internal goroutines needed by the
@@ -374,9 +449,20 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 	s.netWService = ethapi.NewPublicNetWAPI(srvr, s.NetVersion())
 	
 	s.protocolManager.Start()
+	if engine, ok := s.engine.(*bft.BFT); ok {
+		// Blocks agreed on with other proposers are imported like propagated ones
+		engine.Start(s.blockchain, s.eventMux, func(block *types.Block) error {
+			return s.protocolManager.fetcher.Enqueue(bft.ProtocolName, block)
+		})
+	}
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
+	if s.stratum != nil {
+		if err := s.stratum.Start(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -386,12 +472,18 @@ func (s *Ethereum) Stop() error {
 	if s.stopDbUpgrade != nil {
 		s.stopDbUpgrade()
 	}
+	if engine, ok := s.engine.(*bft.BFT); ok {
+		engine.Stop()
+	}
 	s.blockchain.Stop()
 	s.protocolManager.Stop()
 	if s.lesServer != nil {
 		s.lesServer.Stop()
 	}
 	s.txPool.Stop()
+	if s.stratum != nil {
+		s.stratum.Stop()
+	}
 	s.miner.Stop()
 	s.eventMux.Stop()
 
@@ -9,6 +9,8 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -25,6 +27,9 @@ type ContractBackend struct {
 	eapi  *ethapi.PublicEthereumAPI        // Wrapper around the Ethereum object to access metadata
 	bcapi *ethapi.PublicBlockChainAPI      // Wrapper around the blockchain to access chain data
 	txapi *ethapi.PublicTransactionPoolAPI // Wrapper around the transaction pool to access transaction data
+
+	backend ethapi.Backend       // Backend to filter past logs with
+	events  *filters.EventSystem // Event system to stream new logs from
 }
 
 // NewContractBackend creates a new native contract backend using an existing
@@ -34,6 +39,9 @@ func NewContractBackend(apiBackend ethapi.Backend) *ContractBackend {
 		eapi:  ethapi.NewPublicEthereumAPI(apiBackend),
 		bcapi: ethapi.NewPublicBlockChainAPI(apiBackend),
 		txapi: ethapi.NewPublicTransactionPoolAPI(apiBackend, new(ethapi.AddrLocker)),
+
+		backend: apiBackend,
+		events:  filters.NewEventSystem(apiBackend.EventMux(), apiBackend, false),
 	}
 }
 
@@ -121,3 +129,61 @@ func (b *ContractBackend) SendTransaction(ctx context.Context, tx *types.Transac
 	_, err := b.txapi.SendRawTransaction(ctx, raw)
 	return err
 }
+
+// FilterLogs implements bind.ContractFilterer executing a log filter operation,
+// blocking during execution and returning all the results in one batch.
+func (b *ContractBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	// Unset boundaries run from genesis to the chain head
+	from := int64(0)
+	if query.FromBlock != nil {
+		from = query.FromBlock.Int64()
+	}
+	filter := filters.New(b.backend, false)
+	filter.SetBeginBlock(from)
+	filter.SetEndBlock(toBlockNumber(query.ToBlock).Int64())
+	filter.SetAddresses(query.Addresses)
+	filter.SetTopics(query.Topics)
+
+	logs, err := filter.Find(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]types.Log, len(logs))
+	for i, log := range logs {
+		res[i] = *log
+	}
+	return res, nil
+}
+
+// SubscribeFilterLogs implements bind.ContractFilterer creating a background log
+// filtering operation, streaming the logs of new blocks into ch.
+func (b *ContractBackend) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	sink := make(chan []*types.Log)
+
+	sub, err := b.events.SubscribeLogs(filters.FilterCriteria(query), sink)
+	if err != nil {
+		return nil, err
+	}
+	// Logs arrive in batches, flatten them into a plain stream
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case logs := <-sink:
+				for _, log := range logs {
+					select {
+					case ch <- *log:
+					case err := <-sub.Err():
+						return err
+					case <-quit:
+						return nil
+					}
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
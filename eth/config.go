@@ -13,6 +13,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -27,9 +28,11 @@ var DefaultConfig = Config{
 	NetworkId:            1,
 	LightPeers:           20,
 	DatabaseCache:        128,
+	FreezerThreshold:     params.ImmutabilityThreshold,
 	GasPrice:             big.NewInt(18 * params.Shannon / 1E8),	//WATER FIX
 
-	TxPool: core.DefaultTxPoolConfig,
+	TxPool:  core.DefaultTxPoolConfig,
+	Stratum: miner.DefaultStratumConfig,
 	GPO: gasprice.Config{
 		Blocks:     10,
 		Percentile: 50,
@@ -66,10 +69,15 @@ type Config struct {
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
 	MaxPeers   int `toml:"-"`          // Maximum number of global peers
 
+	LightPriorityClients []LightPriorityClient `toml:",omitempty"` // Light clients served with a guaranteed capacity
+	LightAuthToken       string                `toml:",omitempty"` // Token proven to LES servers to claim a priority tier
+
 	// Database options
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
+	DatabaseFreezer    string
+	FreezerThreshold   uint64 // Number of blocks after which chain data is frozen (0 = disabled)
 
 	// Mining-related options
 	Etherbase    common.Address `toml:",omitempty"`
@@ -77,6 +85,12 @@ type Config struct {
 	ExtraData    []byte         `toml:",omitempty"`
 	GasPrice     *big.Int
 
+	// Transaction ordering of the mined blocks
+	Ordering miner.OrderingConfig
+
+	// Stratum mining server options
+	Stratum miner.StratumConfig
+
 	// Ethash options
 	EthashCacheDir       string
 	EthashCachesInMem    int
@@ -101,6 +115,15 @@ type Config struct {
 	PowShared bool   `toml:"-"`
 }
 
+// LightPriorityClient assigns a service tier to a light client of the LES server.
+// The client is identified either by its node ID or by the auth token it proves
+// in the handshake.
+type LightPriorityClient struct {
+	NodeID   string `toml:",omitempty"` // Hex encoded node ID of the client
+	Token    string `toml:",omitempty"` // Auth token of the client
+	Capacity uint64 // Flow control capacity as a multiple of the free client's
+}
+
 type configMarshaling struct {
 	ExtraData hexutil.Bytes
 }
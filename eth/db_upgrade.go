@@ -50,8 +50,10 @@ func upgradeDeduplicateData(db ethdb.Database) func() error {
 	stop := make(chan chan error)
 
 	go func() {
-		// Create an iterator to read the entire database and covert old lookup entires
-		it := db.(*ethdb.LDBDatabase).NewIterator()
+		// Create an iterator to read the entire database and covert old lookup entires,
+		// which are never moved into a freezer, so the key-value store is iterated
+		kvdb := ethdb.KeyValueStore(db).(*ethdb.LDBDatabase)
+		it := kvdb.NewIterator()
 		defer func() {
 			if it != nil {
 				it.Release()
@@ -101,7 +103,7 @@ func upgradeDeduplicateData(db ethdb.Database) func() error {
 			converted++
 			if converted%100000 == 0 {
 				it.Release()
-				it = db.(*ethdb.LDBDatabase).NewIterator()
+				it = kvdb.NewIterator()
 				it.Seek(key)
 
 				log.Info("Deduplicating database entries", "deduped", converted)
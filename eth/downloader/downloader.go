@@ -34,17 +34,19 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/rcrowley/go-metrics"
 )
 
 var (
-	MaxHashFetch    = 512 // Amount of hashes to be fetched per retrieval request
-	MaxBlockFetch   = 128 // Amount of blocks to be fetched per retrieval request
-	MaxHeaderFetch  = 192 // Amount of block headers to be fetched per retrieval request
-	MaxSkeletonSize = 128 // Number of header fetches to need for a skeleton assembly
-	MaxBodyFetch    = 128 // Amount of block bodies to be fetched per retrieval request
-	MaxReceiptFetch = 256 // Amount of transaction receipts to allow fetching per request
-	MaxStateFetch   = 384 // Amount of node state values to allow fetching per request
+	MaxHashFetch    = 512  // Amount of hashes to be fetched per retrieval request
+	MaxBlockFetch   = 128  // Amount of blocks to be fetched per retrieval request
+	MaxHeaderFetch  = 192  // Amount of block headers to be fetched per retrieval request
+	MaxSkeletonSize = 128  // Number of header fetches to need for a skeleton assembly
+	MaxBodyFetch    = 128  // Amount of block bodies to be fetched per retrieval request
+	MaxReceiptFetch = 256  // Amount of transaction receipts to allow fetching per request
+	MaxStateFetch   = 384  // Amount of node state values to allow fetching per request
+	MaxAccountFetch = 4096 // Amount of accounts to allow fetching per range request
 
 	MaxForkAncestry  = 3 * params.EpochDuration // Maximum chain reorganisation
 	rttMinEstimate   = 2 * time.Second          // Minimum round-trip time to target for download requests
@@ -98,6 +100,8 @@ type Downloader struct {
 	mode SyncMode       // Synchronisation mode defining the strategy used (per sync cycle)
 	mux  *event.TypeMux // Event multiplexer to announce sync operation events
 
+	snapSync bool // Whether the fast sync state is retrieved in account ranges (per sync cycle)
+
 	queue   *queue   // Scheduler for selecting the hashes to download
 	peers   *peerSet // Set of active peers from which download can proceed
 	stateDB ethdb.Database
@@ -137,6 +141,7 @@ type Downloader struct {
 	stateSyncStart chan *stateSync
 	trackStateReq  chan *stateReq
 	stateCh        chan dataPack // [eth/63] Channel receiving inbound node state data
+	rangeCh        chan dataPack // [ofsnap/1] Channel receiving inbound account ranges
 
 	// Cancellation and termination
 	cancelPeer string        // Identifier of the peer currently being used as the master (cancel on drop)
@@ -225,6 +230,7 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 		headerProcCh:   make(chan []*types.Header, 1),
 		quitCh:         make(chan struct{}),
 		stateCh:        make(chan dataPack),
+		rangeCh:        make(chan dataPack),
 		stateSyncStart: make(chan *stateSync),
 		trackStateReq:  make(chan *stateReq),
 	}
@@ -249,7 +255,7 @@ func (d *Downloader) Progress() ethereum.SyncProgress {
 	switch d.mode {
 	case FullSync:
 		current = d.blockchain.CurrentBlock().NumberU64()
-	case FastSync:
+	case FastSync, SnapSync:
 		current = d.blockchain.CurrentFastBlock().NumberU64()
 	case LightSync:
 		current = d.lightchain.CurrentHeader().Number.Uint64()
@@ -288,6 +294,22 @@ func (d *Downloader) RegisterLightPeer(id string, version int, peer LightPeer) e
 	return d.RegisterPeer(id, version, &lightPeerWrapper{peer})
 }
 
+// RegisterRangePeer attaches the snapshot sync protocol of an already registered
+// peer, allowing account ranges to be retrieved from it. A nil range peer
+// detaches it again.
+func (d *Downloader) RegisterRangePeer(id string, peer RangePeer) error {
+	p := d.peers.Peer(id)
+	if p == nil {
+		return errNotRegistered
+	}
+	p.SetRangePeer(peer)
+	if peer != nil {
+		// Wake any account range sync waiting for peers able to serve it
+		d.peers.newPeerFeed.Send(p)
+	}
+	return nil
+}
+
 // UnregisterPeer remove a peer from the known list, preventing any action from
 // the specified peer. An effort is also made to return any pending fetches into
 // the queue.
@@ -387,10 +409,15 @@ fmt.Println("*****************SYNC TD:", td)
 
 	defer d.Cancel() // No matter what, we can't leave the cancel channel open
 
-	// Set the requested sync mode, unless it's forbidden
+	// Set the requested sync mode, unless it's forbidden. Snap sync is a fast sync
+	// that only differs in how the state is retrieved.
+	d.snapSync = mode == SnapSync
+	if d.snapSync {
+		mode = FastSync
+	}
 	d.mode = mode
 	if d.mode == FastSync && atomic.LoadUint32(&d.fsPivotFails) >= fsCriticalTrials {
-		d.mode = FullSync
+		d.mode, d.snapSync = FullSync, false
 	}
 	// Retrieve the origin peer and initiate the downloading process
 	p := d.peers.Peer(id)
@@ -1373,7 +1400,12 @@ func (d *Downloader) importBlockResults(results []*fetchResult) error {
 func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 	// Start syncing state of the reported head block.
 	// This should get us most of the state of the pivot block.
-	stateSync := d.syncState(latest.Root)
+	var stateSync *stateSync
+	if d.snapSync {
+		stateSync = d.syncStateRanges(latest.Root)
+	} else {
+		stateSync = d.syncState(latest.Root)
+	}
 	defer stateSync.Cancel()
 	go func() {
 		if err := stateSync.Wait(); err != nil {
@@ -1395,8 +1427,15 @@ func (d *Downloader) processFastSyncContent(latest *types.Header) error {
 			return err
 		}
 		if P != nil {
+			// Account ranges are worth finishing, the pivot state sync only needs
+			// to heal the changes since the head block afterwards
+			if d.snapSync {
+				if err := stateSync.Wait(); err != nil {
+					return err
+				}
+			}
 			stateSync.Cancel()
-			if err := d.commitPivotBlock(P); err != nil {
+			if err := d.commitPivotBlock(P, stateSync); err != nil {
 				return err
 
 			}
@@ -1457,11 +1496,11 @@ func (d *Downloader) commitFastSyncData(results []*fetchResult, stateSync *state
 	return nil
 }
 
-func (d *Downloader) commitPivotBlock(result *fetchResult) error {
+func (d *Downloader) commitPivotBlock(result *fetchResult, head *stateSync) error {
 	b := types.NewBlockWithHeader(result.Header).WithBody(result.Transactions, result.Uncles)
 	// Sync the pivot block state. This should complete reasonably quickly because
 	// we've already synced up to the reported head block state earlier.
-	if err := d.syncState(b.Root()).Wait(); err != nil {
+	if err := d.healState(b.Root(), head).Wait(); err != nil {
 		return err
 	}
 	log.Debug("Committing fast sync pivot as new head", "number", b.Number(), "hash", b.Hash())
@@ -1492,6 +1531,11 @@ func (d *Downloader) DeliverNodeData(id string, data [][]byte) (err error) {
 	return d.deliver(id, d.stateCh, &statePack{id, data}, stateInMeter, stateDropMeter)
 }
 
+// DeliverAccountRange injects a range of accounts received from a remote node.
+func (d *Downloader) DeliverAccountRange(id string, reqID uint64, accounts []AccountRangeItem, firstProof, lastProof []rlp.RawValue) (err error) {
+	return d.deliver(id, d.rangeCh, &rangePack{id, reqID, accounts, firstProof, lastProof}, rangeInMeter, rangeDropMeter)
+}
+
 // deliver injects a new batch of data received from a remote node.
 func (d *Downloader) deliver(id string, destCh chan dataPack, packet dataPack, inMeter, dropMeter metrics.Meter) (err error) {
 	// Update the delivery metrics for both good and failed deliveries
@@ -68,7 +68,8 @@ type peerConnection struct {
 
 	lacking map[common.Hash]struct{} // Set of hashes not to request (didn't have previously)
 
-	peer Peer
+	peer   Peer
+	ranges RangePeer // Snapshot sync protocol of the peer, nil if it doesn't run it
 
 	version int        // Eth protocol version number to switch strategies
 	log     log.Logger // Contextual logger to add extra infos to peer logs
@@ -90,6 +91,13 @@ type Peer interface {
 	RequestNodeData([]common.Hash) error
 }
 
+// RangePeer encapsulates the methods required to retrieve contiguous account
+// ranges of the state trie, along with proofs of the boundary accounts, from a
+// full peer also running the snapshot sync protocol.
+type RangePeer interface {
+	RequestAccountRange(id uint64, root common.Hash, origin common.Hash, limit common.Hash, max uint64) error
+}
+
 // lightPeerWrapper wraps a LightPeer struct, stubbing out the Peer-only methods.
 type lightPeerWrapper struct {
 	peer LightPeer
@@ -222,6 +230,27 @@ func (p *peerConnection) FetchNodeData(hashes []common.Hash) error {
 	return nil
 }
 
+// FetchAccountRange sends an account range retrieval request to the remote peer.
+func (p *peerConnection) FetchAccountRange(id uint64, root, origin, limit common.Hash, max int) error {
+	// Sanity check the protocol support
+	p.lock.RLock()
+	peer := p.ranges
+	p.lock.RUnlock()
+
+	if peer == nil {
+		panic("account range fetch requested on peer without snapshot sync protocol")
+	}
+	// Short circuit if the peer is already fetching
+	if !atomic.CompareAndSwapInt32(&p.stateIdle, 0, 1) {
+		return errAlreadyFetching
+	}
+	p.stateStarted = time.Now()
+
+	go peer.RequestAccountRange(id, root, origin, limit, uint64(max))
+
+	return nil
+}
+
 // SetHeadersIdle sets the peer to idle, allowing it to execute new header retrieval
 // requests. Its estimated header retrieval throughput is updated with that measured
 // just now.
@@ -320,6 +349,24 @@ func (p *peerConnection) NodeDataCapacity(targetRTT time.Duration) int {
 	return int(math.Min(1+math.Max(1, p.stateThroughput*float64(targetRTT)/float64(time.Second)), float64(MaxStateFetch)))
 }
 
+// SetRangePeer attaches or, if nil, detaches the snapshot sync protocol of the
+// peer, enabling or disabling account range retrievals from it.
+func (p *peerConnection) SetRangePeer(peer RangePeer) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.ranges = peer
+}
+
+// AccountRangeCapacity retrieves the peers account range download allowance
+// based on its previously discovered state throughput.
+func (p *peerConnection) AccountRangeCapacity(targetRTT time.Duration) int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return int(math.Min(1+math.Max(float64(MaxStateFetch), p.stateThroughput*float64(targetRTT)/float64(time.Second)), float64(MaxAccountFetch)))
+}
+
 // MarkLacking appends a new entity to the set of items (blocks, receipts, states)
 // that a peer is known not to have (i.e. have been requested before). If the
 // set reaches its maximum allowed capacity, items are randomly dropped off.
@@ -512,6 +559,38 @@ func (ps *peerSet) NodeDataIdlePeers() ([]*peerConnection, int) {
 	return ps.idlePeers(63, 64, idle, throughput)
 }
 
+// AccountRangeIdlePeers retrieves a flat list of all the currently idle peers
+// able to serve account ranges within the active peer set, ordered by their
+// reputation. The total only counts the peers running ofsnap, as the others
+// can't serve any range to begin with.
+func (ps *peerSet) AccountRangeIdlePeers() ([]*peerConnection, int) {
+	serves := func(p *peerConnection) bool {
+		p.lock.RLock()
+		defer p.lock.RUnlock()
+		return p.ranges != nil
+	}
+	idle := func(p *peerConnection) bool {
+		return serves(p) && atomic.LoadInt32(&p.stateIdle) == 0
+	}
+	throughput := func(p *peerConnection) float64 {
+		p.lock.RLock()
+		defer p.lock.RUnlock()
+		return p.stateThroughput
+	}
+	peers, _ := ps.idlePeers(63, 63, idle, throughput)
+
+	ps.lock.RLock()
+	defer ps.lock.RUnlock()
+
+	total := 0
+	for _, p := range ps.peers {
+		if p.version == 63 && serves(p) {
+			total++
+		}
+	}
+	return peers, total
+}
+
 // idlePeers retrieves a flat list of all currently idle peers satisfying the
 // protocol version constraints, using the provided function to check idleness.
 // The resulting set of peers are sorted by their measure throughput.
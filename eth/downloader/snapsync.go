@@ -0,0 +1,337 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	rangeChunks         = 16    // Number of chunks the account hash space is split into for concurrent retrieval
+	rangeCommitInterval = 16384 // Number of accounts after which the range trie is flushed to disk
+
+	errRangeUnavailable = errors.New("account range unavailable")
+)
+
+// rangeTask is a chunk of the account hash space to download.
+type rangeTask struct {
+	next     common.Hash         // Next account hash to retrieve
+	last     common.Hash         // Last account hash covered by the chunk
+	done     bool                // Whether all accounts of the chunk were retrieved
+	attempts map[string]struct{} // Peers that didn't have the chunk available
+}
+
+// rangeReq is an account range request sent to a peer.
+type rangeReq struct {
+	id      uint64          // Request identifier to match the response with
+	task    *rangeTask      // Chunk being downloaded
+	max     int             // Maximum number of accounts requested
+	peer    *peerConnection // Peer that we're requesting from
+	timeout time.Duration   // Maximum round trip time for this to complete
+	timer   *time.Timer     // Timer to fire when the RTT timeout expires
+}
+
+// newRangeTasks splits the account hash space into equal chunks.
+func newRangeTasks(chunks int) []*rangeTask {
+	step := new(big.Int).Div(new(big.Int).Lsh(common.Big1, 256), big.NewInt(int64(chunks)))
+
+	tasks := make([]*rangeTask, chunks)
+	for i := range tasks {
+		next := new(big.Int).Mul(step, big.NewInt(int64(i)))
+		last := new(big.Int).Add(next, step)
+		if i == chunks-1 {
+			last = new(big.Int).Lsh(common.Big1, 256)
+		}
+		tasks[i] = &rangeTask{
+			next:     common.BigToHash(next),
+			last:     common.BigToHash(last.Sub(last, common.Big1)),
+			attempts: make(map[string]struct{}),
+		}
+	}
+	return tasks
+}
+
+// incHash returns the hash following the given one, or false on overflow.
+func incHash(h common.Hash) (common.Hash, bool) {
+	for i := len(h) - 1; i >= 0; i-- {
+		h[i]++
+		if h[i] != 0 {
+			return h, true
+		}
+	}
+	return h, false
+}
+
+// loopRanges is the event loop of an account range sync. It downloads all the
+// accounts of the state trie in contiguous ranges and rebuilds the account trie
+// locally, remembering the storage roots and codes they reference. Whatever the
+// ranges got wrong or missed, including accounts changed since the ranges were
+// served, is fixed up by the healing trie sync of the pivot state afterwards, so
+// the boundary proofs only serve to detect misbehaving peers early.
+func (s *stateSync) loopRanges() error {
+	// Listen for new peer events to assign tasks to them
+	newPeer := make(chan *peerConnection, 1024)
+	peerSub := s.d.peers.SubscribeNewPeers(newPeer)
+	defer peerSub.Unsubscribe()
+
+	t, err := trie.New(common.Hash{}, s.d.stateDB)
+	if err != nil {
+		return err
+	}
+	var (
+		tasks   = newRangeTasks(rangeChunks)
+		active  = make(map[string]*rangeReq) // Currently in-flight requests
+		timeout = make(chan *rangeReq)       // Timed out active requests
+		reqID   uint64                       // Identifier of the last request sent
+		pending int                          // Number of accounts inserted since the last flush
+	)
+	defer func() {
+		// Cancel active request timers on exit and set the peers to idle so they're
+		// available for the healing sync.
+		for _, req := range active {
+			req.timer.Stop()
+			req.peer.SetNodeDataIdle(0)
+		}
+	}()
+	for {
+		// Assign the chunks not being downloaded to the idle peers
+		busy := make(map[*rangeTask]bool)
+		for _, req := range active {
+			busy[req.task] = true
+		}
+		var remaining int
+		for _, task := range tasks {
+			if !task.done {
+				remaining++
+			}
+		}
+		if remaining == 0 {
+			break
+		}
+		peers, total := s.d.peers.AccountRangeIdlePeers()
+		for _, p := range peers {
+			var task *rangeTask
+			for _, candidate := range tasks {
+				if _, tried := candidate.attempts[p.id]; !candidate.done && !busy[candidate] && !tried {
+					task = candidate
+					break
+				}
+			}
+			if task == nil {
+				continue
+			}
+			reqID++
+			req := &rangeReq{
+				id:      reqID,
+				task:    task,
+				max:     p.AccountRangeCapacity(s.d.requestRTT()),
+				peer:    p,
+				timeout: s.d.requestTTL(),
+			}
+			p.log.Trace("Requesting account range", "origin", task.next, "limit", task.last, "max", req.max)
+			if err := p.FetchAccountRange(req.id, s.root, task.next, task.last, req.max); err != nil {
+				continue
+			}
+			req.timer = time.AfterFunc(req.timeout, func() {
+				select {
+				case timeout <- req:
+				case <-s.done:
+				}
+			})
+			active[p.id] = req
+			busy[task] = true
+		}
+		// If no peer is able to serve the remaining chunks, leave them to healing
+		if len(active) == 0 {
+			stuck := true
+			for _, task := range tasks {
+				if !task.done && len(task.attempts) < total {
+					stuck = false
+					break
+				}
+			}
+			if stuck {
+				log.Warn("Account ranges unavailable, falling back to trie sync", "chunks", remaining, "peers", total)
+				break
+			}
+		}
+		// Requests sent, wait for something to happen
+		select {
+		case <-newPeer:
+			// New peer arrived, try to assign it download tasks
+
+		case <-s.cancel:
+			return errCancelStateFetch
+
+		case pack := <-s.rangeCh:
+			// Discard any data not requested (or previously timed out)
+			req := active[pack.PeerId()]
+			if req == nil || req.id != pack.id {
+				log.Debug("Unrequested account range", "peer", pack.PeerId(), "len", pack.Items())
+				continue
+			}
+			req.timer.Stop()
+			delete(active, pack.PeerId())
+
+			start := time.Now()
+			switch err := s.processRange(t, req, pack); err {
+			case nil:
+				req.peer.SetNodeDataIdle(len(pack.accounts))
+			case errRangeUnavailable:
+				req.task.attempts[req.peer.id] = struct{}{}
+				req.peer.SetNodeDataIdle(0)
+			default:
+				log.Warn("Invalid account range, dropping peer", "peer", req.peer.id, "err", err)
+				req.peer.SetNodeDataIdle(0)
+				s.d.dropPeer(req.peer.id)
+				continue
+			}
+			if pending += len(pack.accounts); pending >= rangeCommitInterval {
+				if err := s.commitRanges(t); err != nil {
+					return err
+				}
+				pending = 0
+			}
+			s.updateRangeStats(len(pack.accounts), remaining, time.Since(start))
+
+		case req := <-timeout:
+			// If the peer is already requesting something else, ignore the stale timeout
+			if active[req.peer.id] != req {
+				continue
+			}
+			delete(active, req.peer.id)
+			req.task.attempts[req.peer.id] = struct{}{}
+			req.peer.SetNodeDataIdle(0)
+			log.Debug("Account range request timed out", "peer", req.peer.id, "origin", req.task.next)
+		}
+	}
+	if err := s.commitRanges(t); err != nil {
+		return err
+	}
+	if root := t.Hash(); root != s.root {
+		log.Info("Account ranges downloaded, healing state", "have", root, "want", s.root)
+	} else {
+		log.Info("Account ranges downloaded", "root", root)
+	}
+	return nil
+}
+
+// processRange verifies an account range delivered for a chunk and inserts its
+// accounts into the local trie, advancing the chunk past them.
+func (s *stateSync) processRange(t *trie.Trie, req *rangeReq, pack *rangePack) error {
+	task := req.task
+
+	// An empty response is either the peer lacking the state altogether, or a
+	// proof that there are no accounts left in the chunk
+	if len(pack.accounts) == 0 {
+		if len(pack.firstProof) == 0 {
+			return errRangeUnavailable
+		}
+		value, err := trie.VerifyProof(s.root, task.next[:], pack.firstProof)
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			return fmt.Errorf("origin %x exists but was not delivered", task.next)
+		}
+		task.done = true
+		return nil
+	}
+	if len(pack.accounts) > req.max {
+		return fmt.Errorf("too many accounts: have %d, max %d", len(pack.accounts), req.max)
+	}
+	// Ensure the accounts are within the chunk, in ascending order
+	for i, account := range pack.accounts {
+		if bytes.Compare(account.Hash[:], task.next[:]) < 0 || bytes.Compare(account.Hash[:], task.last[:]) > 0 {
+			return fmt.Errorf("account %x outside of range [%x, %x]", account.Hash, task.next, task.last)
+		}
+		if i > 0 && bytes.Compare(pack.accounts[i-1].Hash[:], account.Hash[:]) >= 0 {
+			return fmt.Errorf("accounts out of order at index %d", i)
+		}
+	}
+	// Ensure the boundary accounts are part of the requested state
+	first, last := pack.accounts[0], pack.accounts[len(pack.accounts)-1]
+	if err := verifyRangeProof(s.root, first, pack.firstProof); err != nil {
+		return fmt.Errorf("first account: %v", err)
+	}
+	if err := verifyRangeProof(s.root, last, pack.lastProof); err != nil {
+		return fmt.Errorf("last account: %v", err)
+	}
+	// Insert the accounts into the local trie, tracking what they reference
+	for _, account := range pack.accounts {
+		var obj state.Account
+		if err := rlp.DecodeBytes(account.Body, &obj); err != nil {
+			return fmt.Errorf("account %x: %v", account.Hash, err)
+		}
+		if err := t.TryUpdate(account.Hash[:], account.Body); err != nil {
+			return err
+		}
+		s.roots[obj.Root] = struct{}{}
+		s.codes[common.BytesToHash(obj.CodeHash)] = struct{}{}
+	}
+	// Advance the chunk past the delivered accounts
+	if last.Hash == task.last {
+		task.done = true
+	} else if next, ok := incHash(last.Hash); ok {
+		task.next = next
+	} else {
+		task.done = true
+	}
+	return nil
+}
+
+// verifyRangeProof checks that an account is part of the state with the given root.
+func verifyRangeProof(root common.Hash, account AccountRangeItem, proof []rlp.RawValue) error {
+	value, err := trie.VerifyProof(root, account.Hash[:], proof)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(value, account.Body) {
+		return fmt.Errorf("account %x mismatches proof", account.Hash)
+	}
+	return nil
+}
+
+// commitRanges flushes the accounts inserted into the local trie out to disk.
+func (s *stateSync) commitRanges(t *trie.Trie) error {
+	batch := s.d.stateDB.NewBatch()
+	if _, err := t.CommitTo(batch); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// updateRangeStats bumps the state sync progress counters with the accounts of
+// a delivered range and displays a log message for the user to see.
+func (s *stateSync) updateRangeStats(accounts, chunks int, duration time.Duration) {
+	s.d.syncStatsLock.Lock()
+	defer s.d.syncStatsLock.Unlock()
+
+	s.d.syncStatsState.processed += uint64(accounts)
+
+	log.Info("Imported new account range", "count", accounts, "elapsed", common.PrettyDuration(duration), "processed", s.d.syncStatsState.processed, "chunks", chunks)
+}
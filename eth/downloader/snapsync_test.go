@@ -0,0 +1,356 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"bytes"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// snapTester is a downloader syncing state from simulated peers, all serving
+// the same source database.
+type snapTester struct {
+	downloader *Downloader
+
+	src ethdb.Database // Database the peers serve the state from
+	dst ethdb.Database // Database the downloader syncs the state into
+
+	dropped map[string]bool // Peers dropped by the downloader
+	lock    sync.Mutex
+}
+
+func newSnapTester() *snapTester {
+	src, _ := ethdb.NewMemDatabase()
+	dst, _ := ethdb.NewMemDatabase()
+
+	tester := &snapTester{src: src, dst: dst, dropped: make(map[string]bool)}
+	tester.downloader = New(FastSync, dst, new(event.TypeMux), nil, nil, tester.dropPeer)
+
+	// Deliveries are only accepted while a sync is running
+	tester.downloader.cancelCh = make(chan struct{})
+	return tester
+}
+
+// terminate aborts any operations on the embedded downloader and releases all
+// held resources.
+func (st *snapTester) terminate() {
+	st.downloader.Terminate()
+}
+
+// dropPeer simulates a hard peer removal from the connection pool.
+func (st *snapTester) dropPeer(id string) {
+	st.lock.Lock()
+	st.dropped[id] = true
+	st.lock.Unlock()
+
+	st.downloader.UnregisterPeer(id)
+}
+
+// isDropped returns whether the downloader dropped the given peer.
+func (st *snapTester) isDropped(id string) bool {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+
+	return st.dropped[id]
+}
+
+// newPeer registers a simulated peer serving the source state, with the given
+// misbehaviour when serving account ranges.
+func (st *snapTester) newPeer(t *testing.T, id string, behaviour snapBehaviour) {
+	peer := &snapTestPeer{id: id, tester: st, behaviour: behaviour}
+	if err := st.downloader.RegisterPeer(id, 63, peer); err != nil {
+		t.Fatalf("failed to register peer %s: %v", id, err)
+	}
+	if err := st.downloader.RegisterRangePeer(id, peer); err != nil {
+		t.Fatalf("failed to register range peer %s: %v", id, err)
+	}
+}
+
+// newPlainPeer registers a simulated eth/63 peer serving the source state that
+// doesn't run the snapshot sync protocol.
+func (st *snapTester) newPlainPeer(t *testing.T, id string) {
+	peer := &snapTestPeer{id: id, tester: st}
+	if err := st.downloader.RegisterPeer(id, 63, peer); err != nil {
+		t.Fatalf("failed to register peer %s: %v", id, err)
+	}
+}
+
+// makeState creates a state in the source database with the given number of
+// accounts, some of them with storage and code, and returns its root. Versions
+// of the state differ in the storage values of some accounts.
+func (st *snapTester) makeState(t *testing.T, accounts int, version byte) common.Hash {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(st.src))
+	for i := 0; i < accounts; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i + 1)))
+		statedb.SetNonce(addr, uint64(i+1))
+		if i%10 == 0 {
+			for j := 0; j < 3; j++ {
+				statedb.SetState(addr, common.BigToHash(big.NewInt(int64(j))), common.BytesToHash([]byte{version, byte(i), byte(j)}))
+			}
+		}
+		if i%25 == 0 {
+			statedb.SetCode(addr, []byte{0x60, byte(i), 0x60, byte(i >> 8)})
+		}
+	}
+	root, err := statedb.CommitTo(st.src, false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	return root
+}
+
+// checkState verifies that every node of the state with the given root, from
+// the account and storage tries to the codes, was synced.
+func (st *snapTester) checkState(t *testing.T, root common.Hash) {
+	statedb, err := state.New(root, state.NewDatabase(st.src))
+	if err != nil {
+		t.Fatalf("failed to open source state: %v", err)
+	}
+	var nodes int
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+		if it.Hash == (common.Hash{}) {
+			continue
+		}
+		nodes++
+		if _, err := st.dst.Get(it.Hash[:]); err != nil {
+			t.Fatalf("state node %x missing after sync", it.Hash)
+		}
+	}
+	if it.Error != nil {
+		t.Fatalf("failed to iterate source state: %v", it.Error)
+	}
+	if nodes == 0 {
+		t.Fatalf("empty source state")
+	}
+}
+
+// snapBehaviour is a way a simulated peer serves account ranges.
+type snapBehaviour int
+
+const (
+	snapHonest  snapBehaviour = iota // Serve ranges as requested
+	snapLacking                      // Claim not to have the state
+	snapTamper                       // Alter the first account of every range
+)
+
+// snapTestPeer is a simulated peer serving state from the tester's source
+// database.
+type snapTestPeer struct {
+	id        string
+	tester    *snapTester
+	behaviour snapBehaviour
+}
+
+func (p *snapTestPeer) Head() (common.Hash, *big.Int) { return common.Hash{}, new(big.Int) }
+
+func (p *snapTestPeer) RequestHeadersByHash(common.Hash, int, int, bool) error { return nil }
+func (p *snapTestPeer) RequestHeadersByNumber(uint64, int, int, bool) error    { return nil }
+func (p *snapTestPeer) RequestBodies([]common.Hash) error                      { return nil }
+func (p *snapTestPeer) RequestReceipts([]common.Hash) error                    { return nil }
+
+// RequestNodeData serves the requested trie nodes and codes.
+func (p *snapTestPeer) RequestNodeData(hashes []common.Hash) error {
+	results := make([][]byte, 0, len(hashes))
+	for _, hash := range hashes {
+		if data, err := p.tester.src.Get(hash[:]); err == nil {
+			results = append(results, data)
+		}
+	}
+	go p.tester.downloader.DeliverNodeData(p.id, results)
+	return nil
+}
+
+// RequestAccountRange serves the accounts of the requested range along with the
+// proofs of its boundaries, the way the eth protocol manager does.
+func (p *snapTestPeer) RequestAccountRange(id uint64, root common.Hash, origin common.Hash, limit common.Hash, max uint64) error {
+	var (
+		accounts              []AccountRangeItem
+		firstProof, lastProof []rlp.RawValue
+	)
+	t, err := trie.New(root, p.tester.src)
+	if err == nil && p.behaviour != snapLacking {
+		it := trie.NewIterator(t.NodeIterator(origin[:]))
+		for uint64(len(accounts)) < max && it.Next() {
+			hash := common.BytesToHash(it.Key)
+			if bytes.Compare(hash[:], limit[:]) > 0 {
+				break
+			}
+			accounts = append(accounts, AccountRangeItem{Hash: hash, Body: common.CopyBytes(it.Value)})
+		}
+		if len(accounts) == 0 {
+			firstProof = t.Prove(origin[:])
+		} else {
+			firstProof = t.Prove(accounts[0].Hash[:])
+			lastProof = t.Prove(accounts[len(accounts)-1].Hash[:])
+		}
+		if p.behaviour == snapTamper && len(accounts) > 0 {
+			var account state.Account
+			rlp.DecodeBytes(accounts[0].Body, &account)
+			account.Nonce++
+			accounts[0].Body, _ = rlp.EncodeToBytes(&account)
+		}
+	}
+	go p.tester.downloader.DeliverAccountRange(p.id, id, accounts, firstProof, lastProof)
+	return nil
+}
+
+// Tests that the state is synced in account ranges from honest peers, with the
+// storage tries and codes of the accounts retrieved by the healing sync.
+func TestSnapSync(t *testing.T) {
+	tester := newSnapTester()
+	defer tester.terminate()
+
+	root := tester.makeState(t, 1000, 1)
+	tester.newPeer(t, "peer-1", snapHonest)
+	tester.newPeer(t, "peer-2", snapHonest)
+
+	ranged := tester.downloader.syncStateRanges(root)
+	if err := ranged.Wait(); err != nil {
+		t.Fatalf("account range sync failed: %v", err)
+	}
+	// All the accounts arrived in ranges, only storage and code are left
+	if trie, err := trie.New(root, tester.dst); err != nil {
+		t.Fatalf("account trie incomplete after range sync: %v", err)
+	} else if trie.Hash() != root {
+		t.Fatalf("account trie root mismatch: have %x, want %x", trie.Hash(), root)
+	}
+	if err := tester.downloader.healState(root, ranged).Wait(); err != nil {
+		t.Fatalf("healing sync failed: %v", err)
+	}
+	tester.checkState(t, root)
+}
+
+// Tests that the accounts changed since the ranges were served, as the pivot
+// moved on, are healed by the trie sync of the new state.
+func TestSnapSyncHealing(t *testing.T) {
+	tester := newSnapTester()
+	defer tester.terminate()
+
+	head := tester.makeState(t, 1000, 1)
+	pivot := tester.makeState(t, 1000, 2)
+	tester.newPeer(t, "peer", snapHonest)
+
+	ranged := tester.downloader.syncStateRanges(head)
+	if err := ranged.Wait(); err != nil {
+		t.Fatalf("account range sync failed: %v", err)
+	}
+	if err := tester.downloader.healState(pivot, ranged).Wait(); err != nil {
+		t.Fatalf("healing sync failed: %v", err)
+	}
+	tester.checkState(t, pivot)
+}
+
+// Tests that a peer serving ranges not matching their proofs gets dropped, and
+// that the sync completes from the honest peers.
+func TestSnapSyncTamperedRange(t *testing.T) {
+	tester := newSnapTester()
+	defer tester.terminate()
+
+	root := tester.makeState(t, 1000, 1)
+	tester.newPeer(t, "honest", snapHonest)
+	tester.newPeer(t, "attacker", snapTamper)
+
+	ranged := tester.downloader.syncStateRanges(root)
+	if err := ranged.Wait(); err != nil {
+		t.Fatalf("account range sync failed: %v", err)
+	}
+	if err := tester.downloader.healState(root, ranged).Wait(); err != nil {
+		t.Fatalf("healing sync failed: %v", err)
+	}
+	tester.checkState(t, root)
+
+	if !tester.isDropped("attacker") {
+		t.Errorf("tampering peer not dropped")
+	}
+	if tester.isDropped("honest") {
+		t.Errorf("honest peer dropped")
+	}
+}
+
+// Tests that if no peer serves the account ranges, the sync falls back to
+// retrieving the whole state through the trie sync.
+func TestSnapSyncRangesUnavailable(t *testing.T) {
+	tester := newSnapTester()
+	defer tester.terminate()
+
+	root := tester.makeState(t, 1000, 1)
+	tester.newPeer(t, "peer-1", snapLacking)
+	tester.newPeer(t, "peer-2", snapLacking)
+
+	ranged := tester.downloader.syncStateRanges(root)
+	if err := ranged.Wait(); err != nil {
+		t.Fatalf("account range sync failed: %v", err)
+	}
+	if _, err := tester.dst.Get(root[:]); err == nil {
+		t.Fatalf("state root present without any range served")
+	}
+	if err := tester.downloader.healState(root, ranged).Wait(); err != nil {
+		t.Fatalf("healing sync failed: %v", err)
+	}
+	tester.checkState(t, root)
+
+	if tester.isDropped("peer-1") || tester.isDropped("peer-2") {
+		t.Errorf("peers lacking the state dropped")
+	}
+}
+
+// Tests that peers not running the snapshot sync protocol don't keep the range
+// sync waiting for them, neither when the ofsnap peers serve the ranges nor when
+// they lack the state and the sync has to fall back to the trie sync.
+func TestSnapSyncPlainPeers(t *testing.T) {
+	for _, behaviour := range []snapBehaviour{snapHonest, snapLacking} {
+		tester := newSnapTester()
+
+		root := tester.makeState(t, 1000, 1)
+		tester.newPeer(t, "snap", behaviour)
+		tester.newPlainPeer(t, "plain-1")
+		tester.newPlainPeer(t, "plain-2")
+
+		ranged := tester.downloader.syncStateRanges(root)
+		if err := ranged.Wait(); err != nil {
+			t.Fatalf("behaviour %d: account range sync failed: %v", behaviour, err)
+		}
+		_, err := tester.dst.Get(root[:])
+		if behaviour == snapHonest && err != nil {
+			t.Fatalf("behaviour %d: state root missing after range sync: %v", behaviour, err)
+		}
+		if behaviour == snapLacking && err == nil {
+			t.Fatalf("behaviour %d: state root present without any range served", behaviour)
+		}
+		if err := tester.downloader.healState(root, ranged).Wait(); err != nil {
+			t.Fatalf("behaviour %d: healing sync failed: %v", behaviour, err)
+		}
+		tester.checkState(t, root)
+
+		for _, id := range []string{"snap", "plain-1", "plain-2"} {
+			if tester.isDropped(id) {
+				t.Errorf("behaviour %d: peer %s dropped", behaviour, id)
+			}
+		}
+		tester.terminate()
+	}
+}
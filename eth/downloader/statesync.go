@@ -57,7 +57,38 @@ type stateSyncStats struct {
 
 // syncState starts downloading state with the given root hash.
 func (d *Downloader) syncState(root common.Hash) *stateSync {
+	return d.startStateSync(newStateSync(d, root))
+}
+
+// syncStateRanges starts downloading the accounts of the state with the given
+// root hash in contiguous ranges. Storage tries and contract codes are left for
+// a subsequent healing sync.
+func (d *Downloader) syncStateRanges(root common.Hash) *stateSync {
+	s := newStateSync(d, root)
+	s.ranges = true
+	s.roots = make(map[common.Hash]struct{})
+	s.codes = make(map[common.Hash]struct{})
+	return d.startStateSync(s)
+}
+
+// healState starts downloading the state with the given root hash on top of the
+// accounts already retrieved by a range sync, also fetching the storage tries and
+// codes of those accounts, which the trie sync itself would never reach.
+func (d *Downloader) healState(root common.Hash, ranged *stateSync) *stateSync {
 	s := newStateSync(d, root)
+	if ranged != nil && ranged.ranges {
+		for hash := range ranged.roots {
+			s.sched.AddAccount(hash, common.Hash{})
+		}
+		for hash := range ranged.codes {
+			s.sched.AddAccount(common.Hash{}, hash)
+		}
+	}
+	return d.startStateSync(s)
+}
+
+// startStateSync hands a state sync over to the state fetcher to run.
+func (d *Downloader) startStateSync(s *stateSync) *stateSync {
 	select {
 	case d.stateSyncStart <- s:
 	case <-d.quitCh:
@@ -78,6 +109,8 @@ func (d *Downloader) stateFetcher() {
 			}
 		case <-d.stateCh:
 			// Ignore state responses while no sync is running.
+		case <-d.rangeCh:
+			// Ignore account ranges while no sync is running.
 		case <-d.quitCh:
 			return
 		}
@@ -142,6 +175,17 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 			finished = append(finished, req)
 			delete(active, pack.PeerId())
 
+		// Forward account ranges to the sync downloading them:
+		case pack := <-d.rangeCh:
+			if !s.ranges {
+				log.Debug("Unrequested account range", "peer", pack.PeerId(), "len", pack.Items())
+				continue
+			}
+			select {
+			case s.rangeCh <- pack.(*rangePack):
+			case <-s.done:
+			}
+
 		// Handle timed-out requests:
 		case req := <-timeout:
 			// If the peer is already requesting something else, ignore the stale timeout.
@@ -191,11 +235,17 @@ type stateSync struct {
 	keccak hash.Hash                  // Keccak256 hasher to verify deliveries with
 	tasks  map[common.Hash]*stateTask // Set of tasks currently queued for retrieval
 
-	deliver    chan *stateReq // Delivery channel multiplexing peer responses
-	cancel     chan struct{}  // Channel to signal a termination request
-	cancelOnce sync.Once      // Ensures cancel only ever gets called once
-	done       chan struct{}  // Channel to signal termination completion
-	err        error          // Any error hit during sync (set before completion)
+	root   common.Hash              // State root being downloaded
+	ranges bool                     // Whether to download account ranges instead of trie nodes
+	roots  map[common.Hash]struct{} // Storage roots of the accounts downloaded in ranges
+	codes  map[common.Hash]struct{} // Code hashes of the accounts downloaded in ranges
+
+	deliver    chan *stateReq  // Delivery channel multiplexing peer responses
+	rangeCh    chan *rangePack // Delivery channel of account ranges
+	cancel     chan struct{}   // Channel to signal a termination request
+	cancelOnce sync.Once       // Ensures cancel only ever gets called once
+	done       chan struct{}   // Channel to signal termination completion
+	err        error           // Any error hit during sync (set before completion)
 }
 
 // stateTask represents a single trie node download taks, containing a set of
@@ -209,10 +259,12 @@ type stateTask struct {
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
 	return &stateSync{
 		d:       d,
+		root:    root,
 		sched:   state.NewStateSync(root, d.stateDB),
 		keccak:  sha3.NewKeccak256(),
 		tasks:   make(map[common.Hash]*stateTask),
 		deliver: make(chan *stateReq),
+		rangeCh: make(chan *rangePack),
 		cancel:  make(chan struct{}),
 		done:    make(chan struct{}),
 	}
@@ -222,7 +274,11 @@ func newStateSync(d *Downloader, root common.Hash) *stateSync {
 // it finishes, and finally notifying any goroutines waiting for the loop to
 // finish.
 func (s *stateSync) run() {
-	s.err = s.loop()
+	if s.ranges {
+		s.err = s.loopRanges()
+	} else {
+		s.err = s.loop()
+	}
 	close(s.done)
 }
 
@@ -19,7 +19,9 @@ package downloader
 import (
 	"fmt"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // peerDropFn is a callback type for dropping a peer detected as malicious.
@@ -77,3 +79,24 @@ type statePack struct {
 func (p *statePack) PeerId() string { return p.peerId }
 func (p *statePack) Items() int     { return len(p.states) }
 func (p *statePack) Stats() string  { return fmt.Sprintf("%d", len(p.states)) }
+
+// AccountRangeItem is an account of the state trie, keyed by the hash of its
+// address, as carried in account range responses.
+type AccountRangeItem struct {
+	Hash common.Hash  // Hash of the account address, the key in the state trie
+	Body rlp.RawValue // RLP encoded account
+}
+
+// rangePack is a contiguous range of accounts returned by a peer, along with the
+// proofs of its first and last entries.
+type rangePack struct {
+	peerId     string
+	id         uint64
+	accounts   []AccountRangeItem
+	firstProof []rlp.RawValue
+	lastProof  []rlp.RawValue
+}
+
+func (p *rangePack) PeerId() string { return p.peerId }
+func (p *rangePack) Items() int     { return len(p.accounts) }
+func (p *rangePack) Stats() string  { return fmt.Sprintf("%d", len(p.accounts)) }
@@ -228,7 +228,7 @@ Logs:
 		}
 
 		for i, topics := range topics {
-			var match bool
+			match := len(topics) == 0 // empty rule set == wildcard
 			for _, topic := range topics {
 				// common.Hash{} is a match all (wildcard)
 				if (topic == common.Hash{}) || log.Topics[i] == topic {
@@ -267,7 +267,7 @@ func bloomFilter(bloom types.Bloom, addresses []common.Address, topics [][]commo
 	}
 
 	for _, sub := range topics {
-		var included bool
+		included := len(sub) == 0 // empty rule set == wildcard
 		for _, topic := range sub {
 			if (topic == common.Hash{}) || types.BloomLookup(bloom, topic) {
 				included = true
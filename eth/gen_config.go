@@ -10,6 +10,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/miner"
 )
 
 func (c Config) MarshalTOML() (interface{}, error) {
@@ -17,16 +18,22 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               uint64
 		SyncMode                downloader.SyncMode
-		LightServ               int  `toml:",omitempty"`
-		LightPeers              int  `toml:",omitempty"`
-		MaxPeers                int  `toml:"-"`
-		SkipBcVersionCheck      bool `toml:"-"`
-		DatabaseHandles         int  `toml:"-"`
+		LightServ               int                   `toml:",omitempty"`
+		LightPeers              int                   `toml:",omitempty"`
+		MaxPeers                int                   `toml:"-"`
+		LightPriorityClients    []LightPriorityClient `toml:",omitempty"`
+		LightAuthToken          string                `toml:",omitempty"`
+		SkipBcVersionCheck      bool                  `toml:"-"`
+		DatabaseHandles         int                   `toml:"-"`
 		DatabaseCache           int
+		DatabaseFreezer         string
+		FreezerThreshold        uint64
 		Etherbase               common.Address `toml:",omitempty"`
 		MinerThreads            int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes  `toml:",omitempty"`
 		GasPrice                *big.Int
+		Ordering                miner.OrderingConfig
+		Stratum                 miner.StratumConfig
 		EthashCacheDir          string
 		EthashCachesInMem       int
 		EthashCachesOnDisk      int
@@ -48,13 +55,19 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
 	enc.MaxPeers = c.MaxPeers
+	enc.LightPriorityClients = c.LightPriorityClients
+	enc.LightAuthToken = c.LightAuthToken
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
+	enc.DatabaseFreezer = c.DatabaseFreezer
+	enc.FreezerThreshold = c.FreezerThreshold
 	enc.Etherbase = c.Etherbase
 	enc.MinerThreads = c.MinerThreads
 	enc.ExtraData = c.ExtraData
 	enc.GasPrice = c.GasPrice
+	enc.Ordering = c.Ordering
+	enc.Stratum = c.Stratum
 	enc.EthashCacheDir = c.EthashCacheDir
 	enc.EthashCachesInMem = c.EthashCachesInMem
 	enc.EthashCachesOnDisk = c.EthashCachesOnDisk
@@ -76,16 +89,22 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		Genesis                 *core.Genesis `toml:",omitempty"`
 		NetworkId               *uint64
 		SyncMode                *downloader.SyncMode
-		LightServ               *int  `toml:",omitempty"`
-		LightPeers              *int  `toml:",omitempty"`
-		MaxPeers                *int  `toml:"-"`
-		SkipBcVersionCheck      *bool `toml:"-"`
-		DatabaseHandles         *int  `toml:"-"`
+		LightServ               *int                  `toml:",omitempty"`
+		LightPeers              *int                  `toml:",omitempty"`
+		MaxPeers                *int                  `toml:"-"`
+		LightPriorityClients    []LightPriorityClient `toml:",omitempty"`
+		LightAuthToken          *string               `toml:",omitempty"`
+		SkipBcVersionCheck      *bool                 `toml:"-"`
+		DatabaseHandles         *int                  `toml:"-"`
 		DatabaseCache           *int
+		DatabaseFreezer         *string
+		FreezerThreshold        *uint64
 		Etherbase               *common.Address `toml:",omitempty"`
 		MinerThreads            *int            `toml:",omitempty"`
 		ExtraData               hexutil.Bytes   `toml:",omitempty"`
 		GasPrice                *big.Int
+		Ordering                *miner.OrderingConfig
+		Stratum                 *miner.StratumConfig
 		EthashCacheDir          *string
 		EthashCachesInMem       *int
 		EthashCachesOnDisk      *int
@@ -122,6 +141,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.MaxPeers != nil {
 		c.MaxPeers = *dec.MaxPeers
 	}
+	if dec.LightPriorityClients != nil {
+		c.LightPriorityClients = dec.LightPriorityClients
+	}
+	if dec.LightAuthToken != nil {
+		c.LightAuthToken = *dec.LightAuthToken
+	}
 	if dec.SkipBcVersionCheck != nil {
 		c.SkipBcVersionCheck = *dec.SkipBcVersionCheck
 	}
@@ -131,6 +156,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseCache != nil {
 		c.DatabaseCache = *dec.DatabaseCache
 	}
+	if dec.DatabaseFreezer != nil {
+		c.DatabaseFreezer = *dec.DatabaseFreezer
+	}
+	if dec.FreezerThreshold != nil {
+		c.FreezerThreshold = *dec.FreezerThreshold
+	}
 	if dec.Etherbase != nil {
 		c.Etherbase = *dec.Etherbase
 	}
@@ -143,6 +174,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.GasPrice != nil {
 		c.GasPrice = dec.GasPrice
 	}
+	if dec.Ordering != nil {
+		c.Ordering = *dec.Ordering
+	}
+	if dec.Stratum != nil {
+		c.Stratum = *dec.Stratum
+	}
 	if dec.EthashCacheDir != nil {
 		c.EthashCacheDir = *dec.EthashCacheDir
 	}
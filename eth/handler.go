@@ -40,6 +40,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 const (
@@ -63,6 +64,7 @@ type ProtocolManager struct {
 	networkId uint64
 
 	fastSync  uint32 // Flag whether fast sync is enabled (gets disabled if we already have blocks)
+	snapSync  bool   // Flag whether fast sync retrieves the state in account ranges
 	acceptTxs uint32 // Flag whether we're considered synchronised (enables transaction processing)
 
 	txpool      txPool
@@ -111,18 +113,19 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 		quitSync:    make(chan struct{}),
 	}
 	// Figure out whether to allow fast sync or not
-	if mode == downloader.FastSync && blockchain.CurrentBlock().NumberU64() > 0 {
+	if (mode == downloader.FastSync || mode == downloader.SnapSync) && blockchain.CurrentBlock().NumberU64() > 0 {
 		log.Warn("Blockchain not empty, fast sync disabled")
 		mode = downloader.FullSync
 	}
-	if mode == downloader.FastSync {
+	if mode == downloader.FastSync || mode == downloader.SnapSync {
 		manager.fastSync = uint32(1)
+		manager.snapSync = mode == downloader.SnapSync
 	}
 	// Initiate a sub-protocol for every implemented version we can handle
 	manager.SubProtocols = make([]p2p.Protocol, 0, len(ProtocolVersions))
 	for i, version := range ProtocolVersions {
 		// Skip protocol version if incompatible with the mode of operation
-		if mode == downloader.FastSync && version < eth63 {
+		if (mode == downloader.FastSync || mode == downloader.SnapSync) && version < eth63 {
 			continue
 		}
 		// Compatible; initialise the sub-protocol
@@ -156,6 +159,17 @@ func NewProtocolManager(config *params.ChainConfig, mode downloader.SyncMode, ne
 	if len(manager.SubProtocols) == 0 {
 		return nil, errIncompatibleConfig
 	}
+	// Serve and retrieve the state in account ranges over a protocol of its own
+	manager.SubProtocols = append(manager.SubProtocols, p2p.Protocol{
+		Name:    SnapProtocolName,
+		Version: SnapProtocolVersion,
+		Length:  SnapProtocolLength,
+		Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+			manager.wg.Add(1)
+			defer manager.wg.Done()
+			return manager.handleSnap(p, rw)
+		},
+	})
 	// Construct the different synchronisation mechanisms
 	manager.downloader = downloader.New(mode, chaindb, manager.eventMux, blockchain, nil, manager.removePeer)
 
@@ -666,6 +680,123 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 	return nil
 }
 
+// handleSnap is the callback invoked to manage the life cycle of a snapshot sync
+// peer. The protocol rides on the eth connection to the same node, so it waits
+// for the eth handshake before serving and requesting account ranges.
+func (pm *ProtocolManager) handleSnap(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	id := p.ID()
+	key := fmt.Sprintf("%x", id[:8])
+
+	timeout := time.NewTimer(handshakeTimeout)
+	defer timeout.Stop()
+
+	eth := pm.peers.Peer(key)
+	for eth == nil {
+		select {
+		case <-time.After(100 * time.Millisecond):
+			eth = pm.peers.Peer(key)
+		case <-timeout.C:
+			return p2p.DiscReadTimeout
+		case <-pm.quitSync:
+			return p2p.DiscQuitting
+		}
+	}
+	sp := &snapPeer{eth: eth, rw: rw}
+	if err := pm.downloader.RegisterRangePeer(eth.id, sp); err != nil {
+		return err
+	}
+	defer pm.downloader.RegisterRangePeer(eth.id, nil)
+
+	eth.Log().Debug("Snapshot sync peer connected")
+	for {
+		if err := pm.handleSnapMsg(sp); err != nil {
+			eth.Log().Debug("Snapshot sync message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleSnapMsg is invoked whenever an inbound message is received from a remote
+// snapshot sync peer. The remote connection is torn down upon returning any error.
+func (pm *ProtocolManager) handleSnapMsg(p *snapPeer) error {
+	// Read the next message from the remote peer, and ensure it's fully consumed
+	msg, err := p.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return errResp(ErrMsgTooLarge, "%v > %v", msg.Size, ProtocolMaxMsgSize)
+	}
+	defer msg.Discard()
+
+	// Handle the message depending on its contents
+	switch msg.Code {
+	case GetAccountRangeMsg:
+		// Decode the account range query
+		var query getAccountRangeData
+		if err := msg.Decode(&query); err != nil {
+			return errResp(ErrDecode, "%v: %v", msg, err)
+		}
+		return p.SendAccountRange(pm.accountRange(&query))
+
+	case AccountRangeMsg:
+		// A range of accounts arrived to one of our previous requests
+		var data accountRangeData
+		if err := msg.Decode(&data); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		// Deliver all to the downloader
+		if err := pm.downloader.DeliverAccountRange(p.eth.id, data.ID, data.Accounts, data.FirstProof, data.LastProof); err != nil {
+			log.Debug("Failed to deliver account range", "err", err)
+		}
+
+	default:
+		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
+	}
+	return nil
+}
+
+// accountRange gathers the accounts of a state trie requested by an account
+// range query until the fetch or network limits are reached, along with the
+// proofs of the boundary accounts. If the state is unknown, the response is
+// empty without proofs.
+func (pm *ProtocolManager) accountRange(query *getAccountRangeData) *accountRangeData {
+	response := &accountRangeData{ID: query.ID}
+
+	t, err := trie.New(query.Root, pm.chaindb)
+	if err != nil {
+		return response
+	}
+	max := query.Max
+	if max > uint64(downloader.MaxAccountFetch) {
+		max = uint64(downloader.MaxAccountFetch)
+	}
+	var (
+		bytes int
+		it    = trie.NewIterator(t.NodeIterator(query.Origin[:]))
+	)
+	for bytes < softResponseLimit && uint64(len(response.Accounts)) < max && it.Next() {
+		hash := common.BytesToHash(it.Key)
+		if hash.Big().Cmp(query.Limit.Big()) > 0 {
+			break
+		}
+		response.Accounts = append(response.Accounts, downloader.AccountRangeItem{Hash: hash, Body: common.CopyBytes(it.Value)})
+		bytes += len(it.Value) + common.HashLength
+	}
+	if it.Err != nil {
+		// The state is incomplete, serve nothing rather than a partial range
+		return &accountRangeData{ID: query.ID}
+	}
+	// Prove the boundaries, or the absence of the origin if the range is empty
+	if len(response.Accounts) == 0 {
+		response.FirstProof = t.Prove(query.Origin[:])
+		return response
+	}
+	response.FirstProof = t.Prove(response.Accounts[0].Hash[:])
+	response.LastProof = t.Prove(response.Accounts[len(response.Accounts)-1].Hash[:])
+	return response
+}
+
 // BroadcastBlock will either propagate a block to a subset of it's peers, or
 // will only announce it's availability (depending what's requested).
 func (pm *ProtocolManager) BroadcastBlock(block *types.Block, propagate bool) {
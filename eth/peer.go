@@ -228,6 +228,25 @@ func (p *peer) RequestReceipts(hashes []common.Hash) error {
 	return p2p.Send(p.rw, GetReceiptsMsg, hashes)
 }
 
+// snapPeer is the snapshot sync protocol connection of an eth peer.
+type snapPeer struct {
+	eth *peer
+	rw  p2p.MsgReadWriter
+}
+
+// SendAccountRange sends a range of accounts along with the proofs of its
+// boundaries, corresponding to an account range query.
+func (p *snapPeer) SendAccountRange(data *accountRangeData) error {
+	return p2p.Send(p.rw, AccountRangeMsg, data)
+}
+
+// RequestAccountRange fetches a contiguous range of accounts from the state trie
+// with the given root, starting at origin and ending no later than limit.
+func (p *snapPeer) RequestAccountRange(id uint64, root common.Hash, origin common.Hash, limit common.Hash, max uint64) error {
+	p.eth.Log().Debug("Fetching account range", "root", root, "origin", origin, "limit", limit, "max", max)
+	return p2p.Send(p.rw, GetAccountRangeMsg, &getAccountRangeData{ID: id, Root: root, Origin: origin, Limit: limit, Max: max})
+}
+
 // Handshake executes the eth protocol handshake, negotiating version number,
 // network IDs, difficulties, head and genesis blocks.
 func (p *peer) Handshake(network uint64, td *big.Int, head common.Hash, genesis common.Hash) error {
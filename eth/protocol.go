@@ -23,6 +23,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -62,6 +63,20 @@ const (
 	ReceiptsMsg    = 0x10
 )
 
+// Snapshot sync protocol run next to eth, serving the state in account ranges.
+// It has a name of its own not to clash with the versions of the eth protocol.
+const (
+	SnapProtocolName    = "ofsnap" // Official short name of the snapshot sync protocol
+	SnapProtocolVersion = 1        // Version of the snapshot sync protocol
+	SnapProtocolLength  = 2        // Number of message codes of the snapshot sync protocol
+)
+
+// ofsnap protocol message codes
+const (
+	GetAccountRangeMsg = 0x00
+	AccountRangeMsg    = 0x01
+)
+
 type errCode int
 
 const (
@@ -175,3 +190,23 @@ type blockBody struct {
 
 // blockBodiesData is the network packet for block content distribution.
 type blockBodiesData []*blockBody
+
+// getAccountRangeData represents an account range query.
+type getAccountRangeData struct {
+	ID     uint64      // Request identifier echoed in the response
+	Root   common.Hash // Root of the state trie to serve the accounts from
+	Origin common.Hash // First account hash of the range
+	Limit  common.Hash // Last account hash of the range
+	Max    uint64      // Maximum number of accounts to retrieve
+}
+
+// accountRangeData is the network packet for account range distribution, the
+// proofs of the first and last accounts allowing the range to be verified
+// against the state root. An empty range is accompanied by the proof of the
+// requested origin instead.
+type accountRangeData struct {
+	ID         uint64
+	Accounts   []downloader.AccountRangeItem
+	FirstProof []rlp.RawValue
+	LastProof  []rlp.RawValue
+}
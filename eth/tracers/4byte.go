@@ -0,0 +1,83 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+func init() {
+	Register("4byteTracer", func() Tracer { return &fourByteTracer{ids: make(map[string]int)} })
+}
+
+// fourByteTracer is a native tracer counting the 4 byte function selectors the
+// contracts called by a message were invoked with, keyed by the selector and
+// the size of the arguments following it, e.g. 0xa9059cbb-64.
+type fourByteTracer struct {
+	env *vm.EVM
+	ids map[string]int // Number of invocations per selector and argument size
+}
+
+// record counts the selector of a contract invocation, if the callee has code
+// and the input carries a selector.
+func (t *fourByteTracer) record(to common.Address, input []byte) {
+	if len(input) < 4 || t.env.StateDB.GetCodeSize(to) == 0 {
+		return
+	}
+	t.ids[fmt.Sprintf("0x%x-%d", input[:4], len(input)-4)]++
+}
+
+// CaptureStart counts the selector the message itself invokes.
+func (t *fourByteTracer) CaptureStart(env *vm.EVM, from common.Address, to *common.Address, input []byte, gas uint64, value *big.Int) error {
+	t.env = env
+	if to != nil {
+		t.record(*to, input)
+	}
+	return nil
+}
+
+// CaptureState counts the selectors of the inner calls.
+func (t *fourByteTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.env == nil {
+		return errors.New("4byteTracer: execution not started")
+	}
+	if err != nil {
+		return nil
+	}
+	switch op {
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		to, _ := accessedAccount(env, op, stack, contract)
+		t.record(to, callInput(op, memory, stack))
+	}
+	return nil
+}
+
+// CaptureEnd is called after the execution of the message.
+func (t *fourByteTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration) error {
+	return nil
+}
+
+// GetResult returns the invocation counts per selector and argument size.
+func (t *fourByteTracer) GetResult() (interface{}, error) {
+	return t.ids, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func init() {
+	Register("balanceDiffTracer", func() Tracer { return &balanceDiffTracer{newPrestateTracer()} })
+}
+
+// balanceDiff is the change of the balance and coinage of an account caused by
+// the traced message.
+type balanceDiff struct {
+	Before        *hexutil.Big `json:"before"` // Balance in wei
+	After         *hexutil.Big `json:"after"`
+	Diff          *hexutil.Big `json:"diff"`
+	CoinageBefore string       `json:"coinageBefore"`
+	CoinageAfter  string       `json:"coinageAfter"`
+}
+
+// balanceDiffTracer is a native tracer reporting the balance and coinage changes
+// of the accounts a message touched, including the gas payment and the miner's
+// reward for it.
+type balanceDiffTracer struct {
+	*prestateTracer
+}
+
+// GetResult returns the changes of the accounts whose balance or coinage was
+// modified by the message.
+func (t *balanceDiffTracer) GetResult() (interface{}, error) {
+	if t.env == nil {
+		return nil, errors.New("balanceDiffTracer: execution not started")
+	}
+	diffs := make(map[common.Address]*balanceDiff)
+	for addr, account := range t.prestate {
+		var (
+			before  = (*big.Int)(account.Balance)
+			after   = readBalance(t.env, addr)
+			coinage = readCoinage(t.env, addr)
+		)
+		if before.Cmp(after) == 0 && sameCoinage(account.Coinage, coinage) {
+			continue
+		}
+		diffs[addr] = &balanceDiff{
+			Before:        account.Balance,
+			After:         (*hexutil.Big)(after),
+			Diff:          (*hexutil.Big)(new(big.Int).Sub(after, before)),
+			CoinageBefore: account.Coinage,
+			CoinageAfter:  coinage,
+		}
+	}
+	return diffs, nil
+}
+
+// sameCoinage reports whether two stored coinages are the same amount, as the
+// state formats the coinage of missing and fresh accounts differently.
+func sameCoinage(a, b string) bool {
+	x, okx := new(big.Rat).SetString(a)
+	y, oky := new(big.Rat).SetString(b)
+	if !okx || !oky {
+		return a == b
+	}
+	return x.Cmp(y) == 0
+}
@@ -0,0 +1,205 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func init() {
+	Register("callTracer", func() Tracer { return new(callTracer) })
+}
+
+// callFrame is a single call or contract creation within the traced message.
+type callFrame struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      common.Address  `json:"to"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Gas     *hexutil.Uint64 `json:"gas,omitempty"`
+	GasUsed *hexutil.Uint64 `json:"gasUsed,omitempty"`
+	Input   hexutil.Bytes   `json:"input"`
+	Output  hexutil.Bytes   `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Calls   []*callFrame    `json:"calls,omitempty"`
+
+	gasIn          uint64 // Gas left in the caller once it paid for the call
+	outOff, outLen int64  // Memory area of the caller receiving the output
+}
+
+// callTracer is a native tracer reconstructing the tree of calls and contract
+// creations executed by a message.
+//
+// The gas of inner calls is only known if the callee executed any code, calls
+// to plain accounts and precompiles report none.
+type callTracer struct {
+	frames    []*callFrame // Stack of the frames being executed, the outermost first
+	descended bool         // Whether the last step entered a new frame
+}
+
+// CaptureStart creates the outermost frame of the message.
+func (t *callTracer) CaptureStart(env *vm.EVM, from common.Address, to *common.Address, input []byte, gas uint64, value *big.Int) error {
+	frame := &callFrame{
+		Type:  vm.CALL.String(),
+		From:  from,
+		Value: (*hexutil.Big)(value),
+		Gas:   newUint64(gas),
+		Input: common.CopyBytes(input),
+	}
+	if to == nil {
+		frame.Type = vm.CREATE.String()
+		frame.To = crypto.CreateAddress(from, env.StateDB.GetNonce(from))
+	} else {
+		frame.To = *to
+	}
+	t.frames = []*callFrame{frame}
+	return nil
+}
+
+// CaptureState tracks the frames entered and left by the execution.
+func (t *callTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if len(t.frames) == 0 {
+		return errors.New("callTracer: execution not started")
+	}
+	// Failing steps report the gas from before paying for them
+	available := gas
+	if err == nil {
+		available += cost
+	}
+	// If the last step entered a frame, its first step reveals the gas allowance
+	if t.descended {
+		t.descended = false
+		if depth == len(t.frames) {
+			frame := t.frames[depth-1]
+			frame.Gas = newUint64(available)
+			if frame.Type == vm.CREATE.String() {
+				frame.gasIn -= available
+			}
+		}
+	}
+	// Finalise the frames returned from, their results are on the stack now
+	for len(t.frames) > depth {
+		t.exit(env, available, memory, stack)
+	}
+	if err != nil {
+		t.frames[depth-1].Error = err.Error()
+		return nil
+	}
+	switch op {
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL, vm.CREATE:
+		to, _ := accessedAccount(env, op, stack, contract)
+		frame := &callFrame{
+			Type:  op.String(),
+			From:  contract.Address(),
+			To:    to,
+			Input: callInput(op, memory, stack),
+			gasIn: gas,
+		}
+		switch op {
+		case vm.CALL, vm.CALLCODE:
+			frame.Value = (*hexutil.Big)(new(big.Int).Set(stack.Back(2)))
+			frame.outOff, frame.outLen = stack.Back(5).Int64(), stack.Back(6).Int64()
+		case vm.DELEGATECALL, vm.STATICCALL:
+			frame.outOff, frame.outLen = stack.Back(4).Int64(), stack.Back(5).Int64()
+		case vm.CREATE:
+			frame.Value = (*hexutil.Big)(new(big.Int).Set(stack.Back(0)))
+		}
+		t.frames = append(t.frames, frame)
+		t.descended = true
+
+	case vm.RETURN, vm.REVERT:
+		t.frames[depth-1].Output = memory.Get(stack.Back(0).Int64(), stack.Back(1).Int64())
+
+	case vm.SELFDESTRUCT:
+		parent := t.frames[depth-1]
+		parent.Calls = append(parent.Calls, &callFrame{
+			Type:  op.String(),
+			From:  contract.Address(),
+			To:    common.BigToAddress(stack.Back(0)),
+			Value: (*hexutil.Big)(math.ParseDecimal(env.StateDB.GetBalance(contract.Address()), big.NewInt(params.Ether))),
+		})
+	}
+	return nil
+}
+
+// exit pops the innermost frame after its caller regained control, finalising
+// it with the results the caller received.
+func (t *callTracer) exit(env *vm.EVM, available uint64, memory *vm.Memory, stack *vm.Stack) {
+	frame := t.frames[len(t.frames)-1]
+	t.frames = t.frames[:len(t.frames)-1]
+
+	parent := t.frames[len(t.frames)-1]
+	parent.Calls = append(parent.Calls, frame)
+
+	// The call pushed its success flag or the created address on the stack
+	var result *big.Int
+	if data := stack.Data(); len(data) > 0 {
+		result = data[len(data)-1]
+	}
+	failed := result == nil || result.Sign() == 0
+	if failed && frame.Error == "" {
+		frame.Error = "internal failure"
+	}
+	if frame.Type == vm.CREATE.String() {
+		if !failed {
+			frame.To = common.BigToAddress(result)
+		}
+	} else if frame.Gas == nil && !failed && frame.outLen > 0 {
+		// No code ran, the output of precompiles is only found in the caller
+		frame.Output = memory.Get(frame.outOff, frame.outLen)
+	}
+	if frame.Gas != nil && available >= frame.gasIn {
+		if refund := available - frame.gasIn; refund <= uint64(*frame.Gas) {
+			frame.GasUsed = newUint64(uint64(*frame.Gas) - refund)
+		}
+	}
+}
+
+// CaptureEnd completes the outermost frame with the results of the message.
+func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration) error {
+	if len(t.frames) == 0 {
+		return errors.New("callTracer: execution not started")
+	}
+	frame := t.frames[0]
+	frame.GasUsed = newUint64(gasUsed)
+	if frame.Error == "" {
+		frame.Output = common.CopyBytes(output)
+	}
+	return nil
+}
+
+// GetResult returns the call tree of the message.
+func (t *callTracer) GetResult() (interface{}, error) {
+	if len(t.frames) == 0 {
+		return nil, errors.New("callTracer: execution not started")
+	}
+	return t.frames[0], nil
+}
+
+// newUint64 returns a reference to the given value, encoded as hex in JSON.
+func newUint64(v uint64) *hexutil.Uint64 {
+	return (*hexutil.Uint64)(&v)
+}
@@ -0,0 +1,145 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func init() {
+	Register("prestateTracer", func() Tracer { return newPrestateTracer() })
+}
+
+// weiPerCoin is the number of wei the state's coin balances are denominated in.
+var weiPerCoin = big.NewInt(params.Ether)
+
+// prestateAccount is the state of an account before the traced message touched
+// it, limited to the storage slots the message accessed.
+type prestateAccount struct {
+	Balance *hexutil.Big                `json:"balance"` // Balance in wei
+	Coinage string                      `json:"coinage"`
+	Last    hexutil.Uint64              `json:"last"` // Block the coinage was last accrued at
+	Nonce   hexutil.Uint64              `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// readBalance returns the balance of an account in wei.
+func readBalance(env *vm.EVM, addr common.Address) *big.Int {
+	return math.ParseDecimal(env.StateDB.GetBalance(addr), weiPerCoin)
+}
+
+// readCoinage returns the coinage of an account exactly as stored, so that
+// replaying the prestate reproduces it without any rounding.
+func readCoinage(env *vm.EVM, addr common.Address) string {
+	return env.StateDB.GetCoinage(addr)
+}
+
+// prestateTracer is a native tracer collecting the state of all the accounts
+// and storage slots a message accesses, as it was before the message executed,
+// which is enough to re-execute the message on an empty state.
+type prestateTracer struct {
+	env      *vm.EVM
+	prestate map[common.Address]*prestateAccount
+}
+
+func newPrestateTracer() *prestateTracer {
+	return &prestateTracer{prestate: make(map[common.Address]*prestateAccount)}
+}
+
+// lookupAccount records the state of an account on its first access.
+func (t *prestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.prestate[addr]; ok {
+		return
+	}
+	db := t.env.StateDB
+	account := &prestateAccount{
+		Balance: (*hexutil.Big)(readBalance(t.env, addr)),
+		Coinage: readCoinage(t.env, addr),
+		Nonce:   hexutil.Uint64(db.GetNonce(addr)),
+		Code:    db.GetCode(addr),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+	// Reading the last accrual block creates the account, don't for missing ones
+	if db.Exist(addr) {
+		last, _ := strconv.ParseUint(db.GetLast(addr), 10, 64)
+		account.Last = hexutil.Uint64(last)
+	}
+	t.prestate[addr] = account
+}
+
+// lookupStorage records the value of a storage slot on its first access.
+func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+	if _, ok := t.prestate[addr].Storage[key]; ok {
+		return
+	}
+	t.prestate[addr].Storage[key] = t.env.StateDB.GetState(addr, key)
+}
+
+// CaptureStart records the sender, the recipient and the miner of the message.
+func (t *prestateTracer) CaptureStart(env *vm.EVM, from common.Address, to *common.Address, input []byte, gas uint64, value *big.Int) error {
+	t.env = env
+
+	t.lookupAccount(from)
+	if to == nil {
+		t.lookupAccount(crypto.CreateAddress(from, env.StateDB.GetNonce(from)))
+	} else {
+		t.lookupAccount(*to)
+	}
+	t.lookupAccount(env.Coinbase)
+	return nil
+}
+
+// CaptureState records the accounts and storage slots accessed by a step.
+func (t *prestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.env == nil {
+		return errors.New("prestateTracer: execution not started")
+	}
+	if err != nil {
+		return nil
+	}
+	switch op {
+	case vm.SLOAD, vm.SSTORE:
+		t.lookupStorage(contract.Address(), common.BigToHash(stack.Back(0)))
+	default:
+		if addr, ok := accessedAccount(env, op, stack, contract); ok {
+			t.lookupAccount(addr)
+		}
+	}
+	return nil
+}
+
+// CaptureEnd is called after the execution of the message.
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration) error {
+	return nil
+}
+
+// GetResult returns the state accessed by the message.
+func (t *prestateTracer) GetResult() (interface{}, error) {
+	return t.prestate, nil
+}
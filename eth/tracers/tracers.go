@@ -0,0 +1,117 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracers implements native Go EVM tracers, selectable by name in the
+// debug API as a fast alternative to JavaScript tracers.
+package tracers
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tracer is a native EVM tracer accumulating a result over the execution of a
+// single message.
+type Tracer interface {
+	vm.Tracer
+
+	// CaptureStart is called with the message about to be executed by env, on
+	// the state before the sender is charged for gas.
+	CaptureStart(env *vm.EVM, from common.Address, to *common.Address, input []byte, gas uint64, value *big.Int) error
+
+	// GetResult returns the JSON serializable result of the trace, once the
+	// message was executed and CaptureEnd called.
+	GetResult() (interface{}, error)
+}
+
+// Constructor creates a new instance of a native tracer.
+type Constructor func() Tracer
+
+var (
+	registry     = make(map[string]Constructor)
+	registryLock sync.RWMutex
+)
+
+// Register makes a native tracer available under the given name. Registering
+// the same name twice panics.
+func Register(name string, constructor Constructor) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic("tracers: tracer " + name + " registered twice")
+	}
+	registry[name] = constructor
+}
+
+// New creates a new instance of the native tracer registered under the given
+// name, reporting whether there is one.
+func New(name string) (Tracer, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	constructor, ok := registry[name]
+	if !ok {
+		return nil, false
+	}
+	return constructor(), true
+}
+
+// Names returns the sorted names of all the registered native tracers.
+func Names() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// accessedAccount returns the account an opcode is about to access, if any.
+// The stack must have been validated for the opcode.
+func accessedAccount(env *vm.EVM, op vm.OpCode, stack *vm.Stack, contract *vm.Contract) (common.Address, bool) {
+	switch op {
+	case vm.BALANCE, vm.EXTCODESIZE, vm.EXTCODECOPY, vm.SELFDESTRUCT:
+		return common.BigToAddress(stack.Back(0)), true
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		return common.BigToAddress(stack.Back(1)), true
+	case vm.CREATE:
+		return crypto.CreateAddress(contract.Address(), env.StateDB.GetNonce(contract.Address())), true
+	}
+	return common.Address{}, false
+}
+
+// callInput returns the input a call or create opcode is about to pass to its
+// callee, read from memory. The stack must have been validated for the opcode.
+func callInput(op vm.OpCode, memory *vm.Memory, stack *vm.Stack) []byte {
+	switch op {
+	case vm.CALL, vm.CALLCODE:
+		return memory.Get(stack.Back(3).Int64(), stack.Back(4).Int64())
+	case vm.DELEGATECALL, vm.STATICCALL:
+		return memory.Get(stack.Back(2).Int64(), stack.Back(3).Int64())
+	case vm.CREATE:
+		return memory.Get(stack.Back(1).Int64(), stack.Back(2).Int64())
+	}
+	return nil
+}
@@ -7,11 +7,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"strings"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 )
@@ -257,11 +259,34 @@ func (ec *Client) NetworkID(ctx context.Context) (*big.Int, error) {
 // BalanceAt returns the wei balance of the given account.
 // The block number can be nil, in which case the balance is taken from the latest known block.
 func (ec *Client) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
-	var result hexutil.Big
+	var result balance
 	err := ec.c.CallContext(ctx, &result, "eth_getBalance", account, toBlockNumArg(blockNumber))
 	return (*big.Int)(&result), err
 }
 
+// balance is an account balance as returned by eth_getBalance, which ofbank nodes
+// serve as a decimal amount of coins rather than a hex quantity of wei.
+type balance big.Int
+
+// UnmarshalJSON decodes a decimal balance into wei. Hex quantities, as served
+// by standard nodes, are accepted too.
+func (b *balance) UnmarshalJSON(input []byte) error {
+	var s string
+	if err := json.Unmarshal(input, &s); err != nil {
+		return fmt.Errorf("invalid balance %s: %v", input, err)
+	}
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return (*hexutil.Big)(b).UnmarshalText([]byte(s))
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || r.Sign() < 0 || strings.ContainsAny(s, "/eE") {
+		return fmt.Errorf("invalid balance %q", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt64(params.Ether))
+	(*big.Int)(b).Quo(r.Num(), r.Denom())
+	return nil
+}
+
 // StorageAt returns the value of key in the contract storage of the given account.
 // The block number can be nil, in which case the value is taken from the latest known block.
 func (ec *Client) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
@@ -286,6 +311,45 @@ func (ec *Client) NonceAt(ctx context.Context, account common.Address, blockNumb
 	return uint64(result), err
 }
 
+// Names
+
+// ResolveName returns the account a bank account name like alice.cn.ofbank
+// resolves to.
+func (ec *Client) ResolveName(ctx context.Context, name string) (common.Address, error) {
+	var result common.Address
+	err := ec.c.CallContext(ctx, &result, "ofbank_resolveName", name)
+	return result, err
+}
+
+// LookupAddress returns the name the given account is known by, or an empty
+// string if it has none.
+func (ec *Client) LookupAddress(ctx context.Context, account common.Address) (string, error) {
+	var result string
+	err := ec.c.CallContext(ctx, &result, "ofbank_lookupAddress", account)
+	return result, err
+}
+
+// ResolveAccount returns the account given either as a hex address or as a
+// name, which has at least one dot.
+func (ec *Client) ResolveAccount(ctx context.Context, account string) (common.Address, error) {
+	if common.IsHexAddress(account) {
+		return common.HexToAddress(account), nil
+	}
+	if !strings.Contains(account, ".") {
+		return common.Address{}, fmt.Errorf("invalid address or name %q", account)
+	}
+	return ec.ResolveName(ctx, account)
+}
+
+// SendTransfer transfers value, a decimal amount of coins, between accounts
+// given as hex addresses or names. The transaction is signed by the node, with
+// the wallet of the sender.
+func (ec *Client) SendTransfer(ctx context.Context, from, to string, value string) (common.Hash, error) {
+	var result common.Hash
+	err := ec.c.CallContext(ctx, &result, "ofbank_sendTransfer", from, to, value)
+	return result, err
+}
+
 // Filters
 
 // FilterLogs executes a filter query.
@@ -317,7 +381,7 @@ func toFilterArg(q ethereum.FilterQuery) interface{} {
 
 // PendingBalanceAt returns the wei balance of the given account in the pending state.
 func (ec *Client) PendingBalanceAt(ctx context.Context, account common.Address) (*big.Int, error) {
-	var result hexutil.Big
+	var result balance
 	err := ec.c.CallContext(ctx, &result, "eth_getBalance", account, "pending")
 	return (*big.Int)(&result), err
 }
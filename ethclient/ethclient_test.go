@@ -0,0 +1,98 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethclient
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// Tests that balances are decoded from both the decimal coin amounts of ofbank
+// nodes and the hex wei quantities of standard nodes.
+func TestBalanceUnmarshal(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string // Wei balance, empty if the input is invalid
+	}{
+		{`"0.000000"`, "0"},
+		{`"12.500000"`, "12500000000000000000"},
+		{`"0.000001"`, "1000000000000"},
+		{`"3"`, "3000000000000000000"},
+		{`"0x0"`, "0"},
+		{`"0x1bc16d674ec80000"`, "2000000000000000000"},
+		{`""`, ""},
+		{`"-1.000000"`, ""},
+		{`"1e18"`, ""},
+		{`"1/2"`, ""},
+		{`"abc"`, ""},
+		{`12`, ""},
+	}
+	for _, tt := range tests {
+		var b balance
+		err := json.Unmarshal([]byte(tt.input), &b)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s: expected error, got %v", tt.input, (*big.Int)(&b))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.input, err)
+			continue
+		}
+		if have := (*big.Int)(&b).String(); have != tt.want {
+			t.Errorf("%s: balance mismatch: have %s, want %s", tt.input, have, tt.want)
+		}
+	}
+}
+
+// Tests that the balance accessors work against the eth_getBalance of a node.
+func TestBalanceAt(t *testing.T) {
+	account := common.HexToAddress("0x00000000010102030405060708090a0b0c0d0e0f1011121314")
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{
+		account: {Balance: new(big.Int).Mul(big.NewInt(25), big.NewInt(1e17))},
+	})
+	client := NewClient(sim.RPCClient())
+
+	want, _ := new(big.Int).SetString("2500000000000000000", 10)
+	if have, err := client.BalanceAt(context.Background(), account, nil); err != nil {
+		t.Fatalf("failed to retrieve balance: %v", err)
+	} else if have.Cmp(want) != 0 {
+		t.Fatalf("balance mismatch: have %v, want %v", have, want)
+	}
+	if err := sim.SetBalance(account, "7.250000"); err != nil {
+		t.Fatalf("failed to set balance: %v", err)
+	}
+	want, _ = new(big.Int).SetString("7250000000000000000", 10)
+	if have, err := client.PendingBalanceAt(context.Background(), account); err != nil {
+		t.Fatalf("failed to retrieve pending balance: %v", err)
+	} else if have.Cmp(want) != 0 {
+		t.Fatalf("pending balance mismatch: have %v, want %v", have, want)
+	}
+	sim.Commit()
+	if have, err := client.BalanceAt(context.Background(), account, big.NewInt(1)); err != nil {
+		t.Fatalf("failed to retrieve balance: %v", err)
+	} else if have.Cmp(want) != 0 {
+		t.Fatalf("committed balance mismatch: have %v, want %v", have, want)
+	}
+}
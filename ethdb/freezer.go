@@ -0,0 +1,308 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// The list of table names of chain freezer.
+const (
+	// FreezerHeaderTable indicates the name of the freezer header table.
+	FreezerHeaderTable = "headers"
+
+	// FreezerHashTable indicates the name of the freezer canonical hash table.
+	FreezerHashTable = "hashes"
+
+	// FreezerBodiesTable indicates the name of the freezer block body table.
+	FreezerBodiesTable = "bodies"
+
+	// FreezerReceiptTable indicates the name of the freezer receipts table.
+	FreezerReceiptTable = "receipts"
+
+	// FreezerDifficultyTable indicates the name of the freezer total difficulty table.
+	FreezerDifficultyTable = "diffs"
+)
+
+// freezerTables lists all the tables maintained by the chain freezer, in the
+// order items are appended to them.
+var freezerTables = []string{
+	FreezerHashTable,
+	FreezerHeaderTable,
+	FreezerBodiesTable,
+	FreezerReceiptTable,
+	FreezerDifficultyTable,
+}
+
+// freezerTableSize defines the maximum size of freezer data files.
+const freezerTableSize = 2 * 1000 * 1000 * 1000
+
+var (
+	// errUnknownTable is returned if the user attempts to read from a table that is
+	// not tracked by the freezer.
+	errUnknownTable = errors.New("unknown table")
+)
+
+// AncientReader contains the methods required to read from immutable ancient
+// chain data stored in a freezer.
+type AncientReader interface {
+	// HasAncient returns an indicator whether the specified data exists in the
+	// ancient store.
+	HasAncient(kind string, number uint64) (bool, error)
+
+	// Ancient retrieves an ancient binary blob from the append-only immutable files.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the ancient item numbers in the ancient store.
+	Ancients() (uint64, error)
+}
+
+// AncientWriter contains the methods required to write to immutable ancient
+// chain data stored in a freezer.
+type AncientWriter interface {
+	// AppendAncient injects all binary blobs belong to block at the end of the
+	// append-only immutable table files.
+	AppendAncient(number uint64, hash, header, body, receipts, td []byte) error
+
+	// TruncateAncients discards all but the first n ancient data from the ancient store.
+	TruncateAncients(n uint64) error
+
+	// SyncAncient flushes all in-memory ancient store data to disk.
+	SyncAncient() error
+}
+
+// AncientStore contains all the methods required to allow handling different
+// ancient data stores backing immutable chain data store.
+type AncientStore interface {
+	AncientReader
+	AncientWriter
+}
+
+// Freezer is an append-only database to store immutable chain data into flat
+// files, keeping it out of LevelDB and thus out of its compaction cycles. All
+// tables are indexed by block number and always hold the same number of items.
+type Freezer struct {
+	frozen uint64 // Number of blocks already frozen (must be first for 64 bit alignment)
+
+	datadir string
+	tables  map[string]*freezerTable // Data tables for storing everything
+
+	readMeter  gometrics.Meter // Meter for measuring the freezer read data usage
+	writeMeter gometrics.Meter // Meter for measuring the freezer write data usage
+}
+
+// NewFreezer creates a chain freezer that moves ancient chain data into
+// append-only flat file containers. The namespace is used to name the freezer
+// metrics.
+func NewFreezer(datadir string, namespace string) (*Freezer, error) {
+	freezer := &Freezer{
+		datadir:    datadir,
+		tables:     make(map[string]*freezerTable),
+		readMeter:  metrics.NewMeter(namespace + "ancient/read"),
+		writeMeter: metrics.NewMeter(namespace + "ancient/write"),
+	}
+	for _, name := range freezerTables {
+		table, err := newTable(datadir, name, freezerTableSize)
+		if err != nil {
+			for _, table := range freezer.tables {
+				table.Close()
+			}
+			return nil, err
+		}
+		freezer.tables[name] = table
+	}
+	if err := freezer.repair(); err != nil {
+		for _, table := range freezer.tables {
+			table.Close()
+		}
+		return nil, err
+	}
+	log.Info("Opened ancient database", "database", datadir, "items", atomic.LoadUint64(&freezer.frozen))
+	return freezer, nil
+}
+
+// Close terminates the chain freezer, closing all the data files.
+func (f *Freezer) Close() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists
+// in the freezer.
+func (f *Freezer) HasAncient(kind string, number uint64) (bool, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.has(number), nil
+	}
+	return false, nil
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (f *Freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if table := f.tables[kind]; table != nil {
+		blob, err := table.Retrieve(number)
+		if err == nil {
+			f.readMeter.Mark(int64(len(blob)))
+		}
+		return blob, err
+	}
+	return nil, errUnknownTable
+}
+
+// Ancients returns the length of the frozen items.
+func (f *Freezer) Ancients() (uint64, error) {
+	return atomic.LoadUint64(&f.frozen), nil
+}
+
+// AppendAncient injects all binary blobs belong to block at the end of the
+// append-only immutable table files.
+//
+// Notably, this function is lock free and expects a single writer. Out-of-order
+// injections will be rejected, but two concurrent injections with the same
+// number are not guarded against.
+func (f *Freezer) AppendAncient(number uint64, hash, header, body, receipts, td []byte) (err error) {
+	// Ensure the binary blobs we are appending is continuous with freezer.
+	if atomic.LoadUint64(&f.frozen) != number {
+		return errOutOrderInsertion
+	}
+	// Roll back all inserted data if any insertion below failed to ensure
+	// the tables don't go out of sync.
+	defer func() {
+		if err != nil {
+			rerr := f.repair()
+			if rerr != nil {
+				log.Crit("Failed to repair freezer", "err", rerr)
+			}
+			log.Info("Append ancient failed", "number", number, "err", err)
+		}
+	}()
+	blobs := map[string][]byte{
+		FreezerHashTable:       hash,
+		FreezerHeaderTable:     header,
+		FreezerBodiesTable:     body,
+		FreezerReceiptTable:    receipts,
+		FreezerDifficultyTable: td,
+	}
+	for _, name := range freezerTables {
+		if err := f.tables[name].Append(number, blobs[name]); err != nil {
+			log.Error("Failed to append ancient "+name, "number", number, "err", err)
+			return err
+		}
+		f.writeMeter.Mark(int64(len(blobs[name])))
+	}
+	atomic.AddUint64(&f.frozen, 1) // Only modify atomically
+	return nil
+}
+
+// TruncateAncients discards any recent data above the provided threshold number.
+func (f *Freezer) TruncateAncients(items uint64) error {
+	if atomic.LoadUint64(&f.frozen) <= items {
+		return nil
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(items); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, items)
+	return nil
+}
+
+// SyncAncient flushes all data tables to disk.
+func (f *Freezer) SyncAncient() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// repair truncates all data tables to the same length.
+func (f *Freezer) repair() error {
+	min := uint64(math.MaxUint64)
+	for _, table := range f.tables {
+		items := atomic.LoadUint64(&table.items)
+		if min > items {
+			min = items
+		}
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(min); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, min)
+	return nil
+}
+
+// freezerdb is a database wrapper that enables freezer data retrievals.
+type freezerdb struct {
+	Database
+	*Freezer
+}
+
+// NewDatabaseWithFreezer creates a high level database on top of a given key-
+// value data store with a freezer moving immutable chain segments into cold
+// storage.
+func NewDatabaseWithFreezer(db Database, freezer string, namespace string) (Database, error) {
+	frdb, err := NewFreezer(freezer, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return &freezerdb{
+		Database: db,
+		Freezer:  frdb,
+	}, nil
+}
+
+// Close implements Database, closing both the fast key-value store as well as
+// the slow ancient tables.
+func (frdb *freezerdb) Close() {
+	if err := frdb.Freezer.Close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	frdb.Database.Close()
+}
+
+// KeyValueStore returns the key-value store backing a database, unwrapping
+// any freezer attached to it.
+func KeyValueStore(db Database) Database {
+	if frdb, ok := db.(*freezerdb); ok {
+		return frdb.Database
+	}
+	return db
+}
@@ -10,18 +10,23 @@ import (
 	"strconv"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/contracts/names"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"	
-//	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/syndtr/goleveldb/leveldb"
@@ -548,6 +553,85 @@ func (s *PublicBlockChainAPI) GetStorageAt(ctx context.Context, address common.A
 	return res[:], state.Error()
 }
 
+// AccountResult is the result of an eth_getProof call, holding the Merkle proof
+// of an account together with all of its fields and the requested storage proofs.
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	Balance      string          `json:"balance"`
+	Coinage      string          `json:"coinage"`
+	LastCBN      string          `json:"last"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the Merkle proof of a single storage slot.
+type StorageResult struct {
+	Key   common.Hash     `json:"key"`
+	Value common.Hash     `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"`
+}
+
+// GetProof returns the Merkle proof of the given account and of the given storage
+// slots of it, against the state root of the given block.
+func (s *PublicBlockChainAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNr rpc.BlockNumber) (*AccountResult, error) {
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	proof, err := state.GetProof(address)
+	if err != nil {
+		return nil, err
+	}
+	// Decode the account from the proof itself, so every field matches the proof
+	account, err := light.VerifyAccountProof(header.Root, address, proof)
+	if err != nil {
+		return nil, err
+	}
+	result := &AccountResult{
+		Address:      address,
+		AccountProof: toHexProof(proof),
+		StorageProof: make([]StorageResult, 0, len(storageKeys)),
+	}
+	if account == nil {
+		// Non-existent account, the proof shows its absence
+		result.Balance, result.Coinage, result.LastCBN = "0.00", "0.00", "0"
+		result.CodeHash = crypto.Keccak256Hash(nil)
+		result.StorageHash = types.EmptyRootHash
+	} else {
+		result.Nonce = hexutil.Uint64(account.Nonce)
+		result.Balance, result.Coinage, result.LastCBN = account.Balance, account.Coinage, account.LastCBN
+		result.CodeHash = common.BytesToHash(account.CodeHash)
+		result.StorageHash = account.Root
+	}
+	for _, key := range storageKeys {
+		slot := StorageResult{Key: common.HexToHash(key), Proof: []hexutil.Bytes{}}
+		if account != nil {
+			proof, err := state.GetStorageProof(address, slot.Key)
+			if err != nil {
+				return nil, err
+			}
+			if slot.Value, err = light.VerifyStorageProof(account.Root, slot.Key, proof); err != nil {
+				return nil, err
+			}
+			slot.Proof = toHexProof(proof)
+		}
+		result.StorageProof = append(result.StorageProof, slot)
+	}
+	return result, state.Error()
+}
+
+// toHexProof converts a Merkle proof into its JSON friendly representation.
+func toHexProof(proof []rlp.RawValue) []hexutil.Bytes {
+	nodes := make([]hexutil.Bytes, len(proof))
+	for i, node := range proof {
+		nodes[i] = hexutil.Bytes(node)
+	}
+	return nodes
+}
+
 // CallArgs represents the arguments for a call.
 type CallArgs struct {
 	From     common.Address  `json:"from"`
@@ -558,12 +642,12 @@ type CallArgs struct {
 	Data     hexutil.Bytes   `json:"data"`
 }
 
-func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config) ([]byte, *big.Int, error) {
+func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber, vmCfg vm.Config) ([]byte, *big.Int, bool, error) {
 	defer func(start time.Time) { log.Debug("Executing EVM call finished", "runtime", time.Since(start)) }(time.Now())
 
 	state, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
 	if state == nil || err != nil {
-		return nil, common.Big0, err
+		return nil, common.Big0, false, err
 	}
 	// Set sender address or use a default if none specified
 	addr := args.From
@@ -601,7 +685,7 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	// Get a new instance of the EVM.
 	evm, vmError, err := s.b.GetEVM(ctx, msg, state, header, vmCfg)
 	if err != nil {
-		return nil, common.Big0, err
+		return nil, common.Big0, false, err
 	}
 	// Wait for the context to be done and cancel the evm. Even if the
 	// EVM has finished, cancelling may be done (repeatedly)
@@ -613,17 +697,17 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	// Setup the gas pool (also for unmetered requests)
 	// and apply the message.
 	gp := new(core.GasPool).AddGas(math.MaxBig256)
-	res, gas, err := core.ApplyMessage(evm, msg, gp)
+	res, gas, failed, err := core.ApplyMessage(evm, msg, gp)
 	if err := vmError(); err != nil {
-		return nil, common.Big0, err
+		return nil, common.Big0, false, err
 	}
-	return res, gas, err
+	return res, gas, failed, err
 }
 
 // Call executes the given transaction on the state for the given block number.
 // It doesn't make and changes in the state/blockchain and is useful to execute and retrieve values.
 func (s *PublicBlockChainAPI) Call(ctx context.Context, args CallArgs, blockNr rpc.BlockNumber) (hexutil.Bytes, error) {
-	result, _, err := s.doCall(ctx, args, blockNr, vm.Config{DisableGasMetering: true})
+	result, _, _, err := s.doCall(ctx, args, blockNr, vm.Config{DisableGasMetering: true})
 	return (hexutil.Bytes)(result), err
 }
 
@@ -646,10 +730,11 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (*
 		mid := (hi + lo) / 2
 		(*big.Int)(&args.Gas).SetUint64(mid)
 
-		_, gas, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{})
+		_, _, failed, err := s.doCall(ctx, args, rpc.PendingBlockNumber, vm.Config{})
 
-		// If the transaction became invalid or used all the gas (failed), raise the gas limit
-		if err != nil || gas.Cmp((*big.Int)(&args.Gas)) == 0 {
+		// If the transaction became invalid or failed (ran out of gas or reverted),
+		// raise the gas limit
+		if err != nil || failed {
 			lo = mid
 			continue
 		}
@@ -664,6 +749,7 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args CallArgs) (*
 // gas used and the return value
 type ExecutionResult struct {
 	Gas         *big.Int       `json:"gas"`
+	Failed      bool           `json:"failed"`
 	ReturnValue string         `json:"returnValue"`
 	StructLogs  []StructLogRes `json:"structLogs"`
 }
@@ -787,6 +873,8 @@ type RPCTransaction struct {
 	V                *hexutil.Big    `json:"v"`
 	R                *hexutil.Big    `json:"r"`
 	S                *hexutil.Big    `json:"s"`
+	FromName         string          `json:"fromName,omitempty"`
+	ToName           string          `json:"toName,omitempty"`
 }
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
@@ -886,7 +974,7 @@ func (s *PublicTransactionPoolAPI) GetBlockTransactionCountByHash(ctx context.Co
 // GetTransactionByBlockNumberAndIndex returns the transaction for the given block number and index.
 func (s *PublicTransactionPoolAPI) GetTransactionByBlockNumberAndIndex(ctx context.Context, blockNr rpc.BlockNumber, index hexutil.Uint) *RPCTransaction {
 	if block, _ := s.b.BlockByNumber(ctx, blockNr); block != nil {
-		return newRPCTransactionFromBlockIndex(block, uint64(index))
+		return withNames(ctx, s.b, newRPCTransactionFromBlockIndex(block, uint64(index)))
 	}
 	return nil
 }
@@ -894,7 +982,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionByBlockNumberAndIndex(ctx conte
 // GetTransactionByBlockHashAndIndex returns the transaction for the given block hash and index.
 func (s *PublicTransactionPoolAPI) GetTransactionByBlockHashAndIndex(ctx context.Context, blockHash common.Hash, index hexutil.Uint) *RPCTransaction {
 	if block, _ := s.b.GetBlock(ctx, blockHash); block != nil {
-		return newRPCTransactionFromBlockIndex(block, uint64(index))
+		return withNames(ctx, s.b, newRPCTransactionFromBlockIndex(block, uint64(index)))
 	}
 	return nil
 }
@@ -929,11 +1017,11 @@ func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, addr
 func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) *RPCTransaction {
 	// Try to return an already finalized transaction
 	if tx, blockHash, blockNumber, index := core.GetTransaction(s.b.ChainDb(), hash); tx != nil {
-		return newRPCTransaction(tx, blockHash, blockNumber, index)
+		return withNames(ctx, s.b, newRPCTransaction(tx, blockHash, blockNumber, index))
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx)
+		return withNames(ctx, s.b, newRPCPendingTransaction(tx))
 	}
 	// Transaction unknown, return as such
 	return nil
@@ -955,7 +1043,7 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 }
 
 // GetTransactionReceipt returns the transaction receipt for the given transaction hash.
-func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (map[string]interface{}, error) {
+func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
 	tx, blockHash, blockNumber, index := core.GetTransaction(s.b.ChainDb(), hash)
 	if tx == nil {
 		return nil, nil
@@ -969,7 +1057,6 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (map[
 	from, _ := types.Sender(signer, tx)
 
 	fields := map[string]interface{}{
-		"root":              hexutil.Bytes(receipt.PostState),
 		"blockHash":         blockHash,
 		"blockNumber":       hexutil.Uint64(blockNumber),
 		"transactionHash":   hash,
@@ -982,6 +1069,12 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (map[
 		"logs":              receipt.Logs,
 		"logsBloom":         receipt.Bloom,
 	}
+	// Assign receipt status or post state.
+	if len(receipt.PostState) > 0 {
+		fields["root"] = hexutil.Bytes(receipt.PostState)
+	} else {
+		fields["status"] = hexutil.Uint(receipt.Status)
+	}
 	if receipt.Logs == nil {
 		fields["logs"] = [][]*types.Log{}
 	}
@@ -989,6 +1082,18 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(hash common.Hash) (map[
 	if receipt.ContractAddress != (common.Address{}) {
 		fields["contractAddress"] = receipt.ContractAddress
 	}
+	// Show the names the sender and recipient are known by, if the chain has a name service
+	if !hasNameService(ctx, s.b) {
+		return fields, nil
+	}
+	if name := lookupName(ctx, s.b, from); name != "" {
+		fields["fromName"] = name
+	}
+	if to := tx.To(); to != nil {
+		if name := lookupName(ctx, s.b, *to); name != "" {
+			fields["toName"] = name
+		}
+	}
 	return fields, nil
 }
 
@@ -1319,7 +1424,7 @@ func NewPrivateDebugAPI(b Backend) *PrivateDebugAPI {
 
 // ChaindbProperty returns leveldb properties of the chain database.
 func (api *PrivateDebugAPI) ChaindbProperty(property string) (string, error) {
-	ldb, ok := api.b.ChainDb().(interface {
+	ldb, ok := ethdb.KeyValueStore(api.b.ChainDb()).(interface {
 		LDB() *leveldb.DB
 	})
 	if !ok {
@@ -1334,7 +1439,7 @@ func (api *PrivateDebugAPI) ChaindbProperty(property string) (string, error) {
 }
 
 func (api *PrivateDebugAPI) ChaindbCompact() error {
-	ldb, ok := api.b.ChainDb().(interface {
+	ldb, ok := ethdb.KeyValueStore(api.b.ChainDb()).(interface {
 		LDB() *leveldb.DB
 	})
 	if !ok {
@@ -1386,33 +1491,108 @@ func (s *PublicNetAPI) Version() string {
 type PublicWaterAPI struct {
 	am		*accounts.Manager	
 	b 		Backend
+	nonceLock	*AddrLocker
 }
 
-func NewPublicWaterAPI(b Backend) *PublicWaterAPI {
+func NewPublicWaterAPI(b Backend, nonceLock *AddrLocker) *PublicWaterAPI {
 	return &PublicWaterAPI {
 		am:	b.AccountManager(),
 		b: b,
+		nonceLock: nonceLock,
 	}
 }
 
-func (s *PublicWaterAPI) Show(ctx context.Context, addr common.Address, blockNr rpc.BlockNumber) (string, error) {
-	state, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
-	if state == nil || err != nil {
+// Show returns the balance of an account, given as a hex address or a name.
+func (s *PublicWaterAPI) Show(ctx context.Context, name string, blockNr rpc.BlockNumber) (string, error) {
+	addr, err := resolveAccount(ctx, s.b, name)
+	if err != nil {
+		return "0.00", err
+	}
+	account, _, _, err := s.account(ctx, addr, blockNr)
+	if account == nil || err != nil {
 		return "0.00", err //nil, err
 	}
-	b := state.GetBalance(addr)			// *big.Int		Water Cherry
-//	blnc_f, _ := strconv.ParseFloat(b, 64)
-//	blnc_s := fmt.Printf("%6.6f", blnc_f)
-	return b, state.Error()	// "web3.fromWei(eth.getBalance(eth.accounts["+x+"]))"
+	return account.Balance, nil	// "web3.fromWei(eth.getBalance(eth.accounts["+x+"]))"
+}
+
+// account retrieves an account at the given block together with its Merkle
+// proof, checking the two against the state root of the block. On a light
+// client the proof is fetched from the network on demand, so the returned
+// values are as trustworthy as the header chain the client follows. A nil
+// account without an error means that the account doesn't exist.
+func (s *PublicWaterAPI) account(ctx context.Context, addr common.Address, blockNr rpc.BlockNumber) (*state.Account, *types.Header, []rlp.RawValue, error) {
+	statedb, header, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
+	if statedb == nil || err != nil {
+		return nil, nil, nil, err
+	}
+	proof, err := statedb.GetProof(addr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	account, err := light.VerifyAccountProof(header.Root, addr, proof)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return account, header, proof, nil
 }
 
-func (s *PublicWaterAPI) UpdCoinage(ctx context.Context, addr common.Address, blockNr rpc.BlockNumber) (string, error) {
-	cur_bn, _, err := s.b.StateAndHeaderByNumber(ctx, blockNr)
-	if cur_bn == nil || err != nil {
+// ProveBalance returns a self-contained proof of the balance, coinage and last
+// coinage block of an account, which can be verified offline against the header
+// of the given block using light.VerifyBalanceProof. The account may be given
+// as a hex address or a name.
+func (s *PublicWaterAPI) ProveBalance(ctx context.Context, name string, blockNr rpc.BlockNumber) (*light.BalanceProof, error) {
+	addr, err := resolveAccount(ctx, s.b, name)
+	if err != nil {
+		return nil, err
+	}
+	account, header, proof, err := s.account(ctx, addr, blockNr)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		if header == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("account %x doesn't exist", addr)
+	}
+	return &light.BalanceProof{
+		Address:     addr,
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		BlockHash:   header.Hash(),
+		StateRoot:   header.Root,
+		Nonce:       hexutil.Uint64(account.Nonce),
+		Balance:     account.Balance,
+		Coinage:     account.Coinage,
+		LastCBN:     account.LastCBN,
+		Proof:       toHexProof(proof),
+	}, nil
+}
+
+// UpdCoinage returns the coinage of an account, given as a hex address or a name.
+func (s *PublicWaterAPI) UpdCoinage(ctx context.Context, name string, blockNr rpc.BlockNumber) (string, error) {
+	addr, err := resolveAccount(ctx, s.b, name)
+	if err != nil {
+		return "0.00", err
+	}
+	account, _, _, err := s.account(ctx, addr, blockNr)
+	if account == nil || err != nil {
 		return "0.00", err
 	}
-	c := cur_bn.GetCoinage(addr)		// Water Redbull
-	return c, cur_bn.Error()
+	return account.Coinage, nil		// Water Redbull
+}
+
+// Last returns the number of the block the coinage of an account was last
+// accrued at. The account may be given as a hex address or a name.
+func (s *PublicWaterAPI) Last(ctx context.Context, name string, blockNr rpc.BlockNumber) (string, error) {
+	addr, err := resolveAccount(ctx, s.b, name)
+	if err != nil {
+		return "0", err
+	}
+	account, _, _, err := s.account(ctx, addr, blockNr)
+	if account == nil || err != nil {
+		return "0", err
+	}
+	return account.LastCBN, nil
 }
 
 func (s *PublicWaterAPI) PrintTS() string {
@@ -1432,11 +1612,18 @@ func (s *PublicWaterAPI) TxpqStat() map[string] hexutil.Uint {
 func (s *PublicWaterAPI) CheckTrans(ctx context.Context, hash common.Hash) *RPCTransaction {
 	// Try to return an already finalized transaction
 	if tx, blockHash, blockNumber, index := core.GetTransaction(s.b.ChainDb(), hash); tx != nil {
-		return newRPCTransaction(tx, blockHash, blockNumber, index)
+		return withNames(ctx, s.b, newRPCTransaction(tx, blockHash, blockNumber, index))
+	}
+	// Light clients only know the position of their own mined transactions, the
+	// body of the block might need to be retrieved on demand
+	if blockHash, blockNumber, index := core.GetTxLookupEntry(s.b.ChainDb(), hash); blockHash != (common.Hash{}) {
+		if block, _ := s.b.GetBlock(ctx, blockHash); block != nil && int(index) < len(block.Transactions()) {
+			return withNames(ctx, s.b, newRPCTransaction(block.Transactions()[index], blockHash, blockNumber, index))
+		}
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx)
+		return withNames(ctx, s.b, newRPCPendingTransaction(tx))
 	}
 	// Transaction unknown, return as such
 	return nil
@@ -1526,6 +1713,47 @@ func (s *PublicWaterAPI) SendTrans(ctx context.Context, from, to common.Address,
 	return submitTransaction(ctx, s.b, signed)
 }
 
+// SendTransfer transfers value, a decimal amount of coins, between accounts
+// given as hex addresses or names, signing with the wallet of the sender.
+func (s *PublicWaterAPI) SendTransfer(ctx context.Context, from, to string, value string) (common.Hash, error) {
+	sender, err := resolveAccount(ctx, s.b, from)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	recipient, err := resolveAccount(ctx, s.b, to)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	amount := math.ParseDecimal(value, big.NewInt(params.Ether))
+	if amount.Sign() <= 0 {
+		return common.Hash{}, fmt.Errorf("invalid amount %q", value)
+	}
+	args := SendTxArgs{From: sender, To: &recipient, Value: (*hexutil.Big)(amount)}
+	return NewPublicTransactionPoolAPI(s.b, s.nonceLock).SendTransaction(ctx, args)
+}
+
+// ResolveName returns the account a name resolves to.
+func (s *PublicWaterAPI) ResolveName(ctx context.Context, name string) (common.Address, error) {
+	if !names.IsName(name) {
+		return common.Address{}, fmt.Errorf("invalid name %q", name)
+	}
+	return resolveAccount(ctx, s.b, name)
+}
+
+// LookupAddress returns the name an account is known by, or an empty string if
+// it has none.
+func (s *PublicWaterAPI) LookupAddress(ctx context.Context, addr common.Address) (string, error) {
+	resolver, err := names.NewResolver(names.RegistryAddress, backendCaller{s.b})
+	if err != nil {
+		return "", err
+	}
+	name, err := resolver.Lookup(&bind.CallOpts{Context: ctx}, addr)
+	if err == bind.ErrNoCode {
+		return "", errNoNameService
+	}
+	return name, err
+}
+
 func (s *PublicWaterAPI) Register(appcode, sccode string, password string) (common.Address, error) {
 	if !isNumber(appcode) {
 		return common.Address{}, fmt.Errorf("app code is not number")
@@ -1614,22 +1842,27 @@ func (s *PublicWaterAPI) UnlockW(addr common.Address, password string, duration
 	return err == nil, err
 }
 
-func (s *PublicWaterAPI) SetCB(ctx context.Context, addr common.Address) string {
+func (s *PublicWaterAPI) SetCB(ctx context.Context, addr common.Address) (string, error) {
 	for _, value := range types.PubMinerCa {
 		if addr == value.Coinbase {
-			return "exist"
+			return "exist", nil
 		}
 	}
 
 	var bufMinCa types.MinerCoinage
 	bufMinCa.Coinbase = addr
-	cur_bn, _, _ := s.b.StateAndHeaderByNumber(ctx,  rpc.LatestBlockNumber)
-	coinage := cur_bn.GetCoinage(addr)		// Water Redbull
-	bufMinCa.Coinage = coinage
+	account, _, _, err := s.account(ctx, addr, rpc.LatestBlockNumber)
+	if err != nil {
+		return "", err
+	}
+	bufMinCa.Coinage = "0.00"
+	if account != nil {
+		bufMinCa.Coinage = account.Coinage		// Water Redbull
+	}
 
 	types.PubMinerCa = append(types.PubMinerCa, bufMinCa)
 
-	return "sucessful"
+	return "sucessful", nil
 }
 
 
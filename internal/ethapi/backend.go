@@ -98,7 +98,7 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 		}, {
 			Namespace: "ofbank",
 			Version:   "0.9",
-			Service:   NewPublicWaterAPI(apiBackend),
+			Service:   NewPublicWaterAPI(apiBackend, nonceLock),
 			Public:    true,
 		},
 	}
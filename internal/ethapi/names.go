@@ -0,0 +1,97 @@
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/contracts/names"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	errNoNameService = errors.New("no name service deployed on this chain")
+	errCallFailed    = errors.New("contract call failed")
+)
+
+// backendCaller implements bind.ContractCaller on top of the API backend, to
+// call contracts like the name registry from within the APIs.
+type backendCaller struct {
+	b Backend
+}
+
+func (c backendCaller) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	state, _, err := c.b.StateAndHeaderByNumber(ctx, toBlockNumber(blockNumber))
+	if state == nil || err != nil {
+		return nil, err
+	}
+	return state.GetCode(contract), state.Error()
+}
+
+func (c backendCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	args := CallArgs{From: call.From, To: call.To, Data: call.Data}
+	res, _, failed, err := (&PublicBlockChainAPI{c.b}).doCall(ctx, args, toBlockNumber(blockNumber), vm.Config{DisableGasMetering: true})
+	if err == nil && failed {
+		err = errCallFailed
+	}
+	return res, err
+}
+
+// toBlockNumber converts the block number of a contract call, nil meaning the
+// latest block.
+func toBlockNumber(number *big.Int) rpc.BlockNumber {
+	if number == nil {
+		return rpc.LatestBlockNumber
+	}
+	return rpc.BlockNumber(number.Int64())
+}
+
+// resolveAccount returns the account given as a hex address or a name, with
+// names resolved at the latest block.
+func resolveAccount(ctx context.Context, b Backend, account string) (common.Address, error) {
+	resolver, err := names.NewResolver(names.RegistryAddress, backendCaller{b})
+	if err != nil {
+		return common.Address{}, err
+	}
+	addr, err := resolver.Account(&bind.CallOpts{Context: ctx}, account)
+	if err == bind.ErrNoCode {
+		return common.Address{}, errNoNameService
+	}
+	return addr, err
+}
+
+// lookupName returns the name an account is known by, or an empty string if it
+// has none. Failures are not reported, names only annotate the outputs.
+func lookupName(ctx context.Context, b Backend, account common.Address) string {
+	resolver, err := names.NewResolver(names.RegistryAddress, backendCaller{b})
+	if err != nil {
+		return ""
+	}
+	name, _ := resolver.Lookup(&bind.CallOpts{Context: ctx}, account)
+	return name
+}
+
+// hasNameService returns whether the registry of the name service is deployed
+// at the latest block.
+func hasNameService(ctx context.Context, b Backend) bool {
+	code, err := backendCaller{b}.CodeAt(ctx, names.RegistryAddress, nil)
+	return err == nil && len(code) > 0
+}
+
+// withNames annotates a transaction with the names its sender and recipient are
+// known by. Chains without the name service are left alone, sparing a registry
+// call per account.
+func withNames(ctx context.Context, b Backend, tx *RPCTransaction) *RPCTransaction {
+	if tx == nil || !hasNameService(ctx, b) {
+		return tx
+	}
+	tx.FromName = lookupName(ctx, b, tx.From)
+	if tx.To != nil {
+		tx.ToName = lookupName(ctx, b, *tx.To)
+	}
+	return tx
+}
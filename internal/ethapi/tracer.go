@@ -10,7 +10,9 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/robertkrimen/otto"
 )
 
@@ -114,9 +116,9 @@ type dbWrapper struct {
 	db vm.StateDB
 }
 
-// getBalance retrieves an account's balance
-func (dw *dbWrapper) getBalance(addr common.Address) string {	// Water Cherry
-	return dw.db.GetBalance(addr)
+// getBalance retrieves an account's balance in wei
+func (dw *dbWrapper) getBalance(addr common.Address) *big.Int {
+	return math.ParseDecimal(dw.db.GetBalance(addr), big.NewInt(params.Ether))
 }
 
 // getNonce retrieves an account's nonce
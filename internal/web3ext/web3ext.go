@@ -5,15 +5,16 @@ package web3ext
 
 var Modules = map[string]string{
 	"admin":      Admin_JS,
+	"bft":        BFT_JS,
 //	"chequebook": Chequebook_JS,
-//	"clique":     Clique_JS,
+	"clique":     Clique_JS,
 	"debug":      Debug_JS,
 	"eth":        Eth_JS,
 	"miner":      Miner_JS,
 	"net":        Net_JS,
 	"personal":   Personal_JS,
 	"rpc":        RPC_JS,
-//	"shh":        Shh_JS,
+	"shh":        Shh_JS,
 //	"swarmfs":    SWARMFS_JS,
 	"txpool":     TxPool_JS,
 	"ofbank":	  W_JS,
@@ -87,6 +88,34 @@ web3._extend({
 			name: 'discard',
 			call: 'clique_discard',
 			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getVoteLog',
+			call: 'clique_getVoteLog',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'getTallyHistory',
+			call: 'clique_getTallyHistory',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'getSignerStatus',
+			call: 'clique_getSignerStatus',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'scheduleProposal',
+			call: 'clique_scheduleProposal',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'discardScheduled',
+			call: 'clique_discardScheduled',
+			params: 2
 		})
   ],
 	properties:
@@ -95,6 +124,62 @@ web3._extend({
 			name: 'proposals',
 			getter: 'clique_proposals'
 		}),
+		new web3._extend.Property({
+			name: 'scheduledProposals',
+			getter: 'clique_scheduledProposals'
+		}),
+	]
+});
+`
+
+const BFT_JS = `
+web3._extend({
+	property: 'bft',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'getSnapshot',
+			call: 'bft_getSnapshot',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'getSnapshotAtHash',
+			call: 'bft_getSnapshotAtHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getValidators',
+			call: 'bft_getValidators',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'getValidatorsAtHash',
+			call: 'bft_getValidatorsAtHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'propose',
+			call: 'bft_propose',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'discard',
+			call: 'bft_discard',
+			params: 1
+		})
+	],
+	properties:
+	[
+		new web3._extend.Property({
+			name: 'proposals',
+			getter: 'bft_proposals'
+		}),
+		new web3._extend.Property({
+			name: 'roundState',
+			getter: 'bft_roundState'
+		}),
 	]
 });
 `
@@ -114,6 +199,16 @@ web3._extend({
 			call: 'admin_removePeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'addAllowedNode',
+			call: 'admin_addAllowedNode',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'removeAllowedNode',
+			call: 'admin_removeAllowedNode',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -149,6 +244,26 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'stopWS',
 			call: 'admin_stopWS'
+		}),
+		new web3._extend.Method({
+			name: 'addPriorityClient',
+			call: 'admin_addPriorityClient',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'removePriorityClient',
+			call: 'admin_removePriorityClient',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'addPriorityToken',
+			call: 'admin_addPriorityToken',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'removePriorityToken',
+			call: 'admin_removePriorityToken',
+			params: 1
 		})
 	],
 	properties:
@@ -164,6 +279,18 @@ web3._extend({
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
+		}),
+		new web3._extend.Property({
+			name: 'allowedNodes',
+			getter: 'admin_allowedNodes'
+		}),
+		new web3._extend.Property({
+			name: 'priorityClients',
+			getter: 'admin_priorityClients'
+		}),
+		new web3._extend.Property({
+			name: 'lightClients',
+			getter: 'admin_lightClients'
 		})
 	]
 });
@@ -192,22 +319,26 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'traceBlock',
 			call: 'debug_traceBlock',
-			params: 1
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new web3._extend.Method({
 			name: 'traceBlockFromFile',
 			call: 'debug_traceBlockFromFile',
-			params: 1
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new web3._extend.Method({
 			name: 'traceBlockByNumber',
 			call: 'debug_traceBlockByNumber',
-			params: 1
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new web3._extend.Method({
 			name: 'traceBlockByHash',
 			call: 'debug_traceBlockByHash',
-			params: 1
+			params: 2,
+			inputFormatter: [null, null]
 		}),
 		new web3._extend.Method({
 			name: 'seedHash',
@@ -321,6 +452,12 @@ web3._extend({
 			params: 2,
 			inputFormatter: [null, null]
 		}),
+		new web3._extend.Method({
+			name: 'traceCall',
+			call: 'debug_traceCall',
+			params: 3,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'preimage',
 			call: 'debug_preimage',
@@ -392,6 +529,12 @@ web3._extend({
 			},
 			params: 2,
 			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, web3._extend.utils.toHex]
+		}),
+		new web3._extend.Method({
+			name: 'getProof',
+			call: 'eth_getProof',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputDefaultBlockNumberFormatter]
 		})
 	],
 	properties:
@@ -447,6 +590,10 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'getHashrate',
 			call: 'miner_getHashrate'
+		}),
+		new web3._extend.Method({
+			name: 'stratumWorkers',
+			call: 'miner_stratumWorkers'
 		})
 	],
 	properties: []
@@ -524,12 +671,12 @@ web3._extend({
 	methods: [
 		new web3._extend.Method({
 			name: 'setMaxMessageLength',
-			call: 'shh_setMaxMessageLength',
+			call: 'shh_setMaxMessageSize',
 			params: 1
 		}),
 		new web3._extend.Method({
 			name: 'setMinimumPoW',
-			call: 'shh_setMinimumPoW',
+			call: 'shh_setMinPoW',
 			params: 1
 		}),
 		new web3._extend.Method({
@@ -693,6 +840,15 @@ web3._extend({
 `
 
 const W_JS = `
+// ofbankAccountFormatter passes names of bank accounts on to the node, which
+// resolves them, and formats anything else as an address.
+var ofbankAccountFormatter = function(account) {
+	if (typeof account === 'string' && account.indexOf('.') >= 0) {
+		return account;
+	}
+	return web3._extend.formatters.inputAddressFormatter(account);
+};
+
 web3._extend({
 	property: 'ofbank',
 	methods: 
@@ -701,7 +857,7 @@ web3._extend({
 			name: 'showbal',
 			call: 'ofbank_show', 		//'eth_getBalance',
 			params: 2,
-			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+			inputFormatter: [ofbankAccountFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter],
 			outputFormatter: function(val) {
 				val = parseFloat(val);	//+'000001');
 				return val;
@@ -711,12 +867,45 @@ web3._extend({
 			name: 'updcage',
 			call: 'ofbank_updCoinage', // update_current_coinage
 			params: 2,
-			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+			inputFormatter: [ofbankAccountFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter],
 			outputFormatter: function(val) {
 				val = parseInt(val);
 				return val;
 			}			
 		}),
+		new web3._extend.Method({
+			name: 'last',
+			call: 'ofbank_last',
+			params: 2,
+			inputFormatter: [ofbankAccountFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter],
+			outputFormatter: function(val) {
+				val = parseInt(val);
+				return val;
+			}
+		}),
+		new web3._extend.Method({
+			name: 'proveBalance',
+			call: 'ofbank_proveBalance',
+			params: 2,
+			inputFormatter: [ofbankAccountFormatter, web3._extend.formatters.inputDefaultBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'subscribePayments',
+			call: 'ofbank_subscribePayments',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'unsubscribePayments',
+			call: 'ofbank_unsubscribePayments',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'paymentNotifier',
+			call: 'ofbank_paymentNotifier',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'register',
 			call: 'ofbank_register', //personal_newAccount',
@@ -786,6 +975,23 @@ web3._extend({
 			params: 3,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputAddressFormatter, null]
 		}),
+		new web3._extend.Method({
+			name: 'sendTransfer',
+			call: 'ofbank_sendTransfer',
+			params: 3,
+			inputFormatter: [ofbankAccountFormatter, ofbankAccountFormatter, function(value) { return String(value); }]
+		}),
+		new web3._extend.Method({
+			name: 'resolveName',
+			call: 'ofbank_resolveName',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'lookupAddress',
+			call: 'ofbank_lookupAddress',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'checkTrans',
 			call: 'ofbank_checkTrans',
@@ -0,0 +1,143 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+// PrivateLightServerAPI provides an API to manage the light clients served by a
+// LES server. Changes made through it last until the node is restarted, clients
+// that should always be prioritised belong in the config file.
+type PrivateLightServerAPI struct {
+	server *LesServer
+}
+
+// NewPrivateLightServerAPI creates a new API for managing light clients.
+func NewPrivateLightServerAPI(server *LesServer) *PrivateLightServerAPI {
+	return &PrivateLightServerAPI{server}
+}
+
+// PriorityClient is a priority tier entry of the light server.
+type PriorityClient struct {
+	NodeID   string `json:"nodeId,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Capacity uint64 `json:"capacity"`
+}
+
+// LightClient is the live flow control status of a connected light client.
+type LightClient struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	NodeID      string `json:"nodeId"`
+	Priority    bool   `json:"priority"`
+	Capacity    uint64 `json:"capacity"`
+	BufValue    uint64 `json:"bufValue"`
+	BufLimit    uint64 `json:"bufLimit"`
+	MinRecharge uint64 `json:"minRecharge"`
+	Connected   string `json:"connected"`
+}
+
+// AddPriorityClient grants a light client, identified by its node ID, the given
+// multiple of the free client capacity. A connected client is disconnected to
+// renegotiate its flow control parameters.
+func (api *PrivateLightServerAPI) AddPriorityClient(nodeID string, capacity uint64) (bool, error) {
+	if err := api.server.clients.addNode(nodeID, capacity); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemovePriorityClient revokes the priority tier of a light client.
+func (api *PrivateLightServerAPI) RemovePriorityClient(nodeID string) (bool, error) {
+	if err := api.server.clients.removeNode(nodeID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AddPriorityToken grants the light clients proving the given auth token the
+// given multiple of the free client capacity.
+func (api *PrivateLightServerAPI) AddPriorityToken(token string, capacity uint64) (bool, error) {
+	if token == "" {
+		return false, fmt.Errorf("empty token")
+	}
+	if err := api.server.clients.addToken(token, capacity); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RemovePriorityToken revokes the priority tier granted by an auth token.
+func (api *PrivateLightServerAPI) RemovePriorityToken(token string) bool {
+	api.server.clients.removeToken(token)
+	return true
+}
+
+// PriorityClients lists the priority tiers of the light server.
+func (api *PrivateLightServerAPI) PriorityClients() []PriorityClient {
+	pool := api.server.clients
+
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	list := make([]PriorityClient, 0, len(pool.nodes)+len(pool.tokens))
+	for id, capacity := range pool.nodes {
+		list = append(list, PriorityClient{NodeID: id.String(), Capacity: capacity})
+	}
+	for token, capacity := range pool.tokens {
+		list = append(list, PriorityClient{Token: token, Capacity: capacity})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].NodeID != list[j].NodeID {
+			return list[i].NodeID < list[j].NodeID
+		}
+		return list[i].Token < list[j].Token
+	})
+	return list
+}
+
+// LightClients reports the flow control status of the connected light clients.
+func (api *PrivateLightServerAPI) LightClients() []LightClient {
+	pool := api.server.clients
+
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	now := mclock.Now()
+	list := make([]LightClient, 0, len(pool.clients))
+	for id, client := range pool.clients {
+		bufValue, params := client.peer.fcClient.BufferStatus()
+		list = append(list, LightClient{
+			ID:          id,
+			Name:        client.peer.Name(),
+			NodeID:      client.peer.ID().String(),
+			Priority:    client.priority,
+			Capacity:    client.capacity,
+			BufValue:    bufValue,
+			BufLimit:    params.BufLimit,
+			MinRecharge: params.MinRecharge,
+			Connected:   time.Duration(now - client.connected).String(),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
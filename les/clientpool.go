@@ -0,0 +1,304 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/les/flowcontrol"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+var (
+	errTooManyClients    = errors.New("too many light clients")
+	errPriorityReconnect = errors.New("priority tier granted, reconnect required")
+	errInvalidCapacity   = errors.New("capacity must be at least 1")
+)
+
+// clientInfo is the bookkeeping of a light client connected to the server.
+type clientInfo struct {
+	peer      *peer
+	capacity  uint64
+	priority  bool
+	connected mclock.AbsTime
+}
+
+// clientPool is the server side client manager, assigning flow control capacity
+// to the connected light clients based on their identity. Priority clients are
+// listed by node ID or by auth token and receive a multiple of the free client
+// parameters; free clients share the remaining slots and are evicted to make
+// room for priority ones when the server is full.
+//
+// Clients never send their auth token, only a proof of it bound to the server's
+// node ID (see authProof), which is worthless to present to any other server.
+type clientPool struct {
+	lock       sync.Mutex
+	maxClients int                       // Maximum number of clients (0 = unlimited)
+	defParams  *flowcontrol.ServerParams // Flow control parameters of free clients
+	local      discover.NodeID           // Node ID of the server, auth proofs are bound to
+
+	nodes  map[discover.NodeID]uint64 // Priority capacity by node ID
+	tokens map[string]uint64          // Priority capacity by auth token
+	bound  map[discover.NodeID]string // Auth tokens proven by nodes
+
+	clients map[string]*clientInfo // Connected clients by peer ID
+	drop    func(id string)        // Disconnects a client
+}
+
+// newClientPool creates a client pool with the priority clients of the config.
+func newClientPool(maxClients int, defParams *flowcontrol.ServerParams, priority []eth.LightPriorityClient, drop func(id string)) (*clientPool, error) {
+	pool := &clientPool{
+		maxClients: maxClients,
+		defParams:  defParams,
+		nodes:      make(map[discover.NodeID]uint64),
+		tokens:     make(map[string]uint64),
+		bound:      make(map[discover.NodeID]string),
+		clients:    make(map[string]*clientInfo),
+		drop:       drop,
+	}
+	for i, client := range priority {
+		var err error
+		switch {
+		case client.NodeID != "" && client.Token != "":
+			err = errors.New("both node ID and token set")
+		case client.NodeID != "":
+			err = pool.addNode(client.NodeID, client.Capacity)
+		case client.Token != "":
+			err = pool.addToken(client.Token, client.Capacity)
+		default:
+			err = errors.New("neither node ID nor token set")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority client #%d: %v", i, err)
+		}
+	}
+	return pool, nil
+}
+
+// authProof returns the proof of an auth token a light client presents to the
+// server with the given node ID.
+func authProof(token string, server discover.NodeID) []byte {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write(server[:])
+	return mac.Sum(nil)
+}
+
+// setLocal sets the node ID of the server, which auth proofs are checked for.
+func (cp *clientPool) setLocal(id discover.NodeID) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	cp.local = id
+}
+
+// proves returns whether the client presented a proof of the given token.
+func (cp *clientPool) proves(p *peer, token string) bool {
+	return len(p.authProof) > 0 && hmac.Equal(p.authProof, authProof(token, cp.local))
+}
+
+// authorize binds the client to the token it presented a proof of, if any is
+// entitled to priority. It's called during the handshake, so that a client
+// dialing the server is admitted with its priority capacity right away.
+func (cp *clientPool) authorize(p *peer) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	for token := range cp.tokens {
+		if cp.proves(p, token) {
+			cp.bound[p.ID()] = token
+			return
+		}
+	}
+}
+
+// capacity returns the capacity the client with the given node ID is entitled
+// to, and whether it's a priority client.
+func (cp *clientPool) capacity(id discover.NodeID) (uint64, bool) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	return cp.lookup(id)
+}
+
+func (cp *clientPool) lookup(id discover.NodeID) (uint64, bool) {
+	if capacity, ok := cp.nodes[id]; ok {
+		return capacity, true
+	}
+	if token, ok := cp.bound[id]; ok {
+		if capacity, ok := cp.tokens[token]; ok {
+			return capacity, true
+		}
+	}
+	return 1, false
+}
+
+// params returns the flow control parameters matching the given capacity.
+func (cp *clientPool) params(capacity uint64) *flowcontrol.ServerParams {
+	return &flowcontrol.ServerParams{
+		BufLimit:    cp.defParams.BufLimit * capacity,
+		MinRecharge: cp.defParams.MinRecharge * capacity,
+	}
+}
+
+// connect admits a client that finished the handshake, evicting a free client
+// if a priority one arrives at a full server.
+func (cp *clientPool) connect(p *peer, capacity uint64) error {
+	var evict []string
+	defer func() {
+		for _, id := range evict {
+			cp.drop(id)
+		}
+	}()
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	// The capacity announced in the handshake is stale if the client's tier
+	// changed meanwhile, or if it was only authorized after the handshake was
+	// sent as the server dialed it; reconnect to pick up the current one.
+	want, priority := cp.lookup(p.ID())
+	if want != capacity {
+		return errPriorityReconnect
+	}
+	if cp.maxClients > 0 && len(cp.clients) >= cp.maxClients {
+		if !priority {
+			return errTooManyClients
+		}
+		// Make room by evicting the free client that connected last, letting
+		// priority clients exceed the limit if there are no free ones left
+		var newest *clientInfo
+		for _, client := range cp.clients {
+			if !client.priority && (newest == nil || client.connected > newest.connected) {
+				newest = client
+			}
+		}
+		if newest != nil {
+			newest.peer.Log().Debug("Evicting free light client", "priority", p.id)
+			delete(cp.clients, newest.peer.id)
+			evict = append(evict, newest.peer.id)
+		}
+	}
+	cp.clients[p.id] = &clientInfo{
+		peer:      p,
+		capacity:  capacity,
+		priority:  priority,
+		connected: mclock.Now(),
+	}
+	return nil
+}
+
+// disconnect removes a client from the pool.
+func (cp *clientPool) disconnect(p *peer) {
+	cp.lock.Lock()
+	defer cp.lock.Unlock()
+
+	if client, ok := cp.clients[p.id]; ok && client.peer == p {
+		delete(cp.clients, p.id)
+	}
+}
+
+// addNode grants priority to a client identified by its node ID.
+func (cp *clientPool) addNode(nodeID string, capacity uint64) error {
+	id, err := discover.HexID(nodeID)
+	if err != nil {
+		return err
+	}
+	if capacity == 0 {
+		return errInvalidCapacity
+	}
+	cp.lock.Lock()
+	cp.nodes[id] = capacity
+	cp.lock.Unlock()
+
+	cp.reconnect(func(client *clientInfo) bool { return client.peer.ID() == id })
+	return nil
+}
+
+// removeNode revokes the priority of a client identified by its node ID.
+func (cp *clientPool) removeNode(nodeID string) error {
+	id, err := discover.HexID(nodeID)
+	if err != nil {
+		return err
+	}
+	cp.lock.Lock()
+	delete(cp.nodes, id)
+	cp.lock.Unlock()
+
+	cp.reconnect(func(client *clientInfo) bool { return client.peer.ID() == id })
+	return nil
+}
+
+// addToken grants priority to the clients presenting the given auth token.
+func (cp *clientPool) addToken(token string, capacity uint64) error {
+	if capacity == 0 {
+		return errInvalidCapacity
+	}
+	cp.lock.Lock()
+	cp.tokens[token] = capacity
+	for _, client := range cp.clients {
+		if cp.proves(client.peer, token) {
+			cp.bound[client.peer.ID()] = token
+		}
+	}
+	cp.lock.Unlock()
+
+	cp.reconnect(func(client *clientInfo) bool { return cp.proves(client.peer, token) })
+	return nil
+}
+
+// removeToken revokes the priority of the clients presenting the given token.
+func (cp *clientPool) removeToken(token string) {
+	cp.lock.Lock()
+	delete(cp.tokens, token)
+	for id, bound := range cp.bound {
+		if bound == token {
+			delete(cp.bound, id)
+		}
+	}
+	cp.lock.Unlock()
+
+	cp.reconnect(func(client *clientInfo) bool { return cp.proves(client.peer, token) })
+}
+
+// reconnect disconnects the matching clients whose capacity changed, so that
+// they pick up their new flow control parameters when reconnecting.
+func (cp *clientPool) reconnect(match func(*clientInfo) bool) {
+	var drop []string
+
+	cp.lock.Lock()
+	for id, client := range cp.clients {
+		if !match(client) {
+			continue
+		}
+		if capacity, _ := cp.lookup(client.peer.ID()); capacity != client.capacity {
+			delete(cp.clients, id)
+			drop = append(drop, id)
+		}
+	}
+	cp.lock.Unlock()
+
+	for _, id := range drop {
+		log.Debug("Reconnecting light client with new capacity", "id", id)
+		cp.drop(id)
+	}
+}
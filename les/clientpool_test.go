@@ -0,0 +1,98 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/les/flowcontrol"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// newTestClientPool creates a client pool of the server with the given node ID,
+// granting priority to the clients proving the given token.
+func newTestClientPool(t *testing.T, local discover.NodeID, token string, capacity uint64) *clientPool {
+	params := &flowcontrol.ServerParams{BufLimit: 300000000, MinRecharge: 50000}
+	priority := []eth.LightPriorityClient{{Token: token, Capacity: capacity}}
+
+	pool, err := newClientPool(0, params, priority, func(string) {})
+	if err != nil {
+		t.Fatalf("failed to create client pool: %v", err)
+	}
+	pool.setLocal(local)
+	return pool
+}
+
+// newTestClient creates a light client peer presenting the given auth proof.
+func newTestClient(id discover.NodeID, proof []byte) *peer {
+	p := newPeer(lpv1, NetworkId, p2p.NewPeer(id, "client", nil), nil)
+	p.authProof = proof
+	return p
+}
+
+// Tests that a light client proving a priority token is admitted with its
+// priority capacity on its first connection.
+func TestClientPoolTokenFirstConnect(t *testing.T) {
+	local, client := discover.NodeID{1}, discover.NodeID{2}
+	pool := newTestClientPool(t, local, "secret", 4)
+
+	p := newTestClient(client, authProof("secret", local))
+	pool.authorize(p)
+
+	capacity, priority := pool.capacity(client)
+	if capacity != 4 || !priority {
+		t.Fatalf("capacity mismatch: have %d (priority %v), want 4 (priority true)", capacity, priority)
+	}
+	if err := pool.connect(p, capacity); err != nil {
+		t.Fatalf("priority client rejected: %v", err)
+	}
+	if info := pool.clients[p.id]; info == nil || !info.priority || info.capacity != 4 {
+		t.Fatalf("priority client not admitted: %+v", info)
+	}
+}
+
+// Tests that auth proofs are bound to the server they were made for, and that
+// they don't reveal the token.
+func TestClientPoolTokenProof(t *testing.T) {
+	local, other, client := discover.NodeID{1}, discover.NodeID{3}, discover.NodeID{2}
+	pool := newTestClientPool(t, local, "secret", 4)
+
+	// A proof made for another server is refused
+	proof := authProof("secret", other)
+	if bytes.Contains(proof, []byte("secret")) {
+		t.Fatalf("auth proof contains the token: %x", proof)
+	}
+	p := newTestClient(client, proof)
+	pool.authorize(p)
+
+	if capacity, priority := pool.capacity(client); capacity != 1 || priority {
+		t.Fatalf("capacity mismatch: have %d (priority %v), want 1 (priority false)", capacity, priority)
+	}
+	if err := pool.connect(p, 1); err != nil {
+		t.Fatalf("free client rejected: %v", err)
+	}
+	// A token added later only applies to clients proving it to this server
+	if err := pool.addToken("other", 2); err != nil {
+		t.Fatalf("failed to add token: %v", err)
+	}
+	if capacity, _ := pool.capacity(client); capacity != 1 {
+		t.Fatalf("capacity mismatch after adding unrelated token: have %d, want 1", capacity)
+	}
+}
@@ -40,13 +40,19 @@ type ClientNode struct {
 }
 
 func NewClientNode(cm *ClientManager, params *ServerParams) *ClientNode {
+	return NewWeightedClientNode(cm, params, 1)
+}
+
+// NewWeightedClientNode creates a client node whose share of the recharge
+// capacity of the client manager is proportional to the given weight.
+func NewWeightedClientNode(cm *ClientManager, params *ServerParams, weight uint64) *ClientNode {
 	node := &ClientNode{
 		cm:       cm,
 		params:   params,
 		bufValue: params.BufLimit,
 		lastTime: mclock.Now(),
 	}
-	node.cmNode = cm.addNode(node)
+	node.cmNode = cm.addNode(node, weight)
 	return node
 }
 
@@ -66,6 +72,16 @@ func (peer *ClientNode) recalcBV(time mclock.AbsTime) {
 	peer.lastTime = time
 }
 
+// BufferStatus returns the current buffer value of the client along with the
+// flow control parameters it was assigned.
+func (peer *ClientNode) BufferStatus() (uint64, ServerParams) {
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+
+	peer.recalcBV(mclock.Now())
+	return peer.bufValue, *peer.params
+}
+
 func (peer *ClientNode) AcceptRequest() (uint64, bool) {
 	peer.lock.Lock()
 	defer peer.lock.Unlock()
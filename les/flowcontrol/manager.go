@@ -98,13 +98,13 @@ func (self *ClientManager) Stop() {
 	close(self.resumeQueue)
 }
 
-func (self *ClientManager) addNode(cnode *ClientNode) *cmNode {
+func (self *ClientManager) addNode(cnode *ClientNode, weight uint64) *cmNode {
 	time := mclock.Now()
 	node := &cmNode{
 		node:           cnode,
 		lastUpdate:     time,
 		finishRecharge: time,
-		rcWeight:       1,
+		rcWeight:       weight,
 	}
 	self.lock.Lock()
 	defer self.lock.Unlock()
@@ -35,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
@@ -91,6 +92,7 @@ type txPool interface {
 
 type ProtocolManager struct {
 	lightSync   bool
+	authToken   string // Token proven to servers to claim a priority tier
 	txpool      txPool
 	txrelay     *LesTxRelay
 	networkId   uint64
@@ -257,6 +259,9 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	p.Log().Debug("Light Ethereum peer connected", "name", p.Name())
 
 	// Execute the LES handshake
+	if pm.lightSync {
+		p.authToken = pm.authToken
+	}
 	td, head, genesis := pm.blockchain.Status()
 	headNum := core.GetBlockNumber(pm.chainDb, head)
 	if err := p.Handshake(td, head, headNum, genesis, pm.server); err != nil {
@@ -266,6 +271,15 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	if rw, ok := p.rw.(*meteredMsgReadWriter); ok {
 		rw.Init(p.version)
 	}
+	// Admit light clients according to their service tier
+	if pm.server != nil && p.fcClient != nil {
+		if err := pm.server.clients.connect(p, p.capacity); err != nil {
+			p.fcClient.Remove(pm.server.fcManager)
+			p.Log().Debug("Light client rejected", "err", err)
+			return err
+		}
+		defer pm.server.clients.disconnect(p)
+	}
 	// Register the peer locally
 	if err := pm.peers.Register(p); err != nil {
 		p.Log().Error("Light Ethereum peer registration failed", "err", err)
@@ -281,7 +295,15 @@ func (pm *ProtocolManager) handle(p *peer) error {
 	if pm.lightSync {
 		p.lock.Lock()
 		head := p.headInfo
+		checkpoint := p.checkpoint
 		p.lock.Unlock()
+		if checkpoint != nil {
+			// Servers announcing checkpoints not signed by the configured signers
+			// are not necessarily malicious, they might just be on an old config
+			if _, err := light.WriteTrustedCheckpoint(pm.chainDb, pm.chainConfig.Checkpoint, checkpoint); err != nil {
+				p.Log().Debug("Rejected announced checkpoint", "section", checkpoint.Checkpoint.Section, "err", err)
+			}
+		}
 		if pm.fetcher != nil {
 			pm.fetcher.announce(p, head)
 		}
@@ -0,0 +1,211 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// This file contains some shares testing functionality, common to multiple
+// different files and modules being tested.
+
+package les
+
+import (
+	"crypto/rand"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/les/flowcontrol"
+	"github.com/ethereum/go-ethereum/light"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	testBankAddress = common.HexToAddress("0x0000000001a94f5374fce5edbc8e2a8697c15331677e6ebf0b")
+	testBankFunds   = new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ether))
+	testBankCoinage = "12.500000"
+	testBankLast    = uint64(1)
+
+	testMinerAddress = common.HexToAddress("0x00000000017e5f4552091a69125d5dfcb7b8c2659029395bdf")
+)
+
+const testBufLimit = 100000000
+
+// testChainGen mines the generated blocks to the test miner, changing its
+// balance with every block.
+func testChainGen(i int, block *core.BlockGen) {
+	block.SetCoinbase(testMinerAddress)
+}
+
+// newTestProtocolManager creates a new protocol manager for testing purposes,
+// with the given number of blocks already known, and potential notification
+// channels for different events. A server has the full chain generated, a
+// light client only the genesis and retrieves the rest on demand.
+func newTestProtocolManager(lightSync bool, blocks int, generator func(int, *core.BlockGen), peers *peerSet, odr *LesOdr, db ethdb.Database) (*ProtocolManager, error) {
+	var (
+		evmux  = new(event.TypeMux)
+		engine = ethash.NewFaker()
+		gspec  = core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBankAddress: {Balance: testBankFunds, Coinage: testBankCoinage, Last: testBankLast},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		chain   BlockChain
+	)
+	if peers == nil {
+		peers = newPeerSet()
+	}
+	if lightSync {
+		chain, _ = light.NewLightChain(odr, gspec.Config, engine, evmux)
+	} else {
+		blockchain, _ := core.NewBlockChain(db, gspec.Config, engine, evmux, vm.Config{})
+		gchain, _ := core.GenerateChain(gspec.Config, genesis, db, blocks, generator)
+		if _, err := blockchain.InsertChain(gchain); err != nil {
+			return nil, err
+		}
+		chain = blockchain
+	}
+	pm, err := NewProtocolManager(gspec.Config, lightSync, NetworkId, evmux, engine, peers, chain, nil, db, odr, nil, make(chan struct{}), new(sync.WaitGroup))
+	if err != nil {
+		return nil, err
+	}
+	if !lightSync {
+		srv := &LesServer{protocolManager: pm}
+		pm.server = srv
+
+		srv.defParams = &flowcontrol.ServerParams{
+			BufLimit:    testBufLimit,
+			MinRecharge: 1,
+		}
+		srv.fcManager = flowcontrol.NewClientManager(50, 10, 1000000000)
+		srv.fcCostStats = newCostStats(db)
+		if srv.clients, err = newClientPool(10, srv.defParams, nil, pm.removePeer); err != nil {
+			return nil, err
+		}
+	}
+	pm.Start()
+	return pm, nil
+}
+
+// newTestProtocolManagerMust creates a new protocol manager for testing purposes,
+// failing the test if it cannot be created.
+func newTestProtocolManagerMust(t *testing.T, lightSync bool, blocks int, generator func(int, *core.BlockGen), peers *peerSet, odr *LesOdr, db ethdb.Database) *ProtocolManager {
+	pm, err := newTestProtocolManager(lightSync, blocks, generator, peers, odr, db)
+	if err != nil {
+		t.Fatalf("Failed to create protocol manager: %v", err)
+	}
+	return pm
+}
+
+// newTestPeerPair connects two protocol managers through a message pipe,
+// returning the peers as seen by either side along with their exit errors.
+func newTestPeerPair(name string, version int, pm, pm2 *ProtocolManager) (*peer, <-chan error, *peer, <-chan error) {
+	// Create a message pipe to communicate through
+	app, net := p2p.MsgPipe()
+
+	// Generate a random id and create the peer
+	var id discover.NodeID
+	rand.Read(id[:])
+
+	peer := pm.newPeer(version, NetworkId, p2p.NewPeer(id, name, nil), net)
+	peer2 := pm2.newPeer(version, NetworkId, p2p.NewPeer(id, name, nil), app)
+
+	// Start the peer on a new thread
+	errc := make(chan error, 1)
+	errc2 := make(chan error, 1)
+	go func() {
+		select {
+		case pm.newPeerCh <- peer:
+			errc <- pm.handle(peer)
+		case <-pm.quitSync:
+			errc <- p2p.DiscQuitting
+		}
+	}()
+	go func() {
+		select {
+		case pm2.newPeerCh <- peer2:
+			errc2 <- pm2.handle(peer2)
+		case <-pm2.quitSync:
+			errc2 <- p2p.DiscQuitting
+		}
+	}()
+	return peer, errc, peer2, errc2
+}
+
+// testLightEnv is a server with a generated chain and a light client connected
+// to it, retrieving everything beyond the headers through ODR requests.
+type testLightEnv struct {
+	sdb, ldb ethdb.Database
+	pm, lpm  *ProtocolManager
+	odr      *LesOdr
+}
+
+// newTestLightEnv creates a server with the given number of blocks and a light
+// client synced to its head.
+func newTestLightEnv(t *testing.T, blocks int) *testLightEnv {
+	peers := newPeerSet()
+	dist := newRequestDistributor(peers, make(chan struct{}))
+	rm := newRetrieveManager(peers, dist, nil)
+	sdb, _ := ethdb.NewMemDatabase()
+	ldb, _ := ethdb.NewMemDatabase()
+	odr := NewLesOdr(ldb, rm)
+
+	pm := newTestProtocolManagerMust(t, false, blocks, testChainGen, nil, nil, sdb)
+	lpm := newTestProtocolManagerMust(t, true, 0, nil, peers, odr, ldb)
+	_, err1, lpeer, err2 := newTestPeerPair("peer", lpv1, pm, lpm)
+	select {
+	case <-time.After(100 * time.Millisecond):
+	case err := <-err1:
+		t.Fatalf("server handshake error: %v", err)
+	case err := <-err2:
+		t.Fatalf("client handshake error: %v", err)
+	}
+	// The total difficulties cached by the header chains are not derived from
+	// the headers, so neither the downloader nor the fetcher can follow the
+	// server. Feed the client the server's headers instead of syncing, and let
+	// it send its requests to the server which has the whole chain.
+	server := pm.blockchain.(*core.BlockChain)
+	headers := make([]*types.Header, 0, blocks)
+	for i := 1; i <= blocks; i++ {
+		headers = append(headers, server.GetHeaderByNumber(uint64(i)))
+	}
+	if _, err := lpm.blockchain.(*light.LightChain).InsertHeaderChain(headers, 1); err != nil {
+		t.Fatalf("failed to import headers: %v", err)
+	}
+	lpeer.lock.Lock()
+	lpeer.hasBlock = func(common.Hash, uint64) bool { return true }
+	lpeer.lock.Unlock()
+
+	if head := lpm.blockchain.CurrentHeader().Number.Uint64(); head != uint64(blocks) {
+		t.Fatalf("light client not synced: head %d, want %d", head, blocks)
+	}
+	return &testLightEnv{sdb: sdb, ldb: ldb, pm: pm, lpm: lpm, odr: odr}
+}
+
+// close tears down the light client and the server.
+func (env *testLightEnv) close() {
+	env.lpm.Stop()
+	env.pm.server.Stop()
+}
@@ -0,0 +1,141 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/light"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// newTestOfbankAPI creates the ofbank API of the light client, served by the
+// light backend from the client's chain and ODR retrievals.
+func newTestOfbankAPI(env *testLightEnv) *ethapi.PublicWaterAPI {
+	lightchain := env.lpm.blockchain.(*light.LightChain)
+	backend := &LesApiBackend{eth: &LightEthereum{
+		odr:         env.odr,
+		chainConfig: env.lpm.chainConfig,
+		blockchain:  lightchain,
+		chainDb:     env.ldb,
+	}}
+	return ethapi.NewPublicWaterAPI(backend, nil)
+}
+
+// Tests that the light client serves the ledger fields of accounts at any
+// block through the ofbank API, retrieving and verifying the state from the
+// server on demand.
+func TestOfbankAccountsLight(t *testing.T) {
+	env := newTestLightEnv(t, 4)
+	defer env.close()
+
+	api := newTestOfbankAPI(env)
+	server := env.pm.blockchain.(*core.BlockChain)
+
+	// The light client holds no state at all, everything comes through ODR
+	if _, err := env.ldb.Get(server.CurrentBlock().Root().Bytes()); err == nil {
+		t.Fatalf("light client holds the state of the head block before any request")
+	}
+	for number := uint64(0); number <= 4; number++ {
+		statedb, err := server.StateAt(server.GetBlockByNumber(number).Root())
+		if err != nil {
+			t.Fatalf("block %d: failed to open server state: %v", number, err)
+		}
+		for _, addr := range []common.Address{testBankAddress, testMinerAddress} {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			blockNr := rpc.BlockNumber(number)
+			if balance, err := api.Show(ctx, addr.Hex(), blockNr); err != nil {
+				t.Errorf("block %d, account %x: failed to retrieve balance: %v", number, addr, err)
+			} else if want := statedb.GetBalance(addr); balance != want && !(balance == "0.00" && !statedb.Exist(addr)) {
+				t.Errorf("block %d, account %x: balance mismatch: have %s, want %s", number, addr, balance, want)
+			}
+			if coinage, err := api.UpdCoinage(ctx, addr.Hex(), blockNr); err != nil {
+				t.Errorf("block %d, account %x: failed to retrieve coinage: %v", number, addr, err)
+			} else if want := statedb.GetCoinage(addr); statedb.Exist(addr) && coinage != want {
+				t.Errorf("block %d, account %x: coinage mismatch: have %s, want %s", number, addr, coinage, want)
+			}
+		}
+	}
+	// The miner's balance grows with every block, check the light client saw it
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	first, _ := api.Show(ctx, testMinerAddress.Hex(), 1)
+	head, _ := api.Show(ctx, testMinerAddress.Hex(), rpc.LatestBlockNumber)
+	if first == head {
+		t.Errorf("miner balance unchanged between block 1 and the head: %s", head)
+	}
+	// The genesis coinage accrual of the bank must be served as allocated
+	last, err := api.Last(ctx, testBankAddress.Hex(), 0)
+	if err != nil {
+		t.Fatalf("failed to retrieve last accrual block: %v", err)
+	}
+	if last != strconv.FormatUint(testBankLast, 10) {
+		t.Errorf("last accrual block mismatch: have %s, want %d", last, testBankLast)
+	}
+}
+
+// Tests that the balance proofs served by the light client verify against the
+// headers it follows, and that accounts missing from the state are reported.
+func TestOfbankBalanceProofLight(t *testing.T) {
+	env := newTestLightEnv(t, 4)
+	defer env.close()
+
+	api := newTestOfbankAPI(env)
+	lightchain := env.lpm.blockchain.(*light.LightChain)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, number := range []rpc.BlockNumber{0, 2, rpc.LatestBlockNumber} {
+		proof, err := api.ProveBalance(ctx, testBankAddress.Hex(), number)
+		if err != nil {
+			t.Fatalf("block %d: failed to prove balance: %v", number, err)
+		}
+		header := lightchain.GetHeaderByHash(proof.BlockHash)
+		if header == nil {
+			t.Fatalf("block %d: proof for unknown block %x", number, proof.BlockHash)
+		}
+		account, err := light.VerifyBalanceProof(header, proof)
+		if err != nil {
+			t.Fatalf("block %d: invalid balance proof: %v", number, err)
+		}
+		if account.Balance != proof.Balance || account.Coinage != testBankCoinage {
+			t.Errorf("block %d: proven account mismatch: have %s/%s, want %s/%s", number, account.Balance, account.Coinage, proof.Balance, testBankCoinage)
+		}
+		// Tampering with the proven fields must be detected
+		proof.Balance = "1000000.000000"
+		if _, err := light.VerifyBalanceProof(header, proof); err == nil {
+			t.Errorf("block %d: tampered balance proof accepted", number)
+		}
+	}
+	unknown := common.HexToAddress("0x00000000010000000000000000000000000000000000dead")
+	if _, err := api.ProveBalance(ctx, unknown.Hex(), rpc.LatestBlockNumber); err == nil {
+		t.Errorf("proof of a missing account succeeded")
+	}
+	if balance, err := api.Show(ctx, unknown.Hex(), rpc.LatestBlockNumber); err != nil || balance != "0.00" {
+		t.Errorf("missing account balance mismatch: have %s/%v, want 0.00", balance, err)
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/les/flowcontrol"
+	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -64,6 +65,11 @@ type peer struct {
 	fcServer       *flowcontrol.ServerNode // nil if the peer is client only
 	fcServerParams *flowcontrol.ServerParams
 	fcCosts        requestCostTable
+
+	checkpoint *light.SignedCheckpoint // Signed checkpoint announced by the server, if any
+	authToken  string                  // Token the light client claims a priority tier with
+	authProof  []byte                  // Proof of an auth token presented by a light client
+	capacity   uint64                  // Flow control capacity assigned to a light client
 }
 
 func newPeer(version int, network uint64, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
@@ -304,6 +310,18 @@ func (p *peer) sendReceiveHandshake(sendList keyValueList) (keyValueList, error)
 		errc <- p2p.Send(p.rw, StatusMsg, sendList)
 	}()
 	// In the mean time retrieve the remote status message
+	recvList, err := p.receiveHandshake()
+	if err != nil {
+		return nil, err
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	return recvList, nil
+}
+
+// receiveHandshake retrieves the remote status message.
+func (p *peer) receiveHandshake() (keyValueList, error) {
 	msg, err := p.rw.ReadMsg()
 	if err != nil {
 		return nil, err
@@ -319,9 +337,6 @@ func (p *peer) sendReceiveHandshake(sendList keyValueList) (keyValueList, error)
 	if err := msg.Decode(&recvList); err != nil {
 		return nil, errResp(ErrDecode, "msg %v: %v", msg, err)
 	}
-	if err := <-errc; err != nil {
-		return nil, err
-	}
 	return recvList, nil
 }
 
@@ -338,20 +353,34 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 	send = send.add("headHash", head)
 	send = send.add("headNum", headNum)
 	send = send.add("genesisHash", genesis)
-	if server != nil {
-		send = send.add("serveHeaders", nil)
-		send = send.add("serveChainSince", uint64(0))
-		send = send.add("serveStateSince", uint64(0))
-		send = send.add("txRelay", nil)
-		send = send.add("flowControl/BL", server.defParams.BufLimit)
-		send = send.add("flowControl/MRR", server.defParams.MinRecharge)
-		list := server.fcCostStats.getCurrentList()
-		send = send.add("flowControl/MRC", list)
-		p.fcCosts = list.decode()
-	}
-	recvList, err := p.sendReceiveHandshake(send)
-	if err != nil {
-		return err
+	if p.authToken != "" {
+		// Only a proof bound to the server is sent, which it can't present elsewhere
+		send = send.add("authProof", authProof(p.authToken, p.ID()))
+	}
+	var (
+		recvList keyValueList
+		err      error
+	)
+	if server != nil && p.Inbound() {
+		// Light clients dial the server, so read their handshake first for the
+		// flow control parameters to match the tier their auth proof grants
+		if recvList, err = p.receiveHandshake(); err != nil {
+			return err
+		}
+		p.authorize(server, recvList.decode())
+		if err := p2p.Send(p.rw, StatusMsg, p.serverStatus(send, server)); err != nil {
+			return err
+		}
+	} else {
+		if server != nil {
+			send = p.serverStatus(send, server)
+		}
+		if recvList, err = p.sendReceiveHandshake(send); err != nil {
+			return err
+		}
+		if server != nil {
+			p.authorize(server, recvList.decode())
+		}
 	}
 	recv := recvList.decode()
 
@@ -392,7 +421,7 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 		/*if recv.get("serveStateSince", nil) == nil {
 			return errResp(ErrUselessPeer, "wanted client, got server")
 		}*/
-		p.fcClient = flowcontrol.NewClientNode(server.fcManager, server.defParams)
+		p.fcClient = flowcontrol.NewWeightedClientNode(server.fcManager, server.clients.params(p.capacity), p.capacity)
 	} else {
 		if recv.get("serveChainSince", nil) != nil {
 			return errResp(ErrUselessPeer, "peer cannot serve chain")
@@ -417,12 +446,54 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 		p.fcServerParams = params
 		p.fcServer = flowcontrol.NewServerNode(params)
 		p.fcCosts = MRC.decode()
+
+		var checkpoint light.SignedCheckpoint
+		if recv.get("checkpoint", &checkpoint) == nil {
+			p.checkpoint = &checkpoint
+		}
 	}
 
 	p.headInfo = &announceData{Td: rTd, Hash: rHash, Number: rNum}
 	return nil
 }
 
+// serverStatus extends a handshake with the services and flow control parameters
+// the server offers the peer.
+func (p *peer) serverStatus(send keyValueList, server *LesServer) keyValueList {
+	send = send.add("serveHeaders", nil)
+	send = send.add("serveChainSince", uint64(0))
+	send = send.add("serveStateSince", uint64(0))
+	send = send.add("txRelay", nil)
+	p.capacity, _ = server.clients.capacity(p.ID())
+	params := server.clients.params(p.capacity)
+	send = send.add("flowControl/BL", params.BufLimit)
+	send = send.add("flowControl/MRR", params.MinRecharge)
+	list := server.fcCostStats.getCurrentList()
+	send = send.add("flowControl/MRC", list)
+	p.fcCosts = list.decode()
+	if checkpoint := light.ReadTrustedCheckpoint(server.protocolManager.chainDb); checkpoint != nil {
+		// Don't vouch for a checkpoint whose tries mismatch the ones built locally
+		local, err := LocalCheckpoint(server.protocolManager.chainDb, checkpoint.Checkpoint.Section)
+		switch {
+		case err != nil:
+			p.Log().Warn("Not announcing unverifiable checkpoint", "section", checkpoint.Checkpoint.Section, "err", err)
+		case *local == checkpoint.Checkpoint:
+			send = send.add("checkpoint", checkpoint)
+		}
+	}
+	return send
+}
+
+// authorize checks the auth proof presented by a light client, if any, granting
+// the client the priority tier of the token it proves.
+func (p *peer) authorize(server *LesServer, recv keyValueMap) {
+	if recv.get("authProof", &p.authProof) != nil {
+		p.authProof = nil
+		return
+	}
+	server.clients.authorize(p)
+}
+
 // String implements fmt.Stringer.
 func (p *peer) String() string {
 	return fmt.Sprintf("Peer %s [%s]", p.id,
@@ -19,11 +19,13 @@ package les
 
 import (
 	"encoding/binary"
+	"fmt"
 	"math"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/bitutil"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth"
@@ -34,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discv5"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/trie"
 )
 
@@ -42,11 +45,16 @@ type LesServer struct {
 	fcManager       *flowcontrol.ClientManager // nil if our node is client only
 	fcCostStats     *requestCostStats
 	defParams       *flowcontrol.ServerParams
+	clients         *clientPool
 	lesTopic        discv5.Topic
 	quitSync        chan struct{}
 }
 
 func NewLesServer(eth *eth.Ethereum, config *eth.Config) (*LesServer, error) {
+	// The CHT and bloom trie sections need the full history to be built
+	if base := core.GetHistoryBase(eth.ChainDb()); base > 0 {
+		return nil, fmt.Errorf("light server unavailable, chain history pruned below block %d", base)
+	}
 	quitSync := make(chan struct{})
 	pm, err := NewProtocolManager(eth.BlockChain().Config(), false, config.NetworkId, eth.EventMux(), eth.Engine(), newPeerSet(), eth.BlockChain(), eth.TxPool(), eth.ChainDb(), nil, nil, quitSync, new(sync.WaitGroup))
 	if err != nil {
@@ -66,16 +74,31 @@ func NewLesServer(eth *eth.Ethereum, config *eth.Config) (*LesServer, error) {
 		MinRecharge: 50000,
 	}
 	srv.fcManager = flowcontrol.NewClientManager(uint64(config.LightServ), 10, 1000000000)
+	if srv.clients, err = newClientPool(config.LightPeers, srv.defParams, config.LightPriorityClients, pm.removePeer); err != nil {
+		return nil, err
+	}
 	srv.fcCostStats = newCostStats(eth.ChainDb())
 	return srv, nil
 }
 
+// APIs returns the administrative APIs of the LES server.
+func (s *LesServer) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "admin",
+			Version:   "1.0",
+			Service:   NewPrivateLightServerAPI(s),
+		},
+	}
+}
+
 func (s *LesServer) Protocols() []p2p.Protocol {
 	return s.protocolManager.SubProtocols
 }
 
 // Start starts the LES server
 func (s *LesServer) Start(srvr *p2p.Server) {
+	s.clients.setLocal(srvr.Self().ID)
 	s.protocolManager.Start()
 	go func() {
 		logger := log.New("topic", s.lesTopic)
@@ -311,8 +334,17 @@ func (pm *ProtocolManager) blockLoop() {
 			case <-newCht:
 				go func() {
 					mu.Lock()
-					more := makeCht(pm.chainDb)
+					more, err := makeCht(pm.chainDb)
+					if err == nil {
+						var moreBlooms bool
+						moreBlooms, err = makeBloomTrie(pm.chainDb)
+						more = more || moreBlooms
+					}
 					mu.Unlock()
+					if err != nil {
+						log.Warn("Failed to generate light client tries", "err", err)
+						return
+					}
 					if more {
 						time.Sleep(time.Millisecond * 10)
 						newCht <- struct{}{}
@@ -328,8 +360,10 @@ func (pm *ProtocolManager) blockLoop() {
 }
 
 var (
-	lastChtKey = []byte("LastChtNumber") // chtNum (uint64 big endian)
-	chtPrefix  = []byte("cht")           // chtPrefix + chtNum (uint64 big endian) -> trie root hash
+	lastChtKey       = []byte("LastChtNumber")       // chtNum (uint64 big endian)
+	chtPrefix        = []byte("cht")                 // chtPrefix + chtNum (uint64 big endian) -> trie root hash
+	lastBloomTrieKey = []byte("LastBloomTrieNumber") // bloomTrieNum (uint64 big endian)
+	bloomTriePrefix  = []byte("bloomTrie")           // bloomTriePrefix + bloomTrieNum (uint64 big endian) -> trie root hash
 )
 
 func getChtRoot(db ethdb.Database, num uint64) common.Hash {
@@ -345,7 +379,24 @@ func storeChtRoot(db ethdb.Database, num uint64, root common.Hash) {
 	db.Put(append(chtPrefix, encNumber[:]...), root[:])
 }
 
-func makeCht(db ethdb.Database) bool {
+func getBloomTrieRoot(db ethdb.Database, num uint64) common.Hash {
+	var encNumber [8]byte
+	binary.BigEndian.PutUint64(encNumber[:], num)
+	data, _ := db.Get(append(bloomTriePrefix, encNumber[:]...))
+	return common.BytesToHash(data)
+}
+
+func storeBloomTrieRoot(db ethdb.Database, num uint64, root common.Hash) {
+	var encNumber [8]byte
+	binary.BigEndian.PutUint64(encNumber[:], num)
+	db.Put(append(bloomTriePrefix, encNumber[:]...), root[:])
+}
+
+// makeCht extends the canonical hash trie with the next section old enough to be
+// covered. It returns whether there are more sections left to process, or an
+// error if the canonical chain of the section is incomplete, in which case the
+// section is skipped until the chain data is available.
+func makeCht(db ethdb.Database) (bool, error) {
 	headHash := core.GetHeadBlockHash(db)
 	headNum := core.GetBlockNumber(db, headHash)
 
@@ -360,7 +411,7 @@ func makeCht(db ethdb.Database) bool {
 		lastChtNum = binary.BigEndian.Uint64(data[:])
 	}
 	if newChtNum <= lastChtNum {
-		return false
+		return false, nil
 	}
 
 	var t *trie.Trie
@@ -378,11 +429,11 @@ func makeCht(db ethdb.Database) bool {
 	for num := lastChtNum * light.ChtFrequency; num < (lastChtNum+1)*light.ChtFrequency; num++ {
 		hash := core.GetCanonicalHash(db, num)
 		if hash == (common.Hash{}) {
-			panic("Canonical hash not found")
+			return false, fmt.Errorf("canonical hash #%d not found for CHT section %d", num, lastChtNum)
 		}
 		td := core.GetTd(db, hash, num)
 		if td == nil {
-			panic("TD not found")
+			return false, fmt.Errorf("total difficulty #%d not found for CHT section %d", num, lastChtNum)
 		}
 		var encNumber [8]byte
 		binary.BigEndian.PutUint64(encNumber[:], num)
@@ -407,5 +458,124 @@ func makeCht(db ethdb.Database) bool {
 		db.Put(lastChtKey, data[:])
 	}
 
-	return newChtNum > lastChtNum
+	return newChtNum > lastChtNum, nil
+}
+
+// makeBloomTrie extends the bloom trie with the next section already covered by
+// the CHT. For every bit of the header bloom, the trie maps the bit index and the
+// section index (uint16 and uint64 big endian) to the compressed bit vector of
+// that bloom bit across the blocks of the section; all-zero vectors compress to
+// nothing and are left out of the trie. It returns whether there are more
+// sections left to process, or an error if a header of the section is missing,
+// in which case the section is skipped until the chain data is available.
+func makeBloomTrie(db ethdb.Database) (bool, error) {
+	var lastChtNum, lastBloomTrieNum uint64
+	if data, _ := db.Get(lastChtKey); len(data) == 8 {
+		lastChtNum = binary.BigEndian.Uint64(data)
+	}
+	if data, _ := db.Get(lastBloomTrieKey); len(data) == 8 {
+		lastBloomTrieNum = binary.BigEndian.Uint64(data)
+	}
+	if lastBloomTrieNum >= lastChtNum {
+		return false, nil
+	}
+
+	var t *trie.Trie
+	if lastBloomTrieNum > 0 {
+		var err error
+		t, err = trie.New(getBloomTrieRoot(db, lastBloomTrieNum), db)
+		if err != nil {
+			lastBloomTrieNum = 0
+		}
+	}
+	if lastBloomTrieNum == 0 {
+		t, _ = trie.New(common.Hash{}, db)
+	}
+
+	vectors := make([][]byte, types.BloomBitLength)
+	for bit := range vectors {
+		vectors[bit] = make([]byte, light.BloomTrieFrequency/8)
+	}
+	for i := uint64(0); i < light.BloomTrieFrequency; i++ {
+		num := lastBloomTrieNum*light.BloomTrieFrequency + i
+		header := core.GetHeader(db, core.GetCanonicalHash(db, num), num)
+		if header == nil {
+			return false, fmt.Errorf("canonical header #%d not found for bloom trie section %d", num, lastBloomTrieNum)
+		}
+		for bit := 0; bit < types.BloomBitLength; bit++ {
+			if header.Bloom[types.BloomByteLength-1-bit/8]&(1<<uint(bit%8)) != 0 {
+				vectors[bit][i/8] |= 0x80 >> (i % 8)
+			}
+		}
+	}
+	for bit := range vectors {
+		var key [10]byte
+		binary.BigEndian.PutUint16(key[0:2], uint16(bit))
+		binary.BigEndian.PutUint64(key[2:10], lastBloomTrieNum)
+		t.Update(key[:], bitutil.CompressBytes(vectors[bit]))
+	}
+
+	root, err := t.Commit()
+	if err != nil {
+		lastBloomTrieNum = 0
+	} else {
+		lastBloomTrieNum++
+
+		log.Trace("Generated bloom trie", "number", lastBloomTrieNum, "root", root.Hex())
+
+		storeBloomTrieRoot(db, lastBloomTrieNum, root)
+		var data [8]byte
+		binary.BigEndian.PutUint64(data[:], lastBloomTrieNum)
+		db.Put(lastBloomTrieKey, data[:])
+	}
+
+	return lastChtNum > lastBloomTrieNum, nil
+}
+
+// GenerateTries builds the canonical hash tries and bloom tries for all the
+// sections of the local chain that are old enough but not yet processed.
+func GenerateTries(db ethdb.Database) error {
+	for {
+		more, err := makeCht(db)
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+	}
+	for {
+		more, err := makeBloomTrie(db)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// LocalCheckpoint assembles the checkpoint of the given section from the tries
+// generated from the local chain. Section zero selects the latest one available.
+func LocalCheckpoint(db ethdb.Database, section uint64) (*light.Checkpoint, error) {
+	var lastBloomTrieNum uint64
+	if data, _ := db.Get(lastBloomTrieKey); len(data) == 8 {
+		lastBloomTrieNum = binary.BigEndian.Uint64(data)
+	}
+	if section == 0 {
+		section = lastBloomTrieNum
+	}
+	if section == 0 || section > lastBloomTrieNum {
+		return nil, fmt.Errorf("section %d not available, have %d", section, lastBloomTrieNum)
+	}
+	checkpoint := &light.Checkpoint{
+		Section:     section,
+		SectionHead: core.GetCanonicalHash(db, section*light.ChtFrequency-1),
+		ChtRoot:     getChtRoot(db, section),
+		BloomRoot:   getBloomTrieRoot(db, section),
+	}
+	if checkpoint.ChtRoot == (common.Hash{}) || checkpoint.BloomRoot == (common.Hash{}) {
+		return nil, fmt.Errorf("tries of section %d not found", section)
+	}
+	return checkpoint, nil
 }
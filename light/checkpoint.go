@@ -0,0 +1,218 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var (
+	// BloomTrieFrequency is the number of blocks in a section of the bloom trie,
+	// equal to the CHT section size so a checkpoint covers both tries.
+	BloomTrieFrequency = ChtFrequency
+
+	errNoCheckpointSigners   = errors.New("no checkpoint signers configured")
+	errInsufficientSignature = errors.New("not enough valid checkpoint signatures")
+	errEmptyCheckpointRoot   = errors.New("checkpoint without CHT or bloom trie root")
+
+	trustedCheckpointKey = []byte("TrustedCheckpoint")
+	trustedBloomTrieKey  = []byte("TrustedBloomTrie")
+
+	// checkpointLock serializes the adoption of checkpoints, so a stale one
+	// never overwrites a newer checkpoint accepted concurrently.
+	checkpointLock sync.Mutex
+)
+
+// Checkpoint is a commitment to the canonical chain up to the end of a section,
+// consisting of the roots of the canonical hash trie and of the bloom trie built
+// over all the sections up to and including it.
+type Checkpoint struct {
+	Section     uint64      `json:"sectionIndex"`  // Number of sections covered
+	SectionHead common.Hash `json:"sectionHead"`   // Hash of the last block of the last section
+	ChtRoot     common.Hash `json:"chtRoot"`       // Root of the canonical hash trie
+	BloomRoot   common.Hash `json:"bloomTrieRoot"` // Root of the bloom trie
+}
+
+// Hash returns the hash of the checkpoint, which is what the signers sign.
+func (c *Checkpoint) Hash() common.Hash {
+	blob, _ := rlp.EncodeToBytes(c)
+	return crypto.Keccak256Hash(blob)
+}
+
+// LastBlock returns the number of the last block covered by the checkpoint.
+func (c *Checkpoint) LastBlock() uint64 {
+	return c.Section*ChtFrequency - 1
+}
+
+// SignedCheckpoint is a checkpoint together with the signatures collected from
+// the configured checkpoint signers.
+type SignedCheckpoint struct {
+	Checkpoint Checkpoint      `json:"checkpoint"`
+	Signatures []hexutil.Bytes `json:"signatures"`
+}
+
+// Sign signs the checkpoint with the given key and adds the signature to the
+// set collected so far.
+func (s *SignedCheckpoint) Sign(key *ecdsa.PrivateKey) error {
+	sig, err := crypto.Sign(s.Checkpoint.Hash().Bytes(), key)
+	if err != nil {
+		return err
+	}
+	return s.AddSignature(sig)
+}
+
+// AddSignature adds a signature to the checkpoint, ignoring duplicates.
+func (s *SignedCheckpoint) AddSignature(sig []byte) error {
+	if len(sig) != 65 {
+		return fmt.Errorf("invalid signature length %d", len(sig))
+	}
+	for _, known := range s.Signatures {
+		if bytes.Equal(known, sig) {
+			return nil
+		}
+	}
+	s.Signatures = append(s.Signatures, common.CopyBytes(sig))
+	return nil
+}
+
+// Signers returns the configured signers that produced a valid signature over
+// the checkpoint, each counted once.
+func (s *SignedCheckpoint) Signers(config *params.CheckpointConfig) []common.Address {
+	if config == nil {
+		return nil
+	}
+	hash := s.Checkpoint.Hash()
+	seen := make(map[common.Address]bool)
+
+	var signers []common.Address
+	for _, sig := range s.Signatures {
+		pubkey, err := crypto.Ecrecover(hash[:], sig)
+		if err != nil {
+			continue
+		}
+		// Signer addresses carry a country code prefix in front of the usual
+		// public key hash, so match on the hash part only
+		keyHash := crypto.Keccak256(pubkey[1:])[12:]
+		for _, signer := range config.Signers {
+			if !seen[signer] && bytes.Equal(signer[common.AddressLength-len(keyHash):], keyHash) {
+				seen[signer] = true
+				signers = append(signers, signer)
+				break
+			}
+		}
+	}
+	return signers
+}
+
+// Verify checks that the checkpoint commits to both tries and was signed by at
+// least the required number of configured signers. The signatures cover the
+// bloom trie root as well, so a root altered in transit invalidates them.
+func (s *SignedCheckpoint) Verify(config *params.CheckpointConfig) error {
+	if s.Checkpoint.ChtRoot == (common.Hash{}) || s.Checkpoint.BloomRoot == (common.Hash{}) {
+		return errEmptyCheckpointRoot
+	}
+	if config == nil || len(config.Signers) == 0 {
+		return errNoCheckpointSigners
+	}
+	threshold := config.Threshold
+	if threshold == 0 {
+		threshold = 1
+	}
+	if signers := s.Signers(config); uint64(len(signers)) < threshold {
+		return fmt.Errorf("%v: have %d, want %d", errInsufficientSignature, len(signers), threshold)
+	}
+	return nil
+}
+
+// TrustedBloomTrie is the bloom trie root of the latest trusted checkpoint,
+// together with the number of sections it covers.
+type TrustedBloomTrie struct {
+	Number uint64
+	Root   common.Hash
+}
+
+// GetTrustedBloomTrie retrieves the trusted bloom trie, or an empty one if no
+// checkpoint was adopted yet.
+func GetTrustedBloomTrie(db ethdb.Database) TrustedBloomTrie {
+	data, _ := db.Get(trustedBloomTrieKey)
+	var res TrustedBloomTrie
+	if err := rlp.DecodeBytes(data, &res); err != nil {
+		return TrustedBloomTrie{}
+	}
+	return res
+}
+
+// WriteTrustedBloomTrie stores the trusted bloom trie.
+func WriteTrustedBloomTrie(db ethdb.Database, bloomTrie TrustedBloomTrie) {
+	data, _ := rlp.EncodeToBytes(bloomTrie)
+	db.Put(trustedBloomTrieKey, data)
+}
+
+// ReadTrustedCheckpoint retrieves the signed checkpoint the local chain trusts,
+// or nil if there's none.
+func ReadTrustedCheckpoint(db ethdb.Database) *SignedCheckpoint {
+	data, _ := db.Get(trustedCheckpointKey)
+	if len(data) == 0 {
+		return nil
+	}
+	checkpoint := new(SignedCheckpoint)
+	if err := rlp.DecodeBytes(data, checkpoint); err != nil {
+		log.Error("Invalid trusted checkpoint RLP", "err", err)
+		return nil
+	}
+	return checkpoint
+}
+
+// WriteTrustedCheckpoint verifies a signed checkpoint against the configured
+// signers and, if it's newer than the trusted CHT, stores it and makes its CHT
+// and bloom trie the trusted ones, allowing the light chain to sync from the end of the
+// checkpoint's last section. It reports whether the checkpoint was adopted.
+func WriteTrustedCheckpoint(db ethdb.Database, config *params.CheckpointConfig, checkpoint *SignedCheckpoint) (bool, error) {
+	if err := checkpoint.Verify(config); err != nil {
+		return false, err
+	}
+	checkpointLock.Lock()
+	defer checkpointLock.Unlock()
+
+	if checkpoint.Checkpoint.Section <= GetTrustedCht(db).Number {
+		return false, nil
+	}
+	data, err := rlp.EncodeToBytes(checkpoint)
+	if err != nil {
+		return false, err
+	}
+	if err := db.Put(trustedCheckpointKey, data); err != nil {
+		return false, err
+	}
+	WriteTrustedCht(db, TrustedCht{Number: checkpoint.Checkpoint.Section, Root: checkpoint.Checkpoint.ChtRoot})
+	WriteTrustedBloomTrie(db, TrustedBloomTrie{Number: checkpoint.Checkpoint.Section, Root: checkpoint.Checkpoint.BloomRoot})
+
+	log.Info("Adopted trusted checkpoint", "section", checkpoint.Checkpoint.Section, "head", checkpoint.Checkpoint.SectionHead, "cht", checkpoint.Checkpoint.ChtRoot, "bloom", checkpoint.Checkpoint.BloomRoot)
+	return true, nil
+}
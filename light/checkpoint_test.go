@@ -0,0 +1,74 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the bloom trie root of a checkpoint is covered by its signatures,
+// and that adopting a checkpoint makes its bloom trie the trusted one.
+func TestCheckpointBloomRoot(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var signer common.Address
+	copy(signer[common.AddressLength-20:], crypto.Keccak256(crypto.FromECDSAPub(&key.PublicKey)[1:])[12:])
+	config := &params.CheckpointConfig{Signers: []common.Address{signer}, Threshold: 1}
+
+	signed := &SignedCheckpoint{Checkpoint: Checkpoint{
+		Section:     2,
+		SectionHead: common.HexToHash("0x01"),
+		ChtRoot:     common.HexToHash("0x02"),
+		BloomRoot:   common.HexToHash("0x03"),
+	}}
+	if err := signed.Sign(key); err != nil {
+		t.Fatalf("failed to sign checkpoint: %v", err)
+	}
+	if err := signed.Verify(config); err != nil {
+		t.Fatalf("signed checkpoint rejected: %v", err)
+	}
+	// A bloom trie root altered after signing invalidates the signatures
+	tampered := *signed
+	tampered.Checkpoint.BloomRoot = common.HexToHash("0x04")
+	if err := tampered.Verify(config); err == nil {
+		t.Fatalf("checkpoint with altered bloom trie root accepted")
+	}
+	// A checkpoint signed without a bloom trie root is rejected
+	empty := &SignedCheckpoint{Checkpoint: signed.Checkpoint}
+	empty.Checkpoint.BloomRoot = common.Hash{}
+	if err := empty.Sign(key); err != nil {
+		t.Fatalf("failed to sign checkpoint: %v", err)
+	}
+	if err := empty.Verify(config); err != errEmptyCheckpointRoot {
+		t.Fatalf("error mismatch: have %v, want %v", err, errEmptyCheckpointRoot)
+	}
+	// Adopting the checkpoint trusts both of its tries
+	db, _ := ethdb.NewMemDatabase()
+	if adopted, err := WriteTrustedCheckpoint(db, config, signed); !adopted || err != nil {
+		t.Fatalf("checkpoint not adopted: %v", err)
+	}
+	if cht := GetTrustedCht(db); cht.Number != 2 || cht.Root != signed.Checkpoint.ChtRoot {
+		t.Errorf("trusted CHT mismatch: have %+v", cht)
+	}
+	if bloomTrie := GetTrustedBloomTrie(db); bloomTrie.Number != 2 || bloomTrie.Root != signed.Checkpoint.BloomRoot {
+		t.Errorf("trusted bloom trie mismatch: have %+v", bloomTrie)
+	}
+}
@@ -446,6 +446,13 @@ func (self *LightChain) SyncCht(ctx context.Context) bool {
 		num := cht.Number*ChtFrequency - 1
 		header, err := GetHeaderByNumber(ctx, self.odr, num)
 		if header != nil && err == nil {
+			// The CHT of a signed checkpoint also commits to its section head
+			if checkpoint := ReadTrustedCheckpoint(self.chainDb); checkpoint != nil && checkpoint.Checkpoint.Section == cht.Number {
+				if hash := header.Hash(); hash != checkpoint.Checkpoint.SectionHead {
+					log.Warn("Section head mismatches trusted checkpoint", "number", num, "hash", hash, "want", checkpoint.Checkpoint.SectionHead)
+					return false
+				}
+			}
 			self.mu.Lock()
 			if self.hc.CurrentHeader().Number.Uint64() < header.Number.Uint64() {
 				self.hc.SetCurrentHeader(header)
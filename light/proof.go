@@ -0,0 +1,112 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package light
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// BalanceProof is a self-contained proof of the ledger fields of an account at
+// a given block. It can be checked offline against the header of the block,
+// without trusting the node that produced it.
+type BalanceProof struct {
+	Address     common.Address  `json:"address"`
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+	BlockHash   common.Hash     `json:"blockHash"`
+	StateRoot   common.Hash     `json:"stateRoot"`
+	Nonce       hexutil.Uint64  `json:"nonce"`
+	Balance     string          `json:"balance"`
+	Coinage     string          `json:"coinage"`
+	LastCBN     string          `json:"last"`
+	Proof       []hexutil.Bytes `json:"proof"`
+}
+
+// VerifyAccountProof checks a Merkle proof of the account at the given address
+// against a state root, returning the proven account. A nil account without an
+// error means that the proof shows the absence of the account.
+func VerifyAccountProof(root common.Hash, address common.Address, proof []rlp.RawValue) (*state.Account, error) {
+	blob, err := verifyProof(root, crypto.Keccak256(address[:]), proof)
+	if err != nil || blob == nil {
+		return nil, err
+	}
+	account := new(state.Account)
+	if err := rlp.DecodeBytes(blob, account); err != nil {
+		return nil, fmt.Errorf("invalid account %x: %v", address, err)
+	}
+	return account, nil
+}
+
+// VerifyStorageProof checks a Merkle proof of a storage slot against the storage
+// root of an account, returning the proven value (zero if the slot is unset).
+func VerifyStorageProof(root common.Hash, key common.Hash, proof []rlp.RawValue) (common.Hash, error) {
+	blob, err := verifyProof(root, crypto.Keccak256(key[:]), proof)
+	if err != nil || blob == nil {
+		return common.Hash{}, err
+	}
+	_, content, _, err := rlp.Split(blob)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid storage slot %x: %v", key, err)
+	}
+	return common.BytesToHash(content), nil
+}
+
+// VerifyBalanceProof checks a balance proof bundle against the header of the
+// block it claims to be taken at, returning the proven account.
+func VerifyBalanceProof(header *types.Header, bundle *BalanceProof) (*state.Account, error) {
+	if hash := header.Hash(); hash != bundle.BlockHash {
+		return nil, fmt.Errorf("block hash mismatch: have %x, want %x", bundle.BlockHash, hash)
+	}
+	if header.Number.Uint64() != uint64(bundle.BlockNumber) {
+		return nil, fmt.Errorf("block number mismatch: have %d, want %d", bundle.BlockNumber, header.Number)
+	}
+	if header.Root != bundle.StateRoot {
+		return nil, fmt.Errorf("state root mismatch: have %x, want %x", bundle.StateRoot, header.Root)
+	}
+	proof := make([]rlp.RawValue, len(bundle.Proof))
+	for i, node := range bundle.Proof {
+		proof[i] = rlp.RawValue(node)
+	}
+	account, err := VerifyAccountProof(header.Root, bundle.Address, proof)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, fmt.Errorf("account %x doesn't exist", bundle.Address)
+	}
+	if account.Nonce != uint64(bundle.Nonce) || account.Balance != bundle.Balance || account.Coinage != bundle.Coinage || account.LastCBN != bundle.LastCBN {
+		return nil, errors.New("account fields don't match the proof")
+	}
+	return account, nil
+}
+
+// verifyProof checks a proof of a hashed key, treating an empty proof of an
+// empty trie as a proof of absence.
+func verifyProof(root common.Hash, key []byte, proof []rlp.RawValue) ([]byte, error) {
+	if len(proof) == 0 && root == types.EmptyRootHash {
+		return nil, nil
+	}
+	return trie.VerifyProof(root, key, proof)
+}
@@ -24,6 +24,8 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
 )
 
@@ -135,6 +137,25 @@ func (t *odrTrie) GetKey(sha []byte) []byte {
 	return nil
 }
 
+// Prove retrieves the path to key from the network if necessary and constructs
+// a merkle proof for it out of the locally cached nodes.
+func (t *odrTrie) Prove(key []byte) []rlp.RawValue {
+	key = crypto.Keccak256(key)
+	var proof []rlp.RawValue
+	err := t.do(key, func() error {
+		if _, err := t.trie.TryGet(key); err != nil {
+			return err
+		}
+		proof = t.trie.Prove(key)
+		return nil
+	})
+	if err != nil {
+		log.Error("Failed to construct proof", "key", key, "err", err)
+		return nil
+	}
+	return proof
+}
+
 // do tries and retries to execute a function until it returns with no error or
 // an error type other than MissingNodeError
 func (t *odrTrie) do(key []byte, fn func() error) error {
@@ -6,8 +6,6 @@ import (
 	"fmt"
 	"sync"
 	"time"
-	"math/big"		// Water Cherry
-	"strconv"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
@@ -338,9 +336,7 @@ func (pool *TxPool) validateTx(ctx context.Context, tx *types.Transaction) error
 	// Transactor should have enough funds to cover the costs
 	// cost == V + GP * GL
 
-	b, _ := strconv.ParseInt(currentState.GetBalance(from), 10, 64)
-	
-	if big.NewInt(b).Cmp(tx.Cost()) < 0 {
+	if core.BalanceToWei(currentState.GetBalance(from)).Cmp(tx.Cost()) < 0 {
 		return core.ErrInsufficientFunds
 	}
 
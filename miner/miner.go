@@ -149,6 +149,12 @@ func (self *Miner) SetExtra(extra []byte) error {
 	return nil
 }
 
+// SetOrdering changes the order transactions are included in the blocks being
+// mined.
+func (self *Miner) SetOrdering(config OrderingConfig) error {
+	return self.worker.setOrdering(config)
+}
+
 // Pending returns the currently pending block and associated state.
 func (self *Miner) Pending() (*types.Block, *state.StateDB) {
 	return self.worker.pending()
@@ -0,0 +1,401 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+	gometrics "github.com/rcrowley/go-metrics"
+)
+
+// Names of the transaction ordering policies.
+const (
+	OrderByPrice    = "price"    // Highest gas price first, the stock Ethereum ordering
+	OrderByArrival  = "fifo"     // First seen transaction first
+	OrderByPriceAge = "priceage" // Highest gas price first, the first seen one among equal prices
+)
+
+// TransactionSet is a set of pending transactions handing them out one by one in
+// the order they should be included in a block, honouring the nonces of each
+// account. It is implemented by types.TransactionsByPriceAndNonce.
+type TransactionSet interface {
+	// Peek returns the next transaction to include, nil if the set is exhausted.
+	Peek() *types.Transaction
+
+	// Shift replaces the current transaction with the next one of the same account.
+	Shift()
+
+	// Pop removes the current transaction, dropping the rest of the account too.
+	Pop()
+}
+
+// OrderingPolicy decides the order the pending transactions are included in the
+// blocks being mined.
+type OrderingPolicy interface {
+	// Name returns the name the policy is selected and reported by.
+	Name() string
+
+	// Order assembles the pending transactions, grouped by account and sorted by
+	// nonce, into a set handing them out in inclusion order. The input map is
+	// reowned by the set. The arrival function reports when a transaction was
+	// first seen locally.
+	Order(signer types.Signer, pending map[common.Address]types.Transactions, arrival func(*types.Transaction) time.Time) TransactionSet
+}
+
+// NewOrderingPolicy returns the transaction ordering policy of the given name.
+func NewOrderingPolicy(name string) (OrderingPolicy, error) {
+	switch name {
+	case "", OrderByPrice:
+		return pricePolicy{}, nil
+	case OrderByArrival:
+		return arrivalPolicy{}, nil
+	case OrderByPriceAge:
+		return priceAgePolicy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown transaction ordering policy %q", name)
+	}
+}
+
+// OrderingConfig is the configuration of the transaction ordering of the blocks
+// being mined.
+type OrderingConfig struct {
+	Policy string         `toml:",omitempty"` // Name of the ordering policy (empty = price)
+	Lanes  []PriorityLane `toml:",omitempty"` // Priority lanes included ahead of all other transactions
+}
+
+// PriorityLane is a group of transactions included in a block ahead of all the
+// others, with some block gas kept for them alone. Transactions of a lane are
+// ordered by the same policy as the rest.
+type PriorityLane struct {
+	Name        string           // Name of the lane, used in logs and metrics
+	Senders     []string         `toml:",omitempty"` // Hex prefixes of the sender addresses served by the lane
+	Recipients  []common.Address `toml:",omitempty"` // System contracts whose transactions the lane serves
+	ReservedGas uint64           `toml:",omitempty"` // Gas of each block held back from transactions outside the lanes
+}
+
+// maxArrivalAge is the time after which the arrival of a transaction not yet
+// included in a block is forgotten.
+var maxArrivalAge = core.DefaultTxPoolConfig.Lifetime
+
+// txOrderer sorts the pending transactions into the priority lanes and orders
+// them by the configured policy, tracking how long transactions wait for their
+// inclusion.
+type txOrderer struct {
+	policy   OrderingPolicy
+	lanes    []PriorityLane
+	prefixes [][]string // Lower case hex sender prefixes of each lane
+	reserved uint64     // Total gas reserved for the lanes
+
+	arrivals map[common.Hash]time.Time // Time each pending transaction was first seen
+	lock     sync.Mutex                // Protects the arrivals
+
+	latency     gometrics.Timer   // Inclusion latency of all transactions
+	laneLatency []gometrics.Timer // Inclusion latency of the transactions of each lane
+}
+
+// newTxOrderer creates a transaction orderer from the given config.
+func newTxOrderer(config OrderingConfig) (*txOrderer, error) {
+	policy, err := NewOrderingPolicy(config.Policy)
+	if err != nil {
+		return nil, err
+	}
+	o := &txOrderer{
+		policy:   policy,
+		lanes:    config.Lanes,
+		arrivals: make(map[common.Hash]time.Time),
+		latency:  metrics.NewTimer("miner/inclusion/" + policy.Name()),
+	}
+	for i, lane := range config.Lanes {
+		if lane.Name == "" {
+			return nil, fmt.Errorf("priority lane #%d has no name", i)
+		}
+		prefixes := make([]string, len(lane.Senders))
+		for j, prefix := range lane.Senders {
+			prefix = strings.ToLower(strings.TrimPrefix(prefix, "0x"))
+			if _, err := hex.DecodeString(prefix + strings.Repeat("0", len(prefix)%2)); err != nil || len(prefix) > 2*common.AddressLength {
+				return nil, fmt.Errorf("priority lane %s: invalid sender prefix %q", lane.Name, lane.Senders[j])
+			}
+			prefixes[j] = prefix
+		}
+		o.prefixes = append(o.prefixes, prefixes)
+		o.reserved += lane.ReservedGas
+		o.laneLatency = append(o.laneLatency, metrics.NewTimer("miner/inclusion/"+policy.Name()+"/"+lane.Name))
+	}
+	return o, nil
+}
+
+// arrived records the time a transaction was first seen.
+func (o *txOrderer) arrived(tx *types.Transaction) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if _, ok := o.arrivals[tx.Hash()]; !ok {
+		o.arrivals[tx.Hash()] = time.Now()
+	}
+}
+
+// arrival returns the time a transaction was first seen, or the zero time if it
+// was pending before the orderer started tracking.
+func (o *txOrderer) arrival(tx *types.Transaction) time.Time {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.arrivals[tx.Hash()]
+}
+
+// included forgets the transactions of a block, reporting their inclusion
+// latency if the block was mined locally. Arrivals of transactions pending for
+// too long are dropped too.
+func (o *txOrderer) included(signer types.Signer, block *types.Block, local bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	for _, tx := range block.Transactions() {
+		arrival, ok := o.arrivals[tx.Hash()]
+		if !ok {
+			continue
+		}
+		delete(o.arrivals, tx.Hash())
+		if !local {
+			continue
+		}
+		o.latency.UpdateSince(arrival)
+
+		from, _ := types.Sender(signer, tx)
+		if lane := o.lane(from, tx); lane >= 0 {
+			o.laneLatency[lane].UpdateSince(arrival)
+		}
+	}
+	for hash, arrival := range o.arrivals {
+		if time.Since(arrival) > maxArrivalAge {
+			delete(o.arrivals, hash)
+		}
+	}
+}
+
+// lane returns the index of the priority lane serving a transaction, -1 if none.
+func (o *txOrderer) lane(from common.Address, tx *types.Transaction) int {
+	sender := hex.EncodeToString(from[:])
+	for i, prefixes := range o.prefixes {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(sender, prefix) {
+				return i
+			}
+		}
+	}
+	if to := tx.To(); to != nil {
+		for i, lane := range o.lanes {
+			for _, recipient := range lane.Recipients {
+				if recipient == *to {
+					return i
+				}
+			}
+		}
+	}
+	return -1
+}
+
+// split sorts the pending transactions into the priority lanes and the rest.
+// Accounts served by a lane through their sender go there as a whole, others
+// only with the leading transactions calling the system contracts of a lane,
+// keeping the nonces in order across the lanes.
+func (o *txOrderer) split(signer types.Signer, pending map[common.Address]types.Transactions) ([]map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
+	if len(o.lanes) == 0 {
+		return nil, pending
+	}
+	lanes := make([]map[common.Address]types.Transactions, len(o.lanes))
+	for i := range lanes {
+		lanes[i] = make(map[common.Address]types.Transactions)
+	}
+	rest := make(map[common.Address]types.Transactions)
+	for from, txs := range pending {
+		lane := o.lane(from, txs[0])
+		if lane < 0 {
+			rest[from] = txs
+			continue
+		}
+		n := 1
+		for n < len(txs) && o.lane(from, txs[n]) == lane {
+			n++
+		}
+		lanes[lane][from] = txs[:n]
+		if n < len(txs) {
+			rest[from] = txs[n:]
+		}
+	}
+	return lanes, rest
+}
+
+// order assembles a group of pending transactions into a set handing them out
+// in the order of the policy.
+func (o *txOrderer) order(signer types.Signer, pending map[common.Address]types.Transactions) TransactionSet {
+	return o.policy.Order(signer, pending, o.arrival)
+}
+
+// restGas returns the gas transactions outside the priority lanes may use in a
+// block, given the gas already used by the lanes.
+func (o *txOrderer) restGas(limit, used *big.Int) *big.Int {
+	gas := new(big.Int).Sub(limit, used)
+	if held := new(big.Int).Sub(new(big.Int).SetUint64(o.reserved), used); held.Sign() > 0 {
+		gas.Sub(gas, held)
+	}
+	if gas.Sign() < 0 {
+		gas.SetInt64(0)
+	}
+	return gas
+}
+
+// pricePolicy orders transactions by gas price, the stock Ethereum ordering.
+type pricePolicy struct{}
+
+func (pricePolicy) Name() string { return OrderByPrice }
+
+func (pricePolicy) Order(signer types.Signer, pending map[common.Address]types.Transactions, arrival func(*types.Transaction) time.Time) TransactionSet {
+	return types.NewTransactionsByPriceAndNonce(pending)
+}
+
+// arrivalPolicy orders transactions by the time they were first seen.
+type arrivalPolicy struct{}
+
+func (arrivalPolicy) Name() string { return OrderByArrival }
+
+func (arrivalPolicy) Order(signer types.Signer, pending map[common.Address]types.Transactions, arrival func(*types.Transaction) time.Time) TransactionSet {
+	return newSortedTransactions(signer, pending, func(a, b *types.Transaction) int {
+		if cmp := compareArrival(arrival(a), arrival(b)); cmp != 0 {
+			return cmp
+		}
+		return b.GasPrice().Cmp(a.GasPrice())
+	})
+}
+
+// priceAgePolicy orders transactions by gas price, and the ones with the same
+// price by the time they were first seen.
+type priceAgePolicy struct{}
+
+func (priceAgePolicy) Name() string { return OrderByPriceAge }
+
+func (priceAgePolicy) Order(signer types.Signer, pending map[common.Address]types.Transactions, arrival func(*types.Transaction) time.Time) TransactionSet {
+	return newSortedTransactions(signer, pending, func(a, b *types.Transaction) int {
+		if cmp := b.GasPrice().Cmp(a.GasPrice()); cmp != 0 {
+			return cmp
+		}
+		return compareArrival(arrival(a), arrival(b))
+	})
+}
+
+// compareArrival orders two arrival times, earliest first.
+func compareArrival(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case b.Before(a):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// sortedTransactions is a transaction set handing out the heads of the accounts
+// in the order of a comparison function, breaking ties by transaction hash to
+// stay deterministic.
+type sortedTransactions struct {
+	signer types.Signer
+	txs    map[common.Address]types.Transactions // Per account nonce-sorted list of transactions
+	heads  *txHeads                              // Next transaction of each account, in inclusion order
+}
+
+// newSortedTransactions creates a transaction set ordered by the given function,
+// which returns a negative number if a should be included before b.
+func newSortedTransactions(signer types.Signer, pending map[common.Address]types.Transactions, cmp func(a, b *types.Transaction) int) *sortedTransactions {
+	heads := &txHeads{cmp: cmp}
+	for from, txs := range pending {
+		if len(txs) == 0 {
+			delete(pending, from)
+			continue
+		}
+		heads.txs = append(heads.txs, txs[0])
+		pending[from] = txs[1:]
+	}
+	heap.Init(heads)
+
+	return &sortedTransactions{
+		signer: signer,
+		txs:    pending,
+		heads:  heads,
+	}
+}
+
+func (s *sortedTransactions) Peek() *types.Transaction {
+	if len(s.heads.txs) == 0 {
+		return nil
+	}
+	return s.heads.txs[0]
+}
+
+func (s *sortedTransactions) Shift() {
+	from, _ := types.Sender(s.signer, s.heads.txs[0])
+	if txs := s.txs[from]; len(txs) > 0 {
+		s.heads.txs[0], s.txs[from] = txs[0], txs[1:]
+		heap.Fix(s.heads, 0)
+	} else {
+		heap.Pop(s.heads)
+	}
+}
+
+func (s *sortedTransactions) Pop() {
+	heap.Pop(s.heads)
+}
+
+// txHeads is a heap of transactions ordered by a comparison function.
+type txHeads struct {
+	txs []*types.Transaction
+	cmp func(a, b *types.Transaction) int
+}
+
+func (h *txHeads) Len() int      { return len(h.txs) }
+func (h *txHeads) Swap(i, j int) { h.txs[i], h.txs[j] = h.txs[j], h.txs[i] }
+
+func (h *txHeads) Less(i, j int) bool {
+	if cmp := h.cmp(h.txs[i], h.txs[j]); cmp != 0 {
+		return cmp < 0
+	}
+	a, b := h.txs[i].Hash(), h.txs[j].Hash()
+	return bytes.Compare(a[:], b[:]) < 0
+}
+
+func (h *txHeads) Push(x interface{}) {
+	h.txs = append(h.txs, x.(*types.Transaction))
+}
+
+func (h *txHeads) Pop() interface{} {
+	old := h.txs
+	n := len(old)
+	x := old[n-1]
+	h.txs = old[:n-1]
+	return x
+}
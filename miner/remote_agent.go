@@ -27,9 +27,12 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 )
 
+var errNoWork = errors.New("No work available yet, don't panic.")
+
 type hashrate struct {
 	ping time.Time
 	rate uint64
@@ -50,6 +53,8 @@ type RemoteAgent struct {
 	hashrateMu sync.RWMutex
 	hashrate   map[common.Hash]hashrate
 
+	workFeed event.Feed // Feed announcing every new work package to external servers
+
 	running int32 // running indicates whether the agent is active. Call atomically
 }
 
@@ -94,6 +99,12 @@ func (a *RemoteAgent) Stop() {
 	close(a.workCh)
 }
 
+// SubscribeWork registers a subscription for the blocks of the new work packages
+// handed to the agent.
+func (a *RemoteAgent) SubscribeWork(ch chan<- *types.Block) event.Subscription {
+	return a.workFeed.Subscribe(ch)
+}
+
 // GetHashRate returns the accumulated hashrate of all identifier combined
 func (a *RemoteAgent) GetHashRate() (tot int64) {
 	a.hashrateMu.RLock()
@@ -128,7 +139,39 @@ func (a *RemoteAgent) GetWork() ([3]string, error) {
 		a.work[block.HashNoNonce()] = a.currentWork
 		return res, nil
 	}
-	return res, errors.New("No work available yet, don't panic.")
+	return res, errNoWork
+}
+
+// workWithAgents retrieves the current work package, splitting the reward of
+// its block among the given miner agents, and tracks it for later submission.
+// If no agents are given, the ones of the work package are kept.
+func (a *RemoteAgent) workWithAgents(agents []types.MinerAgents) (*types.Block, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.currentWork == nil {
+		return nil, errNoWork
+	}
+	work := *a.currentWork
+	if len(agents) > 0 {
+		// The agents are paid when the block is finalized, so finalize it anew
+		// from the state before the rewards
+		if work.unrewarded == nil {
+			return nil, errNoWork
+		}
+		header := work.Block.Header()
+		header.MinerAgents = agents
+
+		work.state = work.unrewarded.Copy()
+		block, err := a.engine.Finalize(a.chain, header, work.state, work.txs, work.Block.Uncles(), work.receipts)
+		if err != nil {
+			return nil, err
+		}
+		work.header, work.Block = header, block
+	}
+
+	a.work[work.Block.HashNoNonce()] = &work
+	return work.Block, nil
 }
 
 // SubmitWork tries to inject a pow solution into the remote agent, returning
@@ -180,6 +223,10 @@ func (a *RemoteAgent) loop(workCh chan *Work, quitCh chan struct{}) {
 			a.mu.Lock()
 			a.currentWork = work
 			a.mu.Unlock()
+
+			if work != nil {
+				a.workFeed.Send(work.Block)
+			}
 		case <-ticker.C:
 			// cleanup
 			a.mu.Lock()
@@ -0,0 +1,695 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+const (
+	stratumVersion = "EthereumStratum/1.0.0" // Protocol version announced to the workers
+
+	stratumMaxLine    = 4096             // Maximum length of a request line
+	stratumMaxJobs    = 8                // Number of recent jobs accepting late shares
+	stratumMaxWorkers = 1024             // Maximum number of distinct worker logins tracked
+	stratumMaxAgents  = 11               // Maximum number of miner agents a block is split among
+	stratumReport     = 5 * time.Second  // Interval of reporting the worker hashrates to the agent
+	stratumIdle       = 10 * time.Minute // Time after which silent connections are dropped
+)
+
+var (
+	// stratumBaseDifficulty is the number of hashes a share of difficulty one
+	// stands for in EthereumStratum.
+	stratumBaseDifficulty = new(big.Int).Lsh(common.Big1, 32)
+
+	// stratumMaxTarget is the target of a share of difficulty one hash.
+	stratumMaxTarget = new(big.Int).Lsh(common.Big1, 256)
+)
+
+// errStratumNoWorkers is returned if the stratum server is started without any
+// worker logins configured.
+var errStratumNoWorkers = errors.New("stratum server requires worker logins")
+
+// Errors returned to the workers, following the codes of the stratum protocols.
+var (
+	errStratumOther        = &stratumError{20, "Other/Unknown"}
+	errStratumStale        = &stratumError{21, "Job not found (=stale)"}
+	errStratumDuplicate    = &stratumError{22, "Duplicate share"}
+	errStratumLowDiff      = &stratumError{23, "Low difficulty share"}
+	errStratumUnauthorized = &stratumError{24, "Unauthorized worker"}
+	errStratumUnsubscribed = &stratumError{25, "Not subscribed"}
+)
+
+// StratumConfig are the configuration parameters of the stratum mining server.
+type StratumConfig struct {
+	Addr        string            `toml:",omitempty"` // Listening address of the server (empty = disabled)
+	Difficulty  uint64            // Difficulty of the shares the workers submit, in hashes
+	Workers     map[string]string `toml:",omitempty"` // Passwords of the worker logins allowed in (required)
+	ShareWindow int               // Number of recent shares the block reward is split by
+}
+
+// DefaultStratumConfig contains the default configurations for the stratum
+// mining server.
+var DefaultStratumConfig = StratumConfig{
+	Difficulty:  1 << 32,
+	ShareWindow: 1000,
+}
+
+// StratumWorker is the accounting of a worker logged into the stratum server.
+type StratumWorker struct {
+	Address   common.Address `json:"address"`   // Account the shares of the worker are credited to
+	Shares    uint64         `json:"shares"`    // Number of accepted shares
+	Stale     uint64         `json:"stale"`     // Number of shares submitted for outdated jobs
+	Invalid   uint64         `json:"invalid"`   // Number of shares not meeting the difficulty
+	Blocks    uint64         `json:"blocks"`    // Number of shares sealing a block
+	Hashrate  float64        `json:"hashrate"`  // Hashes per second estimated from the accepted shares
+	LastShare time.Time      `json:"lastShare"` // Time of the last accepted share
+
+	meter metrics.Meter // Meter of the hashes represented by the accepted shares
+}
+
+// stratumShare is an accepted share, counted in the reward split of later blocks.
+type stratumShare struct {
+	address    common.Address
+	difficulty *big.Int
+}
+
+// stratumJob is a work package handed out to the workers.
+type stratumJob struct {
+	id     string
+	block  *types.Block
+	hash   common.Hash // Sealing hash of the block
+	seed   common.Hash // Seed hash of the ethash epoch of the block
+	target *big.Int    // Proof-of-work value a block seal must stay under
+
+	difficulty  *big.Int // Difficulty of the shares of the job
+	shareTarget *big.Int // Proof-of-work value a share must stay under
+
+	nonces map[uint64]struct{} // Nonces already submitted for the job
+}
+
+// stratumError is an error reported to a worker.
+type stratumError struct {
+	code    int
+	message string
+}
+
+func (e *stratumError) Error() string { return e.message }
+
+// MarshalJSON encodes the error as the [code, message, traceback] triplet of
+// the stratum protocols.
+func (e *stratumError) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.code, e.message, nil})
+}
+
+// stratumRequest is a request sent by a worker.
+type stratumRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// stratumResponse is the answer to a worker request.
+type stratumResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  *stratumError   `json:"error"`
+}
+
+// stratumNotification is a message pushed to a worker.
+type stratumNotification struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// StratumServer is a mining pool server speaking EthereumStratum/1.0 over TCP.
+// It hands out the work packages of a remote agent, pushing new ones to the
+// workers on every chain head, and splits the reward of the blocks among the
+// accounts of the workers in proportion to the shares they recently submitted.
+type StratumServer struct {
+	config StratumConfig
+	agent  *RemoteAgent
+	pow    *ethash.Ethash
+	start  func() error // Callback to start mining once a worker logs in
+
+	listener net.Listener
+	sessions map[*stratumSession]struct{}
+	session  uint32 // Counter of the sessions, used as extranonce
+
+	job    *stratumJob            // Most recent job
+	jobs   map[string]*stratumJob // Recent jobs still accepting shares
+	order  []string               // Identifiers of the recent jobs, oldest first
+	nextID uint64                 // Counter of the jobs, used as identifier
+
+	workers map[string]*StratumWorker // Accounting of the workers, keyed by login
+	shares  []stratumShare            // Recent accepted shares, oldest first
+
+	lock sync.Mutex
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewStratumServer creates a stratum server handing out the work of the given
+// remote agent, sealed with the given ethash engine.
+func NewStratumServer(config StratumConfig, agent *RemoteAgent, pow *ethash.Ethash, start func() error) *StratumServer {
+	if config.Difficulty == 0 {
+		config.Difficulty = DefaultStratumConfig.Difficulty
+	}
+	if config.ShareWindow <= 0 {
+		config.ShareWindow = DefaultStratumConfig.ShareWindow
+	}
+	return &StratumServer{
+		config:   config,
+		agent:    agent,
+		pow:      pow,
+		start:    start,
+		sessions: make(map[*stratumSession]struct{}),
+		jobs:     make(map[string]*stratumJob),
+		workers:  make(map[string]*StratumWorker),
+	}
+}
+
+// Start opens the listener of the server and starts serving workers. Only the
+// configured worker logins are let in, so the server refuses to start without.
+func (s *StratumServer) Start() error {
+	if len(s.config.Workers) == 0 {
+		return errStratumNoWorkers
+	}
+	listener, err := net.Listen("tcp", s.config.Addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	s.quit = make(chan struct{})
+
+	s.wg.Add(2)
+	go s.acceptLoop()
+	go s.workLoop()
+
+	log.Info("Stratum server started", "addr", listener.Addr(), "difficulty", s.config.Difficulty)
+	return nil
+}
+
+// Stop closes the listener and disconnects all workers.
+func (s *StratumServer) Stop() {
+	if s.listener == nil {
+		return
+	}
+	close(s.quit)
+	s.listener.Close()
+
+	s.lock.Lock()
+	for session := range s.sessions {
+		session.conn.Close()
+	}
+	s.lock.Unlock()
+
+	s.wg.Wait()
+	log.Info("Stratum server stopped")
+}
+
+// Addr returns the address the server is listening on.
+func (s *StratumServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Workers returns the accounting of the workers that logged in.
+func (s *StratumServer) Workers() map[string]*StratumWorker {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	workers := make(map[string]*StratumWorker, len(s.workers))
+	for login, worker := range s.workers {
+		cpy := *worker
+		cpy.Hashrate = worker.meter.Rate1()
+		cpy.meter = nil
+		workers[login] = &cpy
+	}
+	return workers
+}
+
+// acceptLoop accepts the connections of the workers until the server stops.
+func (s *StratumServer) acceptLoop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.quit:
+				return
+			default:
+			}
+			log.Debug("Stratum accept failed", "err", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		s.lock.Lock()
+		s.session++
+		session := &stratumSession{
+			server:     s,
+			conn:       conn,
+			enc:        json.NewEncoder(conn),
+			extranonce: fmt.Sprintf("%04x", uint16(s.session)),
+			logins:     make(map[string]bool),
+		}
+		s.sessions[session] = struct{}{}
+		s.lock.Unlock()
+
+		s.wg.Add(1)
+		go session.handle()
+	}
+}
+
+// workLoop turns the work packages of the agent into jobs for the workers, and
+// reports the hashrates of the workers to the agent.
+func (s *StratumServer) workLoop() {
+	defer s.wg.Done()
+
+	blocks := make(chan *types.Block, 4)
+	sub := s.agent.SubscribeWork(blocks)
+	defer sub.Unsubscribe()
+
+	report := time.NewTicker(stratumReport)
+	defer report.Stop()
+
+	for {
+		select {
+		case <-blocks:
+			s.newJob()
+
+		case <-report.C:
+			s.lock.Lock()
+			for login, worker := range s.workers {
+				s.agent.SubmitHashrate(crypto.Keccak256Hash([]byte(login)), uint64(worker.meter.Rate1()))
+			}
+			s.lock.Unlock()
+
+		case <-sub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// newJob creates a job out of the current work package of the agent, splitting
+// its reward by the recent shares, and pushes it to the workers.
+func (s *StratumServer) newJob() {
+	block, err := s.agent.workWithAgents(s.minerAgents())
+	if err != nil {
+		return
+	}
+	difficulty := new(big.Int).SetUint64(s.config.Difficulty)
+	if difficulty.Cmp(block.Difficulty()) > 0 {
+		difficulty.Set(block.Difficulty())
+	}
+	s.lock.Lock()
+	s.nextID++
+	job := &stratumJob{
+		id:          fmt.Sprintf("%x", s.nextID),
+		block:       block,
+		hash:        block.HashNoNonce(),
+		seed:        common.BytesToHash(ethash.SeedHash(block.NumberU64())),
+		target:      new(big.Int).Div(stratumMaxTarget, block.Difficulty()),
+		difficulty:  difficulty,
+		shareTarget: new(big.Int).Div(stratumMaxTarget, difficulty),
+		nonces:      make(map[uint64]struct{}),
+	}
+	clean := s.job == nil || s.job.block.ParentHash() != block.ParentHash()
+	s.job = job
+
+	s.jobs[job.id] = job
+	s.order = append(s.order, job.id)
+	if len(s.order) > stratumMaxJobs {
+		delete(s.jobs, s.order[0])
+		s.order = s.order[1:]
+	}
+	sessions := make([]*stratumSession, 0, len(s.sessions))
+	for session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.lock.Unlock()
+
+	for _, session := range sessions {
+		session.notify(job, clean)
+	}
+}
+
+// minerAgents splits the block reward among the accounts of the workers, in
+// proportion to the difficulty of the shares they recently submitted. Only the
+// largest contributors fit into a block. The split is paid out by the chain from
+// the ethash miner agents fork on, before it the coinbase gets the full reward.
+func (s *StratumServer) minerAgents() []types.MinerAgents {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	contributions := make(map[common.Address]*big.Int)
+	for _, share := range s.shares {
+		if contributions[share.address] == nil {
+			contributions[share.address] = new(big.Int)
+		}
+		contributions[share.address].Add(contributions[share.address], share.difficulty)
+	}
+	if len(contributions) == 0 {
+		return nil
+	}
+	addresses := make([]common.Address, 0, len(contributions))
+	for address := range contributions {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		if cmp := contributions[addresses[i]].Cmp(contributions[addresses[j]]); cmp != 0 {
+			return cmp > 0
+		}
+		return addresses[i].Hex() < addresses[j].Hex()
+	})
+	if len(addresses) > stratumMaxAgents {
+		addresses = addresses[:stratumMaxAgents]
+	}
+	total := new(big.Int)
+	for _, address := range addresses {
+		total.Add(total, contributions[address])
+	}
+	agents := make([]types.MinerAgents, len(addresses))
+	for i, address := range addresses {
+		share, _ := new(big.Rat).SetFrac(new(big.Int).Mul(contributions[address], big.NewInt(100)), total).Float64()
+		agents[i] = types.MinerAgents{
+			Minerbase:  address,
+			Percentage: fmt.Sprintf("%f%%", share),
+		}
+	}
+	return agents
+}
+
+// authorize checks the credentials of a worker login, returning the account
+// its shares are credited to.
+func (s *StratumServer) authorize(login, password string) (*StratumWorker, error) {
+	// Logins are of the form <address>[.<worker name>]
+	account := login
+	if dot := strings.IndexByte(login, '.'); dot >= 0 {
+		account = login[:dot]
+	}
+	if !common.IsHexAddress(account) {
+		return nil, errStratumUnauthorized
+	}
+	expected, ok := s.config.Workers[login]
+	if !ok || subtle.ConstantTimeCompare([]byte(expected), []byte(password)) != 1 {
+		return nil, errStratumUnauthorized
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	worker := s.workers[login]
+	if worker == nil {
+		if len(s.workers) >= stratumMaxWorkers {
+			return nil, errStratumUnauthorized
+		}
+		worker = &StratumWorker{
+			Address: common.HexToAddress(account),
+			meter:   metrics.NewMeter(),
+		}
+		s.workers[login] = worker
+	}
+	return worker, nil
+}
+
+// submit checks a share submitted by a worker, crediting it to the worker and
+// handing it to the agent if it seals a block.
+func (s *StratumServer) submit(login string, worker *StratumWorker, id string, nonce uint64) error {
+	s.lock.Lock()
+	job := s.jobs[id]
+	if job == nil {
+		worker.Stale++
+		s.lock.Unlock()
+		return errStratumStale
+	}
+	if _, ok := job.nonces[nonce]; ok {
+		s.lock.Unlock()
+		return errStratumDuplicate
+	}
+	job.nonces[nonce] = struct{}{}
+	s.lock.Unlock()
+
+	digest, result := s.pow.Hashimoto(job.block.NumberU64(), job.hash, nonce)
+
+	s.lock.Lock()
+	if result.Cmp(job.shareTarget) > 0 {
+		worker.Invalid++
+		s.lock.Unlock()
+		return errStratumLowDiff
+	}
+	worker.Shares++
+	worker.LastShare = time.Now()
+	worker.meter.Mark(job.difficulty.Int64())
+
+	s.shares = append(s.shares, stratumShare{worker.Address, job.difficulty})
+	if len(s.shares) > s.config.ShareWindow {
+		s.shares = append(s.shares[:0], s.shares[len(s.shares)-s.config.ShareWindow:]...)
+	}
+	s.lock.Unlock()
+
+	if result.Cmp(job.target) <= 0 {
+		if s.agent.SubmitWork(types.EncodeNonce(nonce), digest, job.hash) {
+			s.lock.Lock()
+			worker.Blocks++
+			s.lock.Unlock()
+
+			log.Info("Stratum worker sealed block", "worker", login, "number", job.block.Number(), "hash", job.hash)
+		}
+	}
+	return nil
+}
+
+// stratumSession is a connection of a worker to the stratum server.
+type stratumSession struct {
+	server     *StratumServer
+	conn       net.Conn
+	enc        *json.Encoder
+	extranonce string // Hex encoded nonce prefix of the session
+
+	subscribed bool
+	logins     map[string]bool // Worker logins authorized on the connection
+	difficulty *big.Int        // Share difficulty last reported to the worker
+
+	lock sync.Mutex // Protects the fields above and the writes to the connection
+}
+
+// handle serves the requests of the worker until it disconnects.
+func (ss *stratumSession) handle() {
+	s := ss.server
+	defer s.wg.Done()
+	defer func() {
+		s.lock.Lock()
+		delete(s.sessions, ss)
+		s.lock.Unlock()
+		ss.conn.Close()
+	}()
+	log.Debug("Stratum worker connected", "addr", ss.conn.RemoteAddr())
+
+	scanner := bufio.NewScanner(ss.conn)
+	scanner.Buffer(make([]byte, stratumMaxLine), stratumMaxLine)
+	for {
+		ss.conn.SetReadDeadline(time.Now().Add(stratumIdle))
+		if !scanner.Scan() {
+			log.Debug("Stratum worker disconnected", "addr", ss.conn.RemoteAddr(), "err", scanner.Err())
+			return
+		}
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		req := new(stratumRequest)
+		if err := json.Unmarshal(line, req); err != nil {
+			log.Debug("Invalid stratum request", "addr", ss.conn.RemoteAddr(), "err", err)
+			return
+		}
+		result, err := ss.dispatch(req)
+
+		resp := &stratumResponse{ID: req.ID, Result: result}
+		if err != nil {
+			resp.Result = nil
+			if resp.Error, _ = err.(*stratumError); resp.Error == nil {
+				resp.Error = errStratumOther
+			}
+		}
+		if err := ss.send(resp); err != nil {
+			return
+		}
+		// Hand the current job to freshly authorized workers
+		if req.Method == "mining.authorize" && err == nil {
+			s.lock.Lock()
+			job := s.job
+			s.lock.Unlock()
+
+			if job != nil {
+				ss.notify(job, true)
+			}
+		}
+	}
+}
+
+// dispatch executes a worker request, returning its result.
+func (ss *stratumSession) dispatch(req *stratumRequest) (interface{}, error) {
+	var params []string
+	if len(req.Params) > 0 {
+		var raw []interface{}
+		if err := json.Unmarshal(req.Params, &raw); err != nil {
+			return nil, errStratumOther
+		}
+		for _, param := range raw {
+			str, _ := param.(string)
+			params = append(params, str)
+		}
+	}
+	switch req.Method {
+	case "mining.subscribe":
+		ss.lock.Lock()
+		ss.subscribed = true
+		ss.lock.Unlock()
+
+		return []interface{}{
+			[]string{"mining.notify", ss.extranonce, stratumVersion},
+			ss.extranonce,
+		}, nil
+
+	case "mining.extranonce.subscribe":
+		return true, nil
+
+	case "mining.authorize":
+		ss.lock.Lock()
+		subscribed := ss.subscribed
+		ss.lock.Unlock()
+
+		if !subscribed {
+			return nil, errStratumUnsubscribed
+		}
+		if len(params) < 1 {
+			return nil, errStratumUnauthorized
+		}
+		password := ""
+		if len(params) > 1 {
+			password = params[1]
+		}
+		if _, err := ss.server.authorize(params[0], password); err != nil {
+			log.Debug("Stratum worker refused", "addr", ss.conn.RemoteAddr(), "login", params[0])
+			return nil, err
+		}
+		ss.lock.Lock()
+		ss.logins[params[0]] = true
+		ss.lock.Unlock()
+
+		if ss.server.start != nil {
+			if err := ss.server.start(); err != nil {
+				log.Warn("Failed to start mining for stratum workers", "err", err)
+			}
+		}
+		log.Info("Stratum worker logged in", "addr", ss.conn.RemoteAddr(), "login", params[0])
+		return true, nil
+
+	case "mining.submit":
+		if len(params) < 3 {
+			return nil, errStratumOther
+		}
+		ss.lock.Lock()
+		authorized := ss.logins[params[0]]
+		ss.lock.Unlock()
+
+		if !authorized {
+			return nil, errStratumUnauthorized
+		}
+		ss.server.lock.Lock()
+		worker := ss.server.workers[params[0]]
+		ss.server.lock.Unlock()
+
+		nonce, err := ss.nonce(params[2])
+		if err != nil {
+			return nil, errStratumOther
+		}
+		if err := ss.server.submit(params[0], worker, params[1], nonce); err != nil {
+			return nil, err
+		}
+		return true, nil
+
+	default:
+		return nil, errStratumOther
+	}
+}
+
+// nonce assembles the full nonce of a share out of the session's extranonce and
+// the part found by the worker.
+func (ss *stratumSession) nonce(suffix string) (uint64, error) {
+	suffix = strings.TrimPrefix(suffix, "0x")
+	if len(ss.extranonce)+len(suffix) != 16 {
+		return 0, errors.New("invalid nonce length")
+	}
+	return strconv.ParseUint(ss.extranonce+suffix, 16, 64)
+}
+
+// notify pushes a job to the worker, announcing the share difficulty first if
+// it changed.
+func (ss *stratumSession) notify(job *stratumJob, clean bool) {
+	ss.lock.Lock()
+	authorized := len(ss.logins) > 0
+	reported := ss.difficulty
+	ss.lock.Unlock()
+
+	if !authorized {
+		return
+	}
+	if reported == nil || reported.Cmp(job.difficulty) != 0 {
+		difficulty, _ := new(big.Rat).SetFrac(job.difficulty, stratumBaseDifficulty).Float64()
+		if err := ss.send(&stratumNotification{Method: "mining.set_difficulty", Params: []interface{}{difficulty}}); err != nil {
+			return
+		}
+		ss.lock.Lock()
+		ss.difficulty = job.difficulty
+		ss.lock.Unlock()
+	}
+	ss.send(&stratumNotification{
+		Method: "mining.notify",
+		Params: []interface{}{job.id, strings.TrimPrefix(job.seed.Hex(), "0x"), strings.TrimPrefix(job.hash.Hex(), "0x"), clean},
+	})
+}
+
+// send writes a message to the worker.
+func (ss *stratumSession) send(msg interface{}) error {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	ss.conn.SetWriteDeadline(time.Now().Add(stratumReport))
+	return ss.enc.Encode(msg)
+}
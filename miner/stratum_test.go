@@ -0,0 +1,394 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// stratumMessage is any message received by a stratum worker, either a response
+// or a notification.
+type stratumMessage struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Result json.RawMessage   `json:"result"`
+	Error  json.RawMessage   `json:"error"`
+}
+
+// testStratumMiner is an in-process stratum worker driving a server over TCP.
+type testStratumMiner struct {
+	t     *testing.T
+	conn  net.Conn
+	dec   *json.Decoder
+	id    int
+	notes []*stratumMessage // Notifications received while waiting for responses
+}
+
+func newTestStratumMiner(t *testing.T, addr net.Addr) *testStratumMiner {
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatalf("failed to connect to stratum server: %v", err)
+	}
+	return &testStratumMiner{t: t, conn: conn, dec: json.NewDecoder(conn)}
+}
+
+// read waits for the next message of the server.
+func (m *testStratumMiner) read() *stratumMessage {
+	m.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	msg := new(stratumMessage)
+	if err := m.dec.Decode(msg); err != nil {
+		m.t.Fatalf("failed to read stratum message: %v", err)
+	}
+	return msg
+}
+
+// call sends a request and waits for its response, stashing any notification
+// received in between.
+func (m *testStratumMiner) call(method string, params ...interface{}) *stratumMessage {
+	m.id++
+	req, _ := json.Marshal(map[string]interface{}{"id": m.id, "method": method, "params": params})
+	if _, err := m.conn.Write(append(req, '\n')); err != nil {
+		m.t.Fatalf("failed to send %s: %v", method, err)
+	}
+	for {
+		msg := m.read()
+		if msg.Method == "" && string(msg.ID) == fmt.Sprint(m.id) {
+			return msg
+		}
+		m.notes = append(m.notes, msg)
+	}
+}
+
+// job waits for the next job pushed by the server, returning its identifier
+// and sealing hash.
+func (m *testStratumMiner) job() (string, common.Hash) {
+	for {
+		var msg *stratumMessage
+		if len(m.notes) > 0 {
+			msg, m.notes = m.notes[0], m.notes[1:]
+		} else {
+			msg = m.read()
+		}
+		if msg.Method != "mining.notify" {
+			continue
+		}
+		var id, hash string
+		if len(msg.Params) < 3 || json.Unmarshal(msg.Params[0], &id) != nil || json.Unmarshal(msg.Params[2], &hash) != nil {
+			m.t.Fatalf("malformed job notification: %v", msg.Params)
+		}
+		return id, common.HexToHash(hash)
+	}
+}
+
+// testStratumConfig is a chain configuration sharing the block reward among the
+// miner agents, with every block at the minimum difficulty of one so that any
+// share seals a block.
+var testStratumConfig = &params.ChainConfig{
+	ChainId:        big.NewInt(1),
+	HomesteadBlock: big.NewInt(0),
+	EIP150Block:    big.NewInt(0),
+	EIP155Block:    big.NewInt(0),
+	EIP158Block:    big.NewInt(0),
+	Ethash: &params.EthashConfig{
+		AgentsBlock: big.NewInt(0),
+		Difficulty: &params.DifficultyConfig{
+			Block:             big.NewInt(0),
+			Algorithm:         params.DifficultyFixed,
+			MinimumDifficulty: big.NewInt(1),
+		},
+	},
+}
+
+// newTestStratumChain creates a chain with only the genesis block of the test
+// configuration.
+func newTestStratumChain(t *testing.T, engine consensus.Engine) (*core.BlockChain, ethdb.Database) {
+	db, _ := ethdb.NewMemDatabase()
+	genesis := &core.Genesis{Config: testStratumConfig, Difficulty: big.NewInt(1), GasLimit: params.GenesisGasLimit.Uint64()}
+	genesis.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, testStratumConfig, engine, new(event.TypeMux), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	return chain, db
+}
+
+// testStratumWork hands a work package on top of the genesis state to the
+// agent and waits until the server turned it into a job. The work is repeated
+// as the server may not be listening to the agent yet.
+func testStratumWork(t *testing.T, chain *core.BlockChain, agent *RemoteAgent, server *StratumServer, header *types.Header) *types.Block {
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to open genesis state: %v", err)
+	}
+	block := types.NewBlockWithHeader(header)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		agent.Work() <- &Work{Block: block, state: statedb.Copy(), unrewarded: statedb.Copy(), header: header, createdAt: time.Now()}
+
+		for i := 0; i < 10; i++ {
+			server.lock.Lock()
+			job := server.job
+			server.lock.Unlock()
+
+			if job != nil && job.block.ParentHash() == block.ParentHash() {
+				return job.block
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	t.Fatalf("work for block %d not turned into a job", header.Number)
+	return nil
+}
+
+// Tests that a miner logs in and submits shares to the stratum server end to
+// end, and that the block reward is split among the workers by their shares.
+func TestStratumMining(t *testing.T) {
+	address := common.HexToAddress("0x00000000010102030405060708090a0b0c0d0e0f1011121314")
+	login := address.Hex() + ".rig"
+
+	pow := ethash.NewTester()
+	chain, _ := newTestStratumChain(t, pow)
+	agent := NewRemoteAgent(chain, pow)
+	results := make(chan *Result, 1)
+	agent.SetReturnCh(results)
+	agent.Start()
+	defer agent.Stop()
+
+	// A server without worker logins must refuse to start
+	if err := NewStratumServer(StratumConfig{Addr: "127.0.0.1:0"}, agent, pow, nil).Start(); err != errStratumNoWorkers {
+		t.Fatalf("server without logins: error mismatch: have %v, want %v", err, errStratumNoWorkers)
+	}
+	server := NewStratumServer(StratumConfig{
+		Addr:       "127.0.0.1:0",
+		Difficulty: 1,
+		Workers:    map[string]string{login: "secret"},
+	}, agent, pow, nil)
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start stratum server: %v", err)
+	}
+	defer server.Stop()
+
+	// Hand out a job too hard to seal a block with a single share
+	testStratumWork(t, chain, agent, server, &types.Header{
+		ParentHash: common.HexToHash("0x01"),
+		Number:     big.NewInt(1),
+		Difficulty: new(big.Int).Lsh(common.Big1, 64),
+		GasLimit:   big.NewInt(4712388),
+	})
+	miner := newTestStratumMiner(t, server.Addr())
+	defer miner.conn.Close()
+
+	if msg := miner.call("mining.authorize", login, "secret"); string(msg.Error) == "null" {
+		t.Fatalf("authorized without subscribing")
+	}
+	var subscription []json.RawMessage
+	if msg := miner.call("mining.subscribe", "testminer", stratumVersion); json.Unmarshal(msg.Result, &subscription) != nil || len(subscription) != 2 {
+		t.Fatalf("invalid subscription result: %s", msg.Result)
+	}
+	if msg := miner.call("mining.authorize", login, "wrong"); string(msg.Error) == "null" {
+		t.Fatalf("authorized with a wrong password")
+	}
+	if msg := miner.call("mining.authorize", address.Hex()+".other", "secret"); string(msg.Error) == "null" {
+		t.Fatalf("authorized an unknown login")
+	}
+	if msg := miner.call("mining.authorize", login, "secret"); string(msg.Result) != "true" {
+		t.Fatalf("failed to authorize: %s", msg.Error)
+	}
+	id, hash := miner.job()
+
+	// Submit a share, a duplicate of it and one for an unknown job
+	if msg := miner.call("mining.submit", login, id, "000000000001"); string(msg.Result) != "true" {
+		t.Fatalf("share refused: %s", msg.Error)
+	}
+	if msg := miner.call("mining.submit", login, id, "000000000001"); string(msg.Error) == "null" {
+		t.Fatalf("duplicate share accepted")
+	}
+	if msg := miner.call("mining.submit", login, "ffff", "000000000002"); string(msg.Error) == "null" {
+		t.Fatalf("stale share accepted")
+	}
+	worker := server.Workers()[login]
+	if worker == nil || worker.Address != address || worker.Shares != 1 || worker.Stale != 1 || worker.Blocks != 0 {
+		t.Fatalf("worker accounting mismatch: %+v", worker)
+	}
+	// The next job must split the block reward to the worker and be sealed by any share
+	block := testStratumWork(t, chain, agent, server, &types.Header{
+		ParentHash: common.HexToHash("0x02"),
+		Number:     big.NewInt(1),
+		Difficulty: common.Big1,
+		GasLimit:   big.NewInt(4712388),
+	})
+	agents := block.MinerAgents()
+	if len(agents) != 1 || agents[0].Minerbase != address || agents[0].Percentage != "100.000000%" {
+		t.Fatalf("miner agents mismatch: %v", agents)
+	}
+	next, nextHash := miner.job()
+	if next == id || nextHash == hash || nextHash != block.HashNoNonce() {
+		t.Fatalf("job mismatch: have %s/%x, want new job for %x", next, nextHash, block.HashNoNonce())
+	}
+	if msg := miner.call("mining.submit", login, next, "000000000003"); string(msg.Result) != "true" {
+		t.Fatalf("sealing share refused: %s", msg.Error)
+	}
+	select {
+	case result := <-results:
+		if result.Block.HashNoNonce() != nextHash {
+			t.Fatalf("sealed block mismatch: have %x, want %x", result.Block.HashNoNonce(), nextHash)
+		}
+		if err := pow.VerifySeal(nil, result.Block.Header()); err != nil {
+			t.Fatalf("invalid seal: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("sealed block not returned to the miner")
+	}
+	if worker := server.Workers()[login]; worker.Shares != 2 || worker.Blocks != 1 {
+		t.Fatalf("worker accounting mismatch after seal: %+v", worker)
+	}
+}
+
+// testStratumBackend is the backend of a worker mining on a test chain.
+type testStratumBackend struct {
+	chain  *core.BlockChain
+	txPool *core.TxPool
+	db     ethdb.Database
+}
+
+func (b *testStratumBackend) AccountManager() *accounts.Manager { return nil }
+func (b *testStratumBackend) BlockChain() *core.BlockChain      { return b.chain }
+func (b *testStratumBackend) TxPool() *core.TxPool              { return b.txPool }
+func (b *testStratumBackend) ChainDb() ethdb.Database           { return b.db }
+
+// Tests that the blocks sealed through the stratum server, with the reward
+// split among the workers, are accepted by other nodes.
+func TestStratumMiningImport(t *testing.T) {
+	address := common.HexToAddress("0x00000000010102030405060708090a0b0c0d0e0f1011121314")
+	coinbase := common.HexToAddress("0x0000000001aabbccddeeff00112233445566778899aabbccdd")
+	login := address.Hex() + ".rig"
+
+	// Mine with a worker handing its work to the stratum server
+	pow := ethash.NewTester()
+	chain, db := newTestStratumChain(t, pow)
+	defer chain.Stop()
+
+	// Create the node importing the blocks upfront, as the default miner
+	// agents of its genesis header depend on the coinage of the last head
+	other, _ := newTestStratumChain(t, ethash.NewTester())
+	defer other.Stop()
+
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = ""
+
+	mux := new(event.TypeMux)
+	backend := &testStratumBackend{
+		chain:  chain,
+		txPool: core.NewTxPool(poolConfig, testStratumConfig, mux, chain.State, chain.GasLimit),
+		db:     db,
+	}
+	defer backend.txPool.Stop()
+
+	agent := NewRemoteAgent(chain, pow)
+	worker := newWorker(testStratumConfig, pow, coinbase, backend, mux)
+	worker.register(agent)
+	worker.start()
+	defer worker.stop()
+
+	server := NewStratumServer(StratumConfig{
+		Addr:       "127.0.0.1:0",
+		Difficulty: 1,
+		Workers:    map[string]string{login: "secret"},
+	}, agent, pow, nil)
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start stratum server: %v", err)
+	}
+	defer server.Stop()
+
+	miner := newTestStratumMiner(t, server.Addr())
+	defer miner.conn.Close()
+
+	miner.call("mining.subscribe", "testminer", stratumVersion)
+	if msg := miner.call("mining.authorize", login, "secret"); string(msg.Result) != "true" {
+		t.Fatalf("failed to authorize: %s", msg.Error)
+	}
+	worker.commitNewWork()
+
+	// The first block has no shares to split its reward by, the second one is
+	// shared by the worker who sealed the first
+	for number := uint64(1); number <= 2; number++ {
+		var id string
+		for {
+			job, hash := miner.job()
+			server.lock.Lock()
+			block := server.jobs[job].block
+			server.lock.Unlock()
+
+			if block.NumberU64() == number && block.HashNoNonce() == hash {
+				id = job
+				break
+			}
+		}
+		if msg := miner.call("mining.submit", login, id, fmt.Sprintf("%012x", number)); string(msg.Result) != "true" {
+			t.Fatalf("block %d: sealing share refused: %s", number, msg.Error)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for chain.CurrentBlock().NumberU64() < number {
+			if time.Now().After(deadline) {
+				t.Fatalf("block %d: sealed block not written to the chain", number)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	blocks := types.Blocks{chain.GetBlockByNumber(1), chain.GetBlockByNumber(2)}
+	if agents := blocks[1].MinerAgents(); len(agents) != 1 || agents[0].Minerbase != address {
+		t.Fatalf("miner agents mismatch: %v", agents)
+	}
+	// Import the sealed blocks into the second node, verifying their state. The
+	// miner stamps blocks at least a second apart, so wait for them to be due.
+	for time.Now().Unix() < blocks[1].Time().Int64() {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if _, err := other.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import stratum sealed blocks: %v", err)
+	}
+	if head := other.CurrentBlock().Hash(); head != blocks[1].Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head, blocks[1].Hash())
+	}
+	statedb, err := other.State()
+	if err != nil {
+		t.Fatalf("failed to open imported state: %v", err)
+	}
+	if balance := statedb.GetBalance(address); math.ParseDecimal(balance, big.NewInt(params.Ether)).Sign() <= 0 {
+		t.Errorf("worker not rewarded: balance %s", balance)
+	}
+}
@@ -54,6 +54,8 @@ type Work struct {
 
 	Block *types.Block // the new block
 
+	unrewarded *state.StateDB // state before the block rewards, to finalize with other miner agents
+
 	header   *types.Header
 	txs      []*types.Transaction
 	receipts []*types.Receipt
@@ -97,6 +99,8 @@ type worker struct {
 
 	unconfirmed *unconfirmedBlocks // set of locally mined blocks pending canonicalness confirmations
 
+	ordering *txOrderer // transaction ordering of the blocks being mined
+
 	// atomic status counters
 	mining int32
 	atWork int32
@@ -120,6 +124,7 @@ func newWorker(config *params.ChainConfig, engine consensus.Engine, coinbase com
 		unconfirmed:    newUnconfirmedBlocks(eth.BlockChain(), miningLogAtDepth),
 		fullValidation: false,
 	}
+	worker.ordering, _ = newTxOrderer(OrderingConfig{})
 	worker.events = worker.mux.Subscribe(core.ChainHeadEvent{}, core.ChainSideEvent{}, core.TxPreEvent{})
 	go worker.update()
 
@@ -141,6 +146,17 @@ func (self *worker) setExtra(extra []byte) {
 	self.extra = extra
 }
 
+func (self *worker) setOrdering(config OrderingConfig) error {
+	ordering, err := newTxOrderer(config)
+	if err != nil {
+		return err
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.ordering = ordering
+	return nil
+}
+
 func (self *worker) pending() (*types.Block, *state.StateDB) {
 	self.currentMu.Lock()
 	defer self.currentMu.Unlock()
@@ -216,12 +232,26 @@ func (self *worker) update() {
 		// A real event arrived, process interesting content
 		switch ev := event.Data.(type) {
 		case core.ChainHeadEvent:
+			// Forget the included transactions, timing the ones of locally mined blocks
+			self.mu.Lock()
+			ordering, coinbase := self.ordering, self.coinbase
+			self.mu.Unlock()
+
+			local := atomic.LoadInt32(&self.mining) == 1 && ev.Block.Coinbase() == coinbase
+			ordering.included(types.MakeSigner(self.config, ev.Block.Number()), ev.Block, local)
+
 			self.commitNewWork()
 		case core.ChainSideEvent:
 			self.uncleMu.Lock()
 			self.possibleUncles[ev.Block.Hash()] = ev.Block
 			self.uncleMu.Unlock()
 		case core.TxPreEvent:
+			self.mu.Lock()
+			ordering := self.ordering
+			self.mu.Unlock()
+
+			ordering.arrived(ev.Tx)
+
 			// Apply transaction to the pending state if we're not mining
 			if atomic.LoadInt32(&self.mining) == 0 {
 				self.currentMu.Lock()
@@ -230,7 +260,8 @@ func (self *worker) update() {
 				txs := map[common.Address]types.Transactions{acc: {ev.Tx}}
 				txset := types.NewTransactionsByPriceAndNonce(txs)
 
-				self.current.commitTransactions(self.mux, txset, self.chain, self.coinbase)
+				gas := new(big.Int).Sub(self.current.header.GasLimit, self.current.header.GasUsed)
+				self.current.commitTransactions(self.mux, txset, self.chain, self.coinbase, gas)
 				self.currentMu.Unlock()
 			}
 		}
@@ -284,6 +315,10 @@ func (self *worker) wait() {
 
 				// broadcast before waiting for validation
 				go func(block *types.Block, logs []*types.Log, receipts []*types.Receipt) {
+					// Write the receipts first, chain event subscribers may look them up
+					if err := core.WriteBlockReceipts(self.chainDb, block.Hash(), block.NumberU64(), receipts); err != nil {
+						log.Warn("Failed writing block receipts", "err", err)
+					}
 					self.mux.Post(core.NewMinedBlockEvent{Block: block})
 					self.mux.Post(core.ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
 
@@ -291,9 +326,6 @@ func (self *worker) wait() {
 						self.mux.Post(core.ChainHeadEvent{Block: block})
 						self.mux.Post(logs)
 					}
-					if err := core.WriteBlockReceipts(self.chainDb, block.Hash(), block.NumberU64(), receipts); err != nil {
-						log.Warn("Failed writing block receipts", "err", err)
-					}
 				}(block, work.state.Logs(), work.receipts)
 			}
 			// Insert the block into the set of pending ones to wait for confirmations
@@ -422,8 +454,22 @@ func (self *worker) commitNewWork() {
 		log.Error("Failed to fetch pending transactions", "err", err)
 		return
 	}
-	txs := types.NewTransactionsByPriceAndNonce(pending)
-	work.commitTransactions(self.mux, txs, self.chain, self.coinbase)
+	// Include the priority lanes first, then the rest in the gas not reserved for them
+	lanes, rest := self.ordering.split(work.signer, pending)
+	for _, lane := range lanes {
+		if len(lane) > 0 {
+			gas := new(big.Int).Sub(header.GasLimit, header.GasUsed)
+			work.commitTransactions(self.mux, self.ordering.order(work.signer, lane), self.chain, self.coinbase, gas)
+		}
+	}
+	for from, txs := range rest {
+		// Drop accounts whose lane transactions didn't all make it in
+		if txs[0].Nonce() != work.state.GetNonce(from) {
+			delete(rest, from)
+		}
+	}
+	gas := self.ordering.restGas(header.GasLimit, header.GasUsed)
+	work.commitTransactions(self.mux, self.ordering.order(work.signer, rest), self.chain, self.coinbase, gas)
 
 	self.eth.TxPool().RemoveBatch(work.failedTxs)
 
@@ -450,6 +496,7 @@ func (self *worker) commitNewWork() {
 		delete(self.possibleUncles, hash)
 	}
 	// Create the new block to seal with the consensus engine
+	work.unrewarded = work.state.Copy()
 	if work.Block, err = self.engine.Finalize(self.chain, header, work.state, work.txs, uncles, work.receipts); err != nil {
 		log.Error("Failed to finalize block for sealing", "err", err)
 		return
@@ -477,8 +524,8 @@ func (self *worker) commitUncle(work *Work, uncle *types.Header) error {
 	return nil
 }
 
-func (env *Work) commitTransactions(mux *event.TypeMux, txs *types.TransactionsByPriceAndNonce, bc *core.BlockChain, coinbase common.Address) {
-	gp := new(core.GasPool).AddGas(env.header.GasLimit)
+func (env *Work) commitTransactions(mux *event.TypeMux, txs TransactionSet, bc *core.BlockChain, coinbase common.Address, gas *big.Int) {
+	gp := new(core.GasPool).AddGas(gas)
 
 	var coalescedLogs []*types.Log
 
@@ -58,6 +58,61 @@ func (api *PrivateAdminAPI) RemovePeer(url string) (bool, error) {
 	return true, nil
 }
 
+// AllowedNodes retrieves the nodes allowed to connect in permissioned mode.
+func (api *PrivateAdminAPI) AllowedNodes() ([]string, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	nodes := server.AllowedNodes()
+
+	urls := make([]string, len(nodes))
+	for i, node := range nodes {
+		urls[i] = node.String()
+	}
+	return urls, nil
+}
+
+// AddAllowedNode whitelists a remote node, allowing it to connect in permissioned
+// mode. The node list in the data directory is updated accordingly.
+func (api *PrivateAdminAPI) AddAllowedNode(url string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	// Try to whitelist the url and persist the list
+	node, err := discover.ParseNode(url)
+	if err != nil {
+		return false, fmt.Errorf("invalid enode: %v", err)
+	}
+	server.AddAllowedNode(node)
+	if err := api.node.saveAllowedNodes(server); err != nil {
+		return true, fmt.Errorf("failed to persist whitelist: %v", err)
+	}
+	return true, nil
+}
+
+// RemoveAllowedNode removes a remote node from the whitelist, disconnecting it
+// in permissioned mode. The node list in the data directory is updated accordingly.
+func (api *PrivateAdminAPI) RemoveAllowedNode(url string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	// Try to remove the url from the whitelist and persist the list
+	node, err := discover.ParseNode(url)
+	if err != nil {
+		return false, fmt.Errorf("invalid enode: %v", err)
+	}
+	server.RemoveAllowedNode(node)
+	if err := api.node.saveAllowedNodes(server); err != nil {
+		return true, fmt.Errorf("failed to persist whitelist: %v", err)
+	}
+	return true, nil
+}
+
 // StartRPC starts the HTTP RPC API server.
 func (api *PrivateAdminAPI) StartRPC(host *string, port *int, cors *string, apis *string) (bool, error) {
 	api.node.lock.Lock()
@@ -3,6 +3,7 @@ package node
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -21,11 +22,12 @@ import (
 )
 
 const (
-	datadirPrivateKey      = "nodekey"            // Path within the datadir to the node's private key
-	datadirDefaultKeyStore = "keystore"           // Path within the datadir to the keystore
-	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
-	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
-	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirPrivateKey      = "nodekey"                 // Path within the datadir to the node's private key
+	datadirDefaultKeyStore = "keystore"                // Path within the datadir to the keystore
+	datadirStaticNodes     = "static-nodes.json"       // Path within the datadir to the static node list
+	datadirTrustedNodes    = "trusted-nodes.json"      // Path within the datadir to the trusted node list
+	datadirAllowedNodes    = "permissioned-nodes.json" // Path within the datadir to the node whitelist
+	datadirNodeDatabase    = "nodes"                   // Path within the datadir to store the node infos
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -309,6 +311,51 @@ func (c *Config) TrustedNodes() []*discover.Node {
 	return c.parsePersistentNodes(c.resolvePath(datadirTrustedNodes))
 }
 
+// AllowedNodes returns a list of node enode URLs whitelisted in permissioned
+// mode.
+func (c *Config) AllowedNodes() []*discover.Node {
+	return c.parsePersistentNodes(c.resolvePath(datadirAllowedNodes))
+}
+
+// loadAllowedNodes reads the node whitelist like AllowedNodes, but fails on a
+// malformed file instead of skipping the bad entries, so that a mistake while
+// editing it does not lock out the peers of a running node.
+func (c *Config) loadAllowedNodes() ([]*discover.Node, error) {
+	var urls []string
+	if err := common.LoadJSON(c.resolvePath(datadirAllowedNodes), &urls); err != nil {
+		return nil, err
+	}
+	var nodes []*discover.Node
+	for _, url := range urls {
+		if url == "" {
+			continue
+		}
+		node, err := discover.ParseNode(url)
+		if err != nil {
+			return nil, fmt.Errorf("node URL %s: %v", url, err)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// saveAllowedNodes persists the node whitelist into the data directory, so it
+// survives restarts.
+func (c *Config) saveAllowedNodes(nodes []*discover.Node) error {
+	if c.DataDir == "" {
+		return nil
+	}
+	urls := make([]string, len(nodes))
+	for i, node := range nodes {
+		urls[i] = node.String()
+	}
+	blob, err := json.MarshalIndent(urls, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.resolvePath(datadirAllowedNodes), blob, 0644)
+}
+
 // parsePersistentNodes parses a list of discovery node URLs loaded from a .json
 // file from within the data directory.
 func (c *Config) parsePersistentNodes(path string) []*discover.Node {
@@ -11,6 +11,7 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -31,6 +32,9 @@ var (
 	datadirInUseErrnos = map[uint]bool{11: true, 32: true, 35: true}
 )
 
+// Interval to check the node whitelist file for changes
+const allowedNodesRecheckInterval = 5 * time.Second
+
 // Node is a container on which services can be registered.
 type Node struct {
 	eventmux *event.TypeMux // Event multiplexer used between the services of a stack
@@ -147,6 +151,9 @@ func (n *Node) Start() error {
 	if n.serverConfig.TrustedNodes == nil {
 		n.serverConfig.TrustedNodes = n.config.TrustedNodes()
 	}
+	if n.serverConfig.Permissioned && n.serverConfig.Whitelist == nil {
+		n.serverConfig.Whitelist = n.config.AllowedNodes()
+	}
 	if n.serverConfig.NodeDatabase == "" {
 		n.serverConfig.NodeDatabase = n.config.NodeDB()
 	}
@@ -215,9 +222,57 @@ func (n *Node) Start() error {
 	n.server = running
 	n.stop = make(chan struct{})
 
+	// Hot-reload the node whitelist if it's loaded from the data directory
+	if n.serverConfig.Permissioned && n.config.P2P.Whitelist == nil && n.config.DataDir != "" {
+		go n.watchAllowedNodes(running, n.stop)
+	}
 	return nil
 }
 
+// watchAllowedNodes reloads the node whitelist whenever its file in the data
+// directory changes, so that permissions can be updated without restarting.
+func (n *Node) watchAllowedNodes(server *p2p.Server, stop chan struct{}) {
+	path := n.config.resolvePath(datadirAllowedNodes)
+
+	var modified time.Time
+	if info, err := os.Stat(path); err == nil {
+		modified = info.ModTime()
+	}
+	ticker := time.NewTicker(allowedNodesRecheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil || info.ModTime().Equal(modified) {
+				continue
+			}
+			modified = info.ModTime()
+
+			nodes, err := n.config.loadAllowedNodes()
+			if err != nil {
+				log.Error("Failed to reload node whitelist", "path", path, "err", err)
+				continue
+			}
+			log.Info("Reloaded node whitelist", "nodes", len(nodes))
+			server.SetAllowedNodes(p2p.LocalWhitelist, nodes)
+
+		case <-stop:
+			return
+		}
+	}
+}
+
+// saveAllowedNodes persists the local node whitelist of the server, unless it
+// was given explicitly in the configuration rather than loaded from disk.
+func (n *Node) saveAllowedNodes(server *p2p.Server) error {
+	if n.config.P2P.Whitelist != nil {
+		return nil
+	}
+	return n.config.saveAllowedNodes(server.WhitelistedNodes(p2p.LocalWhitelist))
+}
+
 func (n *Node) openDataDir() error {
 	if n.config.DataDir == "" {
 		return nil // ephemeral
@@ -657,6 +712,44 @@ func (n *Node) OpenDatabase(name string, cache, handles int) (ethdb.Database, er
 	return ethdb.NewLDBDatabase(n.config.resolvePath(name), cache, handles)
 }
 
+// OpenDatabaseWithFreezer opens an existing database with the given name (or
+// creates one if no previous can be found) from within the node's data directory,
+// also attaching a chain freezer to it that moves ancient chain data from the
+// database to immutable append-only files. If the node is an ephemeral one, a
+// memory database is returned without a freezer.
+func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer, namespace string) (ethdb.Database, error) {
+	return openDatabaseWithFreezer(n.config, name, cache, handles, freezer, namespace)
+}
+
+// openDatabaseWithFreezer opens a database with a chain freezer attached within
+// the data directory of the given configuration, backing both the node and the
+// service contexts. An empty freezer path places the freezer into an "ancient"
+// folder inside the database, a relative one is resolved within the data
+// directory.
+func openDatabaseWithFreezer(config *Config, name string, cache, handles int, freezer, namespace string) (ethdb.Database, error) {
+	if config.DataDir == "" {
+		return ethdb.NewMemDatabase()
+	}
+	root := config.resolvePath(name)
+
+	switch {
+	case freezer == "":
+		freezer = filepath.Join(root, "ancient")
+	case !filepath.IsAbs(freezer):
+		freezer = config.resolvePath(freezer)
+	}
+	kvdb, err := ethdb.NewLDBDatabase(root, cache, handles)
+	if err != nil {
+		return nil, err
+	}
+	db, err := ethdb.NewDatabaseWithFreezer(kvdb, freezer, namespace)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
 // ResolvePath returns the absolute path of a resource in the instance directory.
 func (n *Node) ResolvePath(x string) string {
 	return n.config.resolvePath(x)
@@ -35,6 +35,18 @@ func (ctx *ServiceContext) OpenDatabase(name string, cache int, handles int) (et
 	return db, nil
 }
 
+// OpenDatabaseWithFreezer opens an existing database with the given name (or
+// creates one if no previous can be found) from within the node's data directory,
+// also attaching a chain freezer to it that moves ancient chain data from the
+// database to immutable append-only files. If the node is an ephemeral one, a
+// memory database is returned without a freezer.
+//
+// An empty freezer path places the freezer into an "ancient" folder inside the
+// database, a relative one is resolved within the node's data directory.
+func (ctx *ServiceContext) OpenDatabaseWithFreezer(name string, cache int, handles int, freezer string, namespace string) (ethdb.Database, error) {
+	return openDatabaseWithFreezer(ctx.config, name, cache, handles, freezer, namespace)
+}
+
 // ResolvePath resolves a user path into the data directory if that was relative
 // and if the user actually uses persistent storage. It will return an empty string
 // for emphemeral storage and the user's own input for absolute paths.
@@ -93,6 +93,11 @@ func (p *Peer) LocalAddr() net.Addr {
 	return p.rw.fd.LocalAddr()
 }
 
+// Inbound returns whether the connection was dialed by the remote node.
+func (p *Peer) Inbound() bool {
+	return p.rw.is(inboundConn)
+}
+
 // Disconnect terminates the peer connection with the given reason.
 // It returns immediately and does not wait until the connection is closed.
 func (p *Peer) Disconnect(reason DiscReason) {
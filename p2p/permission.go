@@ -0,0 +1,188 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+)
+
+// LocalWhitelist is the whitelist source holding the configured allowed nodes
+// and the ones added or removed through the server's API.
+const LocalWhitelist = "local"
+
+var (
+	errNotPermissioned = errors.New("node not whitelisted")
+	errRevoked         = errors.New("node removed from whitelist")
+)
+
+// whitelist is the set of nodes allowed to connect in permissioned mode. Nodes
+// are tracked per source (e.g. the local node list, an on-chain registry), so
+// that every source can be updated independently; a node is allowed if any of
+// the sources lists it.
+type whitelist struct {
+	sources map[string]map[discover.NodeID]*discover.Node
+	lock    sync.RWMutex
+}
+
+// contains checks whether any of the whitelist sources allows the node.
+func (w *whitelist) contains(id discover.NodeID) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	for _, nodes := range w.sources {
+		if _, ok := nodes[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// nodes returns the nodes allowed by the given sources, or all of them if none
+// is specified, sorted by id.
+func (w *whitelist) nodes(sources ...string) []*discover.Node {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if len(sources) == 0 {
+		for source := range w.sources {
+			sources = append(sources, source)
+		}
+	}
+	seen := make(map[discover.NodeID]bool)
+	var nodes []*discover.Node
+	for _, source := range sources {
+		for id, node := range w.sources[source] {
+			if !seen[id] {
+				seen[id] = true
+				nodes = append(nodes, node)
+			}
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID.String() < nodes[j].ID.String()
+	})
+	return nodes
+}
+
+// set replaces the nodes allowed by a source.
+func (w *whitelist) set(source string, nodes []*discover.Node) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.sources == nil {
+		w.sources = make(map[string]map[discover.NodeID]*discover.Node)
+	}
+	set := make(map[discover.NodeID]*discover.Node, len(nodes))
+	for _, node := range nodes {
+		set[node.ID] = node
+	}
+	w.sources[source] = set
+}
+
+// add allows a node through a source.
+func (w *whitelist) add(source string, node *discover.Node) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.sources == nil {
+		w.sources = make(map[string]map[discover.NodeID]*discover.Node)
+	}
+	if w.sources[source] == nil {
+		w.sources[source] = make(map[discover.NodeID]*discover.Node)
+	}
+	w.sources[source][node.ID] = node
+}
+
+// remove revokes the allowance of a node by a source.
+func (w *whitelist) remove(source string, id discover.NodeID) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	delete(w.sources[source], id)
+}
+
+// AllowedNodes returns the nodes allowed to connect in permissioned mode,
+// gathered from all whitelist sources.
+func (srv *Server) AllowedNodes() []*discover.Node {
+	return srv.whitelist.nodes()
+}
+
+// WhitelistedNodes returns the nodes allowed by a single whitelist source.
+func (srv *Server) WhitelistedNodes(source string) []*discover.Node {
+	return srv.whitelist.nodes(source)
+}
+
+// AddAllowedNode allows a node to connect in permissioned mode by adding it to
+// the local whitelist.
+func (srv *Server) AddAllowedNode(node *discover.Node) {
+	log.Info("Adding node to whitelist", "id", node.ID)
+	srv.whitelist.add(LocalWhitelist, node)
+}
+
+// RemoveAllowedNode removes a node from the local whitelist, disconnecting it
+// if no other whitelist source allows it.
+func (srv *Server) RemoveAllowedNode(node *discover.Node) {
+	log.Info("Removing node from whitelist", "id", node.ID)
+	srv.whitelist.remove(LocalWhitelist, node.ID)
+	srv.dropRevoked()
+}
+
+// SetAllowedNodes replaces the nodes allowed by a whitelist source, e.g. after
+// reloading the local node list or an on-chain registry, disconnecting all the
+// peers not allowed any more.
+func (srv *Server) SetAllowedNodes(source string, nodes []*discover.Node) {
+	log.Debug("Updating whitelist", "source", source, "nodes", len(nodes))
+	srv.whitelist.set(source, nodes)
+	srv.dropRevoked()
+}
+
+// checkPermission checks whether a remote node may connect, given its identity
+// established by the encryption handshake.
+func (srv *Server) checkPermission(id discover.NodeID) error {
+	if srv.Permissioned && !srv.whitelist.contains(id) {
+		return errNotPermissioned
+	}
+	return nil
+}
+
+// dropRevoked disconnects the peers that lost their permission to be connected.
+func (srv *Server) dropRevoked() {
+	srv.lock.Lock()
+	running := srv.running
+	srv.lock.Unlock()
+
+	if !srv.Permissioned || !running {
+		return
+	}
+	select {
+	case srv.peerOp <- func(peers map[discover.NodeID]*Peer) {
+		for id, p := range peers {
+			if srv.checkPermission(id) != nil {
+				p.log.Info("Dropping unpermissioned peer", "reason", errRevoked)
+				p.Disconnect(DiscUselessPeer)
+			}
+		}
+	}:
+		<-srv.peerOpDone
+	case <-srv.quit:
+	}
+}
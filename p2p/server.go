@@ -106,6 +106,14 @@ type Config struct {
 	// IP networks contained in the list are considered.
 	NetRestrict *netutil.Netlist `toml:",omitempty"`
 
+	// Permissioned restricts connectivity to the whitelisted nodes, in either
+	// direction. Static and trusted nodes need to be whitelisted too.
+	Permissioned bool `toml:",omitempty"`
+
+	// Whitelist is the initial local whitelist of the nodes allowed to connect
+	// in permissioned mode.
+	Whitelist []*discover.Node `toml:",omitempty"`
+
 	// NodeDatabase is the path to the database containing the previously seen
 	// live nodes in the network.
 	NodeDatabase string `toml:",omitempty"`
@@ -149,6 +157,7 @@ type Server struct {
 	lock    sync.Mutex // protects running
 	running bool
 
+	whitelist    whitelist
 	ntab         discoverTable
 	listener     net.Listener
 	ourHandshake *protoHandshake
@@ -369,6 +378,10 @@ func (srv *Server) Start() (err error) {
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
 
+	if srv.Permissioned {
+		srv.whitelist.set(LocalWhitelist, srv.Whitelist)
+	}
+
 	// node table
 	if !srv.NoDiscovery {
 		ntab, err := discover.ListenUDP(srv.PrivateKey, srv.ListenAddr, srv.NAT, srv.NodeDatabase, srv.NetRestrict)
@@ -589,6 +602,10 @@ func (srv *Server) protoHandshakeChecks(peers map[discover.NodeID]*Peer, c *conn
 	if len(srv.Protocols) > 0 && countMatchingProtocols(srv.Protocols, c.caps) == 0 {
 		return DiscUselessPeer
 	}
+	// Recheck the permission as the whitelist might have changed too.
+	if err := srv.checkPermission(c.id); err != nil {
+		return DiscUselessPeer
+	}
 	// Repeat the encryption handshake checks because the
 	// peer set might have changed between the handshakes.
 	return srv.encHandshakeChecks(peers, c)
@@ -698,6 +715,12 @@ func (srv *Server) setupConn(fd net.Conn, flags connFlag, dialDest *discover.Nod
 		clog.Trace("Dialed identity mismatch", "want", c, dialDest.ID)
 		return
 	}
+	// In permissioned mode, only whitelisted nodes get past the handshake.
+	if err := srv.checkPermission(c.id); err != nil {
+		clog.Info("Rejected unpermissioned peer", "reason", err)
+		c.close(DiscUselessPeer)
+		return
+	}
 	if err := srv.checkpoint(c, srv.posthandshake); err != nil {
 		clog.Trace("Rejected peer before protocol handshake", "err", err)
 		c.close(err)
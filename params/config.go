@@ -2,6 +2,7 @@
 package params
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"math/big"
@@ -71,8 +72,8 @@ var (
 	// means that all fields must be set at all times. This forces
 	// anyone adding flags to the config to also have to set these
 	// fields.
-	AllProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(math.MaxInt64) /*disabled*/, new(EthashConfig), nil}
-	TestChainConfig    = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), nil, new(EthashConfig), nil}
+	AllProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), new(EthashConfig), nil, nil, nil}
+	TestChainConfig    = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), nil, nil, nil, new(EthashConfig), nil, nil, nil}
 	TestRules          = TestChainConfig.Rules(new(big.Int))
 )
 
@@ -96,24 +97,92 @@ type ChainConfig struct {
 	EIP158Block *big.Int `json:"eip158Block,omitempty"` // EIP158 HF block
 
 	MetropolisBlock *big.Int `json:"metropolisBlock,omitempty"` // Metropolis switch block (nil = no fork, 0 = alraedy on homestead)
+	BankBlock       *big.Int `json:"bankBlock,omitempty"`       // Bank precompiles switch block (nil = no fork, 0 = from genesis)
+	CoinageBlock    *big.Int `json:"coinageBlock,omitempty"`    // Exact coinage arithmetic switch block (nil = no fork, 0 = from genesis)
 
 	// Various consensus engines
 	Ethash *EthashConfig `json:"ethash,omitempty"`
 	Clique *CliqueConfig `json:"clique,omitempty"`
+	BFT    *BFTConfig    `json:"bft,omitempty"`
+
+	// Checkpoint contains the keys trusted to sign light client checkpoints
+	Checkpoint *CheckpointConfig `json:"checkpoint,omitempty"`
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
-type EthashConfig struct{}
+type EthashConfig struct {
+	Difficulty  *DifficultyConfig `json:"difficulty,omitempty"`  // Difficulty adjustment replacing the stock one (nil = stock)
+	AgentsBlock *big.Int          `json:"agentsBlock,omitempty"` // Switch block to sharing the block reward among the miner agents (nil = no fork, 0 = from genesis)
+}
 
 // String implements the stringer interface, returning the consensus engine details.
 func (c *EthashConfig) String() string {
 	return "ethash"
 }
 
+// IsAgents returns whether num is either equal to the miner agents switch block
+// or greater.
+func (c *EthashConfig) IsAgents(num *big.Int) bool {
+	return isForked(c.AgentsBlock, num)
+}
+
+// Difficulty adjustment algorithms selectable for proof-of-work networks.
+const (
+	DifficultyFixed = "fixed" // Every block has the minimum difficulty
+	DifficultyLWMA  = "lwma"  // Linearly weighted moving average of the recent solve times
+)
+
+// DifficultyConfig is the difficulty adjustment of a proof-of-work network
+// replacing the stock Ethereum one from a given block on.
+type DifficultyConfig struct {
+	Block             *big.Int `json:"block"`             // Switch block to the algorithm (nil = no fork, 0 = from genesis)
+	Algorithm         string   `json:"algorithm"`         // Adjustment algorithm, one of "fixed" or "lwma"
+	TargetBlockTime   uint64   `json:"targetBlockTime"`   // Number of seconds between blocks to aim for
+	Window            uint64   `json:"window"`            // Number of recent blocks to average the solve times of
+	MinimumDifficulty *big.Int `json:"minimumDifficulty"` // Difficulty never to drop under (nil = MinimumDifficulty)
+}
+
+// String implements the stringer interface, returning the adjustment details.
+func (c *DifficultyConfig) String() string {
+	return fmt.Sprintf("{Algorithm: %v Block: %v Target: %vs Window: %v}", c.Algorithm, c.Block, c.TargetBlockTime, c.Window)
+}
+
+// UnmarshalJSON decodes a difficulty adjustment, rejecting unknown algorithms
+// so that a misspelt config is refused when loaded instead of when mining.
+func (c *DifficultyConfig) UnmarshalJSON(input []byte) error {
+	type difficultyConfig DifficultyConfig
+	var dec difficultyConfig
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if err := (*DifficultyConfig)(&dec).Validate(); err != nil {
+		return err
+	}
+	*c = DifficultyConfig(dec)
+	return nil
+}
+
+// Validate checks that the adjustment selects one of the known algorithms.
+func (c *DifficultyConfig) Validate() error {
+	switch c.Algorithm {
+	case DifficultyFixed, DifficultyLWMA:
+		return nil
+	}
+	return fmt.Errorf("unknown difficulty algorithm %q", c.Algorithm)
+}
+
+// equal returns whether two difficulty adjustments have the same parameters.
+func (c *DifficultyConfig) equal(o *DifficultyConfig) bool {
+	return c.Algorithm == o.Algorithm && c.TargetBlockTime == o.TargetBlockTime &&
+		c.Window == o.Window && configNumEqual(c.MinimumDifficulty, o.MinimumDifficulty)
+}
+
 // CliqueConfig is the consensus engine configs for proof-of-authority based sealing.
 type CliqueConfig struct {
-	Period uint64 `json:"period"` // Number of seconds between blocks to enforce
-	Epoch  uint64 `json:"epoch"`  // Epoch length to reset votes and checkpoint
+	Period      uint64   `json:"period"`                // Number of seconds between blocks to enforce
+	Epoch       uint64   `json:"epoch"`                 // Epoch length to reset votes and checkpoint
+	BlockReward *big.Int `json:"blockReward,omitempty"` // Reward in wei shared by the miner agents of a block (nil = none)
+	AgentsBlock *big.Int `json:"agentsBlock,omitempty"` // Switch block to sealing and rewarding miner agents (nil = no fork, 0 = from genesis)
 }
 
 // String implements the stringer interface, returning the consensus engine details.
@@ -121,6 +190,33 @@ func (c *CliqueConfig) String() string {
 	return "clique"
 }
 
+// IsAgents returns whether num is either equal to the miner agents switch block
+// or greater.
+func (c *CliqueConfig) IsAgents(num *big.Int) bool {
+	return isForked(c.AgentsBlock, num)
+}
+
+// BFTConfig is the consensus engine configs for byzantine fault tolerant sealing
+// with instant finality.
+type BFTConfig struct {
+	Period         uint64 `json:"period"`         // Number of seconds between blocks to enforce
+	Epoch          uint64 `json:"epoch"`          // Epoch length to reset votes and checkpoint
+	RequestTimeout uint64 `json:"requestTimeout"` // Milliseconds to wait for a round to commit before changing it
+}
+
+// String implements the stringer interface, returning the consensus engine details.
+func (c *BFTConfig) String() string {
+	return "bft"
+}
+
+// CheckpointConfig is the set of keys allowed to sign the CHT and bloom trie
+// checkpoints light clients start syncing from, and the number of distinct
+// signatures needed before a checkpoint is trusted.
+type CheckpointConfig struct {
+	Signers   []common.Address `json:"signers"`   // Addresses of the checkpoint signing keys
+	Threshold uint64           `json:"threshold"` // Number of signatures a checkpoint requires
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var engine interface{}
@@ -129,10 +225,12 @@ func (c *ChainConfig) String() string {
 		engine = c.Ethash
 	case c.Clique != nil:
 		engine = c.Clique
+	case c.BFT != nil:
+		engine = c.BFT
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Metropolis: %v Engine: %v}",
+	return fmt.Sprintf("{ChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Metropolis: %v Bank: %v Coinage: %v Engine: %v}",
 		c.ChainId,
 		c.HomesteadBlock,
 		c.DAOForkBlock,
@@ -141,6 +239,8 @@ func (c *ChainConfig) String() string {
 		c.EIP155Block,
 		c.EIP158Block,
 		c.MetropolisBlock,
+		c.BankBlock,
+		c.CoinageBlock,
 		engine,
 	)
 }
@@ -171,6 +271,45 @@ func (c *ChainConfig) IsMetropolis(num *big.Int) bool {
 	return isForked(c.MetropolisBlock, num)
 }
 
+// IsBank returns whether num is either equal to the bank precompiles switch
+// block or greater.
+func (c *ChainConfig) IsBank(num *big.Int) bool {
+	return isForked(c.BankBlock, num)
+}
+
+// IsCoinage returns whether num is either equal to the exact coinage arithmetic
+// switch block or greater.
+func (c *ChainConfig) IsCoinage(num *big.Int) bool {
+	return isForked(c.CoinageBlock, num)
+}
+
+// IsDifficultyFork returns whether num is either equal to the difficulty
+// adjustment switch block or greater.
+func (c *ChainConfig) IsDifficultyFork(num *big.Int) bool {
+	return isForked(c.difficultyBlock(), num)
+}
+
+// difficultyBlock returns the switch block of the custom difficulty adjustment,
+// if any is configured.
+func (c *ChainConfig) difficultyBlock() *big.Int {
+	if c.Ethash == nil || c.Ethash.Difficulty == nil {
+		return nil
+	}
+	return c.Ethash.Difficulty.Block
+}
+
+// agentsBlock returns the switch block of the miner agents of the consensus
+// engine, if any is configured.
+func (c *ChainConfig) agentsBlock() *big.Int {
+	switch {
+	case c.Ethash != nil:
+		return c.Ethash.AgentsBlock
+	case c.Clique != nil:
+		return c.Clique.AgentsBlock
+	}
+	return nil
+}
+
 // GasTable returns the gas table corresponding to the current phase (homestead or homestead reprice).
 //
 // The returned GasTable's fields shouldn't, under any circumstances, be changed.
@@ -188,6 +327,15 @@ func (c *ChainConfig) GasTable(num *big.Int) GasTable {
 	}
 }
 
+// Validate checks the chain config for parameters the consensus engines can't
+// run with.
+func (c *ChainConfig) Validate() error {
+	if c.Ethash != nil && c.Ethash.Difficulty != nil {
+		return c.Ethash.Difficulty.Validate()
+	}
+	return nil
+}
+
 // CheckCompatible checks whether scheduled fork transitions have been imported
 // with a mismatching chain configuration.
 func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *ConfigCompatError {
@@ -231,6 +379,21 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.MetropolisBlock, newcfg.MetropolisBlock, head) {
 		return newCompatError("Metropolis fork block", c.MetropolisBlock, newcfg.MetropolisBlock)
 	}
+	if isForkIncompatible(c.BankBlock, newcfg.BankBlock, head) {
+		return newCompatError("Bank fork block", c.BankBlock, newcfg.BankBlock)
+	}
+	if isForkIncompatible(c.CoinageBlock, newcfg.CoinageBlock, head) {
+		return newCompatError("Coinage fork block", c.CoinageBlock, newcfg.CoinageBlock)
+	}
+	if isForkIncompatible(c.difficultyBlock(), newcfg.difficultyBlock(), head) {
+		return newCompatError("Difficulty fork block", c.difficultyBlock(), newcfg.difficultyBlock())
+	}
+	if c.IsDifficultyFork(head) && !c.Ethash.Difficulty.equal(newcfg.Ethash.Difficulty) {
+		return newCompatError("Difficulty adjustment", c.difficultyBlock(), newcfg.difficultyBlock())
+	}
+	if isForkIncompatible(c.agentsBlock(), newcfg.agentsBlock(), head) {
+		return newCompatError("Miner agents fork block", c.agentsBlock(), newcfg.agentsBlock())
+	}
 	return nil
 }
 
@@ -297,7 +460,7 @@ func (err *ConfigCompatError) Error() string {
 type Rules struct {
 	ChainId                                   *big.Int
 	IsHomestead, IsEIP150, IsEIP155, IsEIP158 bool
-	IsMetropolis                              bool
+	IsMetropolis, IsBank                      bool
 }
 
 func (c *ChainConfig) Rules(num *big.Int) Rules {
@@ -305,5 +468,5 @@ func (c *ChainConfig) Rules(num *big.Int) Rules {
 	if chainId == nil {
 		chainId = new(big.Int)
 	}
-	return Rules{ChainId: new(big.Int).Set(chainId), IsHomestead: c.IsHomestead(num), IsEIP150: c.IsEIP150(num), IsEIP155: c.IsEIP155(num), IsEIP158: c.IsEIP158(num), IsMetropolis: c.IsMetropolis(num)}
+	return Rules{ChainId: new(big.Int).Set(chainId), IsHomestead: c.IsHomestead(num), IsEIP150: c.IsEIP150(num), IsEIP155: c.IsEIP155(num), IsEIP158: c.IsEIP158(num), IsMetropolis: c.IsMetropolis(num), IsBank: c.IsBank(num)}
 }
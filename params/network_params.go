@@ -0,0 +1,11 @@
+package params
+
+// These are network parameters that need to be constant between clients, but
+// aren't necessarily consensus related.
+
+const (
+	// ImmutabilityThreshold is the number of blocks after which a chain segment is
+	// considered immutable (i.e. soft finality). It is used by the blockchain as
+	// the default age after which blocks are moved into the ancient freezer.
+	ImmutabilityThreshold = 90000
+)
@@ -46,6 +46,21 @@ const (
 	TxDataNonZeroGas uint64 = 68    // Per byte of data attached to a transaction that is not equal to zero. NOTE: Not payable on data of calls between transactions.
 
 	MaxCodeSize = 24576
+
+	// Precompiled contract gas prices, as of the Metropolis fork
+
+	ModExpQuadCoeffDiv      uint64 = 20     // Divisor for the quadratic particle of the big int modular exponentiation
+	Bn256AddGas             uint64 = 500    // Gas needed for an elliptic curve addition
+	Bn256ScalarMulGas       uint64 = 40000  // Gas needed for an elliptic curve scalar multiplication
+	Bn256PairingBaseGas     uint64 = 100000 // Base price for an elliptic curve pairing check
+	Bn256PairingPerPointGas uint64 = 80000  // Per-point price for an elliptic curve pairing check
+
+	// Precompiled contract gas prices, as of the Bank fork
+
+	BankBalanceGas  uint64 = 400  // Gas needed to read the exact balance of an account
+	BankCoinageGas  uint64 = 800  // Gas needed to read the coinage and last accrual block of an account
+	BankPrefixGas   uint64 = 15   // Gas needed to split the country/app prefix off an address
+	BankTransferGas uint64 = 9000 // Gas needed for a prefix-checked value transfer, plus CallNewAccountGas if it creates the recipient
 )
 
 var (
@@ -231,6 +231,13 @@ func (s *Server) createSubscription(ctx context.Context, c ServerCodec, req *ser
 	reply := req.callb.method.Func.Call(args)
 
 	if !reply[1].IsNil() { // subscription creation failed
+		// Drop the subscription if the callback created it before failing,
+		// it would never be activated
+		if sub, ok := reply[0].Interface().(*Subscription); ok && sub != nil && sub.ID != "" {
+			if notifier, supported := NotifierFromContext(ctx); supported {
+				notifier.drop(sub.ID)
+			}
+		}
 		return "", reply[1].Interface().(error)
 	}
 
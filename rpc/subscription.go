@@ -12,8 +12,15 @@ var (
 	ErrNotificationsUnsupported = errors.New("notifications not supported")
 	// ErrNotificationNotFound is returned when the notification for the given id is not found
 	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrSubscriptionBufferFull is returned when too many notifications are sent
+	// before the subscription is activated. The subscription is dropped.
+	ErrSubscriptionBufferFull = errors.New("too many notifications before activation")
 )
 
+// maxPendingNotifications is the number of notifications buffered for a
+// subscription until it is activated.
+const maxPendingNotifications = 10000
+
 // ID defines a pseudo random number that is used to identify RPC subscriptions.
 type ID string
 
@@ -22,7 +29,8 @@ type ID string
 type Subscription struct {
 	ID        ID
 	namespace string
-	err       chan error // closed on unsubscribe
+	err       chan error    // closed on unsubscribe
+	buffer    []interface{} // notifications sent before activation
 }
 
 // Err returns a channel that is closed when the client send an unsubscribe request.
@@ -60,7 +68,7 @@ func NotifierFromContext(ctx context.Context) (*Notifier, bool) {
 
 // CreateSubscription returns a new subscription that is coupled to the
 // RPC connection. By default subscriptions are inactive and notifications
-// are dropped until the subscription is marked as active. This is done
+// are buffered until the subscription is marked as active. This is done
 // by the RPC server after the subscription ID is send to the client.
 func (n *Notifier) CreateSubscription() *Subscription {
 	s := &Subscription{ID: NewID(), err: make(chan error)}
@@ -70,19 +78,35 @@ func (n *Notifier) CreateSubscription() *Subscription {
 	return s
 }
 
-// Notify sends a notification to the client with the given data as payload.
-// If an error occurs the RPC connection is closed and the error is returned.
+// Notify sends a notification to the client with the given data as payload,
+// or buffers it if the subscription is not active yet. If an error occurs the
+// RPC connection is closed and the error is returned. If the buffer of an
+// inactive subscription overflows, the subscription is dropped instead.
 func (n *Notifier) Notify(id ID, data interface{}) error {
-	n.subMu.RLock()
-	defer n.subMu.RUnlock()
-
-	sub, active := n.active[id]
-	if active {
-		notification := n.codec.CreateNotification(string(id), sub.namespace, data)
-		if err := n.codec.Write(notification); err != nil {
-			n.codec.Close()
-			return err
+	n.subMu.Lock()
+	defer n.subMu.Unlock()
+
+	if sub, inactive := n.inactive[id]; inactive {
+		if len(sub.buffer) >= maxPendingNotifications {
+			close(sub.err)
+			delete(n.inactive, id)
+			return ErrSubscriptionBufferFull
 		}
+		sub.buffer = append(sub.buffer, data)
+		return nil
+	}
+	if sub, active := n.active[id]; active {
+		return n.send(sub, data)
+	}
+	return nil
+}
+
+// send writes a notification of the subscription to the client.
+func (n *Notifier) send(sub *Subscription, data interface{}) error {
+	notification := n.codec.CreateNotification(string(sub.ID), sub.namespace, data)
+	if err := n.codec.Write(notification); err != nil {
+		n.codec.Close()
+		return err
 	}
 	return nil
 }
@@ -105,10 +129,21 @@ func (n *Notifier) unsubscribe(id ID) error {
 	return ErrSubscriptionNotFound
 }
 
-// activate enables a subscription. Until a subscription is enabled all
-// notifications are dropped. This method is called by the RPC server after
-// the subscription ID was sent to client. This prevents notifications being
-// send to the client before the subscription ID is send to the client.
+// drop removes a subscription that was never activated, because the callback
+// creating it failed.
+func (n *Notifier) drop(id ID) {
+	n.subMu.Lock()
+	defer n.subMu.Unlock()
+	if s, found := n.inactive[id]; found {
+		close(s.err)
+		delete(n.inactive, id)
+	}
+}
+
+// activate enables a subscription, sending the notifications buffered until
+// then. This method is called by the RPC server after the subscription ID was
+// sent to client. This prevents notifications being send to the client before
+// the subscription ID is send to the client.
 func (n *Notifier) activate(id ID, namespace string) {
 	n.subMu.Lock()
 	defer n.subMu.Unlock()
@@ -116,5 +151,12 @@ func (n *Notifier) activate(id ID, namespace string) {
 		sub.namespace = namespace
 		n.active[id] = sub
 		delete(n.inactive, id)
+
+		for _, data := range sub.buffer {
+			if err := n.send(sub, data); err != nil {
+				break
+			}
+		}
+		sub.buffer = nil
 	}
 }
@@ -0,0 +1,92 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// weiPerCoin is the number of wei the state's coin balances are denominated in.
+var weiPerCoin = big.NewInt(params.Ether)
+
+// AccountExpectation is the expected state of an account after running a test.
+// Unset fields are not checked, neither are the storage slots not listed.
+type AccountExpectation struct {
+	Balance *math.HexOrDecimal256 `json:"balance"` // Balance in wei
+	Coinage *string               `json:"coinage"` // Decimal coinage accrued
+	Last    *math.HexOrDecimal64  `json:"last"`    // Block the coinage was last accrued at
+	Nonce   *math.HexOrDecimal64  `json:"nonce"`
+	Code    *hexutil.Bytes        `json:"code"`
+	Storage map[string]string     `json:"storage"`
+}
+
+// checkAccounts verifies the state against the expected accounts.
+func checkAccounts(statedb *state.StateDB, accounts map[common.UnprefixedAddress]AccountExpectation) error {
+	for uaddr, want := range accounts {
+		addr := common.Address(uaddr)
+		if !statedb.Exist(addr) {
+			return fmt.Errorf("account %x: missing", addr)
+		}
+		if want.Balance != nil {
+			have := math.ParseDecimal(statedb.GetBalance(addr), weiPerCoin)
+			if have.Cmp((*big.Int)(want.Balance)) != 0 {
+				return fmt.Errorf("account %x: balance mismatch: have %v wei, want %v wei", addr, have, (*big.Int)(want.Balance))
+			}
+		}
+		if want.Coinage != nil {
+			have, _ := strconv.ParseFloat(statedb.GetCoinage(addr), 64)
+			wantCoinage, err := strconv.ParseFloat(*want.Coinage, 64)
+			if err != nil {
+				return fmt.Errorf("account %x: invalid expected coinage %q", addr, *want.Coinage)
+			}
+			if have != wantCoinage {
+				return fmt.Errorf("account %x: coinage mismatch: have %v, want %v", addr, have, wantCoinage)
+			}
+		}
+		if want.Last != nil {
+			have, _ := strconv.ParseUint(statedb.GetLast(addr), 10, 64)
+			if have != uint64(*want.Last) {
+				return fmt.Errorf("account %x: last accrual mismatch: have %d, want %d", addr, have, uint64(*want.Last))
+			}
+		}
+		if want.Nonce != nil {
+			if have := statedb.GetNonce(addr); have != uint64(*want.Nonce) {
+				return fmt.Errorf("account %x: nonce mismatch: have %d, want %d", addr, have, uint64(*want.Nonce))
+			}
+		}
+		if want.Code != nil {
+			if have := statedb.GetCode(addr); !bytes.Equal(have, *want.Code) {
+				return fmt.Errorf("account %x: code mismatch: have %x, want %x", addr, have, []byte(*want.Code))
+			}
+		}
+		for key, value := range want.Storage {
+			if have := statedb.GetState(addr, common.HexToHash(key)); have != common.HexToHash(value) {
+				return fmt.Errorf("account %x: storage %s mismatch: have %x, want %s", addr, key, have, value)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// BlockTest checks handling of entire blocks: a chain is built from the genesis
+// and the blocks of the test are imported into it.
+type BlockTest struct {
+	json btJSON
+}
+
+func (t *BlockTest) UnmarshalJSON(in []byte) error {
+	return json.Unmarshal(in, &t.json)
+}
+
+type btJSON struct {
+	Blocks     []btBlock                                       `json:"blocks"`
+	Genesis    btHeader                                        `json:"genesisBlockHeader"`
+	Pre        core.GenesisAlloc                               `json:"pre"`
+	Post       map[common.UnprefixedAddress]AccountExpectation `json:"postState"`
+	BestBlock  *common.UnprefixedHash                          `json:"lastblockhash"`
+	Network    string                                          `json:"network"`
+	SealEngine string                                          `json:"sealEngine"`
+}
+
+// btBlock is a block to import. Blocks without a header are expected to be
+// rejected by the chain.
+type btBlock struct {
+	BlockHeader *btHeader `json:"blockHeader"`
+	Rlp         string    `json:"rlp"`
+}
+
+// btHeader holds the header fields of the genesis block, and the ones checked
+// of the imported blocks.
+type btHeader struct {
+	Hash       *common.UnprefixedHash   `json:"hash"`
+	StateRoot  *common.UnprefixedHash   `json:"stateRoot"`
+	Coinbase   common.UnprefixedAddress `json:"coinbase"`
+	Difficulty *math.HexOrDecimal256    `json:"difficulty"`
+	ExtraData  hexutil.Bytes            `json:"extraData"`
+	GasLimit   math.HexOrDecimal64      `json:"gasLimit"`
+	GasUsed    math.HexOrDecimal64      `json:"gasUsed"`
+	MixHash    common.UnprefixedHash    `json:"mixHash"`
+	Nonce      math.HexOrDecimal64      `json:"nonce"`
+	Number     math.HexOrDecimal64      `json:"number"`
+	ParentHash common.UnprefixedHash    `json:"parentHash"`
+	Timestamp  math.HexOrDecimal64      `json:"timestamp"`
+}
+
+// Network returns the fork the test runs on.
+func (t *BlockTest) Network() string {
+	return t.json.Network
+}
+
+// Run imports the blocks of the test into a new chain and verifies the result.
+func (t *BlockTest) Run(vmconfig vm.Config) error {
+	config, ok := Forks[t.json.Network]
+	if !ok {
+		return UnsupportedForkError{t.json.Network}
+	}
+	// Import the genesis block and check it against the test
+	db, _ := ethdb.NewMemDatabase()
+	gblock, err := t.genesis(config).Commit(db)
+	if err != nil {
+		return err
+	}
+	if want := t.json.Genesis.Hash; want != nil && gblock.Hash() != common.Hash(*want) {
+		return fmt.Errorf("genesis block hash doesn't match test: computed=%x, test=%x", gblock.Hash(), *want)
+	}
+	if want := t.json.Genesis.StateRoot; want != nil && gblock.Root() != common.Hash(*want) {
+		return fmt.Errorf("genesis block state root does not match test: computed=%x, test=%x", gblock.Root(), *want)
+	}
+	engine := ethash.NewShared()
+	if t.json.SealEngine == "NoProof" {
+		engine = ethash.NewFaker()
+	}
+	chain, err := core.NewBlockChain(db, config, engine, new(event.TypeMux), vmconfig)
+	if err != nil {
+		return err
+	}
+	defer chain.Stop()
+
+	if err := t.insertBlocks(chain); err != nil {
+		return err
+	}
+	if want := t.json.BestBlock; want != nil {
+		if head := chain.CurrentBlock().Hash(); head != common.Hash(*want) {
+			return fmt.Errorf("last block hash mismatch: have %x, want %x", head, *want)
+		}
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		return err
+	}
+	return checkAccounts(statedb, t.json.Post)
+}
+
+func (t *BlockTest) genesis(config *params.ChainConfig) *core.Genesis {
+	return &core.Genesis{
+		Config:     config,
+		Nonce:      uint64(t.json.Genesis.Nonce),
+		Timestamp:  uint64(t.json.Genesis.Timestamp),
+		ParentHash: common.Hash(t.json.Genesis.ParentHash),
+		ExtraData:  string(t.json.Genesis.ExtraData),
+		GasLimit:   uint64(t.json.Genesis.GasLimit),
+		GasUsed:    uint64(t.json.Genesis.GasUsed),
+		Difficulty: (*big.Int)(t.json.Genesis.Difficulty),
+		Mixhash:    common.Hash(t.json.Genesis.MixHash),
+		Coinbase:   common.Address(t.json.Genesis.Coinbase),
+		Alloc:      t.json.Pre,
+	}
+}
+
+// insertBlocks imports the blocks of the test one by one, checking that the
+// valid ones are accepted as described and the invalid ones are rejected.
+func (t *BlockTest) insertBlocks(chain *core.BlockChain) error {
+	for i, b := range t.json.Blocks {
+		blob, err := hexutil.Decode(b.Rlp)
+		if err != nil {
+			if b.BlockHeader == nil {
+				continue // Invalid encoding was expected
+			}
+			return fmt.Errorf("block #%d: invalid RLP hex: %v", i, err)
+		}
+		block := new(types.Block)
+		if err := rlp.Decode(bytes.NewReader(blob), block); err != nil {
+			if b.BlockHeader == nil {
+				continue // Invalid encoding was expected
+			}
+			return fmt.Errorf("block #%d: RLP decoding failed: %v", i, err)
+		}
+		if _, err := chain.InsertChain(types.Blocks{block}); err != nil {
+			if b.BlockHeader == nil {
+				continue // Rejection was expected
+			}
+			return fmt.Errorf("block #%d: insertion failed: %v", i, err)
+		}
+		if b.BlockHeader == nil {
+			return fmt.Errorf("block #%d: insertion should have failed", i)
+		}
+		if want := b.BlockHeader.Hash; want != nil && block.Hash() != common.Hash(*want) {
+			return fmt.Errorf("block #%d: hash mismatch: have %x, want %x", i, block.Hash(), *want)
+		}
+	}
+	return nil
+}
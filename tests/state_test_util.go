@@ -0,0 +1,240 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tests
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// defaultCountryCode is the address prefix of the senders derived from the
+// secret keys of the tests, unless given explicitly.
+var defaultCountryCode = []int{0, 0, 0, 0, 156}
+
+// StateTest checks transaction processing without block context.
+type StateTest struct {
+	json stJSON
+}
+
+// StateSubtest selects a specific configuration of a state test.
+type StateSubtest struct {
+	Fork  string
+	Index int
+}
+
+func (t *StateTest) UnmarshalJSON(in []byte) error {
+	return json.Unmarshal(in, &t.json)
+}
+
+type stJSON struct {
+	Env  stEnv                    `json:"env"`
+	Pre  core.GenesisAlloc        `json:"pre"`
+	Tx   stTransaction            `json:"transaction"`
+	Post map[string][]stPostState `json:"post"`
+}
+
+// stPostState is the expected outcome of a subtest: the state root and logs
+// hash, as in the Ethereum tests, and/or the expected state of some accounts.
+type stPostState struct {
+	Root    *common.UnprefixedHash                          `json:"hash"`
+	Logs    *common.UnprefixedHash                          `json:"logs"`
+	State   map[common.UnprefixedAddress]AccountExpectation `json:"state"`
+	Indexes struct {
+		Data  int `json:"data"`
+		Gas   int `json:"gas"`
+		Value int `json:"value"`
+	} `json:"indexes"`
+}
+
+type stEnv struct {
+	Coinbase   common.UnprefixedAddress `json:"currentCoinbase"`
+	Difficulty *math.HexOrDecimal256    `json:"currentDifficulty"`
+	GasLimit   math.HexOrDecimal64      `json:"currentGasLimit"`
+	Number     math.HexOrDecimal64      `json:"currentNumber"`
+	Timestamp  math.HexOrDecimal64      `json:"currentTimestamp"`
+}
+
+// stTransaction is the transaction of a state test, with alternative data, gas
+// and value fields selected by the indexes of the post states. The sender is
+// derived from the secret key, unless given explicitly.
+type stTransaction struct {
+	GasPrice   *math.HexOrDecimal256     `json:"gasPrice"`
+	Nonce      math.HexOrDecimal64       `json:"nonce"`
+	To         string                    `json:"to"`
+	Data       []string                  `json:"data"`
+	GasLimit   []math.HexOrDecimal64     `json:"gasLimit"`
+	Value      []string                  `json:"value"`
+	PrivateKey hexutil.Bytes             `json:"secretKey"`
+	Sender     *common.UnprefixedAddress `json:"sender"`
+}
+
+// Subtests returns all valid subtests of the test, ordered by fork.
+func (t *StateTest) Subtests() []StateSubtest {
+	forks := make([]string, 0, len(t.json.Post))
+	for fork := range t.json.Post {
+		forks = append(forks, fork)
+	}
+	sort.Strings(forks)
+
+	var sub []StateSubtest
+	for _, fork := range forks {
+		for i := range t.json.Post[fork] {
+			sub = append(sub, StateSubtest{fork, i})
+		}
+	}
+	return sub
+}
+
+// Run executes a specific subtest, returning the post state even if the test
+// failed to verify it.
+func (t *StateTest) Run(subtest StateSubtest, vmconfig vm.Config) (*state.StateDB, error) {
+	config, ok := Forks[subtest.Fork]
+	if !ok {
+		return nil, UnsupportedForkError{subtest.Fork}
+	}
+	post := t.json.Post[subtest.Fork][subtest.Index]
+	msg, err := t.json.Tx.toMessage(post)
+	if err != nil {
+		return nil, err
+	}
+	block, statedb := t.genesis(config).ToBlock()
+
+	context := core.NewEVMContext(msg, block.Header(), nil, &block.Header().Coinbase)
+	context.GetHash = vmTestBlockHash
+	evm := vm.NewEVM(context, statedb, config, vmconfig)
+
+	// Invalid transactions leave no trace in the state
+	gaspool := new(core.GasPool).AddGas(block.GasLimit())
+	snapshot := statedb.Snapshot()
+	if _, _, _, err := core.ApplyMessage(evm, msg, gaspool); err != nil {
+		statedb.RevertToSnapshot(snapshot)
+	}
+	root := statedb.IntermediateRoot(config.IsEIP158(block.Number()))
+
+	if post.Logs != nil {
+		if logs := rlpHash(statedb.Logs()); logs != common.Hash(*post.Logs) {
+			return statedb, fmt.Errorf("post state logs hash mismatch: got %x, want %x", logs, *post.Logs)
+		}
+	}
+	if post.Root != nil && root != common.Hash(*post.Root) {
+		return statedb, fmt.Errorf("post state root mismatch: got %x, want %x", root, *post.Root)
+	}
+	if err := checkAccounts(statedb, post.State); err != nil {
+		return statedb, err
+	}
+	return statedb, nil
+}
+
+func (t *StateTest) genesis(config *params.ChainConfig) *core.Genesis {
+	return &core.Genesis{
+		Config:     config,
+		Coinbase:   common.Address(t.json.Env.Coinbase),
+		Difficulty: (*big.Int)(t.json.Env.Difficulty),
+		GasLimit:   uint64(t.json.Env.GasLimit),
+		Number:     uint64(t.json.Env.Number),
+		Timestamp:  uint64(t.json.Env.Timestamp),
+		Alloc:      t.json.Pre,
+	}
+}
+
+func (tx *stTransaction) toMessage(ps stPostState) (core.Message, error) {
+	// Derive sender from the private key if not given explicitly
+	var from common.Address
+	switch {
+	case tx.Sender != nil:
+		from = common.Address(*tx.Sender)
+	case len(tx.PrivateKey) > 0:
+		key, err := crypto.ToECDSA(tx.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %v", err)
+		}
+		from = crypto.PubkeyToAddress(key.PublicKey, defaultCountryCode)
+	default:
+		return nil, fmt.Errorf("transaction has neither sender nor secret key")
+	}
+	// Parse recipient if present
+	var to *common.Address
+	if tx.To != "" {
+		var addr common.UnprefixedAddress
+		if err := addr.UnmarshalText([]byte(tx.To)); err != nil {
+			return nil, fmt.Errorf("invalid to address: %v", err)
+		}
+		to = (*common.Address)(&addr)
+	}
+	// Get values specific to this post state
+	if ps.Indexes.Data >= len(tx.Data) {
+		return nil, fmt.Errorf("tx data index %d out of bounds", ps.Indexes.Data)
+	}
+	if ps.Indexes.Value >= len(tx.Value) {
+		return nil, fmt.Errorf("tx value index %d out of bounds", ps.Indexes.Value)
+	}
+	if ps.Indexes.Gas >= len(tx.GasLimit) {
+		return nil, fmt.Errorf("tx gas limit index %d out of bounds", ps.Indexes.Gas)
+	}
+	dataHex := tx.Data[ps.Indexes.Data]
+	valueHex := tx.Value[ps.Indexes.Value]
+	gasLimit := tx.GasLimit[ps.Indexes.Gas]
+
+	// Value, Data hex encoding is messy: https://github.com/ethereum/tests/issues/203
+	value := new(big.Int)
+	if valueHex != "0x" {
+		v, ok := math.ParseBig256(valueHex)
+		if !ok {
+			return nil, fmt.Errorf("invalid tx value %q", valueHex)
+		}
+		value = v
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(dataHex, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid tx data %q", dataHex)
+	}
+	gasPrice := new(big.Int)
+	if tx.GasPrice != nil {
+		gasPrice = (*big.Int)(tx.GasPrice)
+	}
+	msg := types.NewMessage(from, to, uint64(tx.Nonce), value, new(big.Int).SetUint64(uint64(gasLimit)), gasPrice, data, true)
+	return msg, nil
+}
+
+// vmTestBlockHash returns a deterministic fake hash of a block number, as the
+// tests have no chain to look up block hashes in.
+func vmTestBlockHash(n uint64) common.Hash {
+	return common.BytesToHash(crypto.Keccak256([]byte(big.NewInt(int64(n)).String())))
+}
+
+func rlpHash(x interface{}) (h common.Hash) {
+	hw := sha3.NewKeccak256()
+	rlp.Encode(hw, x)
+	hw.Sum(h[:0])
+	return h
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 var secureKeyPrefix = []byte("secure-key-")
@@ -161,6 +162,13 @@ func (t *SecureTrie) Copy() *SecureTrie {
 	return &cpy
 }
 
+// Prove constructs a merkle proof for key, which is hashed before the lookup
+// just like for all other accesses of the secure trie. See Trie.Prove for the
+// layout of the proof.
+func (t *SecureTrie) Prove(key []byte) []rlp.RawValue {
+	return t.trie.Prove(t.hashKey(key))
+}
+
 // NodeIterator returns an iterator that returns nodes of the underlying trie. Iteration
 // starts at the key after the given start key.
 func (t *SecureTrie) NodeIterator(start []byte) NodeIterator {
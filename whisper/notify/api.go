@@ -0,0 +1,66 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package notify
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// PaymentNotifier describes where and how the notifications of a node are
+// published, so clients can install the matching whisper filters.
+type PaymentNotifier struct {
+	PubKey hexutil.Bytes `json:"pubKey"` // Key signing the notifications
+	Topic  hexutil.Bytes `json:"topic"`  // Topic the notifications are posted on
+}
+
+// PublicNotifyAPI provides the RPC methods managing payment notifications.
+type PublicNotifyAPI struct {
+	s *Service
+}
+
+// NewPublicNotifyAPI creates a new payment notification API.
+func NewPublicNotifyAPI(s *Service) *PublicNotifyAPI {
+	return &PublicNotifyAPI{s}
+}
+
+// SubscribePayments requests notifications about payments to the given address
+// to be encrypted to pubKey and posted on the address's payment topic. The key
+// has to be signed by the address the way personal_sign does, proving that the
+// subscriber owns it. It returns the ID of the subscription.
+func (api *PublicNotifyAPI) SubscribePayments(address common.Address, pubKey hexutil.Bytes, sig hexutil.Bytes) (string, error) {
+	return api.s.subscribe(address, pubKey, sig)
+}
+
+// UnsubscribePayments cancels a payment subscription.
+func (api *PublicNotifyAPI) UnsubscribePayments(id string) (bool, error) {
+	if err := api.s.unsubscribe(id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PaymentNotifier returns the key signing the notifications about payments to
+// the given address, and the topic they are posted on.
+func (api *PublicNotifyAPI) PaymentNotifier(address common.Address) *PaymentNotifier {
+	topic := Topic(address)
+	return &PaymentNotifier{
+		PubKey: crypto.FromECDSAPub(&api.s.key.PublicKey),
+		Topic:  topic[:],
+	}
+}
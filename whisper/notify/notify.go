@@ -0,0 +1,482 @@
+// Copyright 2017 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package notify publishes whisper notifications about payments mined into the
+// chain to the parties that subscribed to the receiving addresses.
+//
+// Every notification is signed by the node's notifier key and encrypted to the
+// public key the subscriber registered, and is posted on the payment topic of the
+// receiving address. Clients that are offline when a payment is mined can fetch
+// the envelopes later from any whisper mail server archiving the topic.
+//
+// Only the value carried by successful transactions is notified. Transfers made
+// internally by contracts, such as calls with value or the bank transfer
+// precompile, leave no trace in the block besides the state change and aren't
+// covered.
+package notify
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	whisper "github.com/ethereum/go-ethereum/whisper/whisperv5"
+)
+
+const (
+	// keyFile is the name of the file in the data directory holding the private
+	// key used to sign the notifications.
+	keyFile = "notifykey"
+
+	// subscriptionsFile is the name of the file in the data directory holding the
+	// persisted payment subscriptions.
+	subscriptionsFile = "notify-subscriptions.json"
+
+	// postQueueSize is the number of notifications waiting to be sealed above
+	// which new ones are dropped instead of holding up the chain import.
+	postQueueSize = 1024
+
+	// postWorkers is the number of notifications sealed concurrently.
+	postWorkers = 4
+)
+
+// paymentTopicPrefix is mixed into the address when deriving its payment topic.
+var paymentTopicPrefix = []byte("ofbank-payment")
+
+var (
+	errNoSubscription = errors.New("subscription not found")
+	errInvalidKey     = errors.New("invalid public key")
+	errInvalidSig     = errors.New("invalid signature")
+	errNotOwner       = errors.New("public key not signed by the address")
+)
+
+// Config contains the parameters of the envelopes carrying notifications.
+type Config struct {
+	TTL      uint32  // Time-to-live of the notifications in seconds
+	PoW      float64 // Proof of work target of the notifications
+	WorkTime uint32  // Maximum time in seconds spent on sealing a notification
+}
+
+// DefaultConfig contains the default settings of the notification service.
+var DefaultConfig = Config{
+	TTL:      3600,
+	PoW:      whisper.DefaultMinimumPoW,
+	WorkTime: 5,
+}
+
+// Topic returns the whisper topic payment notifications to the given address
+// are posted on.
+func Topic(address common.Address) whisper.TopicType {
+	return whisper.BytesToTopic(crypto.Keccak256(paymentTopicPrefix, address[:]))
+}
+
+// Payment is the plaintext payload of a payment notification.
+type Payment struct {
+	To          common.Address `json:"to"`
+	From        common.Address `json:"from"`
+	Value       *hexutil.Big   `json:"value"`
+	TxHash      common.Hash    `json:"transactionHash"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	BlockHash   common.Hash    `json:"blockHash"`
+}
+
+// subscription is a request to be notified about payments to an address, with
+// the notifications encrypted to the given public key.
+type subscription struct {
+	ID      string         `json:"id"`
+	Address common.Address `json:"address"`
+	PubKey  hexutil.Bytes  `json:"pubKey"`
+
+	key *ecdsa.PublicKey
+}
+
+// notification is a sealed payment notification waiting to be posted.
+type notification struct {
+	sub     *subscription
+	payload []byte
+	tx      common.Hash
+}
+
+// Service watches the chain for mined payments and posts a notification for
+// each of them that is subscribed to.
+type Service struct {
+	config      *Config
+	chainConfig *params.ChainConfig
+	chainDb     ethdb.Database
+	mux         *event.TypeMux
+	shh         *whisper.Whisper
+	key         *ecdsa.PrivateKey
+	datafile    string // File the subscriptions are persisted in, empty if ephemeral
+
+	lock   sync.RWMutex
+	subs   map[string]*subscription
+	byAddr map[common.Address]map[string]*subscription
+
+	sub   *event.TypeMuxSubscription
+	posts chan *notification
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// New creates a payment notification service on top of the full node and the
+// whisper node already registered with the service context.
+func New(ctx *node.ServiceContext, config *Config) (*Service, error) {
+	var ethereum *eth.Ethereum
+	if err := ctx.Service(&ethereum); err != nil {
+		return nil, fmt.Errorf("payment notifications require a full node: %v", err)
+	}
+	var shh *whisper.Whisper
+	if err := ctx.Service(&shh); err != nil {
+		return nil, fmt.Errorf("payment notifications require whisper: %v", err)
+	}
+	key, err := loadKey(ctx.ResolvePath(keyFile))
+	if err != nil {
+		return nil, err
+	}
+	s := &Service{
+		config:      config,
+		chainConfig: ethereum.BlockChain().Config(),
+		chainDb:     ethereum.ChainDb(),
+		mux:         ethereum.EventMux(),
+		shh:         shh,
+		key:         key,
+		datafile:    ctx.ResolvePath(subscriptionsFile),
+		subs:        make(map[string]*subscription),
+		byAddr:      make(map[common.Address]map[string]*subscription),
+		posts:       make(chan *notification, postQueueSize),
+		quit:        make(chan struct{}),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// loadKey loads the notifier key from the given file, generating and storing a
+// new one if it doesn't exist yet. An empty path yields an ephemeral key.
+func loadKey(path string) (*ecdsa.PrivateKey, error) {
+	if path == "" {
+		return crypto.GenerateKey()
+	}
+	if key, err := crypto.LoadECDSA(path); err == nil {
+		return key, nil
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load notifier key: %v", err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := crypto.SaveECDSA(path, key); err != nil {
+		return nil, fmt.Errorf("failed to store notifier key: %v", err)
+	}
+	return key, nil
+}
+
+// Protocols implements node.Service, returning no p2p protocols as the
+// notifications are carried by whisper.
+func (s *Service) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints provided by the
+// notification service.
+func (s *Service) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "ofbank",
+			Version:   "1.0",
+			Service:   NewPublicNotifyAPI(s),
+			Public:    true,
+		},
+	}
+}
+
+// Start implements node.Service, starting to watch the chain for payments.
+func (s *Service) Start(server *p2p.Server) error {
+	s.sub = s.mux.Subscribe(core.ChainEvent{})
+
+	s.wg.Add(1 + postWorkers)
+	go s.loop()
+	for i := 0; i < postWorkers; i++ {
+		go s.worker()
+	}
+
+	log.Info("Started payment notifications", "notifier", common.ToHex(crypto.FromECDSAPub(&s.key.PublicKey)))
+	return nil
+}
+
+// Stop implements node.Service, terminating the notification loop.
+func (s *Service) Stop() error {
+	s.sub.Unsubscribe()
+	close(s.quit)
+	s.wg.Wait()
+
+	log.Info("Payment notifications stopped")
+	return nil
+}
+
+// loop posts the notifications for every block added to the canonical chain.
+func (s *Service) loop() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case ev, ok := <-s.sub.Chan():
+			if !ok {
+				return
+			}
+			if event, ok := ev.Data.(core.ChainEvent); ok {
+				s.notify(event.Block)
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// worker seals and posts the queued notifications. Sealing takes up to the
+// configured work time, so it's kept off the chain event loop.
+func (s *Service) worker() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case n := <-s.posts:
+			if err := s.post(n.sub, n.payload); err != nil {
+				log.Warn("Failed to post payment notification", "to", n.sub.Address, "tx", n.tx, "err", err)
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// notify queues a notification for every successful transfer in the block whose
+// recipient is subscribed to. Notifications are dropped if the queue is full.
+func (s *Service) notify(block *types.Block) {
+	signer := types.MakeSigner(s.chainConfig, block.Number())
+
+	// Failed transactions don't transfer their value, tell them by their receipts
+	receipts := core.GetBlockReceipts(s.chainDb, block.Hash(), block.NumberU64())
+	if len(receipts) != len(block.Transactions()) {
+		log.Warn("Missing receipts, skipping payment notifications", "number", block.Number(), "hash", block.Hash())
+		return
+	}
+	for i, tx := range block.Transactions() {
+		if tx.To() == nil || tx.Value().Sign() <= 0 || failed(receipts[i]) {
+			continue
+		}
+		subs := s.subscribers(*tx.To())
+		if len(subs) == 0 {
+			continue
+		}
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			log.Warn("Failed to derive payment sender", "tx", tx.Hash(), "err", err)
+			continue
+		}
+		payload, err := json.Marshal(&Payment{
+			To:          *tx.To(),
+			From:        from,
+			Value:       (*hexutil.Big)(tx.Value()),
+			TxHash:      tx.Hash(),
+			BlockNumber: hexutil.Uint64(block.NumberU64()),
+			BlockHash:   block.Hash(),
+		})
+		if err != nil {
+			log.Error("Failed to encode payment notification", "err", err)
+			continue
+		}
+		for _, sub := range subs {
+			select {
+			case s.posts <- &notification{sub: sub, payload: payload, tx: tx.Hash()}:
+			default:
+				log.Warn("Dropping payment notification, queue full", "to", sub.Address, "tx", tx.Hash())
+			}
+		}
+	}
+}
+
+// failed returns whether the receipt reports its transaction as failed. Receipts
+// before Metropolis carry the post state root instead and can't tell.
+func failed(receipt *types.Receipt) bool {
+	return len(receipt.PostState) == 0 && receipt.Status == types.ReceiptStatusFailed
+}
+
+// post seals a notification signed by the notifier and encrypted to the
+// subscriber, and injects it into the whisper network.
+func (s *Service) post(sub *subscription, payload []byte) error {
+	params := &whisper.MessageParams{
+		TTL:      s.config.TTL,
+		Src:      s.key,
+		Dst:      sub.key,
+		Topic:    Topic(sub.Address),
+		WorkTime: s.config.WorkTime,
+		PoW:      s.config.PoW,
+		Payload:  payload,
+	}
+	msg, err := whisper.NewSentMessage(params)
+	if err != nil {
+		return err
+	}
+	env, err := msg.Wrap(params)
+	if err != nil {
+		return err
+	}
+	return s.shh.Send(env)
+}
+
+// subscribers returns the subscriptions to payments to the given address.
+func (s *Service) subscribers(address common.Address) []*subscription {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	subs := make([]*subscription, 0, len(s.byAddr[address]))
+	for _, sub := range s.byAddr[address] {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// subscribe registers a public key to be notified about payments to an address.
+// The public key has to be signed by the address, so only its owner can learn
+// about the payments it receives.
+func (s *Service) subscribe(address common.Address, pubKey []byte, sig []byte) (string, error) {
+	key := crypto.ToECDSAPub(pubKey)
+	if !whisper.ValidatePublicKey(key) {
+		return "", errInvalidKey
+	}
+	if err := verifyOwner(address, pubKey, sig); err != nil {
+		return "", err
+	}
+	id, err := whisper.GenerateRandomID()
+	if err != nil {
+		return "", err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.add(&subscription{ID: id, Address: address, PubKey: common.CopyBytes(pubKey), key: key})
+	return id, s.store()
+}
+
+// verifyOwner checks that sig is a signature of the public key by the account
+// owning address, calculated the way personal_sign does:
+// keccak256("\x19Ethereum Signed Message:\n" + len(pubKey) + pubKey).
+//
+// Only the key hash can be recovered from the signature, the country code of the
+// address isn't checked.
+func verifyOwner(address common.Address, pubKey []byte, sig []byte) error {
+	if len(sig) != 65 || (sig[64] != 27 && sig[64] != 28) {
+		return errInvalidSig
+	}
+	rsig := common.CopyBytes(sig)
+	rsig[64] -= 27 // Transform yellow paper V from 27/28 to 0/1
+
+	msg := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(pubKey), pubKey)
+	rpk, err := crypto.Ecrecover(crypto.Keccak256([]byte(msg)), rsig)
+	if err != nil {
+		return errInvalidSig
+	}
+	if !bytes.Equal(address[common.AddressLength-20:], crypto.Keccak256(rpk[1:])[12:]) {
+		return errNotOwner
+	}
+	return nil
+}
+
+// unsubscribe removes a payment subscription.
+func (s *Service) unsubscribe(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sub, ok := s.subs[id]
+	if !ok {
+		return errNoSubscription
+	}
+	delete(s.subs, id)
+	delete(s.byAddr[sub.Address], id)
+	if len(s.byAddr[sub.Address]) == 0 {
+		delete(s.byAddr, sub.Address)
+	}
+	return s.store()
+}
+
+// add inserts a subscription into the indexes. The lock must be held.
+func (s *Service) add(sub *subscription) {
+	s.subs[sub.ID] = sub
+	if s.byAddr[sub.Address] == nil {
+		s.byAddr[sub.Address] = make(map[string]*subscription)
+	}
+	s.byAddr[sub.Address][sub.ID] = sub
+}
+
+// load restores the persisted subscriptions.
+func (s *Service) load() error {
+	if s.datafile == "" {
+		return nil
+	}
+	blob, err := ioutil.ReadFile(s.datafile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var subs []*subscription
+	if err := json.Unmarshal(blob, &subs); err != nil {
+		return fmt.Errorf("invalid subscriptions file %s: %v", s.datafile, err)
+	}
+	for _, sub := range subs {
+		if sub.key = crypto.ToECDSAPub(sub.PubKey); !whisper.ValidatePublicKey(sub.key) {
+			log.Warn("Dropping invalid payment subscription", "id", sub.ID, "address", sub.Address)
+			continue
+		}
+		s.add(sub)
+	}
+	return nil
+}
+
+// store persists the current subscriptions. The lock must be held.
+func (s *Service) store() error {
+	if s.datafile == "" {
+		return nil
+	}
+	subs := make([]*subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	blob, err := json.MarshalIndent(subs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.datafile, blob, 0600)
+}